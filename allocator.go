@@ -37,21 +37,28 @@ func init() {
 
 //export go_malloc
 func go_malloc(size C.size_t) unsafe.Pointer {
-	return malloc_fn.Load().(func(C.size_t) unsafe.Pointer)(size)
+	ptr := malloc_fn.Load().(func(C.size_t) unsafe.Pointer)(size)
+	trackAllocation(ptr, uint(size))
+	return ptr
 }
 
 //export go_calloc
 func go_calloc(num, size C.size_t) unsafe.Pointer {
-	return calloc_fn.Load().(func(C.size_t, C.size_t) unsafe.Pointer)(num, size)
+	ptr := calloc_fn.Load().(func(C.size_t, C.size_t) unsafe.Pointer)(num, size)
+	trackAllocation(ptr, uint(num*size))
+	return ptr
 }
 
 //export go_realloc
 func go_realloc(ptr unsafe.Pointer, size C.size_t) unsafe.Pointer {
-	return realloc_fn.Load().(func(unsafe.Pointer, C.size_t) unsafe.Pointer)(ptr, size)
+	return trackRealloc(ptr, uint(size), func() unsafe.Pointer {
+		return realloc_fn.Load().(func(unsafe.Pointer, C.size_t) unsafe.Pointer)(ptr, size)
+	})
 }
 
 //export go_free
 func go_free(ptr unsafe.Pointer) {
+	trackFree(ptr)
 	free_fn.Load().(func(unsafe.Pointer))(ptr)
 }
 
@@ -0,0 +1,57 @@
+package tree_sitter
+
+/*
+#cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// A flag that halts any parse using it once it is set, from any goroutine.
+//
+// The flag lives in C memory, so that parsers can read it directly while
+// parsing without calling back into Go. Assign it to parsers with
+// [Parser.SetCancelFlag], and free it with [CancelFlag.Close] once no parser
+// uses it anymore.
+type CancelFlag struct {
+	_inner *C.size_t
+}
+
+// Create a new cancellation flag, initially not set.
+func NewCancelFlag() *CancelFlag {
+	return &CancelFlag{_inner: (*C.size_t)(C.calloc(1, C.sizeof_size_t))}
+}
+
+func (f *CancelFlag) value() *uintptr {
+	if f._inner == nil {
+		panic("tree_sitter: use of closed CancelFlag")
+	}
+	return (*uintptr)(unsafe.Pointer(f._inner))
+}
+
+// Set the flag, halting every parse that uses it.
+func (f *CancelFlag) Cancel() {
+	atomic.StoreUintptr(f.value(), 1)
+}
+
+// Clear the flag, so that parsers using it can parse again.
+func (f *CancelFlag) Reset() {
+	atomic.StoreUintptr(f.value(), 0)
+}
+
+// Check whether the flag is set.
+func (f *CancelFlag) IsCancelled() bool {
+	return atomic.LoadUintptr(f.value()) != 0
+}
+
+// Free the flag's memory. It must not be assigned to any parser anymore.
+func (f *CancelFlag) Close() {
+	if f._inner != nil {
+		C.free(unsafe.Pointer(f._inner))
+		f._inner = nil
+	}
+}
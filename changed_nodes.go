@@ -0,0 +1,54 @@
+package tree_sitter
+
+import "iter"
+
+// Iterate over the outermost nodes beneath `root` that were changed by
+// [Tree.Edit], in document order.
+//
+// An edit marks every node that contains it as changed, all the way up to
+// the root, so the nodes yielded are the changed children of `root`. Their
+// descendants are not yielded; use [AllChangedNodes] to visit them as well.
+// Nodes that are not yielded were not affected by the edits, so anything
+// derived from them, such as a cache keyed by node, is still valid.
+func ChangedNodes(root Node) iter.Seq[Node] {
+	return changedNodes(root, false)
+}
+
+// Iterate over every node beneath `root` that was changed by [Tree.Edit],
+// in document order.
+//
+// The walk only descends into changed nodes, so unchanged subtrees are
+// skipped without being visited.
+func AllChangedNodes(root Node) iter.Seq[Node] {
+	return changedNodes(root, true)
+}
+
+func changedNodes(root Node, all bool) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		if !root.HasChanges() {
+			return
+		}
+		cursor := acquireCursor(root)
+		defer releaseCursor(cursor)
+		if !cursor.GotoFirstChild() {
+			return
+		}
+		for {
+			node := cursor.Node()
+			if node.HasChanges() {
+				if !yield(*node) {
+					return
+				}
+				if all && cursor.GotoFirstChild() {
+					continue
+				}
+			}
+			for !cursor.GotoNextSibling() {
+				// Back at the root, whose children have all been visited.
+				if !cursor.GotoParent() || cursor.Depth() == 0 {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package tree_sitter_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestChangedNodes(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+
+	var source strings.Builder
+	for i := range 10 {
+		fmt.Fprintf(&source, "fn f%d() -> i32 {\n    %d + 1\n}\n", i, i)
+	}
+	code := []byte(source.String())
+	tree := parser.Parse(code, nil)
+	defer tree.Close()
+	root := tree.RootNode()
+	assert.Empty(t, slices.Collect(ChangedNodes(*root)))
+
+	// Change the body of the fourth function.
+	position := uint(strings.Index(string(code), "3 + 1"))
+	_, err := performEdit(tree, &code, &testEdit{position: position, deletedLength: 1, insertedText: []byte("30")})
+	assert.NoError(t, err)
+	root = tree.RootNode()
+	function := root.NamedChild(3)
+
+	changed := slices.Collect(ChangedNodes(*root))
+	assert.Len(t, changed, 1)
+	assert.True(t, changed[0].Equals(*function))
+
+	all := slices.Collect(AllChangedNodes(*root))
+	assert.Greater(t, len(all), 1)
+	assert.True(t, all[0].Equals(*function))
+	var kinds []string
+	for _, node := range all {
+		assert.True(t, node.HasChanges())
+		assert.GreaterOrEqual(t, node.StartByte(), function.StartByte())
+		assert.LessOrEqual(t, node.EndByte(), function.EndByte())
+		kinds = append(kinds, node.Kind())
+	}
+	assert.Contains(t, kinds, "integer_literal")
+
+	// Iteration can stop early.
+	for node := range AllChangedNodes(*root) {
+		assert.True(t, node.Equals(*function))
+		break
+	}
+}
@@ -0,0 +1,50 @@
+package tree_sitter
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+var autoCleanupDisabled atomic.Bool
+
+// Enable automatic cleanup of [Parser], [Tree], [Query], [QueryCursor] and
+// [TreeCursor] objects that are created from now on. This is the default.
+//
+// With automatic cleanup, an object that becomes unreachable without being
+// closed has its C memory freed by the garbage collector. Closing objects
+// explicitly is still preferable, as the garbage collector does not know
+// how much C memory an object holds and may run long after it was dropped.
+//
+// A [Node] does not keep its tree reachable, so a tree must stay reachable
+// for as long as its nodes are used; see [Tree].
+func EnableAutoCleanup() {
+	autoCleanupDisabled.Store(false)
+}
+
+// Disable automatic cleanup for objects that are created from now on, which
+// must then always be closed explicitly. This makes freeing C memory
+// deterministic and avoids the cost of registering a finalizer for each
+// object.
+//
+// Objects that were created while automatic cleanup was enabled are still
+// cleaned up if they are not closed.
+func DisableAutoCleanup() {
+	autoCleanupDisabled.Store(true)
+}
+
+// Arrange for `close` to be called on `obj` once it becomes unreachable, if
+// automatic cleanup is enabled, and count it as live for [LiveObjects].
+func addCleanup[T any](obj *T, close func(*T)) *T {
+	trackObject(obj)
+	if !autoCleanupDisabled.Load() {
+		runtime.SetFinalizer(obj, close)
+	}
+	return obj
+}
+
+// Stop `obj` from being cleaned up automatically or counted as live, once it
+// has been closed.
+func removeCleanup[T any](obj *T) {
+	untrackObject(obj)
+	runtime.SetFinalizer(obj, nil)
+}
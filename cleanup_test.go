@@ -0,0 +1,40 @@
+package tree_sitter_test
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestAutoCleanupFreesUnclosedTrees(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	SetMemoryTracking(true)
+	defer SetMemoryTracking(false)
+
+	for i := range 1000 {
+		tree := parser.Parse([]byte(fmt.Sprintf("[%d, {\"a\": null}]", i)), nil)
+		assert.NotNil(t, tree)
+	}
+	leaked := ReadMemoryStats().LiveAllocations
+	assert.Greater(t, leaked, uint(1000))
+
+	// Closed objects are not freed a second time.
+	closed := parser.Parse([]byte("[]"), nil)
+	closed.Close()
+	cursor := NewQueryCursor()
+	cursor.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ReadMemoryStats().LiveAllocations > leaked/10 && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, ReadMemoryStats().LiveAllocations, leaked/10)
+}
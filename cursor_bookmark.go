@@ -0,0 +1,77 @@
+package tree_sitter
+
+/*
+#cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
+#include <tree_sitter/api.h>
+
+// Get the node that the cursor was created with or last reset to, which it
+// cannot walk outside of.
+static TSNode ts_go_tree_cursor_root(const TSTreeCursor *cursor) {
+  TSTreeCursor copy = ts_tree_cursor_copy(cursor);
+  while (ts_tree_cursor_goto_parent(&copy)) {}
+  TSNode root = ts_tree_cursor_current_node(&copy);
+  ts_tree_cursor_delete(&copy);
+  return root;
+}
+*/
+import "C"
+import "errors"
+
+// The error returned by [TreeCursor.ResumeAt] for a bookmark that was taken
+// on a cursor with a different root node, such as one of another tree.
+var ErrBookmarkOtherRoot = errors.New("bookmark was taken in a different tree or subtree")
+
+// The error returned by [TreeCursor.ResumeAt] for a bookmark whose tree has
+// been edited with [Tree.Edit] since the bookmark was taken.
+var ErrBookmarkTreeEdited = errors.New("tree was edited after the bookmark was taken")
+
+// A position of a [TreeCursor], from [TreeCursor.Bookmark], that a cursor
+// can later return to with [TreeCursor.ResumeAt].
+//
+// A bookmark is a small value that holds no memory of its own, so it can be
+// kept for as long as needed, unlike a copy of the cursor.
+type CursorBookmark struct {
+	root  Node
+	edit  uint64
+	index uint32
+}
+
+// Take a bookmark of the cursor's current position, which is its
+// [TreeCursor.DescendantIndex] below the node that the cursor was created
+// with. A cursor made from the zero [Node] gives the zero bookmark.
+func (tc *TreeCursor) Bookmark() CursorBookmark {
+	if tc.isNull() {
+		return CursorBookmark{}
+	}
+	return CursorBookmark{
+		root:  Node{_inner: C.ts_go_tree_cursor_root(&tc._inner)},
+		edit:  lastEdit((*C.TSTree)(tc._inner.tree)),
+		index: tc.DescendantIndex(),
+	}
+}
+
+// Move the cursor back to the position saved with [TreeCursor.Bookmark],
+// on this cursor or on another one with the same root node, using
+// [TreeCursor.GotoDescendant].
+//
+// This returns [ErrBookmarkOtherRoot] if the cursor's root is not the node
+// that the bookmark was taken below, and [ErrBookmarkTreeEdited] if the
+// tree has been edited since, as the bookmarked index could then refer to a
+// different node. The cursor is not moved in either case. A cursor made
+// from the zero [Node] can only resume at the zero bookmark.
+func (tc *TreeCursor) ResumeAt(bookmark CursorBookmark) error {
+	if tc.isNull() || bookmark.root.IsNull() {
+		if tc.isNull() && bookmark.root.IsNull() {
+			return nil
+		}
+		return ErrBookmarkOtherRoot
+	}
+	if !bookmark.root.Equals(Node{_inner: C.ts_go_tree_cursor_root(&tc._inner)}) {
+		return ErrBookmarkOtherRoot
+	}
+	if lastEdit((*C.TSTree)(tc._inner.tree)) != bookmark.edit {
+		return ErrBookmarkTreeEdited
+	}
+	tc.GotoDescendant(bookmark.index)
+	return nil
+}
@@ -0,0 +1,40 @@
+package tree_sitter
+
+/*
+#cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
+#include <tree_sitter/api.h>
+*/
+import "C"
+import (
+	"runtime"
+	"sync"
+)
+
+// Cursors for the library's own traversals, which reuse them rather than
+// allocating a new cursor for every walk.
+var cursorPool sync.Pool
+
+// Get a cursor on `node` from the pool, or create one if the pool is empty.
+// It must be given back with [releaseCursor] once the traversal is done, and
+// must not be used afterward.
+//
+// Pooled cursors are not counted by [LiveObjects]. A cursor that the pool
+// drops is always cleaned up by the garbage collector, since nothing else
+// could close it.
+func acquireCursor(node Node) *TreeCursor {
+	if cursor, ok := cursorPool.Get().(*TreeCursor); ok {
+		C.ts_tree_cursor_reset(&cursor._inner, node._inner)
+		return cursor
+	}
+	cursor := &TreeCursor{_inner: C.ts_tree_cursor_new(node._inner)}
+	runtime.SetFinalizer(cursor, func(cursor *TreeCursor) {
+		C.ts_tree_cursor_delete(&cursor._inner)
+	})
+	return cursor
+}
+
+// Return a cursor obtained from [acquireCursor] to the pool.
+func releaseCursor(cursor *TreeCursor) {
+	cursor.tree = nil
+	cursorPool.Put(cursor)
+}
@@ -0,0 +1,20 @@
+#include "decoder.h"
+
+extern uint32_t go_decode(void *decoder, uint8_t *string, uint32_t length, int32_t *code_point);
+
+// The decode function has no payload, so the Go decoder in use is tracked
+// per thread. Go callbacks from a cgo call always run on the calling thread.
+static _Thread_local void *current_decoder;
+
+static uint32_t c_decode_fn(const uint8_t *string, uint32_t length, int32_t *code_point) {
+  return go_decode(current_decoder, (uint8_t *)string, length, code_point);
+}
+
+TSTree *ts_go_parse_with_decoder(TSParser *self, const TSTree *old_tree, TSInput input, TSParseOptions options, void *decoder) {
+  void *previous_decoder = current_decoder;
+  current_decoder = decoder;
+  input.decode = c_decode_fn;
+  TSTree *tree = ts_parser_parse_with_options(self, old_tree, input, options);
+  current_decoder = previous_decoder;
+  return tree;
+}
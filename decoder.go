@@ -0,0 +1,125 @@
+package tree_sitter
+
+/*
+#cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
+#include <tree_sitter/api.h>
+#include "decoder.h"
+
+extern char *readCustomEncoding(void *payload, uint32_t byte_offset, TSPoint position, uint32_t *bytes_read);
+*/
+import "C"
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/mattn/go-pointer"
+)
+
+// A function that implements [Decoder].
+type DecodeFunc func(data []byte) (codePoint int32, bytesRead uint32)
+
+func (f DecodeFunc) Decode(data []byte) (codePoint int32, bytesRead uint32) {
+	return f(data)
+}
+
+var (
+	// Decodes ISO-8859-1 (Latin-1) text, where every byte is one code point.
+	Latin1Decoder = DecodeFunc(decodeLatin1)
+
+	// Decodes UTF16 little-endian text.
+	UTF16LEDecoder = DecodeFunc(func(data []byte) (int32, uint32) {
+		return decodeUTF16(data, func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 })
+	})
+
+	// Decodes UTF16 big-endian text.
+	UTF16BEDecoder = DecodeFunc(func(data []byte) (int32, uint32) {
+		return decodeUTF16(data, func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) })
+	})
+)
+
+func decodeLatin1(data []byte) (int32, uint32) {
+	return int32(data[0]), 1
+}
+
+func decodeUTF16(data []byte, unit func([]byte) uint16) (int32, uint32) {
+	if len(data) < 2 {
+		return -1, uint32(len(data))
+	}
+	first := rune(unit(data))
+	if !utf16.IsSurrogate(first) {
+		return first, 2
+	}
+	if len(data) < 4 {
+		return -1, uint32(len(data))
+	}
+	r := utf16.DecodeRune(first, rune(unit(data[2:])))
+	if r == utf8.RuneError {
+		return -1, 2
+	}
+	return r, 4
+}
+
+// This C function is passed to Tree-sitter as the decode function by the
+// shim in decoder.c.
+//
+//export go_decode
+func go_decode(decoder unsafe.Pointer, data *C.uint8_t, length C.uint32_t, codePoint *C.int32_t) C.uint32_t {
+	d := pointer.Restore(decoder).(Decoder)
+	r, n := d.Decode(unsafe.Slice((*byte)(data), int(length)))
+	if n == 0 || n > uint32(length) {
+		r, n = -1, 1
+	}
+	*codePoint = C.int32_t(r)
+	return C.uint32_t(n)
+}
+
+// Parse a slice of text in a custom encoding, using a [Decoder] written
+// in Go.
+//
+// # Arguments:
+//   - `text` The encoded text to parse.
+//   - `decoder` The decoder that turns the bytes of `text` into code points,
+//     such as [Latin1Decoder], or a [DecodeFunc].
+//   - `old_tree` A previous syntax tree parsed from the same document. If the text of the
+//     document has changed since `old_tree` was created, then you must edit `old_tree` to match
+//     the new text using [Tree.Edit].
+//
+// Byte offsets and [Point] columns in the resulting tree refer to the
+// encoded bytes of `text`, so a node's text can be sliced directly from it.
+// The decoder is called once per code point; if decoding speed matters more
+// than convenience, use [Parser.ParseCustomEncoding] with a C function.
+func (p *Parser) ParseWithDecoder(text []byte, decoder Decoder, oldTree *Tree) *Tree {
+	length := len(text)
+	payload := &payload[byte]{
+		callback: func(i int, _ Point) []byte {
+			if i < length {
+				return text[i:]
+			}
+			return []byte{}
+		},
+		text:     nil,
+		cStrings: make([]*C.char, 0),
+	}
+
+	defer func() {
+		for _, cString := range payload.cStrings {
+			go_free(unsafe.Pointer(cString))
+		}
+	}()
+
+	cptr := pointer.Save(payload)
+	defer pointer.Unref(cptr)
+
+	decoderPtr := pointer.Save(decoder)
+	defer pointer.Unref(decoderPtr)
+
+	cInput := C.TSInput{
+		payload:  unsafe.Pointer(cptr),
+		read:     (*[0]byte)(C.readCustomEncoding),
+		encoding: C.TSInputEncodingCustom,
+	}
+
+	return p.parseInput(cInput, oldTree, nil, decoderPtr)
+}
@@ -0,0 +1,3 @@
+#include <tree_sitter/api.h>
+
+TSTree *ts_go_parse_with_decoder(TSParser *self, const TSTree *old_tree, TSInput input, TSParseOptions options, void *decoder);
@@ -6,6 +6,19 @@ package tree_sitter
 */
 import "C"
 
+import (
+	"bytes"
+	"context"
+	"errors"
+	"unicode/utf8"
+)
+
+// A description of a change to a document, used by [Tree.Edit] and
+// [Node.Edit] to keep a syntax tree in sync with its source code so that it
+// can be passed to a parse as the old tree.
+//
+// Every offset is given both in bytes and as a [Point], measured from the
+// start of the document.
 type InputEdit struct {
 	StartByte      uint
 	OldEndByte     uint
@@ -25,3 +38,94 @@ func (i *InputEdit) toTSInputEdit() *C.TSInputEdit {
 		new_end_point: i.NewEndPosition.toTSPoint(),
 	}
 }
+
+// Compute the edits that turn `oldText` into `newText`, for use with
+// [Tree.Edit].
+//
+// This finds the longest common prefix and suffix of the two texts, so it
+// returns a single edit replacing everything between them, or no edits if
+// the texts are equal. The edit's boundaries never split a UTF8 character.
+func EditsForChange(oldText, newText []byte) []InputEdit {
+	maxCommon := min(len(oldText), len(newText))
+
+	prefix := 0
+	for prefix < maxCommon && oldText[prefix] == newText[prefix] {
+		prefix++
+	}
+	if prefix == len(oldText) && prefix == len(newText) {
+		return nil
+	}
+	for prefix > 0 && (!runeStartAt(oldText, prefix) || !runeStartAt(newText, prefix)) {
+		prefix--
+	}
+
+	suffix := 0
+	for suffix < maxCommon-prefix && oldText[len(oldText)-1-suffix] == newText[len(newText)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !utf8.RuneStart(oldText[len(oldText)-suffix]) {
+		suffix--
+	}
+
+	oldEnd := len(oldText) - suffix
+	newEnd := len(newText) - suffix
+	return []InputEdit{{
+		StartByte:      uint(prefix),
+		OldEndByte:     uint(oldEnd),
+		NewEndByte:     uint(newEnd),
+		StartPosition:  pointForOffset(oldText, prefix),
+		OldEndPosition: pointForOffset(oldText, oldEnd),
+		NewEndPosition: pointForOffset(newText, newEnd),
+	}}
+}
+
+// Check whether a UTF8 character starts at the given offset of the text,
+// counting its end as a character boundary.
+func runeStartAt(text []byte, offset int) bool {
+	return offset == len(text) || utf8.RuneStart(text[offset])
+}
+
+// Get the row and column of a byte offset in the text. Like every
+// [Point], the column is counted in bytes.
+func pointForOffset(text []byte, offset int) Point {
+	before := text[:offset]
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return Point{
+		Row:    uint(bytes.Count(before, []byte{'\n'})),
+		Column: uint(offset - lineStart),
+	}
+}
+
+// Apply edits to a tree and reparse the edited document, reusing the
+// unchanged parts of the old tree.
+//
+// This edits `old` with `edits`, parses `newSrc` with `p` using `old` as the
+// old tree, and closes `old` once the new tree has been created. If `edits`
+// is nil, they are computed from `oldSrc` and `newSrc` with
+// [EditsForChange]. If `old` is nil, `newSrc` is parsed from scratch.
+//
+// If parsing fails, the error is returned as by [Parser.ParseCtx] and `old`
+// is not closed, but it has already been edited, so it matches `newSrc`
+// rather than `oldSrc`.
+func EditAndReparse(p *Parser, old *Tree, oldSrc, newSrc []byte, edits []InputEdit) (*Tree, error) {
+	if old != nil {
+		if edits == nil {
+			edits = EditsForChange(oldSrc, newSrc)
+		}
+		for _, edit := range edits {
+			old.Edit(&edit)
+		}
+	}
+
+	tree, err := p.ParseCtx(context.Background(), newSrc, old)
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		return nil, errors.New("failed to parse the edited document")
+	}
+	if old != nil {
+		old.Close()
+	}
+	return tree, nil
+}
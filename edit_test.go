@@ -3,7 +3,9 @@ package tree_sitter_test
 import (
 	"fmt"
 	"math/rand"
+	"testing"
 
+	"github.com/stretchr/testify/assert"
 	. "github.com/tree-sitter/go-tree-sitter"
 )
 
@@ -154,3 +156,99 @@ func randWords(rand *rand.Rand, maxCount int) []byte {
 	}
 	return result
 }
+
+func TestEditsForChange(t *testing.T) {
+	// Insertion at the start
+	assert.Equal(t, []InputEdit{{
+		StartByte: 0, OldEndByte: 0, NewEndByte: 4,
+		StartPosition: Point{0, 0}, OldEndPosition: Point{0, 0}, NewEndPosition: Point{1, 0},
+	}}, EditsForChange([]byte("a = 1\n"), []byte("// \na = 1\n")))
+
+	// Deletion across a newline
+	assert.Equal(t, []InputEdit{{
+		StartByte: 4, OldEndByte: 10, NewEndByte: 4,
+		StartPosition: Point{0, 4}, OldEndPosition: Point{1, 4}, NewEndPosition: Point{0, 4},
+	}}, EditsForChange([]byte("a = 1\nb = 2\n"), []byte("a = 2\n")))
+
+	// Replacement in the middle
+	assert.Equal(t, []InputEdit{{
+		StartByte: 10, OldEndByte: 11, NewEndByte: 14,
+		StartPosition: Point{1, 4}, OldEndPosition: Point{1, 5}, NewEndPosition: Point{1, 8},
+	}}, EditsForChange([]byte("a = 1\nb = 2\nc = 3\n"), []byte("a = 1\nb = f(2)\nc = 3\n")))
+
+	// Replacing a multibyte character doesn't split the characters around it
+	assert.Equal(t, []InputEdit{{
+		StartByte: 1, OldEndByte: 3, NewEndByte: 3,
+		StartPosition: Point{0, 1}, OldEndPosition: Point{0, 3}, NewEndPosition: Point{0, 3},
+	}}, EditsForChange([]byte("\"é\""), []byte("\"è\"")))
+
+	// No-op change
+	assert.Empty(t, EditsForChange([]byte("a = 1\n"), []byte("a = 1\n")))
+}
+
+func TestTreeEditFromChange(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("python"))
+
+	oldText := []byte("def f():\n    return 1\n\ndef g():\n    return 2\n")
+	newText := []byte("def f():\n    x = 1\n    return x\n\ndef g():\n    return 2\n")
+	tree := parser.Parse(oldText, nil)
+	defer tree.Close()
+
+	tree.EditFromChange(oldText, newText)
+	newTree := parser.Parse(newText, tree)
+	defer newTree.Close()
+	freshTree := parser.Parse(newText, nil)
+	defer freshTree.Close()
+
+	assert.Equal(t, freshTree.RootNode().ToSexp(), newTree.RootNode().ToSexp())
+	assert.Equal(t, freshTree.RootNode().NamedChild(1).Range(), newTree.RootNode().NamedChild(1).Range())
+}
+
+func TestEditAndReparse(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+
+	// Without an old tree, the document is parsed from scratch.
+	oldSrc := []byte("fn a() { 1 }\nfn b() { 2 }\n")
+	tree, err := EditAndReparse(parser, nil, nil, oldSrc, nil)
+	assert.NoError(t, err)
+	functionB := tree.RootNode().NamedChild(1)
+	functionBId := functionB.Id()
+	functionBStart := functionB.StartByte()
+
+	// The edits are computed from the sources.
+	newSrc := []byte("fn a() { 10 + 1 }\nfn b() { 2 }\n")
+	newTree, err := EditAndReparse(parser, tree, oldSrc, newSrc, nil)
+	assert.NoError(t, err)
+	assert.Panics(t, func() { tree.RootNode() })
+
+	functionB = newTree.RootNode().NamedChild(1)
+	assert.Equal(t, functionBId, functionB.Id())
+	assert.Equal(t, functionBStart+5, functionB.StartByte())
+	assert.Equal(t, "fn b() { 2 }", functionB.Utf8Text(newSrc))
+
+	// The edits can also be given explicitly.
+	tree = newTree
+	oldSrc = newSrc
+	newSrc = []byte("fn a() { 3 }\nfn b() { 2 }\n")
+	edit := InputEdit{
+		StartByte:      9,
+		OldEndByte:     15,
+		NewEndByte:     10,
+		StartPosition:  NewPoint(0, 9),
+		OldEndPosition: NewPoint(0, 15),
+		NewEndPosition: NewPoint(0, 10),
+	}
+	newTree, err = EditAndReparse(parser, tree, oldSrc, newSrc, []InputEdit{edit})
+	assert.NoError(t, err)
+	defer newTree.Close()
+	assert.Panics(t, func() { tree.RootNode() })
+
+	functionB = newTree.RootNode().NamedChild(1)
+	assert.Equal(t, functionBId, functionB.Id())
+	assert.Equal(t, "fn b() { 2 }", functionB.Utf8Text(newSrc))
+	assert.Equal(t, "(source_file (function_item name: (identifier) parameters: (parameters) body: (block (integer_literal))) (function_item name: (identifier) parameters: (parameters) body: (block (integer_literal))))", newTree.RootNode().ToSexp())
+}
@@ -0,0 +1,219 @@
+package tree_sitter
+
+/*
+#cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
+#include <tree_sitter/api.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"sort"
+)
+
+// The number of levels of nested injections that [InjectionParser.Parse]
+// follows, which also stops languages that inject themselves from
+// recursing forever.
+const maxInjectionDepth = 4
+
+// A document in another language embedded in a host document, such as SQL
+// in a Go string or JavaScript in an HTML `<script>` element.
+type Injection struct {
+	// The language of the embedded document.
+	Language *Language
+
+	// The syntax tree of the embedded document. Its positions are relative to
+	// the host document, not to the start of the embedded text.
+	Tree *Tree
+
+	// The ranges of the host document that make up the embedded document.
+	// Combined injections have one range for every piece of content.
+	Ranges []Range
+}
+
+// An InjectionParser finds and parses the documents embedded in a syntax
+// tree, using an injections query that follows the conventions of
+// `injections.scm` files:
+//
+//   - `@injection.content` captures the nodes containing the embedded text.
+//   - `@injection.language` captures a node whose text is the name of the
+//     embedded language. The name can also be given with
+//     `(#set! injection.language "name")`.
+//   - `(#set! injection.self)` injects the host document's own language.
+//   - `(#set! injection.combined)` parses every match of the pattern with
+//     the same language as a single document, instead of one per match.
+//   - `(#set! injection.include-children)` keeps the text of the content
+//     nodes' children, which is excluded by default.
+type InjectionParser struct {
+	parser  *Parser
+	query   *Query
+	resolve func(name string) *Language
+	queries map[*C.TSLanguage]*Query
+}
+
+// Create a new injection parser that runs `query` on host trees and looks
+// up the languages it names with `resolve`. Injections of a language for
+// which `resolve` returns nil are skipped.
+func NewInjectionParser(query *Query, resolve func(name string) *Language) *InjectionParser {
+	return &InjectionParser{
+		parser:  NewParser(),
+		query:   query,
+		resolve: resolve,
+		queries: make(map[*C.TSLanguage]*Query),
+	}
+}
+
+// Delete the injection parser's own [Parser]. The queries it was given are
+// not closed.
+func (ip *InjectionParser) Close() {
+	ip.parser.Close()
+}
+
+// Set the injections query to run on trees of an injected language, so
+// that the documents embedded in those are parsed as well.
+func (ip *InjectionParser) SetInjectionQuery(language *Language, query *Query) {
+	ip.queries[language.Inner] = query
+}
+
+// Parse the documents embedded in the given host tree, along with the ones
+// nested inside of those.
+//
+// `source` is the text that `tree` was parsed from. The injections are
+// returned in the order they were found, with the ones nested in an
+// injection following it. Each injection's tree must be closed by the
+// caller.
+func (ip *InjectionParser) Parse(tree *Tree, source []byte) ([]Injection, error) {
+	var injections []Injection
+	if err := ip.parse(tree, ip.query, source, 1, &injections); err != nil {
+		for _, injection := range injections {
+			injection.Tree.Close()
+		}
+		return nil, err
+	}
+	return injections, nil
+}
+
+func (ip *InjectionParser) parse(tree *Tree, query *Query, source []byte, depth int, injections *[]Injection) error {
+	for _, injection := range findInjections(tree, query, source, ip.resolve) {
+		if err := ip.parser.SetLanguage(injection.Language); err != nil {
+			return err
+		}
+		if err := ip.parser.SetIncludedRanges(injection.Ranges); err != nil {
+			return err
+		}
+		injection.Tree = ip.parser.Parse(source, nil)
+		if injection.Tree == nil {
+			return errors.New("failed to parse injected document")
+		}
+		*injections = append(*injections, injection)
+
+		if nested := ip.queries[injection.Language.Inner]; nested != nil && depth < maxInjectionDepth {
+			if err := ip.parse(injection.Tree, nested, source, depth+1, injections); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run an injections query on a tree and group the content it captures
+// into documents, without parsing them.
+func findInjections(tree *Tree, query *Query, source []byte, resolve func(string) *Language) []Injection {
+	contentIndex, ok := query.CaptureIndexForName("injection.content")
+	if !ok {
+		return nil
+	}
+	languageIndex, hasLanguageCapture := query.CaptureIndexForName("injection.language")
+
+	type combinedKey struct {
+		pattern  uint
+		language *C.TSLanguage
+	}
+	var injections []Injection
+	combined := make(map[combinedKey]int)
+
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	treeCursor := acquireCursor(*tree.RootNode())
+	defer releaseCursor(treeCursor)
+
+	matches := cursor.Matches(query, tree.RootNode(), source)
+	for match := matches.Next(); match != nil; match = matches.Next() {
+		var name string
+		properties := match.Properties(query)
+		if property, ok := properties["injection.language"]; ok && property.Value != nil {
+			name = *property.Value
+		}
+		_, isSelf := properties["injection.self"]
+		_, isCombined := properties["injection.combined"]
+		_, includeChildren := properties["injection.include-children"]
+
+		var ranges []Range
+		for _, capture := range match.Captures {
+			switch {
+			case uint(capture.Index) == contentIndex:
+				ranges = append(ranges, contentRanges(&capture.Node, includeChildren, treeCursor)...)
+			case hasLanguageCapture && uint(capture.Index) == languageIndex:
+				name = capture.Node.Utf8Text(source)
+			}
+		}
+		if len(ranges) == 0 {
+			continue
+		}
+
+		var language *Language
+		if isSelf {
+			language = tree.Language()
+		} else if name != "" {
+			language = resolve(name)
+		}
+		if language == nil {
+			continue
+		}
+
+		if isCombined {
+			key := combinedKey{match.PatternIndex, language.Inner}
+			if i, ok := combined[key]; ok {
+				injections[i].Ranges = append(injections[i].Ranges, ranges...)
+				continue
+			}
+			combined[key] = len(injections)
+		}
+		injections = append(injections, Injection{Language: language, Ranges: ranges})
+	}
+
+	for _, injection := range injections {
+		sort.Slice(injection.Ranges, func(i, j int) bool {
+			return injection.Ranges[i].StartByte < injection.Ranges[j].StartByte
+		})
+	}
+	return injections
+}
+
+// Get the ranges of a content node's text, leaving out the text of its
+// children unless `includeChildren` is set.
+func contentRanges(node *Node, includeChildren bool, cursor *TreeCursor) []Range {
+	if includeChildren {
+		return []Range{node.Range()}
+	}
+
+	var ranges []Range
+	start := node.Range()
+	for _, child := range node.Children(cursor) {
+		childRange := child.Range()
+		if childRange.StartByte > start.StartByte {
+			ranges = append(ranges, Range{
+				StartByte:  start.StartByte,
+				StartPoint: start.StartPoint,
+				EndByte:    childRange.StartByte,
+				EndPoint:   childRange.StartPoint,
+			})
+		}
+		start.StartByte = childRange.EndByte
+		start.StartPoint = childRange.EndPoint
+	}
+	if start.EndByte > start.StartByte {
+		ranges = append(ranges, start)
+	}
+	return ranges
+}
@@ -0,0 +1,109 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestInjectionParser(t *testing.T) {
+	languages := map[string]*Language{
+		"go":         getLanguage("go"),
+		"html":       getLanguage("html"),
+		"javascript": getLanguage("javascript"),
+		"json":       getLanguage("json"),
+	}
+	resolve := func(name string) *Language { return languages[name] }
+
+	source := []byte("package main\n\n" +
+		"var a = json(`{\"x\": [1, 2]}`)\n" +
+		"var b = js(`let a = 1;`)\n" +
+		"var c = sql(`SELECT 1`)\n" +
+		"var d = js(`let b = 2;`)\n" +
+		"var e = html(`<p>hi</p><script>let c = 3;</script>`)\n")
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(languages["go"])
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	goInjections, err := NewQuery(languages["go"], `
+		(call_expression
+			function: (identifier) @injection.language
+			arguments: (argument_list (raw_string_literal (raw_string_literal_content) @injection.content))
+			(#not-eq? @injection.language "js"))
+
+		((call_expression
+			function: (identifier) @_function
+			arguments: (argument_list (raw_string_literal (raw_string_literal_content) @injection.content)))
+			(#eq? @_function "js")
+			(#set! injection.language "javascript")
+			(#set! injection.combined))
+	`)
+	assert.Nil(t, err)
+	defer goInjections.Close()
+
+	htmlInjections, err := NewQuery(languages["html"], `
+		((script_element (raw_text) @injection.content)
+			(#set! injection.language "javascript"))
+	`)
+	assert.Nil(t, err)
+	defer htmlInjections.Close()
+
+	injectionParser := NewInjectionParser(goInjections, resolve)
+	defer injectionParser.Close()
+	injectionParser.SetInjectionQuery(languages["html"], htmlInjections)
+
+	injections, parseErr := injectionParser.Parse(tree, source)
+	assert.NoError(t, parseErr)
+	defer func() {
+		for _, injection := range injections {
+			injection.Tree.Close()
+		}
+	}()
+
+	type result struct {
+		language *Language
+		sexp     string
+		texts    []string
+	}
+	var results []result
+	for _, injection := range injections {
+		var texts []string
+		for _, r := range injection.Ranges {
+			texts = append(texts, string(source[r.StartByte:r.EndByte]))
+		}
+		results = append(results, result{injection.Language, injection.Tree.RootNode().ToSexp(), texts})
+	}
+
+	// The SQL string is skipped because its language is unknown.
+	assert.Equal(t, []result{
+		{
+			languages["json"],
+			"(document (object (pair key: (string (string_content)) value: (array (number) (number)))))",
+			[]string{`{"x": [1, 2]}`},
+		},
+		{
+			languages["javascript"],
+			"(program (lexical_declaration (variable_declarator name: (identifier) value: (number))) (lexical_declaration (variable_declarator name: (identifier) value: (number))))",
+			[]string{"let a = 1;", "let b = 2;"},
+		},
+		{
+			languages["html"],
+			"(document (element (start_tag (tag_name)) (text) (end_tag (tag_name))) (script_element (start_tag (tag_name)) (raw_text) (end_tag (tag_name))))",
+			[]string{"<p>hi</p><script>let c = 3;</script>"},
+		},
+		{
+			languages["javascript"],
+			"(program (lexical_declaration (variable_declarator name: (identifier) value: (number))))",
+			[]string{"let c = 3;"},
+		},
+	}, results)
+
+	// Positions in injected trees are relative to the host document.
+	js := injections[3].Tree.RootNode()
+	assert.Equal(t, injections[3].Ranges[0].StartByte, js.StartByte())
+	assert.Equal(t, NewPoint(6, 31), js.StartPosition())
+}
@@ -0,0 +1,38 @@
+// Package fakelang builds languages that have nothing in them but an ABI
+// version and a name, for testing how the bindings handle languages that
+// they cannot use.
+package fakelang
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/../../src -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
+#include <stdlib.h>
+#include <string.h>
+#include "parser.h"
+
+static const TSLanguage *fake_language_new(uint32_t abi_version, const char *name) {
+  TSLanguage *language = calloc(1, sizeof(TSLanguage));
+  language->abi_version = abi_version;
+  language->name = strdup(name);
+  return language;
+}
+
+static void fake_language_delete(const TSLanguage *language) {
+  free((void *)language->name);
+  free((void *)language);
+}
+*/
+import "C"
+import "unsafe"
+
+// Allocate a zeroed TSLanguage with the given ABI version and name, which
+// must be freed with [Delete].
+func New(abiVersion uint32, name string) unsafe.Pointer {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	return unsafe.Pointer(C.fake_language_new(C.uint32_t(abiVersion), cName))
+}
+
+// Free a language allocated with [New].
+func Delete(language unsafe.Pointer) {
+	C.fake_language_delete((*C.TSLanguage)(language))
+}
@@ -0,0 +1,81 @@
+package tree_sitter
+
+import (
+	"iter"
+	"math"
+)
+
+// A set of node kinds of a [Language], for checking the kind of many nodes
+// quickly. Checking whether a set contains a node compares the node's
+// [Node.KindId] against a bitset, without converting its kind to a string.
+//
+// The zero KindSet is empty.
+type KindSet struct {
+	bits []uint64
+
+	// Whether the set contains the built-in `ERROR` kind, whose id is past
+	// the end of the bitset.
+	hasError bool
+}
+
+// Create a set of the given node kinds. A name is looked up as a named
+// kind first, then as an anonymous one, and names that the language does
+// not have are ignored.
+func NewKindSet(language *Language, kinds ...string) KindSet {
+	set := KindSet{bits: make([]uint64, (language.NodeKindCount()+63)/64)}
+	for _, kind := range kinds {
+		if id := language.IdForNodeKind(kind, true); id != 0 {
+			set.add(id)
+		} else if id := language.IdForNodeKind(kind, false); id != 0 {
+			set.add(id)
+		}
+	}
+	return set
+}
+
+func (s *KindSet) add(id uint16) {
+	if id == math.MaxUint16 {
+		s.hasError = true
+	} else if int(id/64) < len(s.bits) {
+		s.bits[id/64] |= 1 << (id % 64)
+	}
+}
+
+// Check whether the set contains the kind with the given id.
+func (s KindSet) ContainsId(id uint16) bool {
+	if id == math.MaxUint16 {
+		return s.hasError
+	}
+	return int(id/64) < len(s.bits) && s.bits[id/64]&(1<<(id%64)) != 0
+}
+
+// Check whether the set contains the node's kind. The node must come from
+// a tree of the set's language.
+func (s KindSet) Contains(node Node) bool {
+	return !node.IsNull() && s.ContainsId(node.KindId())
+}
+
+// Check whether the set is empty.
+func (s KindSet) IsEmpty() bool {
+	if s.hasError {
+		return false
+	}
+	for _, word := range s.bits {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Iterate over the nodes in the subtree rooted at `node` whose kinds are
+// in `kinds`, like [Walk].
+func WalkKinds(node Node, kinds KindSet) iter.Seq2[Node, int] {
+	return func(yield func(Node, int) bool) {
+		for node, depth := range Walk(node) {
+			if kinds.Contains(node) && !yield(node, depth) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestKindSet(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	language := getLanguage("go")
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(walkExample), nil)
+	defer tree.Close()
+
+	set := NewKindSet(language, "identifier", "field_identifier", "(", "no_such_kind")
+	assert.False(t, set.IsEmpty())
+	assert.True(t, set.ContainsId(language.IdForNodeKind("identifier", true)))
+	assert.True(t, set.ContainsId(language.IdForNodeKind("(", false)))
+	assert.False(t, set.ContainsId(language.IdForNodeKind("block", true)))
+	assert.False(t, set.ContainsId(65535))
+	assert.False(t, set.Contains(Node{}))
+
+	var fromSet, fromKinds []Node
+	for node := range WalkKinds(*tree.RootNode(), set) {
+		fromSet = append(fromSet, node)
+	}
+	for node := range Walk(*tree.RootNode()) {
+		switch node.Kind() {
+		case "identifier", "field_identifier", "(":
+			fromKinds = append(fromKinds, node)
+		}
+	}
+	assert.NotEmpty(t, fromSet)
+	assert.Equal(t, fromKinds, fromSet)
+
+	assert.True(t, KindSet{}.IsEmpty())
+	assert.False(t, KindSet{}.Contains(*tree.RootNode()))
+	assert.True(t, NewKindSet(language).IsEmpty())
+
+	// The built-in ERROR kind can be in a set too.
+	errorKinds := NewKindSet(language, "ERROR")
+	assert.False(t, errorKinds.IsEmpty())
+	assert.True(t, errorKinds.ContainsId(65535))
+	assert.False(t, errorKinds.ContainsId(language.IdForNodeKind("identifier", true)))
+}
+
+func allNodesOfLargeGoTree(b *testing.B) (*Parser, *Tree, []Node) {
+	parser, tree := largeGoTree(b)
+	var nodes []Node
+	for node := range Walk(*tree.RootNode()) {
+		nodes = append(nodes, node)
+	}
+	return parser, tree, nodes
+}
+
+func BenchmarkKindCheckWithString(b *testing.B) {
+	parser, tree, nodes := allNodesOfLargeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+
+	b.ResetTimer()
+	for range b.N {
+		count := 0
+		for _, node := range nodes {
+			if node.Kind() == "identifier" {
+				count++
+			}
+		}
+	}
+}
+
+func BenchmarkKindCheckWithKindSet(b *testing.B) {
+	parser, tree, nodes := allNodesOfLargeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+	identifiers := NewKindSet(tree.Language(), "identifier")
+
+	b.ResetTimer()
+	for range b.N {
+		count := 0
+		for _, node := range nodes {
+			if identifiers.Contains(node) {
+				count++
+			}
+		}
+	}
+}
@@ -25,6 +25,7 @@ type Language struct {
 // a [TSParser].
 type LanguageError struct {
 	version uint32
+	name    string
 }
 
 // The metadata associated with a language.
@@ -57,6 +58,18 @@ func (l *Language) AbiVersion() uint32 {
 	return uint32(C.ts_language_abi_version(l.Inner))
 }
 
+// Get the name of this language, as given in the grammar.
+//
+// This returns an empty string for languages generated with an ABI version
+// older than 15, which did not record their name.
+func (l *Language) Name() string {
+	name := C.ts_language_name(l.Inner)
+	if name == nil {
+		return ""
+	}
+	return C.GoString(name)
+}
+
 // Get the metadata for this language. This information is generated by the
 // CLI, and relies on the language author providing the correct metadata in
 // the language's `tree-sitter.json` file.
@@ -84,12 +97,15 @@ func (l *Language) ParseStateCount() uint32 {
 
 // Get the name of the node kind for the given numerical id.
 func (l *Language) NodeKindForId(id uint16) string {
-	return C.GoString(C.ts_language_symbol_name(l.Inner, C.TSSymbol(id)))
+	return namesOf(l.Inner).kind(id)
 }
 
-// Get the numeric id for the given node kind.
+// Get the numeric id for the given node kind, or zero if the language has
+// no such kind.
 func (l *Language) IdForNodeKind(kind string, named bool) uint16 {
-	return uint16(C.ts_language_symbol_for_name(l.Inner, C.CString(kind), C.uint32_t(len(kind)), C.bool(named)))
+	cKind := C.CString(kind)
+	defer go_free(unsafe.Pointer(cKind))
+	return uint16(C.ts_language_symbol_for_name(l.Inner, cKind, C.uint32_t(len(kind)), C.bool(named)))
 }
 
 // Check if the node type for the given numerical id is named (as opposed
@@ -116,12 +132,14 @@ func (l *Language) FieldCount() uint32 {
 
 // Get the field names for the given numerical id.
 func (l *Language) FieldNameForId(id uint16) string {
-	return C.GoString(C.ts_language_field_name_for_id(l.Inner, C.TSFieldId(id)))
+	return namesOf(l.Inner).field(id)
 }
 
 // Get the numerical id for the given field name.
 func (l *Language) FieldIdForName(name string) uint16 {
-	return uint16(C.ts_language_field_id_for_name(l.Inner, C.CString(name), C.uint32_t(len(name))))
+	cName := C.CString(name)
+	defer go_free(unsafe.Pointer(cName))
+	return uint16(C.ts_language_field_id_for_name(l.Inner, cName, C.uint32_t(len(name))))
 }
 
 // Get the next parse state. Combine this with
@@ -153,5 +171,9 @@ func (l *Language) LookaheadIterator(state uint16) *LookaheadIterator {
 }
 
 func (l *LanguageError) Error() string {
-	return fmt.Sprintf("Incompatible language version %d. Expected minimum %d, maximum %d", l.version, C.TREE_SITTER_MIN_COMPATIBLE_LANGUAGE_VERSION, C.TREE_SITTER_LANGUAGE_VERSION)
+	var language string
+	if l.name != "" {
+		language = fmt.Sprintf(" of language %q", l.name)
+	}
+	return fmt.Sprintf("Incompatible language version %d%s. Expected minimum %d, maximum %d", l.version, language, C.TREE_SITTER_MIN_COMPATIBLE_LANGUAGE_VERSION, C.TREE_SITTER_LANGUAGE_VERSION)
 }
@@ -0,0 +1,77 @@
+package tree_sitter
+
+/*
+#cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
+#include <tree_sitter/api.h>
+#include "names.h"
+*/
+import "C"
+
+import (
+	"math"
+	"sync"
+)
+
+// The node kind and field names of a language, converted to Go strings once
+// so that looking them up does not allocate.
+type languageNames struct {
+	language *C.TSLanguage
+	kinds    []string
+	fields   []string
+}
+
+// The names of every language that has been used so far, keyed by its
+// *C.TSLanguage. Languages are never unloaded, so they are kept for good.
+var languageNamesCache sync.Map
+
+func namesOf(language *C.TSLanguage) *languageNames {
+	if names, ok := languageNamesCache.Load(language); ok {
+		return names.(*languageNames)
+	}
+
+	names := &languageNames{
+		language: language,
+		kinds:    make([]string, C.ts_language_symbol_count(language)),
+		fields:   make([]string, C.ts_language_field_count(language)+1),
+	}
+	for id := range names.kinds {
+		names.kinds[id] = C.GoString(C.ts_language_symbol_name(language, C.TSSymbol(id)))
+	}
+	for id := 1; id < len(names.fields); id++ {
+		names.fields[id] = C.GoString(C.ts_language_field_name_for_id(language, C.TSFieldId(id)))
+	}
+	actual, _ := languageNamesCache.LoadOrStore(language, names)
+	return actual.(*languageNames)
+}
+
+func nameOfKind(name C.TSGoName) string {
+	if name.language == nil {
+		return ""
+	}
+	return namesOf(name.language).kind(uint16(name.id))
+}
+
+func nameOfField(name C.TSGoName) string {
+	if name.language == nil {
+		return ""
+	}
+	return namesOf(name.language).field(uint16(name.id))
+}
+
+func (names *languageNames) kind(id uint16) string {
+	switch {
+	case int(id) < len(names.kinds):
+		return names.kinds[id]
+	case id == math.MaxUint16:
+		return "ERROR"
+	default:
+		return C.GoString(C.ts_language_symbol_name(names.language, C.TSSymbol(id)))
+	}
+}
+
+func (names *languageNames) field(id uint16) string {
+	if int(id) < len(names.fields) {
+		return names.fields[id]
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestNodeKindAndFieldNames(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	language := getLanguage("go")
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte("package main\n\nfunc add(a int) int { return a + }\n"), nil)
+	defer tree.Close()
+
+	var kinds, fields []string
+	for node := range Walk(*tree.RootNode()) {
+		if !node.IsNamed() {
+			continue
+		}
+		kinds = append(kinds, node.Kind())
+		for i := range node.ChildCount() {
+			if field := node.FieldNameForChild(uint32(i)); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	assert.Equal(t, []string{
+		"source_file", "package_clause", "package_identifier",
+		"function_declaration", "identifier", "parameter_list",
+		"parameter_declaration", "identifier", "type_identifier",
+		"type_identifier", "block", "return_statement",
+		"expression_list", "identifier", "ERROR",
+	}, kinds)
+	assert.Equal(t, []string{
+		"name", "parameters", "result", "body", "name", "type",
+	}, fields)
+
+	// Looking the names up again in C gives back kinds and fields with the
+	// same names.
+	for id := range uint16(language.NodeKindCount()) {
+		kind := language.NodeKindForId(id)
+		if kind == "" {
+			continue
+		}
+		if other := language.IdForNodeKind(kind, language.NodeKindIsNamed(id)); other != 0 {
+			assert.Equal(t, kind, language.NodeKindForId(other))
+		}
+	}
+	for id := range uint16(language.FieldCount() + 1) {
+		if name := language.FieldNameForId(id); name != "" {
+			assert.Equal(t, id, language.FieldIdForName(name))
+		}
+	}
+	assert.Equal(t, "", language.FieldNameForId(0))
+	assert.Equal(t, "", language.FieldNameForId(uint16(language.FieldCount()+1)))
+	assert.Equal(t, "ERROR", language.NodeKindForId(65535))
+}
+
+func TestNodeKindDoesNotAllocate(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse([]byte("package main\n\nfunc add(a int) int { return a }\n"), nil)
+	defer tree.Close()
+	function := tree.RootNode().NamedChild(1)
+	cursor := function.Walk()
+	defer cursor.Close()
+	cursor.GotoFirstChild()
+	cursor.GotoNextSibling()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = function.Kind()
+		_ = function.GrammarName()
+		_ = function.FieldNameForChild(1)
+		_ = cursor.FieldName()
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkNodeKind(b *testing.B) {
+	parser, tree, nodes := allNodesOfLargeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+
+	b.ResetTimer()
+	for range b.N {
+		for _, node := range nodes {
+			_ = node.Kind()
+		}
+	}
+}
+
+func BenchmarkTreeCursorFieldName(b *testing.B) {
+	parser, tree := largeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+
+	visit := func(cursor *TreeCursor) {
+		for {
+			_ = cursor.FieldName()
+			if cursor.GotoFirstChild() {
+				continue
+			}
+			for !cursor.GotoNextSibling() {
+				if !cursor.GotoParent() {
+					return
+				}
+			}
+		}
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		cursor := tree.Walk()
+		visit(cursor)
+		cursor.Close()
+	}
+}
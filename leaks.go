@@ -0,0 +1,93 @@
+package tree_sitter
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	leakTracking atomic.Bool
+	nextLiveID   atomic.Uint64
+
+	liveMu      sync.Mutex
+	liveObjects map[uint64]string
+)
+
+// The leak tracking state of an object that [LiveObjects] counts, embedded
+// in each type that it counts. Objects are counted by an id of their own
+// rather than by their address, since the address of an object that was
+// dropped without being closed can be reused by a new object.
+type liveObject struct {
+	// The id that the object is counted by, or zero if it is not counted.
+	liveID uint64
+}
+
+func (l *liveObject) leakState() *liveObject {
+	return l
+}
+
+type leakTracked interface {
+	leakState() *liveObject
+}
+
+// Enable counting of the [Parser], [Tree], [Query], [QueryCursor] and
+// [TreeCursor] objects that are created from now on and not yet closed,
+// which are reported by [LiveObjects].
+func EnableLeakTracking() {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	if liveObjects == nil {
+		liveObjects = make(map[uint64]string)
+	}
+	leakTracking.Store(true)
+}
+
+// Disable leak tracking, forgetting the objects that have been counted.
+func DisableLeakTracking() {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	liveObjects = nil
+	leakTracking.Store(false)
+}
+
+// Get the number of objects of each type that were created while leak
+// tracking was enabled with [EnableLeakTracking] and have not been closed,
+// keyed by type name, e.g. "Tree". Types without live objects are omitted.
+//
+// An object that becomes unreachable without being closed stays counted
+// until automatic cleanup closes it, or for good if automatic cleanup was
+// disabled with [DisableAutoCleanup] when it was created.
+func LiveObjects() map[string]int {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	counts := make(map[string]int)
+	for _, kind := range liveObjects {
+		counts[kind]++
+	}
+	return counts
+}
+
+func trackObject[T any](obj *T) {
+	if !leakTracking.Load() {
+		return
+	}
+	state := any(obj).(leakTracked).leakState()
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	if liveObjects != nil {
+		state.liveID = nextLiveID.Add(1)
+		liveObjects[state.liveID] = reflect.TypeFor[T]().Name()
+	}
+}
+
+func untrackObject[T any](obj *T) {
+	state := any(obj).(leakTracked).leakState()
+	if state.liveID == 0 {
+		return
+	}
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	delete(liveObjects, state.liveID)
+	state.liveID = 0
+}
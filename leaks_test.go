@@ -0,0 +1,65 @@
+package tree_sitter_test
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestLiveObjects(t *testing.T) {
+	// Objects created before tracking is enabled are not counted.
+	untracked := NewQueryCursor()
+	EnableLeakTracking()
+	defer DisableLeakTracking()
+	untracked.Close()
+	assert.Empty(t, LiveObjects())
+
+	language := getLanguage("json")
+	var wg sync.WaitGroup
+	trees := make([]*Tree, 8)
+	for i := range trees {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parser := NewParser()
+			defer parser.Close()
+			parser.SetLanguage(language)
+			trees[i] = parser.Parse([]byte("[1, 2, 3]"), nil)
+			cursor := trees[i].Walk()
+			defer cursor.Close()
+			queryCursor := NewQueryCursor()
+			defer queryCursor.Close()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]int{"Tree": 8}, LiveObjects())
+
+	query, err := NewQuery(language, "(number) @number")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"Query": 1, "Tree": 8}, LiveObjects())
+	query.Close()
+	query.Close()
+
+	for _, tree := range trees {
+		tree.Close()
+	}
+	assert.Empty(t, LiveObjects())
+}
+
+func TestLiveObjectsCountsDroppedObjects(t *testing.T) {
+	EnableLeakTracking()
+	defer DisableLeakTracking()
+	DisableAutoCleanup()
+	defer EnableAutoCleanup()
+
+	// Cursors that are dropped without being closed stay counted, even once
+	// the garbage collector has given their addresses to new cursors.
+	for range 100 {
+		NewQueryCursor()
+		runtime.GC()
+	}
+	assert.Equal(t, map[string]int{"QueryCursor": 100}, LiveObjects())
+}
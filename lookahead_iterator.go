@@ -3,6 +3,7 @@ package tree_sitter
 /*
 #cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
 #include <tree_sitter/api.h>
+#include "names.h"
 */
 import "C"
 
@@ -33,7 +34,7 @@ func (l *LookaheadIterator) Symbol() uint16 {
 
 // Get the current symbol name of the lookahead iterator.
 func (l *LookaheadIterator) SymbolName() string {
-	return C.GoString(C.ts_lookahead_iterator_current_symbol_name(l._inner))
+	return nameOfKind(C.ts_go_lookahead_iterator_symbol(l._inner))
 }
 
 // Reset the lookahead iterator.
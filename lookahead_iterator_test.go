@@ -49,3 +49,21 @@ func TestLookaheadIterator(t *testing.T) {
 	}
 	assert.Equal(t, names, expectedSymbols)
 }
+
+func TestNodeLookaheadIteratorAfterKeyword(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	language := getLanguage("go")
+	parser.SetLanguage(language)
+
+	tree := parser.Parse([]byte("package main\n\nfunc main() {}\n"), nil)
+	defer tree.Close()
+	keyword := tree.RootNode().NamedChild(1).Child(0)
+	assert.Equal(t, "func", keyword.Kind())
+	assert.Equal(t, language.NextState(keyword.ParseState(), keyword.GrammarId()), keyword.NextParseState())
+
+	lookahead := keyword.LookaheadIterator()
+	assert.NotNil(t, lookahead)
+	defer lookahead.Close()
+	assert.Contains(t, lookahead.IterNames(), "identifier")
+}
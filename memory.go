@@ -0,0 +1,146 @@
+package tree_sitter
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrMemoryLimitExceeded is returned by [Parser.ParseCtx] when parsing was
+// halted because the memory used by the Tree-sitter runtime went past the
+// limit given to [SetMemoryLimit].
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// Statistics about the memory that the Tree-sitter runtime has allocated
+// while memory tracking was enabled.
+type MemoryStats struct {
+	// The number of bytes currently allocated.
+	CurrentBytes uint
+
+	// The largest number of bytes that were allocated at the same time.
+	PeakBytes uint
+
+	// The number of allocations that have not been freed yet.
+	LiveAllocations uint
+}
+
+var (
+	memoryTracking atomic.Bool
+	memoryLimit    atomic.Uint64
+
+	memoryMu    sync.Mutex
+	memorySizes map[uintptr]uint
+	memoryStats MemoryStats
+)
+
+// Enable or disable tracking of the memory allocated by the Tree-sitter
+// runtime, which is reported by [ReadMemoryStats].
+//
+// Tracking records the size of every allocation, so it adds some overhead
+// to parsing. Only allocations made while it is enabled are counted.
+// Disabling it clears the statistics.
+func SetMemoryTracking(enabled bool) {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	if enabled {
+		if memorySizes == nil {
+			memorySizes = make(map[uintptr]uint)
+		}
+	} else {
+		memorySizes = nil
+		memoryStats = MemoryStats{}
+	}
+	memoryTracking.Store(enabled)
+}
+
+// Get statistics about the memory allocated by the Tree-sitter runtime
+// since memory tracking was enabled with [SetMemoryTracking].
+func ReadMemoryStats() MemoryStats {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	return memoryStats
+}
+
+// Set the number of bytes that the Tree-sitter runtime may allocate before
+// parses are halted, or remove the limit by passing zero. Setting a limit
+// enables memory tracking.
+//
+// The runtime cannot recover from failed allocations, so allocations past
+// the limit still succeed. Instead, parsers check the limit periodically
+// while parsing and halt once it has been exceeded, returning `nil` from
+// [Parser.Parse], or [ErrMemoryLimitExceeded] from [Parser.ParseCtx].
+func SetMemoryLimit(bytes uint) {
+	if bytes > 0 {
+		SetMemoryTracking(true)
+	}
+	memoryLimit.Store(uint64(bytes))
+}
+
+// Check whether the memory limit is set and has been exceeded.
+func memoryLimitExceeded() bool {
+	limit := memoryLimit.Load()
+	return limit > 0 && uint64(ReadMemoryStats().CurrentBytes) > limit
+}
+
+func trackAllocation(ptr unsafe.Pointer, size uint) {
+	if ptr == nil || !memoryTracking.Load() {
+		return
+	}
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	recordAllocation(ptr, size)
+}
+
+func trackFree(ptr unsafe.Pointer) {
+	if ptr == nil || !memoryTracking.Load() {
+		return
+	}
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	recordFree(ptr)
+}
+
+// Call `realloc` and record that `ptr` was replaced by the block that it
+// returns. The lock is held for the whole call, since once `ptr` has been
+// freed, another thread could be given the same address and record it
+// before the free of `ptr` was recorded.
+func trackRealloc(ptr unsafe.Pointer, size uint, realloc func() unsafe.Pointer) unsafe.Pointer {
+	if !memoryTracking.Load() {
+		return realloc()
+	}
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	newPtr := realloc()
+	if newPtr != nil || size == 0 {
+		recordFree(ptr)
+		recordAllocation(newPtr, size)
+	}
+	return newPtr
+}
+
+// Record an allocation, with memoryMu held.
+func recordAllocation(ptr unsafe.Pointer, size uint) {
+	if ptr == nil || memorySizes == nil {
+		return
+	}
+	memorySizes[uintptr(ptr)] = size
+	memoryStats.CurrentBytes += size
+	memoryStats.LiveAllocations++
+	if memoryStats.CurrentBytes > memoryStats.PeakBytes {
+		memoryStats.PeakBytes = memoryStats.CurrentBytes
+	}
+}
+
+// Record a free, with memoryMu held.
+func recordFree(ptr unsafe.Pointer) {
+	if ptr == nil || memorySizes == nil {
+		return
+	}
+	// Allocations made before tracking was enabled are not known.
+	if size, ok := memorySizes[uintptr(ptr)]; ok {
+		delete(memorySizes, uintptr(ptr))
+		memoryStats.CurrentBytes -= size
+		memoryStats.LiveAllocations--
+	}
+}
@@ -0,0 +1,94 @@
+package tree_sitter_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestMemoryStatsReturnToZero(t *testing.T) {
+	SetMemoryTracking(true)
+	defer SetMemoryTracking(false)
+	assert.Equal(t, MemoryStats{}, ReadMemoryStats())
+
+	parser := NewParser()
+	parser.SetLanguage(getLanguage("rust"))
+
+	var trees []*Tree
+	for i := range 20 {
+		trees = append(trees, parser.Parse([]byte(fmt.Sprintf("fn f%d() { let x = %d; }", i, i)), nil))
+	}
+	stats := ReadMemoryStats()
+	assert.Greater(t, stats.CurrentBytes, uint(0))
+	assert.Greater(t, stats.LiveAllocations, uint(0))
+	assert.GreaterOrEqual(t, stats.PeakBytes, stats.CurrentBytes)
+
+	for _, tree := range trees {
+		tree.Close()
+	}
+	parser.Close()
+
+	stats = ReadMemoryStats()
+	assert.Equal(t, uint(0), stats.CurrentBytes)
+	assert.Equal(t, uint(0), stats.LiveAllocations)
+	assert.Greater(t, stats.PeakBytes, uint(0))
+}
+
+func TestMemoryStatsReturnToZeroWithConcurrentParsers(t *testing.T) {
+	SetMemoryTracking(true)
+	defer SetMemoryTracking(false)
+
+	// Reallocations on one thread and allocations on another can be given
+	// the same addresses, which must not unbalance the statistics.
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parser := NewParser()
+			defer parser.Close()
+			parser.SetLanguage(getLanguage("json"))
+			for j := range 20 {
+				source := fmt.Sprintf("[%d, %s0]", i, strings.Repeat("[1, {\"a\": 2}], ", j*10))
+				parser.Parse([]byte(source), nil).Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := ReadMemoryStats()
+	assert.Equal(t, uint(0), stats.CurrentBytes)
+	assert.Equal(t, uint(0), stats.LiveAllocations)
+}
+
+func TestMemoryLimitHaltsParsing(t *testing.T) {
+	SetMemoryLimit(64 * 1024)
+	defer SetMemoryTracking(false)
+	defer SetMemoryLimit(0)
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	source := []byte("[" + strings.Repeat("[0, 1], ", 100_000) + "0]")
+	assert.Nil(t, parser.Parse(source, nil))
+
+	tree, err := parser.ParseCtx(context.Background(), source, nil)
+	assert.Nil(t, tree)
+	assert.ErrorIs(t, err, ErrMemoryLimitExceeded)
+
+	// Parsing with a Go decoder is halted by the limit as well.
+	parser.Reset()
+	assert.Nil(t, parser.ParseWithDecoder(source, Latin1Decoder, nil))
+
+	// Small documents still fit within the limit.
+	parser.Reset()
+	tree = parser.Parse([]byte("[0, 1]"), nil)
+	assert.NotNil(t, tree)
+	defer tree.Close()
+}
@@ -0,0 +1,47 @@
+#include "names.h"
+
+TSGoName ts_go_node_kind(TSNode self, bool grammar) {
+  TSGoName result = {ts_node_language(self), 0};
+  result.id = grammar ? ts_node_grammar_symbol(self) : ts_node_symbol(self);
+  return result;
+}
+
+// Find the id of a field from its name, which must be one of the language's
+// own field name strings, so that the pointers can be compared.
+static TSFieldId field_id_for_name_string(const TSLanguage *language, const char *name) {
+  if (name == NULL) {
+    return 0;
+  }
+  uint32_t count = ts_language_field_count(language);
+  for (TSFieldId id = 1; id <= count; id++) {
+    if (ts_language_field_name_for_id(language, id) == name) {
+      return id;
+    }
+  }
+  return 0;
+}
+
+TSGoName ts_go_node_field_for_child(TSNode self, uint32_t index, bool named) {
+  TSGoName result = {ts_node_language(self), 0};
+  const char *name = named
+    ? ts_node_field_name_for_named_child(self, index)
+    : ts_node_field_name_for_child(self, index);
+  result.id = field_id_for_name_string(result.language, name);
+  return result;
+}
+
+TSGoName ts_go_tree_cursor_field(const TSTreeCursor *self) {
+  TSGoName result = {
+    ts_node_language(ts_tree_cursor_current_node(self)),
+    ts_tree_cursor_current_field_id(self),
+  };
+  return result;
+}
+
+TSGoName ts_go_lookahead_iterator_symbol(const TSLookaheadIterator *self) {
+  TSGoName result = {
+    ts_lookahead_iterator_language(self),
+    ts_lookahead_iterator_current_symbol(self),
+  };
+  return result;
+}
@@ -0,0 +1,13 @@
+#include <tree_sitter/api.h>
+
+// A symbol or field id along with the language that it belongs to, so that
+// its interned name can be looked up after a single call.
+typedef struct {
+  const TSLanguage *language;
+  uint16_t id;
+} TSGoName;
+
+TSGoName ts_go_node_kind(TSNode self, bool grammar);
+TSGoName ts_go_node_field_for_child(TSNode self, uint32_t index, bool named);
+TSGoName ts_go_tree_cursor_field(const TSTreeCursor *self);
+TSGoName ts_go_lookahead_iterator_symbol(const TSLookaheadIterator *self);
@@ -3,12 +3,61 @@ package tree_sitter
 /*
 #cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
 #include <tree_sitter/api.h>
+#include "names.h"
+
+typedef struct {
+	uint32_t count;
+	bool more;
+} TSGoSiblings;
+
+// Copy up to `length` nodes into `buffer`, starting with the one at the
+// cursor and moving on through its siblings, skipping anonymous nodes if
+// `named` is set. `more` is set if the cursor was left on a sibling that has
+// not been looked at yet.
+static TSGoSiblings ts_go_tree_cursor_collect_siblings(TSTreeCursor *cursor, TSNode *buffer, uint32_t length, bool named) {
+	TSGoSiblings result = {0, true};
+	while (result.count < length) {
+		TSNode node = ts_tree_cursor_current_node(cursor);
+		if (!named || ts_node_is_named(node)) {
+			buffer[result.count++] = node;
+		}
+		if (!ts_tree_cursor_goto_next_sibling(cursor)) {
+			result.more = false;
+			break;
+		}
+	}
+	return result;
+}
+
+// Copy up to `length` of the node's children into `buffer` with a single
+// call, skipping anonymous children if `named` is set.
+static uint32_t ts_go_node_children(TSNode self, TSNode *buffer, uint32_t length, bool named) {
+	uint32_t count = 0;
+	TSTreeCursor cursor = ts_tree_cursor_new(self);
+	if (ts_tree_cursor_goto_first_child(&cursor)) {
+		count = ts_go_tree_cursor_collect_siblings(&cursor, buffer, length, named).count;
+	}
+	ts_tree_cursor_delete(&cursor);
+	return count;
+}
 */
 import "C"
-import "unsafe"
+import (
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+	"unsafe"
+)
 
 // A single node within a syntax [Tree].
 // Note that this is a C-compatible struct
+//
+// The zero value, for which [Node.IsNull] reports true, refers to no node.
+// Its methods are safe to call: it has no kind, children or parent, and it
+// spans the empty range at the start of the source.
 type Node struct {
 	_inner C.TSNode
 }
@@ -26,43 +75,90 @@ func newNode(node C.TSNode) *Node {
 // a new tree is created based on an older tree, and a node from the old
 // tree is reused in the process, then that node will have the same id in
 // both trees.
+//
+// Comparing ids across an incremental reparse therefore tells whether a
+// subtree was reused. Nodes outside the edited ranges are usually reused,
+// but the parser does not guarantee it, so an id that changed does not mean
+// the node's contents did. Once a tree is closed, the ids of its nodes may
+// be given to nodes of other trees.
 func (n *Node) Id() uintptr {
 	return uintptr(n._inner.id)
 }
 
-// Get this node's type as a numerical id.
+// A comparable value that identifies a node within its tree, for use as a
+// map key. Two nodes have the same key exactly when [Node.Equals] reports
+// that they are equal.
+//
+// Unlike [Node.Id], the key includes the tree, so a node that was reused
+// by an incremental reparse has a different key in the new tree.
+type NodeKey struct {
+	tree uintptr
+	id   uintptr
+}
+
+// Get the key that identifies this node within its tree.
+func (n *Node) Key() NodeKey {
+	return NodeKey{tree: uintptr(unsafe.Pointer(n._inner.tree)), id: uintptr(n._inner.id)}
+}
+
+// Get this node's type as a numerical id. For a node that was renamed with
+// an alias in the grammar, this is the id of the alias; see
+// [Node.GrammarId] for the id of the rule itself.
+//
+// [Language.NodeKindForId] converts the id back to the node's kind.
 func (n *Node) KindId() uint16 {
+	if n.IsNull() {
+		return 0
+	}
 	return uint16(C.ts_node_symbol(n._inner))
 }
 
 // Get the node's type as a numerical id as it appears in the grammar
 // ignoring aliases.
 func (n *Node) GrammarId() uint16 {
+	if n.IsNull() {
+		return 0
+	}
 	return uint16(C.ts_node_grammar_symbol(n._inner))
 }
 
 // Get this node's type as a string.
 func (n *Node) Kind() string {
-	return C.GoString(C.ts_node_type(n._inner))
+	if n.IsNull() {
+		return ""
+	}
+	return nameOfKind(C.ts_go_node_kind(n._inner, false))
 }
 
 // Get this node's symbol name as it appears in the grammar ignoring
 // aliases as a string.
 func (n *Node) GrammarName() string {
-	return C.GoString(C.ts_node_grammar_type(n._inner))
+	if n.IsNull() {
+		return ""
+	}
+	return nameOfKind(C.ts_go_node_kind(n._inner, true))
 }
 
 // Get the [Language] that was used to parse this node's syntax tree.
 func (n *Node) Language() *Language {
+	if n.IsNull() {
+		return nil
+	}
 	return &Language{Inner: C.ts_node_language(n._inner)}
 }
 
+// Check if this is the zero [Node], which does not refer to any node in a
+// tree. The other predicates return false for it.
+func (n *Node) IsNull() bool {
+	return n._inner.id == nil
+}
+
 // Check if this node is *named*.
 //
 // Named nodes correspond to named rules in the grammar, whereas
 // *anonymous* nodes correspond to string literals in the grammar.
 func (n *Node) IsNamed() bool {
-	return bool(C.ts_node_is_named(n._inner))
+	return !n.IsNull() && bool(C.ts_node_is_named(n._inner))
 }
 
 // Check if this node is *extra*.
@@ -70,18 +166,18 @@ func (n *Node) IsNamed() bool {
 // Extra nodes represent things like comments, which are not required in the
 // grammar, but can appear anywhere.
 func (n *Node) IsExtra() bool {
-	return bool(C.ts_node_is_extra(n._inner))
+	return !n.IsNull() && bool(C.ts_node_is_extra(n._inner))
 }
 
 // Check if this node has been edited.
 func (n *Node) HasChanges() bool {
-	return bool(C.ts_node_has_changes(n._inner))
+	return !n.IsNull() && bool(C.ts_node_has_changes(n._inner))
 }
 
 // Check if this node represents a syntax error or contains any syntax
 // errors anywhere within it.
 func (n *Node) HasError() bool {
-	return bool(C.ts_node_has_error(n._inner))
+	return !n.IsNull() && bool(C.ts_node_has_error(n._inner))
 }
 
 // Check if this node represents a syntax error.
@@ -89,34 +185,61 @@ func (n *Node) HasError() bool {
 // Syntax errors represent parts of the code that could not be incorporated
 // into a valid syntax tree.
 func (n *Node) IsError() bool {
-	return bool(C.ts_node_is_error(n._inner))
+	return !n.IsNull() && bool(C.ts_node_is_error(n._inner))
 }
 
 // Get this node's parse state.
 func (n *Node) ParseState() uint16 {
+	if n.IsNull() {
+		return 0
+	}
 	return uint16(C.ts_node_parse_state(n._inner))
 }
 
-// Get the parse state after this node.
+// Get the parse state after this node, which is the state that
+// [Language.NextState] gives for this node's [Node.ParseState] and
+// [Node.GrammarId].
 func (n *Node) NextParseState() uint16 {
+	if n.IsNull() {
+		return 0
+	}
 	return uint16(C.ts_node_next_parse_state(n._inner))
 }
 
+// Create a lookahead iterator over the symbols that are valid after this
+// node, such as the tokens that could be typed next at a completion
+// position that follows it.
+//
+// This returns `nil` if the state after the node is not a valid parse
+// state. The iterator must be closed after use.
+func (n *Node) LookaheadIterator() *LookaheadIterator {
+	if n.IsNull() {
+		return nil
+	}
+	return n.Language().LookaheadIterator(n.NextParseState())
+}
+
 // Check if this node is *missing*.
 //
 // Missing nodes are inserted by the parser in order to recover from
 // certain kinds of syntax errors.
 func (n *Node) IsMissing() bool {
-	return bool(C.ts_node_is_missing(n._inner))
+	return !n.IsNull() && bool(C.ts_node_is_missing(n._inner))
 }
 
 // Get the byte offsets where this node starts.
 func (n *Node) StartByte() uint {
+	if n.IsNull() {
+		return 0
+	}
 	return uint(C.ts_node_start_byte(n._inner))
 }
 
 // Get the byte offsets where this node end.
 func (n *Node) EndByte() uint {
+	if n.IsNull() {
+		return 0
+	}
 	return uint(C.ts_node_end_byte(n._inner))
 }
 
@@ -136,8 +259,41 @@ func (n *Node) Range() Range {
 	}
 }
 
+// Check whether the byte at the given offset is part of this node, which
+// spans from [Node.StartByte] up to but not including [Node.EndByte]. A
+// node of zero width contains no bytes.
+func (n *Node) ContainsByte(offset uint) bool {
+	return n.StartByte() <= offset && offset < n.EndByte()
+}
+
+// Check whether the given point is within this node, which spans from
+// [Node.StartPosition] up to but not including [Node.EndPosition]. A node
+// of zero width contains no points.
+func (n *Node) ContainsPoint(point Point) bool {
+	return !point.less(n.StartPosition()) && point.less(n.EndPosition())
+}
+
+// Check whether `other` lies entirely within this node, comparing their
+// byte ranges. A node contains itself, and a node of zero width is
+// contained in any node that it lies within or at either end of.
+func (n *Node) ContainsNode(other Node) bool {
+	return n.StartByte() <= other.StartByte() && other.EndByte() <= n.EndByte()
+}
+
+// Check whether this node and the given range have at least one byte in
+// common. Since both end before their end byte, a node or range of zero
+// width overlaps nothing, and neither do a node and a range that only
+// touch.
+func (n *Node) RangeOverlaps(r Range) bool {
+	start, end := n.StartByte(), n.EndByte()
+	return start < end && r.StartByte < r.EndByte && start < r.EndByte && r.StartByte < end
+}
+
 // Get this node's start position in terms of rows and columns.
 func (n *Node) StartPosition() Point {
+	if n.IsNull() {
+		return Point{}
+	}
 	p := Point{}
 	p.fromTSPoint(C.ts_node_start_point(n._inner))
 	return p
@@ -145,23 +301,50 @@ func (n *Node) StartPosition() Point {
 
 // Get this node's end position in terms of rows and columns.
 func (n *Node) EndPosition() Point {
+	if n.IsNull() {
+		return Point{}
+	}
 	p := Point{}
 	p.fromTSPoint(C.ts_node_end_point(n._inner))
 	return p
 }
 
+// Get this node's start position in a tree parsed from UTF16 text with
+// [Parser.ParseUTF16LE] or [Parser.ParseUTF16BE], with a column that counts
+// UTF16 code units rather than bytes. A character outside the Basic
+// Multilingual Plane counts as two units.
+//
+// For a tree parsed from UTF8 text, use [Node.StartPointUTF16] instead.
+func (n *Node) Utf16StartPosition() Point {
+	p := n.StartPosition()
+	return Point{Row: p.Row, Column: p.Column / 2}
+}
+
+// Get this node's end position in a tree parsed from UTF16 text, with a
+// column that counts code units, like [Node.Utf16StartPosition].
+func (n *Node) Utf16EndPosition() Point {
+	p := n.EndPosition()
+	return Point{Row: p.Row, Column: p.Column / 2}
+}
+
 // Get the node's child at the given index, where zero represents the first
 // child.
 //
 // This method is fairly fast, but its cost is technically log(i), so if
 // you might be iterating over a long list of children, you should use
-// [Node.Children] instead.
+// [Node.ChildrenSeq] or [Node.Children] instead.
 func (n *Node) Child(i uint) *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_child(n._inner, C.uint(i)))
 }
 
 // Get this node's number of children.
 func (n *Node) ChildCount() uint {
+	if n.IsNull() {
+		return 0
+	}
 	return uint(C.ts_node_child_count(n._inner))
 }
 
@@ -170,8 +353,11 @@ func (n *Node) ChildCount() uint {
 // See also [Node.IsNamed].
 // This method is fairly fast, but its cost is technically log(i), so if
 // you might be iterating over a long list of children, you should use
-// [Node.NamedChildren] instead.
+// [Node.NamedChildrenSeq] or [Node.NamedChildren] instead.
 func (n *Node) NamedChild(i uint) *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_named_child(n._inner, C.uint(i)))
 }
 
@@ -179,43 +365,58 @@ func (n *Node) NamedChild(i uint) *Node {
 //
 // See also [Node.IsNamed].
 func (n *Node) NamedChildCount() uint {
+	if n.IsNull() {
+		return 0
+	}
 	return uint(C.ts_node_named_child_count(n._inner))
 }
 
-// Get the first child with the given field name.
+// Get the first child with the given field name, or `nil` if there is no
+// such child. A field whose node is present but zero-width, like a
+// missing node, still returns that node.
 //
 // If multiple children may have the same field name, access them using
-// [Node.ChildrenByFieldName]
+// [Node.ChildrenByFieldName] or [Node.ChildrenByFieldNameSeq].
 func (n *Node) ChildByFieldName(fieldName string) *Node {
+	if n.IsNull() {
+		return nil
+	}
 	cFieldName := C.CString(fieldName)
 	defer go_free(unsafe.Pointer(cFieldName))
 	return newNode(C.ts_node_child_by_field_name(n._inner, cFieldName, C.uint32_t(len(fieldName))))
 }
 
-// Get this node's child with the given numerical field id.
+// Get this node's child with the given numerical field id, or `nil` if
+// there is no such child.
 //
 // See also [Node.ChildByFieldName]. You can
 // convert a field name to an id using [Language.FieldIdForName].
 func (n *Node) ChildByFieldId(fieldId uint16) *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_child_by_field_id(n._inner, C.uint16_t(fieldId)))
 }
 
-// Get the field name of this node's child at the given index.
+// Get the field name of this node's child at the given index, or an empty
+// string if the child has no field name or there is no such child.
+//
+// Together with [Node.Child], this lets a generic traversal report which
+// field each child occupies.
 func (n *Node) FieldNameForChild(childIndex uint32) string {
-	ptr := C.ts_node_field_name_for_child(n._inner, C.uint32_t(childIndex))
-	if ptr == nil {
+	if n.IsNull() {
 		return ""
 	}
-	return C.GoString(ptr)
+	return nameOfField(C.ts_go_node_field_for_child(n._inner, C.uint32_t(childIndex), false))
 }
 
-// Get the field name of this node's named child at the given index.
+// Get the field name of this node's named child at the given index, or an
+// empty string if the child has no field name or there is no such child.
 func (n *Node) FieldNameForNamedChild(namedChildIndex uint32) string {
-	ptr := C.ts_node_field_name_for_named_child(n._inner, C.uint32_t(namedChildIndex))
-	if ptr == nil {
+	if n.IsNull() {
 		return ""
 	}
-	return C.GoString(ptr)
+	return nameOfField(C.ts_go_node_field_for_child(n._inner, C.uint32_t(namedChildIndex), true))
 }
 
 // Iterate over this node's children.
@@ -228,6 +429,9 @@ func (n *Node) FieldNameForNamedChild(namedChildIndex uint32) string {
 // If you're walking the tree recursively, you may want to use the
 // [TreeCursor] APIs directly instead.
 func (n *Node) Children(cursor *TreeCursor) []Node {
+	if n.IsNull() {
+		return []Node{}
+	}
 	cursor.Reset(*n)
 	cursor.GotoFirstChild()
 	childCount := n.ChildCount()
@@ -243,6 +447,9 @@ func (n *Node) Children(cursor *TreeCursor) []Node {
 //
 // See also [Node.Children].
 func (n *Node) NamedChildren(cursor *TreeCursor) []Node {
+	if n.IsNull() {
+		return []Node{}
+	}
 	cursor.Reset(*n)
 	cursor.GotoFirstChild()
 	namedChildCount := n.NamedChildCount()
@@ -259,10 +466,117 @@ func (n *Node) NamedChildren(cursor *TreeCursor) []Node {
 	return result
 }
 
+// The number of children that the iterators over a node's children fetch
+// at a time.
+const childrenBatchSize = 64
+
+// Buffers for the children that the iterators fetch at a time, which are
+// reused like the iterators' cursors.
+var childrenBatches = sync.Pool{
+	New: func() any { return new([childrenBatchSize]Node) },
+}
+
+// Get an iterator over this node's children, for use with a `for` loop
+// and `range`.
+//
+// The children are fetched in batches, like with [Node.ChildrenInto], so
+// iterating over a wide node takes far fewer calls into the Tree-sitter
+// library than looking up each child with [Node.Child].
+func (n *Node) ChildrenSeq() iter.Seq[Node] {
+	return n.childrenSeq(false)
+}
+
+// Get an iterator over this node's named children.
+//
+// See also [Node.ChildrenSeq].
+func (n *Node) NamedChildrenSeq() iter.Seq[Node] {
+	return n.childrenSeq(true)
+}
+
+func (n *Node) childrenSeq(named bool) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		if n.IsNull() {
+			return
+		}
+		cursor := acquireCursor(*n)
+		defer releaseCursor(cursor)
+		if !cursor.GotoFirstChild() {
+			return
+		}
+
+		batch := childrenBatches.Get().(*[childrenBatchSize]Node)
+		defer childrenBatches.Put(batch)
+		buffer := batch[:]
+		for more := true; more; {
+			var count int
+			count, more = collectSiblings(&cursor._inner, buffer, named)
+			for _, child := range buffer[:count] {
+				if !yield(child) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Fill `buffer` with the node at the cursor and its next siblings,
+// reporting whether any siblings are left.
+func collectSiblings(cursor *C.TSTreeCursor, buffer []Node, named bool) (int, bool) {
+	result := C.ts_go_tree_cursor_collect_siblings(
+		cursor,
+		(*C.TSNode)(unsafe.Pointer(&buffer[0])),
+		C.uint32_t(len(buffer)),
+		C.bool(named),
+	)
+	return int(result.count), bool(result.more)
+}
+
+// Get this node's children as a slice, without having to provide a
+// [TreeCursor] as with [Node.Children].
+func (n *Node) ChildrenSlice() []Node {
+	result := make([]Node, n.ChildCount())
+	return result[:n.ChildrenInto(result)]
+}
+
+// Copy this node's first children into `buffer`, returning the number of
+// children that were copied, which is at most `len(buffer)`.
+//
+// The children are fetched with a single call into the Tree-sitter
+// library, which makes this the cheapest way to get the children of a wide
+// node. A buffer of [Node.ChildCount] nodes is large enough for all of
+// them.
+func (n *Node) ChildrenInto(buffer []Node) int {
+	return n.childrenInto(buffer, false)
+}
+
+// Copy this node's first named children into `buffer`, like
+// [Node.ChildrenInto]. A buffer of [Node.NamedChildCount] nodes is large
+// enough for all of them.
+func (n *Node) NamedChildrenInto(buffer []Node) int {
+	return n.childrenInto(buffer, true)
+}
+
+func (n *Node) childrenInto(buffer []Node, named bool) int {
+	if len(buffer) == 0 || n.IsNull() {
+		return 0
+	}
+	// A Node has the same layout as a TSNode, so the buffer can be filled
+	// in place.
+	return int(C.ts_go_node_children(
+		n._inner,
+		(*C.TSNode)(unsafe.Pointer(&buffer[0])),
+		C.uint32_t(len(buffer)),
+		C.bool(named),
+	))
+}
+
 // Iterate over this node's children with a given field name.
 //
 // See also [Node.Children].
 func (n *Node) ChildrenByFieldName(fieldName string, cursor *TreeCursor) []Node {
+	if n.IsNull() {
+		return []Node{}
+	}
 	fieldId := n.Language().FieldIdForName(fieldName)
 	done := fieldId == 0
 	if !done {
@@ -284,94 +598,481 @@ func (n *Node) ChildrenByFieldName(fieldName string, cursor *TreeCursor) []Node
 	return result
 }
 
-// Get this node's immediate parent.
-// Prefer [Node.ChildWithDescendant]
-// for iterating over this node's ancestors.
+// Get an iterator over this node's children with a given field name. A
+// field can be given to more than one child, for example to each of the
+// `alternative` branches of an `if` statement.
+//
+// See also [Node.ChildrenSeq].
+func (n *Node) ChildrenByFieldNameSeq(fieldName string) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		if n.IsNull() {
+			return
+		}
+		fieldId := n.Language().FieldIdForName(fieldName)
+		if fieldId == 0 {
+			return
+		}
+		cursor := acquireCursor(*n)
+		defer releaseCursor(cursor)
+		if !cursor.GotoFirstChild() {
+			return
+		}
+		for {
+			if cursor.FieldId() == fieldId && !yield(*cursor.Node()) {
+				return
+			}
+			if !cursor.GotoNextSibling() {
+				return
+			}
+		}
+	}
+}
+
+// Get this node's immediate parent, or `nil` for the root node.
+// Prefer [Node.Ancestors] for iterating over this node's ancestors.
 func (n *Node) Parent() *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_parent(n._inner))
 }
 
-// Get the node that contains `descendant`.
+// Get an iterator over this node's ancestors, from its parent up to the
+// root of the tree.
+//
+// The path from the root is found once, when iteration starts, rather than
+// by calling [Node.Parent] repeatedly, which would search from the root
+// again for every ancestor.
+func (n *Node) Ancestors() iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		if n.IsNull() {
+			return
+		}
+		var path []Node
+		root := newNode(C.ts_tree_root_node(n._inner.tree))
+		for ancestor := root; ancestor != nil && !ancestor.Equals(*n); ancestor = ancestor.ChildWithDescendant(n) {
+			path = append(path, *ancestor)
+		}
+		for i := len(path) - 1; i >= 0; i-- {
+			if !yield(path[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Get the closest of this node's ancestors that has one of the given kinds,
+// or `nil` if there is none. The node itself is not considered.
+//
+// The kinds are converted to a [KindSet] once, so that each ancestor is
+// checked without comparing strings.
+func (n *Node) ClosestAncestor(kinds ...string) *Node {
+	if n.IsNull() {
+		return nil
+	}
+	set := NewKindSet(n.Language(), kinds...)
+	if set.IsEmpty() {
+		return nil
+	}
+	for ancestor := range n.Ancestors() {
+		if set.Contains(ancestor) {
+			return &ancestor
+		}
+	}
+	return nil
+}
+
+// Get the direct child of this node that contains `descendant`, or `nil`
+// if `descendant` is not within this node.
 // Note that this can return `descendant` itself.
 func (n *Node) ChildWithDescendant(descendant *Node) *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_child_with_descendant(n._inner, descendant._inner))
 }
 
-// Get this node's next sibling.
+// Get this node's next sibling, or `nil` for its parent's last child.
 func (n *Node) NextSibling() *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_next_sibling(n._inner))
 }
 
-// Get this node's previous sibling.
+// Get this node's previous sibling, or `nil` for its parent's first child.
 func (n *Node) PrevSibling() *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_prev_sibling(n._inner))
 }
 
-// Get this node's next named sibling.
+// Get this node's next named sibling, skipping anonymous nodes such as
+// punctuation, or `nil` if there is none.
 func (n *Node) NextNamedSibling() *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_next_named_sibling(n._inner))
 }
 
-// Get this node's previous named sibling.
+// Get this node's previous named sibling, skipping anonymous nodes such as
+// punctuation, or `nil` if there is none.
 func (n *Node) PrevNamedSibling() *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_prev_named_sibling(n._inner))
 }
 
-// Get the node's first child that contains or starts after the given byte offset.
+// Get the node's first child that contains or starts after the given byte
+// offset, or `nil` if every child ends at or before it.
 func (n *Node) FirstChildForByte(byteOffset uint) *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_first_child_for_byte(n._inner, C.uint(byteOffset)))
 }
 
-// Get the node's first named child that contains or starts after the given byte offset.
+// Get the node's first named child that contains or starts after the given
+// byte offset, or `nil` if there is none.
 func (n *Node) FirstNamedChildForByte(byteOffset uint) *Node {
+	if n.IsNull() {
+		return nil
+	}
 	return newNode(C.ts_node_first_named_child_for_byte(n._inner, C.uint(byteOffset)))
 }
 
 // Get the node's number of descendants, including one for the node itself.
 func (n *Node) DescendantCount() uint {
+	if n.IsNull() {
+		return 0
+	}
 	return uint(C.ts_node_descendant_count(n._inner))
 }
 
+// Get the number of nodes within this node, including the node itself, that
+// overlap the byte range from `start` up to `end`. Zero-width nodes are
+// counted if they are positioned within the range.
+//
+// Subtrees that lie entirely inside the range are counted with
+// [Node.DescendantCount] rather than visited, and subtrees outside it are
+// skipped, so this only walks the nodes on the boundaries of the range.
+func (n *Node) DescendantsInRangeCount(start, end uint) uint {
+	if n.IsNull() {
+		return 0
+	}
+	overlaps := func(node *Node) bool {
+		nodeStart, nodeEnd := node.ByteRange()
+		if nodeStart == nodeEnd {
+			return start <= nodeStart && nodeStart < end
+		}
+		return nodeStart < end && nodeEnd > start
+	}
+
+	cursor := acquireCursor(*n)
+	defer releaseCursor(cursor)
+	var count uint
+	for {
+		node := cursor.Node()
+		descend := false
+		if overlaps(node) {
+			if nodeStart, nodeEnd := node.ByteRange(); start <= nodeStart && nodeEnd <= end {
+				count += node.DescendantCount()
+			} else {
+				count++
+				descend = true
+			}
+		}
+		if descend && cursor.GotoFirstChild() {
+			continue
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				return count
+			}
+		}
+	}
+}
+
 // Get the smallest node within this node that spans the given range.
+//
+// A zero-width range, where `start` equals `end`, finds the node at that
+// position, and a range that spans several siblings finds their parent.
+// A range that is not within this node finds this node itself. This
+// returns `nil` if the range is inverted or extends past the end of the
+// document.
 func (n *Node) DescendantForByteRange(start, end uint) *Node {
+	if n.IsNull() {
+		return nil
+	}
+	if !n.validByteRange(start, end) {
+		return nil
+	}
 	return newNode(C.ts_node_descendant_for_byte_range(n._inner, C.uint(start), C.uint(end)))
 }
 
 // Get the smallest named node within this node that spans the given range.
+//
+// See also [Node.DescendantForByteRange].
 func (n *Node) NamedDescendantForByteRange(start, end uint) *Node {
+	if n.IsNull() {
+		return nil
+	}
+	if !n.validByteRange(start, end) {
+		return nil
+	}
 	return newNode(C.ts_node_named_descendant_for_byte_range(n._inner, C.uint(start), C.uint(end)))
 }
 
 // Get the smallest node within this node that spans the given range.
+//
+// See also [Node.DescendantForByteRange].
 func (n *Node) DescendantForPointRange(start, end Point) *Node {
+	if n.IsNull() {
+		return nil
+	}
+	if !n.validPointRange(start, end) {
+		return nil
+	}
 	return newNode(C.ts_node_descendant_for_point_range(n._inner, start.toTSPoint(), end.toTSPoint()))
 }
 
 // Get the smallest named node within this node that spans the given range.
+//
+// See also [Node.DescendantForByteRange].
 func (n *Node) NamedDescendantForPointRange(start, end Point) *Node {
+	if n.IsNull() {
+		return nil
+	}
+	if !n.validPointRange(start, end) {
+		return nil
+	}
 	return newNode(C.ts_node_named_descendant_for_point_range(n._inner, start.toTSPoint(), end.toTSPoint()))
 }
 
+// Check that a range is not inverted and does not extend past the end of
+// the document. The root node ends at the end of the document, even when
+// it starts after some leading whitespace. A node from
+// [Tree.RootNodeWithOffset] has shifted positions that its tree's root does
+// not share, so a range that ends within the node itself is also accepted.
+func (n *Node) validByteRange(start, end uint) bool {
+	return start <= end && (end <= n.EndByte() || end <= n.documentRoot().EndByte())
+}
+
+func (n *Node) validPointRange(start, end Point) bool {
+	return !end.less(start) && (!n.EndPosition().less(end) || !n.documentRoot().EndPosition().less(end))
+}
+
+func (n *Node) documentRoot() *Node {
+	return &Node{_inner: C.ts_tree_root_node(n._inner.tree)}
+}
+
+// Get the s-expression of this node, as with [Node.ToSexp], or `(null)` for
+// the zero [Node].
+func (n *Node) String() string {
+	if n.IsNull() {
+		return "(null)"
+	}
+	return n.ToSexp()
+}
+
 func (n *Node) ToSexp() string {
+	if n.IsNull() {
+		return ""
+	}
 	cString := C.ts_node_string(n._inner)
 	result := C.GoString(cString)
 	go_free(unsafe.Pointer(cString))
 	return result
 }
 
+// Write a graph of the subtree rooted at this node to `w`, in the DOT
+// language used by graphviz.
+//
+// Unlike [Tree.PrintDotGraph], which writes the whole tree in the
+// Tree-sitter library's own format, this only includes this node and its
+// descendants. Edges to children that have a field name are labelled with
+// it, anonymous nodes are drawn without a border, and `ERROR` and `MISSING`
+// nodes are highlighted in red.
+func (n *Node) WriteDotGraph(w io.Writer) error {
+	dw := dotWriter{w: w}
+	dw.printf("digraph tree {\n")
+	dw.printf("  edge [arrowhead=none]\n")
+	if n.IsNull() {
+		dw.printf("}\n")
+		return dw.err
+	}
+
+	cursor := acquireCursor(*n)
+	defer releaseCursor(cursor)
+
+	// The ids of the nodes from the root down to the cursor's node.
+	var ancestors []int
+	for id := 0; dw.err == nil; id++ {
+		node := cursor.Node()
+		attributes := "label=" + strconv.Quote(node.Kind())
+		if !node.IsNamed() {
+			attributes += ", shape=plaintext"
+		}
+		if node.IsError() || node.IsMissing() {
+			attributes += ", color=red, fontcolor=red"
+		}
+		if node.IsMissing() {
+			attributes += ", style=dashed"
+		}
+		dw.printf("  node%d [%s]\n", id, attributes)
+		if len(ancestors) > 0 {
+			parent := ancestors[len(ancestors)-1]
+			if field := cursor.FieldName(); field != "" {
+				dw.printf("  node%d -> node%d [label=%s]\n", parent, id, strconv.Quote(field))
+			} else {
+				dw.printf("  node%d -> node%d\n", parent, id)
+			}
+		}
+
+		if cursor.GotoFirstChild() {
+			ancestors = append(ancestors, id)
+			continue
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				dw.printf("}\n")
+				return dw.err
+			}
+			ancestors = ancestors[:len(ancestors)-1]
+		}
+	}
+	return dw.err
+}
+
+// Writes formatted text, keeping the first error that occurs.
+type dotWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (dw *dotWriter) printf(format string, args ...any) {
+	if dw.err == nil {
+		_, dw.err = fmt.Fprintf(dw.w, format, args...)
+	}
+}
+
+// Get the text of this node from the UTF8 source that it was parsed from.
+//
+// If `source` is too short to contain the node, as happens when it is a
+// stale buffer from before an edit, this returns an empty string instead of
+// panicking.
 func (n *Node) Utf8Text(source []byte) string {
-	return string(source[n.StartByte():n.EndByte()])
+	return string(n.textIn(source))
+}
+
+// Get the text of this node from the UTF8 source that it was parsed from,
+// without copying it. Like [Node.Utf8Text], this returns an empty slice if
+// `source` is too short to contain the node.
+func (n *Node) Utf8Bytes(source []byte) []byte {
+	return n.textIn(source)
 }
 
+// Get the text of this node from the UTF8 source that it was parsed from,
+// checking that the source still contains the whole node and that its text
+// is well-formed UTF8. Otherwise, this returns a [*NodeTextError] saying
+// where the text could not be read.
+func (n *Node) ValidatedUtf8Text(source []byte) (string, error) {
+	start, end := n.ByteRange()
+	if end > uint(len(source)) {
+		return "", &NodeTextError{Offset: uint(len(source)), OutOfRange: true}
+	}
+	text := source[start:end]
+	if utf8.Valid(text) {
+		return string(text), nil
+	}
+	for i := 0; ; {
+		r, size := utf8.DecodeRune(text[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return "", &NodeTextError{Offset: start + uint(i)}
+		}
+		i += size
+	}
+}
+
+// An error that occurred in [Node.ValidatedUtf8Text].
+type NodeTextError struct {
+	// The offset in the source of the first byte of the node's text that
+	// could not be read.
+	Offset uint
+
+	// Whether the source ended at `Offset`, before the end of the node,
+	// rather than containing invalid UTF8 there.
+	OutOfRange bool
+}
+
+func (e *NodeTextError) Error() string {
+	if e.OutOfRange {
+		return fmt.Sprintf("source ends at byte %d, before the end of the node", e.Offset)
+	}
+	return fmt.Sprintf("invalid UTF-8 in the node's text at byte %d", e.Offset)
+}
+
+func (n *Node) textIn(source []byte) []byte {
+	start, end := n.ByteRange()
+	if end > uint(len(source)) {
+		return []byte{}
+	}
+	return source[start:end]
+}
+
+// Get the text of this node from the source retained by its tree with
+// [Tree.SetSource].
+//
+// This panics if the tree has no retained source; use [Node.Utf8Text] to
+// pass the source explicitly instead. The zero [Node] has no text.
+func (n *Node) Text() []byte {
+	if n.IsNull() {
+		return []byte{}
+	}
+	source, ok := treeSources.Load(n._inner.tree)
+	if !ok {
+		panic("tree_sitter: Node.Text called on a node whose tree has no source, see Tree.SetSource")
+	}
+	return n.textIn(source.([]byte))
+}
+
+// Get the text of this node as a string, from the source retained by its
+// tree with [Tree.SetSource].
+//
+// Like [Node.Text], this panics if the tree has no retained source.
+func (n *Node) Content() string {
+	return string(n.Text())
+}
+
+// Get the text of this node from a UTF16 source that was parsed with
+// [Parser.ParseUTF16LE] or [Parser.ParseUTF16BE].
+//
+// The node's byte offsets are converted to indices into `source` by halving
+// them, since every code unit is two bytes long.
+//
+// Like [Node.Utf8Text], this returns an empty slice if `source` is too short
+// to contain the node.
 func (n *Node) Utf16Text(source []uint16) []uint16 {
-	return source[n.StartByte():n.EndByte()]
+	start, end := n.StartByte()/2, n.EndByte()/2
+	if end > uint(len(source)) {
+		return []uint16{}
+	}
+	return source[start:end]
 }
 
 // Create a new [TreeCursor] starting from this node.
 //
 // Note that the given node is considered the root of the cursor,
-// and the cursor cannot walk outside this node.
+// and the cursor cannot walk outside this node. A cursor made from the zero
+// [Node] acts as if it were on a node with no children, and its current node
+// is nil, until it is reset to another node.
 func (n *Node) Walk() *TreeCursor {
+	if n.IsNull() {
+		return addCleanup(&TreeCursor{}, (*TreeCursor).Close)
+	}
 	return newTreeCursor(*n)
 }
 
@@ -382,7 +1083,15 @@ func (n *Node) Walk() *TreeCursor {
 // the tree afterward will already reflect the edit. You only need to
 // use [Node.Edit] when you have a specific [Node] instance that
 // you want to keep and continue to use after an edit.
+//
+// The node is updated in place. Only its position is adjusted: its kind,
+// children and other properties still describe the tree before the edit,
+// and a node inside the edited range keeps its old extent. Reparse the
+// document to learn how the edit changed the structure of the tree.
 func (n *Node) Edit(edit *InputEdit) {
+	if n.IsNull() {
+		return
+	}
 	C.ts_node_edit(&n._inner, edit.toTSInputEdit())
 }
 
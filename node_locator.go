@@ -0,0 +1,187 @@
+package tree_sitter
+
+/*
+#cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
+#include <tree_sitter/api.h>
+
+typedef struct {
+	TSNode node;
+	uint32_t start_byte;
+	uint32_t end_byte;
+	TSPoint start_point;
+	TSPoint end_point;
+} TSGoLocatorEntry;
+
+static bool ts_go_point_lt(TSPoint a, TSPoint b) {
+	return a.row < b.row || (a.row == b.row && a.column < b.column);
+}
+
+// Move the cursor down from its node, one child at a time, for as long as
+// there is a child that contains the goal, which is a point if `by_point`
+// is set and a byte offset otherwise. Each child that the cursor moves to is
+// stored in `entries`, up to `capacity` of them, and their number is
+// returned.
+static uint32_t ts_go_locator_descend(TSTreeCursor *cursor, uint32_t goal_byte, TSPoint goal_point, bool by_point, TSGoLocatorEntry *entries, uint32_t capacity) {
+	uint32_t count = 0;
+	while (count < capacity) {
+		int64_t index = by_point
+			? ts_tree_cursor_goto_first_child_for_point(cursor, goal_point)
+			: ts_tree_cursor_goto_first_child_for_byte(cursor, goal_byte);
+		if (index < 0) {
+			break;
+		}
+		TSNode node = ts_tree_cursor_current_node(cursor);
+		TSGoLocatorEntry entry = {
+			node,
+			ts_node_start_byte(node),
+			ts_node_end_byte(node),
+			ts_node_start_point(node),
+			ts_node_end_point(node),
+		};
+		// The child ends after the goal, but it may also start after it.
+		if (by_point ? ts_go_point_lt(goal_point, entry.start_point) : goal_byte < entry.start_byte) {
+			ts_tree_cursor_goto_parent(cursor);
+			break;
+		}
+		entries[count++] = entry;
+	}
+	return count;
+}
+*/
+import "C"
+import "unsafe"
+
+// The number of nodes that a [NodeLocator] moves down through with each call
+// into the Tree-sitter library.
+const locatorBatchSize = 16
+
+// A NodeLocator finds the nodes of a [Tree] at given positions, such as the
+// node under an editor's cursor, and is faster than searching from the root
+// with [Node.DescendantForByteRange] when successive positions are close
+// together.
+//
+// The locator remembers the path from the root to the node it found last,
+// and answers the next lookup by moving up that path to the first node that
+// contains the new position and then descending from there. After the
+// document is edited and reparsed, give the locator the new tree with
+// [NodeLocator.SetTree], since the remembered path belongs to the old one.
+//
+// The locator must be closed with [NodeLocator.Close] once it is no longer
+// needed, and the tree must not be closed while the locator is in use.
+type NodeLocator struct {
+	cursor *TreeCursor
+
+	// The nodes from the root down to the cursor's node, along with their
+	// ranges, so that moving up the path needs no calls into the
+	// Tree-sitter library.
+	path []C.TSGoLocatorEntry
+
+	// A buffer in C memory for the nodes of one descent, which, unlike Go
+	// memory, is not checked for Go pointers when it is passed to C.
+	batch *C.TSGoLocatorEntry
+}
+
+// Create a new [NodeLocator] for the given tree.
+func NewNodeLocator(tree *Tree) *NodeLocator {
+	l := &NodeLocator{batch: (*C.TSGoLocatorEntry)(go_malloc(C.sizeof_TSGoLocatorEntry * locatorBatchSize))}
+	l.SetTree(tree)
+	return l
+}
+
+// Delete the locator, freeing the memory used by its cursor.
+func (l *NodeLocator) Close() {
+	l.cursor.Close()
+	go_free(unsafe.Pointer(l.batch))
+}
+
+// Make the locator find nodes in the given tree, forgetting the path to the
+// last node that it found. This must be called after the locator's tree is
+// edited, as well as to move on to a new tree.
+func (l *NodeLocator) SetTree(tree *Tree) {
+	if l.cursor != nil {
+		l.cursor.Close()
+	}
+	l.cursor = tree.Walk()
+	root := l.cursor.Node()
+	start, end := root.StartPosition(), root.EndPosition()
+	l.path = append(l.path[:0], C.TSGoLocatorEntry{
+		node:        root._inner,
+		start_byte:  C.uint32_t(root.StartByte()),
+		end_byte:    C.uint32_t(root.EndByte()),
+		start_point: start.toTSPoint(),
+		end_point:   end.toTSPoint(),
+	})
+}
+
+// Get the smallest node that contains the given byte offset, which is the
+// node that `root.DescendantForByteRange(offset, offset)` finds, where
+// `root` is the root node of the tree. Unlike that method, this never finds
+// a node of zero width, such as a missing node, but finds the node that
+// contains it instead.
+//
+// This returns `nil` if the offset is outside the root node.
+func (l *NodeLocator) NodeAt(offset uint) *Node {
+	goal := C.uint32_t(offset)
+	if root := &l.path[0]; goal < root.start_byte || goal > root.end_byte {
+		return nil
+	}
+
+	// A node that contains the offset is on the path that a descent from
+	// the root takes, since its earlier siblings end before the offset.
+	for len(l.path) > 1 {
+		if last := &l.path[len(l.path)-1]; last.start_byte <= goal && goal < last.end_byte {
+			break
+		}
+		l.up()
+	}
+	l.descend(goal, C.TSPoint{}, false)
+	return &Node{_inner: l.path[len(l.path)-1].node}
+}
+
+// Get the smallest named node that contains the given point, which is the
+// node that `root.NamedDescendantForPointRange(point, point)` finds, where
+// `root` is the root node of the tree. As with [NodeLocator.NodeAt], a node
+// of zero width is never found.
+//
+// This returns `nil` if the point is outside the root node.
+func (l *NodeLocator) NamedNodeAt(point Point) *Node {
+	goal := point.toTSPoint()
+	if root := &l.path[0]; tsPointLess(goal, root.start_point) || tsPointLess(root.end_point, goal) {
+		return nil
+	}
+
+	for len(l.path) > 1 {
+		if last := &l.path[len(l.path)-1]; !tsPointLess(goal, last.start_point) && tsPointLess(goal, last.end_point) {
+			break
+		}
+		l.up()
+	}
+	l.descend(0, goal, true)
+	for i := len(l.path) - 1; i > 0; i-- {
+		if node := (Node{_inner: l.path[i].node}); node.IsNamed() {
+			return &node
+		}
+	}
+	return &Node{_inner: l.path[0].node}
+}
+
+func tsPointLess(a, b C.TSPoint) bool {
+	return a.row < b.row || (a.row == b.row && a.column < b.column)
+}
+
+func (l *NodeLocator) up() {
+	l.cursor.GotoParent()
+	l.path = l.path[:len(l.path)-1]
+}
+
+// Move the cursor down to the smallest node that contains the goal,
+// extending the path with the nodes that it moves through.
+func (l *NodeLocator) descend(goalByte C.uint32_t, goalPoint C.TSPoint, byPoint bool) {
+	for {
+		count := C.ts_go_locator_descend(&l.cursor._inner, goalByte, goalPoint, C.bool(byPoint), l.batch, locatorBatchSize)
+		l.path = append(l.path, unsafe.Slice(l.batch, count)...)
+		if count < locatorBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestNodeLocator(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := []byte(walkExample)
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	root := tree.RootNode()
+
+	locator := NewNodeLocator(tree)
+	defer locator.Close()
+
+	// Lookups in order, backward, and jumping around all agree with a
+	// search from the root.
+	check := func(offset uint) {
+		expected := root.DescendantForByteRange(offset, offset)
+		assert.Equal(t, expected, locator.NodeAt(offset), "offset %d", offset)
+		point := expected.StartPosition()
+		assert.Equal(t, root.NamedDescendantForPointRange(point, point), locator.NamedNodeAt(point), "point %v", point)
+	}
+	for offset := range uint(len(source)) {
+		check(offset)
+	}
+	for offset := uint(len(source)); offset > 0; offset-- {
+		check(offset)
+	}
+	for i := range uint(200) {
+		check(i * 37 % uint(len(source)))
+	}
+
+	plus := uint(indexOf(source, "+"))
+	assert.Equal(t, "+", locator.NodeAt(plus).Kind())
+	assert.Equal(t, "binary_expression", locator.NamedNodeAt(locator.NodeAt(plus).StartPosition()).Kind())
+	assert.Nil(t, locator.NodeAt(uint(len(source))+1))
+	assert.Nil(t, locator.NamedNodeAt(Point{Row: 20, Column: 0}))
+}
+
+func TestNodeLocatorSetTree(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := []byte(walkExample)
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	locator := NewNodeLocator(tree)
+	defer locator.Close()
+	offset := uint(indexOf(source, "x :="))
+	assert.Equal(t, "x", locator.NodeAt(offset).Utf8Text(source))
+
+	// Insert a statement before `x`, which moves it down a line.
+	insertion := []byte("y := 0\n\t")
+	newSource := append(append(append([]byte{}, source[:offset]...), insertion...), source[offset:]...)
+	tree.Edit(&InputEdit{
+		StartByte:      offset,
+		OldEndByte:     offset,
+		NewEndByte:     offset + uint(len(insertion)),
+		StartPosition:  Point{Row: 7, Column: 1},
+		OldEndPosition: Point{Row: 7, Column: 1},
+		NewEndPosition: Point{Row: 8, Column: 1},
+	})
+	newTree := parser.Parse(newSource, tree)
+	defer newTree.Close()
+
+	locator.SetTree(newTree)
+	y := locator.NodeAt(offset)
+	assert.Equal(t, "y", y.Utf8Text(newSource))
+	assert.True(t, y.Equals(*newTree.RootNode().DescendantForByteRange(offset, offset)))
+	assert.Equal(t, "x", locator.NamedNodeAt(Point{Row: 8, Column: 1}).Utf8Text(newSource))
+}
+
+// Look up the node at each offset of every line in turn, as if moving an
+// editor's cursor across the source.
+func BenchmarkNodeAtWithNodeLocator(b *testing.B) {
+	parser, tree := largeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+	locator := NewNodeLocator(tree)
+	defer locator.Close()
+	end := tree.RootNode().EndByte()
+
+	b.ResetTimer()
+	for i := range b.N {
+		locator.NodeAt(uint(i) % end)
+	}
+}
+
+func BenchmarkNodeAtWithDescendantForByteRange(b *testing.B) {
+	parser, tree := largeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+	root := tree.RootNode()
+	end := root.EndByte()
+
+	b.ResetTimer()
+	for i := range b.N {
+		offset := uint(i) % end
+		root.DescendantForByteRange(offset, offset)
+	}
+}
@@ -0,0 +1,60 @@
+package tree_sitter
+
+// One step in a path from a node down to one of its descendants.
+type PathStep struct {
+	// The index of the child within its parent, as passed to [Node.Child].
+	Index int
+
+	// The child's field name, or an empty string if it has none.
+	FieldName string
+
+	// The child's kind, as returned by [Node.Kind].
+	Kind string
+}
+
+// Get the path from `root` down to `target`, with one step for each node
+// below `root`, or nil if `target` is not within `root`. The path from a
+// node to itself is empty.
+//
+// A path only refers to nodes by their position in the tree, so it can be
+// stored and replayed with [ResolvePath] on another parse of the same
+// source, or of a version that only differs in the contents of the nodes.
+func NodePath(root, target Node) []PathStep {
+	path := []PathStep{}
+	cursor := acquireCursor(root)
+	defer releaseCursor(cursor)
+	for node := root; node.Id() != target.Id(); {
+		child := node.ChildWithDescendant(&target)
+		if child == nil {
+			return nil
+		}
+
+		cursor.GotoFirstChild()
+		index := 0
+		for cursor.Node().Id() != child.Id() {
+			cursor.GotoNextSibling()
+			index++
+		}
+		path = append(path, PathStep{Index: index, FieldName: cursor.FieldName(), Kind: child.Kind()})
+		node = *child
+	}
+	return path
+}
+
+// Follow a path from [NodePath] down from `root`, returning the node that it
+// leads to, or nil if it does not fit the tree: if a step's index is out of
+// range, or the child there has another kind or field name.
+func ResolvePath(root Node, path []PathStep) *Node {
+	node := &root
+	for _, step := range path {
+		if step.Index < 0 || uint(step.Index) >= node.ChildCount() {
+			return nil
+		}
+		child := node.Child(uint(step.Index))
+		if child.Kind() != step.Kind || node.FieldNameForChild(uint32(step.Index)) != step.FieldName {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
@@ -0,0 +1,78 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+const nodePathExample = `package main
+
+func Foo(a int, b string) {}
+
+func Bar() {}
+`
+
+func TestNodePath(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse([]byte(nodePathExample), nil)
+	defer tree.Close()
+	root := *tree.RootNode()
+
+	// The type of Foo's second parameter.
+	parameters := root.NamedChild(1).ChildByFieldName("parameters")
+	target := parameters.NamedChild(1).ChildByFieldName("type")
+	assert.Equal(t, "string", target.Utf8Text([]byte(nodePathExample)))
+
+	path := NodePath(root, *target)
+	assert.Equal(t, []PathStep{
+		{Index: 1, Kind: "function_declaration"},
+		{Index: 2, FieldName: "parameters", Kind: "parameter_list"},
+		{Index: 3, Kind: "parameter_declaration"},
+		{Index: 1, FieldName: "type", Kind: "type_identifier"},
+	}, path)
+	assert.Equal(t, target, ResolvePath(root, path))
+
+	assert.Equal(t, []PathStep{}, NodePath(root, root))
+	assert.Equal(t, &root, ResolvePath(root, nil))
+	assert.Nil(t, NodePath(*parameters, *root.NamedChild(2)))
+
+	// Paths that do not fit the tree resolve to nil.
+	assert.Nil(t, ResolvePath(root, []PathStep{{Index: 9, Kind: "function_declaration"}}))
+	assert.Nil(t, ResolvePath(root, []PathStep{{Index: -1, Kind: "function_declaration"}}))
+	assert.Nil(t, ResolvePath(root, []PathStep{{Index: 1, Kind: "method_declaration"}}))
+	assert.Nil(t, ResolvePath(root, []PathStep{{Index: 1, FieldName: "body", Kind: "function_declaration"}}))
+}
+
+func TestResolvePathAfterReparse(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse([]byte(nodePathExample), nil)
+	defer tree.Close()
+
+	parameters := tree.RootNode().NamedChild(1).ChildByFieldName("parameters")
+	path := NodePath(*tree.RootNode(), *parameters.NamedChild(1))
+
+	// Only the whitespace has changed, so the path still leads to `b string`.
+	source := []byte("package main\n\n\nfunc Foo(\n\ta int,\n\tb string,\n) {}\n\nfunc Bar() {}\n")
+	newTree := parser.Parse(source, nil)
+	defer newTree.Close()
+	node := ResolvePath(*newTree.RootNode(), path)
+	if assert.NotNil(t, node) {
+		assert.Equal(t, "b string", node.Utf8Text(source))
+	}
+
+	// Renaming the function changes nothing that the path refers to, but
+	// turning it into a method does.
+	renamed := parser.Parse([]byte("package main\n\nfunc Baz(x int, y string) {}\n"), nil)
+	defer renamed.Close()
+	assert.NotNil(t, ResolvePath(*renamed.RootNode(), path))
+
+	method := parser.Parse([]byte("package main\n\nfunc (t T) Foo(a int, b string) {}\n"), nil)
+	defer method.Close()
+	assert.Nil(t, ResolvePath(*method.RootNode(), path))
+}
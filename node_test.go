@@ -1,8 +1,13 @@
 package tree_sitter_test
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"math/rand"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
@@ -238,6 +243,12 @@ func TestNodeChildrenByFieldName(t *testing.T) {
 		alternativeTexts = append(alternativeTexts, string(source[condition.StartByte():condition.EndByte()]))
 	}
 	assert.Equal(t, []string{"two", "three", "four"}, alternativeTexts)
+
+	var alternativesSeq []Node
+	for alternative := range node.ChildrenByFieldNameSeq("alternative") {
+		alternativesSeq = append(alternativesSeq, alternative)
+	}
+	assert.Equal(t, alternatives, alternativesSeq)
 }
 
 func TestNodeParentOfChildByFieldName(t *testing.T) {
@@ -291,6 +302,11 @@ func TestFirstChildForOffset(t *testing.T) {
 	assert.Equal(t, "identifier", sumNode.FirstChildForByte(1).Kind())
 	assert.Equal(t, "+", sumNode.FirstChildForByte(3).Kind())
 	assert.Equal(t, "number", sumNode.FirstChildForByte(5).Kind())
+
+	// No child contains or starts after the end of the last child.
+	assert.Equal(t, "number", sumNode.FirstChildForByte(8).Kind())
+	assert.Nil(t, sumNode.FirstChildForByte(9))
+	assert.Nil(t, sumNode.FirstChildForByte(100))
 }
 
 func TestFirstNamedChildForOffset(t *testing.T) {
@@ -305,6 +321,7 @@ func TestFirstNamedChildForOffset(t *testing.T) {
 	assert.Equal(t, "identifier", sumNode.FirstNamedChildForByte(0).Kind())
 	assert.Equal(t, "identifier", sumNode.FirstNamedChildForByte(1).Kind())
 	assert.Equal(t, "number", sumNode.FirstNamedChildForByte(3).Kind())
+	assert.Nil(t, sumNode.FirstNamedChildForByte(9))
 }
 
 func TestNodeFieldNameForChild(t *testing.T) {
@@ -363,6 +380,29 @@ func TestNodeFieldNameForNamedChild(t *testing.T) {
 	assert.Equal(t, "", binaryExpressionNode.FieldNameForNamedChild(3))
 }
 
+func TestNodeFieldNamesOfGoFunction(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+
+	tree := parser.Parse([]byte("package main\n\nfunc add(a, b int) int { return a + b }\n"), nil)
+	defer tree.Close()
+	function := tree.RootNode().NamedChild(1)
+	assert.Equal(t, "function_declaration", function.Kind())
+
+	var fields []string
+	for i := range uint32(function.ChildCount()) {
+		fields = append(fields, function.FieldNameForChild(i))
+	}
+	assert.Equal(t, []string{"", "name", "parameters", "result", "body"}, fields)
+
+	fields = nil
+	for i := range uint32(function.NamedChildCount()) {
+		fields = append(fields, function.FieldNameForNamedChild(i))
+	}
+	assert.Equal(t, []string{"name", "parameters", "result", "body"}, fields)
+}
+
 func TestNodeChildByFieldNameWithExtraHiddenChildren(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -462,6 +502,43 @@ func TestNodeDescendantCount(t *testing.T) {
 	}
 }
 
+func TestNodeDescendantCountOfSubtrees(t *testing.T) {
+	tree := parseJsonExample()
+	defer tree.Close()
+	root := tree.RootNode()
+
+	var sum uint
+	for child := range root.ChildrenSeq() {
+		sum += child.DescendantCount()
+	}
+	assert.Equal(t, sum+1, root.DescendantCount())
+
+	leaf := root.DescendantForByteRange(uint(strings.Index(JSON_EXAMPLE, "null")), uint(strings.Index(JSON_EXAMPLE, "null")))
+	assert.Equal(t, "null", leaf.Kind())
+	assert.Equal(t, uint(1), leaf.DescendantCount())
+}
+
+func TestNodeDescendantsInRangeCount(t *testing.T) {
+	tree := parseJsonExample()
+	defer tree.Close()
+	root := tree.RootNode()
+	array := root.NamedChild(0)
+
+	assert.Equal(t, root.DescendantCount(), root.DescendantsInRangeCount(0, root.EndByte()))
+	assert.Equal(t, uint(0), root.DescendantsInRangeCount(root.EndByte(), root.EndByte()+10))
+
+	// The range only covers the object, which is counted along with the
+	// array and document that contain it.
+	object := array.NamedChild(2)
+	assert.Equal(t, "object", object.Kind())
+	assert.Equal(t, object.DescendantCount()+2, root.DescendantsInRangeCount(object.StartByte(), object.EndByte()))
+
+	// Within a single token.
+	number := array.NamedChild(0)
+	assert.Equal(t, uint(3), root.DescendantsInRangeCount(number.StartByte()+1, number.StartByte()+2))
+	assert.Equal(t, uint(1), number.DescendantsInRangeCount(number.StartByte(), number.EndByte()))
+}
+
 func TestDescendantCountSingleNodeTree(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -610,6 +687,30 @@ func TestNodeEdit(t *testing.T) {
 	}
 }
 
+func TestNodeEditShiftsRetainedNode(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	code := []byte("package main\n\nfunc a() {}\n\nfunc b() {}\n")
+	tree := parser.Parse(code, nil)
+	defer tree.Close()
+
+	retained := tree.RootNode().NamedChild(2)
+	assert.Equal(t, "func b() {}", retained.Utf8Text(code))
+	start := retained.StartByte()
+	startPosition := retained.StartPosition()
+
+	inserted := []byte("\nvar x = 1\n")
+	edit, err := performEdit(tree, &code, &testEdit{position: 14, insertedText: inserted})
+	assert.NoError(t, err)
+
+	retained.Edit(&edit)
+	assert.Equal(t, start+uint(len(inserted)), retained.StartByte())
+	assert.Equal(t, NewPoint(startPosition.Row+2, startPosition.Column), retained.StartPosition())
+	assert.Equal(t, "func b() {}", retained.Utf8Text(code))
+	assert.Equal(t, "function_declaration", retained.Kind())
+}
+
 func TestRootNodeWithOffset(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -643,6 +744,75 @@ func TestRootNodeWithOffset(t *testing.T) {
 	assert.Equal(t, Point{2, 10}, child.EndPosition())
 }
 
+func TestRootNodeWithOffsetForSnippet(t *testing.T) {
+	document := "# Example\n\n```js\nlet a = 1;\nlet b = 2;\n```\n"
+	start := strings.Index(document, "let a")
+	end := strings.LastIndex(document, "```")
+	snippet := []byte(document[start:end])
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("javascript"))
+	tree := parser.Parse(snippet, nil)
+	defer tree.Close()
+
+	plain := tree.RootNode().NamedChild(1)
+	shifted := tree.RootNodeWithOffset(start, Point{3, 0}).NamedChild(1)
+	assert.Equal(t, "let b = 2;", plain.Utf8Text(snippet))
+
+	assert.Equal(t, plain.StartByte()+uint(start), shifted.StartByte())
+	assert.Equal(t, plain.EndByte()+uint(start), shifted.EndByte())
+	assert.Equal(t, Point{1, 0}, plain.StartPosition())
+	assert.Equal(t, Point{4, 0}, shifted.StartPosition())
+	assert.Equal(t, "let b = 2;", document[shifted.StartByte():shifted.EndByte()])
+
+	// Descendants are shifted as well.
+	number := shifted.NamedDescendantForByteRange(shifted.EndByte()-2, shifted.EndByte()-2)
+	assert.Equal(t, "number", number.Kind())
+	assert.Equal(t, Point{4, 8}, number.StartPosition())
+	assert.Equal(t, "2", document[number.StartByte():number.EndByte()])
+}
+
+func TestNodeTextFromRetainedSource(t *testing.T) {
+	source := []byte(`["héllo", "日本語", "🌳"]`)
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	array := tree.RootNode().NamedChild(0)
+	assert.PanicsWithValue(t, "tree_sitter: Node.Text called on a node whose tree has no source, see Tree.SetSource", func() {
+		array.Text()
+	})
+
+	tree.SetSource(source)
+	assert.Equal(t, source, tree.Source())
+	assert.Equal(t, `"héllo"`, array.NamedChild(0).Content())
+	assert.Equal(t, []byte(`"日本語"`), array.NamedChild(1).Text())
+
+	// The last string and the closing bracket end at the very end of the buffer.
+	assert.Equal(t, `"🌳"`, array.NamedChild(2).Content())
+	closing := array.Child(array.ChildCount() - 1)
+	assert.Equal(t, uint(len(source)), closing.EndByte())
+	assert.Equal(t, "]", closing.Content())
+	assert.Equal(t, string(source), tree.RootNode().Content())
+
+	// Copies share the retained source, but edits forget it.
+	clone := tree.Clone()
+	defer clone.Close()
+	assert.Equal(t, `"日本語"`, clone.RootNode().NamedChild(0).NamedChild(1).Content())
+
+	tree.Edit(&InputEdit{
+		StartByte: 1, OldEndByte: 1, NewEndByte: 2,
+		StartPosition: NewPoint(0, 1), OldEndPosition: NewPoint(0, 1), NewEndPosition: NewPoint(0, 2),
+	})
+	assert.Nil(t, tree.Source())
+	assert.Panics(t, func() { tree.RootNode().Content() })
+	assert.Equal(t, source, clone.Source())
+}
+
 func TestNodeIsExtra(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -794,3 +964,759 @@ func parseJsonExample() *Tree {
 	parser.SetLanguage(getLanguage("json"))
 	return parser.Parse([]byte(JSON_EXAMPLE), nil)
 }
+
+func TestNodeWriteDotGraph(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+	tree := parser.Parse([]byte("fn one() -> i32 { 1 }\nfn two() { let x = ; }"), nil)
+	defer tree.Close()
+
+	var graph strings.Builder
+	assert.NoError(t, tree.RootNode().NamedChild(1).WriteDotGraph(&graph))
+	assert.True(t, strings.HasPrefix(graph.String(), "digraph tree {\n"))
+	assert.True(t, strings.HasSuffix(graph.String(), "}\n"))
+	assert.Contains(t, graph.String(), `node0 [label="function_item"]`)
+	assert.Contains(t, graph.String(), `node0 -> node2 [label="name"]`)
+	assert.Contains(t, graph.String(), `node0 -> node6 [label="body"]`)
+	assert.Contains(t, graph.String(), `node8 [label="let_declaration"]`)
+	assert.Contains(t, graph.String(), `node8 -> node10 [label="pattern"]`)
+	assert.Contains(t, graph.String(), `node11 [label="ERROR", color=red, fontcolor=red]`)
+	assert.Contains(t, graph.String(), `node12 [label="=", shape=plaintext]`)
+	assert.Contains(t, graph.String(), "node11 -> node12\n")
+
+	// Only the given node's subtree is included.
+	assert.NotContains(t, graph.String(), `label="source_file"`)
+	assert.NotContains(t, graph.String(), `label="integer_literal"`)
+
+	assert.ErrorIs(t, tree.RootNode().WriteDotGraph(failingWriter{}), errWriteFailed)
+}
+
+var errWriteFailed = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+func TestNodeTextFromStaleSource(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	source := []byte(`["héllo", "wörld"]`)
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	second := tree.RootNode().NamedChild(0).NamedChild(1)
+	start, end := second.ByteRange()
+	assert.Equal(t, uint(11), start)
+	assert.Equal(t, uint(19), end)
+	assert.Equal(t, `"wörld"`, second.Utf8Text(source))
+	assert.Equal(t, []byte(`"wörld"`), second.Utf8Bytes(source))
+	assert.Equal(t, "ö", second.NamedChild(0).Utf8Text(source)[1:3])
+
+	// A buffer that no longer contains the node gives empty text.
+	stale := source[:15]
+	assert.Equal(t, "", second.Utf8Text(stale))
+	assert.Empty(t, second.Utf8Bytes(stale))
+	assert.Equal(t, `"héllo"`, tree.RootNode().NamedChild(0).NamedChild(0).Utf8Text(stale))
+
+	tree.SetSource(stale)
+	assert.Equal(t, "", second.Content())
+	assert.Equal(t, `"héllo"`, tree.RootNode().NamedChild(0).NamedChild(0).Content())
+}
+
+func TestNodeValidatedUtf8Text(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	source := []byte("[\"ok\", \"b\xffd\"]")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	array := tree.RootNode().NamedChild(0)
+
+	text, err := array.NamedChild(0).ValidatedUtf8Text(source)
+	assert.NoError(t, err)
+	assert.Equal(t, `"ok"`, text)
+
+	var textErr *NodeTextError
+	_, err = array.NamedChild(1).ValidatedUtf8Text(source)
+	if assert.ErrorAs(t, err, &textErr) {
+		assert.Equal(t, NodeTextError{Offset: 9}, *textErr)
+		assert.Equal(t, "invalid UTF-8 in the node's text at byte 9", err.Error())
+	}
+
+	_, err = array.NamedChild(1).ValidatedUtf8Text(source[:8])
+	if assert.ErrorAs(t, err, &textErr) {
+		assert.Equal(t, NodeTextError{Offset: 8, OutOfRange: true}, *textErr)
+		assert.Equal(t, "source ends at byte 8, before the end of the node", err.Error())
+	}
+}
+
+// Slice the text of random nodes out of a source with multibyte characters,
+// both from the source itself and from stale copies of random lengths.
+func TestNodeTextOfRandomNodes(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("javascript"))
+
+	source := []byte("const 名前 = \"日本語\"; // 😀 émoji\nlet s = `${名前}😀${'ü'}`;\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	nodes := getAllNodes(tree)
+
+	random := rand.New(rand.NewSource(0))
+	for range 1000 {
+		node := nodes[random.Intn(len(nodes))]
+		start, end := node.ByteRange()
+
+		text, err := node.ValidatedUtf8Text(source)
+		assert.NoError(t, err)
+		assert.Equal(t, string(source[start:end]), text)
+		assert.Equal(t, text, node.Utf8Text(source))
+
+		stale := source[:random.Intn(len(source)+1)]
+		text, err = node.ValidatedUtf8Text(stale)
+		if end <= uint(len(stale)) {
+			assert.NoError(t, err)
+			assert.Equal(t, string(source[start:end]), text)
+		} else {
+			var textErr *NodeTextError
+			assert.ErrorAs(t, err, &textErr)
+			assert.True(t, textErr.OutOfRange)
+			assert.Empty(t, node.Utf8Text(stale))
+			assert.Empty(t, node.Utf8Bytes(stale))
+		}
+	}
+}
+
+func TestNodeContainment(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	// (array [0, 7] (number [1, 2]) (number [5, 7]) (MISSING "]" [7, 7]))
+	tree := parser.Parse([]byte("[1,\n 22"), nil)
+	defer tree.Close()
+	array := *tree.RootNode().Child(0)
+	one := *array.NamedChild(0)
+	twentyTwo := *array.NamedChild(1)
+	missing := *array.Child(4)
+	assert.True(t, missing.IsMissing())
+
+	byteTests := []struct {
+		node   Node
+		offset uint
+		want   bool
+	}{
+		{array, 0, true},
+		{array, 6, true},
+		{array, 7, false},
+		{twentyTwo, 4, false},
+		{twentyTwo, 5, true},
+		{twentyTwo, 6, true},
+		{twentyTwo, 7, false},
+		{missing, 6, false},
+		{missing, 7, false},
+	}
+	for _, test := range byteTests {
+		assert.Equal(t, test.want, test.node.ContainsByte(test.offset), "%s contains byte %d", test.node.Kind(), test.offset)
+	}
+
+	pointTests := []struct {
+		node  Node
+		point Point
+		want  bool
+	}{
+		{array, Point{0, 99}, true},
+		{twentyTwo, Point{0, 9}, false},
+		{twentyTwo, Point{1, 0}, false},
+		{twentyTwo, Point{1, 1}, true},
+		{twentyTwo, Point{1, 2}, true},
+		{twentyTwo, Point{1, 3}, false},
+		{missing, Point{1, 3}, false},
+	}
+	for _, test := range pointTests {
+		assert.Equal(t, test.want, test.node.ContainsPoint(test.point), "%s contains point %v", test.node.Kind(), test.point)
+	}
+
+	nodeTests := []struct {
+		node, other Node
+		want        bool
+	}{
+		{array, array, true},
+		{array, twentyTwo, true},
+		{array, missing, true},
+		{twentyTwo, missing, true},
+		{missing, missing, true},
+		{missing, twentyTwo, false},
+		{twentyTwo, array, false},
+		{one, twentyTwo, false},
+	}
+	for _, test := range nodeTests {
+		assert.Equal(t, test.want, test.node.ContainsNode(test.other), "%s contains %s", test.node.Kind(), test.other.Kind())
+	}
+
+	rangeTests := []struct {
+		node       Node
+		start, end uint
+		want       bool
+	}{
+		{array, 0, 7, true},
+		{twentyTwo, 4, 5, false},
+		{twentyTwo, 4, 6, true},
+		{twentyTwo, 6, 9, true},
+		{twentyTwo, 7, 9, false},
+		{twentyTwo, 6, 6, false},
+		{missing, 6, 8, false},
+	}
+	for _, test := range rangeTests {
+		r := Range{StartByte: test.start, EndByte: test.end}
+		assert.Equal(t, test.want, test.node.RangeOverlaps(r), "%s overlaps [%d, %d)", test.node.Kind(), test.start, test.end)
+	}
+}
+
+func TestNodeChildrenSeq(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	tree := parser.Parse([]byte(`[1, "two", null]`), nil)
+	defer tree.Close()
+	array := tree.RootNode().NamedChild(0)
+
+	var kinds []string
+	for child := range array.ChildrenSeq() {
+		kinds = append(kinds, child.Kind())
+	}
+	assert.Equal(t, []string{"[", "number", ",", "string", ",", "null", "]"}, kinds)
+
+	kinds = nil
+	for child := range array.NamedChildrenSeq() {
+		kinds = append(kinds, child.Kind())
+		if child.Kind() == "string" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"number", "string"}, kinds)
+
+	cursor := tree.Walk()
+	defer cursor.Close()
+	children := array.ChildrenSlice()
+	assert.Equal(t, array.Children(cursor), children)
+	assert.Equal(t, int(array.ChildCount()), cap(children))
+
+	leaf := array.NamedChild(0)
+	assert.Empty(t, leaf.ChildrenSlice())
+	for range leaf.ChildrenSeq() {
+		t.Fatal("a leaf node has no children")
+	}
+}
+
+func TestNodeChildrenInto(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	source := "[" + strings.Repeat("1, ", 99) + "1]"
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	array := tree.RootNode().NamedChild(0)
+
+	cursor := tree.Walk()
+	defer cursor.Close()
+	children := array.Children(cursor)
+
+	buffer := make([]Node, 300)
+	assert.Equal(t, len(children), array.ChildrenInto(buffer))
+	assert.Equal(t, children, buffer[:len(children)])
+
+	assert.Equal(t, 3, array.ChildrenInto(buffer[:3]))
+	assert.Equal(t, children[:3], buffer[:3])
+	assert.Equal(t, 0, array.ChildrenInto(nil))
+
+	named := array.NamedChildren(cursor)
+	assert.Equal(t, 100, array.NamedChildrenInto(buffer))
+	assert.Equal(t, named, buffer[:100])
+
+	// The iterators fetch the children in batches, so check that nothing is
+	// lost or repeated at the batch boundaries.
+	assert.Equal(t, children, slices.Collect(array.ChildrenSeq()))
+	assert.Equal(t, named, slices.Collect(array.NamedChildrenSeq()))
+}
+
+// A JSON document whose array has about 5000 children.
+func largeJSONArray(b *testing.B) (*Parser, *Tree) {
+	parser := NewParser()
+	parser.SetLanguage(getLanguage("json"))
+	source := "[" + strings.Repeat("1, ", 2500) + "1]"
+	return parser, parser.Parse([]byte(source), nil)
+}
+
+func BenchmarkChildrenWithChild(b *testing.B) {
+	parser, tree := largeJSONArray(b)
+	defer parser.Close()
+	defer tree.Close()
+	array := tree.RootNode().NamedChild(0)
+
+	b.ResetTimer()
+	for range b.N {
+		for i := range array.ChildCount() {
+			_ = array.Child(i)
+		}
+	}
+}
+
+func BenchmarkChildrenWithChildrenSeq(b *testing.B) {
+	parser, tree := largeJSONArray(b)
+	defer parser.Close()
+	defer tree.Close()
+	array := tree.RootNode().NamedChild(0)
+
+	b.ResetTimer()
+	for range b.N {
+		for range array.ChildrenSeq() {
+		}
+	}
+}
+
+func BenchmarkChildrenWithChildrenSlice(b *testing.B) {
+	parser, tree := largeJSONArray(b)
+	defer parser.Close()
+	defer tree.Close()
+	array := tree.RootNode().NamedChild(0)
+
+	b.ResetTimer()
+	for range b.N {
+		_ = array.ChildrenSlice()
+	}
+}
+
+func BenchmarkChildrenWithChildrenInto(b *testing.B) {
+	parser, tree := largeJSONArray(b)
+	defer parser.Close()
+	defer tree.Close()
+	array := tree.RootNode().NamedChild(0)
+	buffer := make([]Node, array.ChildCount())
+
+	b.ResetTimer()
+	for range b.N {
+		_ = array.ChildrenInto(buffer)
+	}
+}
+
+func TestNodeChildByFieldNameOfGoFunction(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+
+	source := []byte("package main\n\nfunc add(a, b int) int {\n\treturn a + b\n}\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	function := tree.RootNode().NamedChild(1)
+	assert.Equal(t, "function_declaration", function.Kind())
+
+	name := function.ChildByFieldName("name")
+	assert.NotNil(t, name)
+	assert.Equal(t, "add", name.Utf8Text(source))
+	body := function.ChildByFieldName("body")
+	assert.NotNil(t, body)
+	assert.Equal(t, "block", body.Kind())
+
+	fieldId := tree.Language().FieldIdForName("body")
+	assert.True(t, body.Equals(*function.ChildByFieldId(fieldId)))
+
+	// Fields that are absent, or unknown to the grammar, give no node.
+	assert.Nil(t, function.ChildByFieldName("type_parameters"))
+	assert.Nil(t, function.ChildByFieldName("no_such_field"))
+
+	parameters := function.ChildByFieldName("parameters").NamedChild(0)
+	var names []string
+	for child := range parameters.ChildrenByFieldNameSeq("name") {
+		names = append(names, child.Utf8Text(source))
+	}
+	assert.Equal(t, []string{"a", "b"}, names)
+	for range parameters.ChildrenByFieldNameSeq("no_such_field") {
+		t.Fatal("no children have an unknown field")
+	}
+}
+
+func TestNodeDescendantForRangeOutsideNode(t *testing.T) {
+	tree := parseJsonExample()
+	defer tree.Close()
+	root := tree.RootNode()
+	end := root.EndByte()
+
+	// A zero-width range at the end of the document is still within it.
+	node := root.DescendantForByteRange(end, end)
+	assert.NotNil(t, node)
+	assert.Equal(t, end, node.EndByte())
+	assert.NotNil(t, root.NamedDescendantForPointRange(root.EndPosition(), root.EndPosition()))
+
+	// Ranges past the end of the document are not.
+	assert.Nil(t, root.DescendantForByteRange(end, end+1))
+	assert.Nil(t, root.NamedDescendantForByteRange(end+5, end+10))
+	assert.Nil(t, root.DescendantForPointRange(Point{100, 0}, Point{100, 0}))
+	assert.Nil(t, root.NamedDescendantForPointRange(root.StartPosition(), Point{100, 0}))
+
+	// Inverted ranges find no node.
+	assert.Nil(t, root.DescendantForByteRange(end, 0))
+	assert.Nil(t, root.NamedDescendantForPointRange(root.EndPosition(), Point{}))
+
+	// A range outside a node finds the node itself.
+	array := root.NamedChild(0)
+	second := array.NamedChild(1)
+	assert.Equal(t, second, second.DescendantForByteRange(array.StartByte(), array.StartByte()))
+
+	// A range spanning two siblings finds their parent.
+	first := array.NamedChild(0)
+	parent := root.DescendantForByteRange(first.StartByte(), second.EndByte())
+	assert.Equal(t, array, parent)
+}
+
+func TestNodeDescendantForRangeInLeadingWhitespace(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+	tree := parser.Parse([]byte("\n\nfn main() {}\n\n"), nil)
+	defer tree.Close()
+	root := tree.RootNode()
+
+	// The root starts after the leading newlines, but they are still part of
+	// the document.
+	assert.EqualValues(t, 2, root.StartByte())
+	assert.Equal(t, root, root.DescendantForByteRange(0, 0))
+	assert.Equal(t, root, root.NamedDescendantForPointRange(Point{}, Point{}))
+}
+
+func TestNodePredicates(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("c"))
+
+	type predicates struct {
+		IsNull, IsNamed, IsExtra, IsError, IsMissing, HasError, HasChanges bool
+	}
+	predicatesOf := func(node *Node) predicates {
+		return predicates{
+			IsNull:     node.IsNull(),
+			IsNamed:    node.IsNamed(),
+			IsExtra:    node.IsExtra(),
+			IsError:    node.IsError(),
+			IsMissing:  node.IsMissing(),
+			HasError:   node.HasError(),
+			HasChanges: node.HasChanges(),
+		}
+	}
+
+	tree := parser.Parse([]byte("int main() {\n  // one\n  int a = 1\n  return a;\n}\n"), nil)
+	defer tree.Close()
+	body := tree.RootNode().NamedChild(0).ChildByFieldName("body")
+
+	comment := body.NamedChild(0)
+	assert.Equal(t, "comment", comment.Kind())
+	assert.Equal(t, predicates{IsNamed: true, IsExtra: true}, predicatesOf(comment))
+
+	declaration := body.NamedChild(1)
+	missing := declaration.Child(declaration.ChildCount() - 1)
+	assert.Equal(t, ";", missing.Kind())
+	assert.Equal(t, predicates{IsMissing: true, HasError: true}, predicatesOf(missing))
+	assert.Equal(t, predicates{IsNamed: true, HasError: true}, predicatesOf(declaration))
+	assert.Equal(t, predicates{}, predicatesOf(body.Child(0)))
+	assert.Equal(t, predicates{IsNamed: true}, predicatesOf(body.NamedChild(2)))
+
+	tree = parser.Parse([]byte("int x = @;"), nil)
+	defer tree.Close()
+	// Error recovery marks the ERROR node as an extra, since it does not fit
+	// into the grammar.
+	errorNode := tree.RootNode().NamedChild(0)
+	assert.Equal(t, predicates{IsNamed: true, IsExtra: true, IsError: true, HasError: true}, predicatesOf(errorNode))
+
+	edit := InputEdit{StartByte: 8, OldEndByte: 9, NewEndByte: 9, StartPosition: NewPoint(0, 8), OldEndPosition: NewPoint(0, 9), NewEndPosition: NewPoint(0, 9)}
+	tree.Edit(&edit)
+	assert.Equal(t, predicates{IsNamed: true, IsExtra: true, IsError: true, HasError: true, HasChanges: true}, predicatesOf(tree.RootNode().NamedChild(0)))
+
+	assert.Equal(t, predicates{IsNull: true}, predicatesOf(&Node{}))
+}
+
+func TestNodeNamedSiblingsOfStatements(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("javascript"))
+	source := []byte("{ let a = 1; f(a); ; return a; }")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	root := tree.RootNode()
+	block := root.NamedChild(0)
+	assert.Equal(t, "statement_block", block.Kind())
+
+	statements := []string{"let a = 1;", "f(a);", ";", "return a;"}
+	tests := []struct {
+		name  string
+		first *Node
+		next  func(*Node) *Node
+		want  []string
+	}{
+		{"forwards", block.NamedChild(0), (*Node).NextNamedSibling, statements},
+		{"backwards", block.NamedChild(block.NamedChildCount() - 1), (*Node).PrevNamedSibling, []string{"return a;", ";", "f(a);", "let a = 1;"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var texts []string
+			for node := test.first; node != nil; node = test.next(node) {
+				texts = append(texts, node.Utf8Text(source))
+				assert.Equal(t, block, node.Parent())
+				assert.Equal(t, node, block.ChildWithDescendant(node))
+			}
+			assert.Equal(t, test.want, texts)
+		})
+	}
+
+	// Unnamed siblings include the braces.
+	assert.Equal(t, "{", block.NamedChild(0).PrevSibling().Kind())
+	assert.Nil(t, block.NamedChild(0).PrevSibling().PrevSibling())
+	assert.Equal(t, "}", block.NamedChild(3).NextSibling().Kind())
+	assert.Nil(t, block.NamedChild(3).NextSibling().NextSibling())
+
+	// Anonymous tokens have named siblings too.
+	brace := block.Child(0)
+	assert.Equal(t, "let a = 1;", brace.NextNamedSibling().Utf8Text(source))
+	assert.Nil(t, brace.PrevNamedSibling())
+	assert.Equal(t, block, brace.Parent())
+
+	// The root has no parent or siblings.
+	assert.Nil(t, root.Parent())
+	assert.Nil(t, root.NextSibling())
+	assert.Nil(t, root.PrevNamedSibling())
+
+	identifier := block.NamedChild(1).NamedChild(0).ChildByFieldName("arguments").NamedChild(0)
+	assert.Equal(t, "a", identifier.Utf8Text(source))
+	assert.Equal(t, block.NamedChild(1), block.ChildWithDescendant(identifier))
+	assert.Nil(t, block.NamedChild(0).ChildWithDescendant(identifier))
+}
+
+func TestNodeKey(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+	code := []byte("fn a() { 1 }\nfn b() { 2 }\n")
+	tree := parser.Parse(code, nil)
+	defer tree.Close()
+
+	// Looking up the same node twice gives equal nodes with equal keys.
+	first := tree.RootNode().NamedChild(1)
+	second := tree.RootNode().Child(1)
+	assert.True(t, first.Equals(*second))
+	assert.Equal(t, first.Id(), second.Id())
+	assert.Equal(t, first.Key(), second.Key())
+	assert.NotEqual(t, first.Key(), tree.RootNode().NamedChild(0).Key())
+
+	kinds := map[NodeKey]string{}
+	for child := range tree.RootNode().ChildrenSeq() {
+		kinds[child.Key()] = child.Kind()
+	}
+	assert.Len(t, kinds, 2)
+	assert.Equal(t, "function_item", kinds[second.Key()])
+
+	// After an unrelated edit, the untouched function keeps its id, but has
+	// a different key in the new tree.
+	_, err := performEdit(tree, &code, &testEdit{position: 9, deletedLength: 1, insertedText: []byte("10")})
+	assert.NoError(t, err)
+	newTree := parser.Parse(code, tree)
+	defer newTree.Close()
+	reused := newTree.RootNode().NamedChild(1)
+	assert.Equal(t, first.Id(), reused.Id())
+	assert.NotEqual(t, first.Key(), reused.Key())
+	assert.False(t, first.Equals(*reused))
+	assert.NotEqual(t, tree.RootNode().NamedChild(0).Id(), newTree.RootNode().NamedChild(0).Id())
+}
+
+func TestNodeGrammarKindOfAliasedNodes(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	language := getLanguage("go")
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte("package main\n\ntype point struct{ x int }\n\nfunc (p point) get() int { return p.x }\n"), nil)
+	defer tree.Close()
+
+	aliased := map[string]string{}
+	cursor := tree.Walk()
+	defer cursor.Close()
+walk:
+	for {
+		node := cursor.Node()
+		assert.Equal(t, node.Kind(), language.NodeKindForId(node.KindId()))
+		assert.Equal(t, node.GrammarName(), language.NodeKindForId(node.GrammarId()))
+		if node.Kind() != node.GrammarName() {
+			assert.NotEqual(t, node.KindId(), node.GrammarId())
+			aliased[node.Kind()] = node.GrammarName()
+		} else {
+			assert.Equal(t, node.KindId(), node.GrammarId())
+		}
+
+		if cursor.GotoFirstChild() {
+			continue
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				break walk
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]string{
+		"package_identifier": "identifier",
+		"type_identifier":    "identifier",
+		"field_identifier":   "identifier",
+	}, aliased)
+}
+
+func TestNodeAncestors(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := "package main\n\nvar top = 1\n\nfunc main() {\n\tfor {\n\t\tif true {\n\t\t\tprintln(top)\n\t\t}\n\t}\n}\n"
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	root := tree.RootNode()
+
+	offset := uint(strings.LastIndex(source, "top"))
+	identifier := root.NamedDescendantForByteRange(offset, offset+3)
+	assert.Equal(t, "identifier", identifier.Kind())
+
+	var kinds []string
+	for ancestor := range identifier.Ancestors() {
+		kinds = append(kinds, ancestor.Kind())
+	}
+	assert.Equal(t, []string{
+		"argument_list",
+		"call_expression",
+		"expression_statement",
+		"block",
+		"if_statement",
+		"block",
+		"for_statement",
+		"block",
+		"function_declaration",
+		"source_file",
+	}, kinds)
+
+	function := identifier.ClosestAncestor("function_declaration", "method_declaration")
+	assert.NotNil(t, function)
+	assert.True(t, function.Equals(*root.NamedChild(2)))
+	assert.Equal(t, "if_statement", identifier.ClosestAncestor("for_statement", "if_statement").Kind())
+	assert.Nil(t, identifier.ClosestAncestor("no_such_kind"))
+
+	// A node at the top level has no enclosing function.
+	offset = uint(strings.Index(source, "top"))
+	topLevel := root.NamedDescendantForByteRange(offset, offset+3)
+	assert.Equal(t, "identifier", topLevel.Kind())
+	assert.Nil(t, topLevel.ClosestAncestor("function_declaration"))
+	assert.Equal(t, "var_declaration", topLevel.ClosestAncestor("var_declaration").Kind())
+
+	// The built-in ERROR kind is found like any other.
+	broken := "package main\n\nfunc f() { foo(1 2 3 }\n"
+	brokenTree := parser.Parse([]byte(broken), nil)
+	defer brokenTree.Close()
+	offset = uint(strings.Index(broken, "2"))
+	literal := brokenTree.RootNode().NamedDescendantForByteRange(offset, offset+1)
+	assert.Equal(t, "int_literal", literal.Kind())
+	errorNode := literal.ClosestAncestor("ERROR")
+	assert.NotNil(t, errorNode)
+	assert.True(t, errorNode.IsError())
+
+	for range root.Ancestors() {
+		t.Fatal("the root has no ancestors")
+	}
+}
+
+func TestZeroNode(t *testing.T) {
+	var node Node
+	assert.True(t, node.IsNull())
+	assert.Equal(t, "", node.Kind())
+	assert.Equal(t, "(null)", node.String())
+	assert.Equal(t, "(null)", fmt.Sprint(&node))
+	assert.EqualValues(t, 0, node.ChildCount())
+	assert.EqualValues(t, 0, node.DescendantCount())
+	assert.Equal(t, Range{}, node.Range())
+	assert.Nil(t, node.Child(0))
+	assert.Nil(t, node.Parent())
+	assert.Nil(t, node.ChildByFieldName("name"))
+	assert.Nil(t, node.Language())
+	assert.Empty(t, slices.Collect(node.ChildrenSeq()))
+	assert.Empty(t, slices.Collect(node.Ancestors()))
+	assert.Empty(t, node.ChildrenSlice())
+	assert.Equal(t, "", node.Utf8Text([]byte("source")))
+
+	var dot strings.Builder
+	assert.NoError(t, node.WriteDotGraph(&dot))
+	assert.Equal(t, "digraph tree {\n  edge [arrowhead=none]\n}\n", dot.String())
+
+	// The functions that walk a subtree treat the zero node as empty.
+	for range Walk(node) {
+		t.Error("the zero node was walked")
+	}
+	for range WalkFields(node) {
+		t.Error("the zero node was walked")
+	}
+	Visit(node, func(Node, string, int) VisitAction {
+		t.Error("the zero node was visited")
+		return VisitContinue
+	})
+	walker := NewWalker(node)
+	assert.False(t, walker.Next())
+	assert.Nil(t, walker.Node())
+	walker.Close()
+	assert.True(t, TreesEqual(node, Node{}))
+	var json strings.Builder
+	assert.NoError(t, WriteTreeJSON(&json, node, nil))
+	assert.Equal(t, "", json.String())
+
+	// Every method can be called without crashing, with the zero value for
+	// each of its arguments.
+	value := reflect.ValueOf(&node)
+	for i := range value.NumMethod() {
+		method := value.Type().Method(i)
+		t.Run(method.Name, func(t *testing.T) {
+			methodType := method.Type
+			var args []reflect.Value
+			for j := 1; j < methodType.NumIn(); j++ {
+				argType := methodType.In(j)
+				switch {
+				case methodType.IsVariadic() && j == methodType.NumIn()-1:
+				case argType == reflect.TypeFor[io.Writer]():
+					args = append(args, reflect.ValueOf(io.Discard))
+				default:
+					args = append(args, reflect.Zero(argType))
+				}
+			}
+
+			var results []reflect.Value
+			assert.NotPanics(t, func() { results = value.Method(i).Call(args) })
+			for _, result := range results {
+				switch result := result.Interface().(type) {
+				case *Node:
+					assert.Nil(t, result)
+				case *TreeCursor:
+					assert.NotPanics(t, func() {
+						assert.Nil(t, result.Node())
+						assert.False(t, result.GotoFirstChild())
+						assert.False(t, result.GotoParent())
+						assert.Equal(t, "", result.FieldName())
+						assert.Nil(t, result.GotoFirstChildForByte(0))
+						copied := result.Copy()
+						assert.Nil(t, copied.Node())
+						copied.Close()
+						result.Close()
+					})
+				case iter.Seq[Node]:
+					assert.NotPanics(t, func() {
+						for range result {
+							t.Error("the zero node yielded a node")
+						}
+					})
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package tree_sitter
+
+import (
+	"context"
+	"iter"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Options for [ParseFilesWithOptions].
+type ParseFilesOptions struct {
+	// The maximum duration that parsing a single file may take. A file
+	// that takes longer is reported to the callback with a nil tree and
+	// [context.DeadlineExceeded]. Zero means there is no timeout.
+	Timeout time.Duration
+}
+
+// Parse a batch of UTF8 documents for a single language using a pool of
+// worker goroutines.
+//
+// See [ParseFilesWithOptions] for more information.
+func ParseFiles(
+	language *Language,
+	sources iter.Seq2[string, []byte],
+	workers int,
+	fn func(name string, tree *Tree, err error),
+) {
+	ParseFilesWithOptions(language, sources, workers, fn, nil)
+}
+
+// Parse a batch of UTF8 documents for a single language using a pool of
+// worker goroutines, and return once all of them have been handled.
+//
+// # Arguments:
+//   - `language` The language to parse every document with.
+//   - `sources` The documents to parse, as pairs of names and contents.
+//   - `workers` The number of goroutines to parse with. Each one owns a
+//     single [Parser] that it reuses for every document it parses. Zero or
+//     less means [runtime.GOMAXPROCS].
+//   - `fn` The function to call with the result of each document, either a
+//     tree or an error. It is called from the worker goroutines, so it may
+//     run concurrently for different documents, but never for two
+//     documents with the same name.
+//   - `options` Optional settings for the batch; see [ParseFilesOptions].
+//
+// The tree passed to `fn` is closed once `fn` returns. To keep it, store a
+// copy made with [Tree.Clone] instead.
+func ParseFilesWithOptions(
+	language *Language,
+	sources iter.Seq2[string, []byte],
+	workers int,
+	fn func(name string, tree *Tree, err error),
+	options *ParseFilesOptions,
+) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var timeout time.Duration
+	if options != nil {
+		timeout = options.Timeout
+	}
+
+	type file struct {
+		name   string
+		source []byte
+	}
+	files := make(chan file)
+	var locks nameLocks
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			parser := NewParser()
+			defer parser.Close()
+			langErr := parser.SetLanguage(language)
+
+			for f := range files {
+				locks.lock(f.name)
+				if langErr != nil {
+					fn(f.name, nil, langErr)
+				} else {
+					parseFile(parser, f.name, f.source, timeout, fn)
+				}
+				locks.unlock(f.name)
+			}
+		}()
+	}
+
+	for name, source := range sources {
+		files <- file{name, source}
+	}
+	close(files)
+	wg.Wait()
+}
+
+func parseFile(parser *Parser, name string, source []byte, timeout time.Duration, fn func(string, *Tree, error)) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tree, err := parser.ParseCtx(ctx, source, nil)
+	if err != nil {
+		// Whatever halted the parse, the worker's next file must not resume it.
+		parser.Reset()
+	} else {
+		defer tree.Close()
+	}
+	fn(name, tree, err)
+}
+
+// A set of mutexes keyed by document name, each of which only exists while
+// it is held or waited on.
+type nameLocks struct {
+	mu    sync.Mutex
+	locks map[string]*nameLock
+}
+
+type nameLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (l *nameLocks) lock(name string) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*nameLock)
+	}
+	lock, ok := l.locks[name]
+	if !ok {
+		lock = &nameLock{}
+		l.locks[name] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+}
+
+func (l *nameLocks) unlock(name string) {
+	l.mu.Lock()
+	lock := l.locks[name]
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, name)
+	}
+	l.mu.Unlock()
+
+	lock.Unlock()
+}
@@ -0,0 +1,138 @@
+package tree_sitter_test
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestParseFilesReusesWorkers(t *testing.T) {
+	sources := make(map[string][]byte)
+	for i := range 50 {
+		sources[fmt.Sprintf("%d.json", i)] = []byte(fmt.Sprintf("[%d]", i))
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	var mu sync.Mutex
+	results := make(map[string]string)
+	ParseFiles(getLanguage("json"), maps.All(sources), 2, func(name string, tree *Tree, err error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+
+		assert.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		results[name] = tree.RootNode().ToSexp()
+	})
+
+	assert.Len(t, results, 50)
+	for _, sexp := range results {
+		assert.Equal(t, "(document (array (number)))", sexp)
+	}
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(2))
+}
+
+func TestParseFilesReportsErrors(t *testing.T) {
+	sources := map[string][]byte{
+		"small.json": []byte("[1]"),
+		"large.json": []byte("[" + strings.Repeat("1, ", 500_000) + "1]"),
+	}
+
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	ParseFilesWithOptions(getLanguage("json"), maps.All(sources), 1, func(name string, tree *Tree, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs[name] = err
+		if err != nil {
+			assert.Nil(t, tree)
+		}
+	}, &ParseFilesOptions{Timeout: time.Millisecond})
+
+	assert.NoError(t, errs["small.json"])
+	assert.ErrorIs(t, errs["large.json"], context.DeadlineExceeded)
+
+	// An incompatible language is reported for every file.
+	buf := make([]uint64, 1024)
+	language := NewLanguage(unsafe.Pointer(&buf[0]))
+	count := 0
+	ParseFiles(language, maps.All(sources), 1, func(name string, tree *Tree, err error) {
+		count++
+		assert.Nil(t, tree)
+		var languageErr *LanguageError
+		assert.ErrorAs(t, err, &languageErr)
+	})
+	assert.Equal(t, 2, count)
+}
+
+func TestParseFilesResetsHaltedParses(t *testing.T) {
+	SetMemoryLimit(64 * 1024)
+	defer SetMemoryTracking(false)
+	defer SetMemoryLimit(0)
+
+	sources := func(yield func(string, []byte) bool) {
+		if yield("large.json", []byte("["+strings.Repeat("[0, 1], ", 100_000)+"0]")) {
+			yield("small.json", []byte("[1]"))
+		}
+	}
+	errs := make(map[string]error)
+	sexps := make(map[string]string)
+	ParseFiles(getLanguage("json"), sources, 1, func(name string, tree *Tree, err error) {
+		errs[name] = err
+		if tree != nil {
+			sexps[name] = tree.RootNode().ToSexp()
+		}
+	})
+
+	assert.ErrorIs(t, errs["large.json"], ErrMemoryLimitExceeded)
+	assert.NoError(t, errs["small.json"])
+	assert.Equal(t, "(document (array (number)))", sexps["small.json"])
+}
+
+func TestParseFilesClosesTreesUnlessCloned(t *testing.T) {
+	sources := func(yield func(string, []byte) bool) {
+		for i := range 10 {
+			if !yield("same.json", []byte(fmt.Sprintf("[%d]", i))) {
+				return
+			}
+		}
+	}
+
+	var kept, passed []*Tree
+	var running atomic.Bool
+	ParseFiles(getLanguage("json"), sources, 4, func(name string, tree *Tree, err error) {
+		// Documents with the same name are never handled concurrently.
+		assert.True(t, running.CompareAndSwap(false, true))
+		defer running.Store(false)
+
+		passed = append(passed, tree)
+		kept = append(kept, tree.Clone())
+	})
+
+	for _, tree := range passed {
+		assert.PanicsWithValue(t, "tree_sitter: use of closed Tree", func() {
+			tree.RootNode()
+		})
+	}
+
+	assert.Len(t, kept, 10)
+	for _, tree := range kept {
+		assert.Equal(t, "(document (array (number)))", tree.RootNode().ToSexp())
+		tree.Close()
+	}
+}
@@ -4,6 +4,7 @@ package tree_sitter
 #cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
 #include <tree_sitter/api.h>
 #include <stdio.h>
+#include "decoder.h"
 
 extern void logCallback(void *payload, TSLogType log_type, char *message);
 extern char *readUTF8(void *payload, uint32_t byte_index, TSPoint position, uint32_t *bytes_read);
@@ -16,17 +17,50 @@ import "C"
 
 import (
 	"context"
+	"errors"
+	"io"
 	"os"
+	"runtime"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/mattn/go-pointer"
 )
 
+// ErrParseTimeout is returned by [Parser.ParseCtx] and
+// [Parser.ParseWithOptions] when parsing was halted because it took longer
+// than the parser's [Parser.Timeout].
+var ErrParseTimeout = errors.New("parsing timed out")
+
+// ErrParseCancelled is returned by [Parser.ParseCtx] and
+// [Parser.ParseWithOptions] when parsing was halted because the parser's
+// [Parser.CancelFlag] was set.
+var ErrParseCancelled = errors.New("parsing was cancelled")
+
+// ErrParseAborted is returned by [Parser.ParseWithOptions] when parsing was
+// halted because the [ParseOptions.ProgressCallback] returned `true`.
+var ErrParseAborted = errors.New("parsing was aborted by the progress callback")
+
+// ErrNoLanguage is returned by [Parser.ParseStrict], [Parser.ParseCtx] and
+// [Parser.ParseWithOptions] when the parser has no language to parse with.
+var ErrNoLanguage = errors.New("parser has no language")
+
+// The error for a parse that was halted for a reason that is not known.
+var errParseHalted = errors.New("parsing was halted")
+
 // A stateful object that this is used to produce a [Tree] based on some
 // source code.
+//
+// A parser is not safe for concurrent use. Parsing, or changing the
+// parser's configuration, while another goroutine is doing the same panics.
 type Parser struct {
-	_inner *C.TSParser
+	_inner        *C.TSParser
+	dotGraphsDone chan struct{}
+	cancelFlag    *CancelFlag
+	inUse         atomic.Bool
+
+	liveObject
 }
 
 // A stateful object that is passed into the progress callback [ParseOptions.ProgressCallback]
@@ -52,18 +86,54 @@ type ParseOptions struct {
 	// parsing and check where the parser is at in the document. The progress callback
 	// takes a single argument, which is a [ParseState] representing the current
 	// state of the parser.
+	//
+	// When parsing is cancelled this way, [Parser.ParseWithOptions] returns
+	// a nil tree and [ErrParseAborted]. The next parse resumes where this one left off unless [Parser.Reset]
+	// is called first.
 	ProgressCallback func(ParseState) bool
 }
 
 // Create a new parser.
 func NewParser() *Parser {
-	return &Parser{_inner: C.ts_parser_new()}
+	return addCleanup(&Parser{_inner: C.ts_parser_new()}, (*Parser).Close)
 }
 
+// Delete the parser, freeing all of the memory that it used.
+//
+// Closing a parser more than once has no effect, but calling any other
+// method on a closed parser panics.
 func (p *Parser) Close() {
-	p.StopPrintingDotGraphs()
-	p.SetLogger(nil)
+	if p._inner == nil {
+		return
+	}
+	p.acquire()
+	defer p.release()
+	removeCleanup(p)
+	p.stopPrintingDotGraphs()
+	p.setLogger(nil)
 	C.ts_parser_delete(p._inner)
+	p._inner = nil
+}
+
+func (p *Parser) inner() *C.TSParser {
+	if p._inner == nil {
+		panic("tree_sitter: use of closed Parser")
+	}
+	return p._inner
+}
+
+// Mark the parser as in use for the duration of a call that mutates it.
+//
+// A [Parser] must not be used from multiple goroutines at the same time.
+// Doing so would corrupt its memory, so it panics instead.
+func (p *Parser) acquire() {
+	if !p.inUse.CompareAndSwap(false, true) {
+		panic("tree_sitter: Parser used concurrently from multiple goroutines")
+	}
+}
+
+func (p *Parser) release() {
+	p.inUse.Store(false)
 }
 
 // Set the language that the parser should use for parsing.
@@ -72,20 +142,23 @@ func (p *Parser) Close() {
 // assigned. Nil means assignment succeeded. Non-nil means there was a
 // version mismatch: the language was generated with an incompatible
 // version of the Tree-sitter CLI. Check the language's ABI version using
-// [Language.Version] and compare it to this library's [LANGUAGE_VERSION] and
-// [MIN_COMPATIBLE_LANGUAGE_VERSION] constants.
+// [Language.AbiVersion] and compare it to this library's [LANGUAGE_VERSION] and
+// [MIN_COMPATIBLE_LANGUAGE_VERSION] constants. The returned [LanguageError]
+// mentions both, along with the language's name when it is known.
 func (p *Parser) SetLanguage(l *Language) error {
 	version := l.AbiVersion()
 	if version >= MIN_COMPATIBLE_LANGUAGE_VERSION && version <= LANGUAGE_VERSION {
-		C.ts_parser_set_language(p._inner, l.Inner)
+		p.acquire()
+		defer p.release()
+		C.ts_parser_set_language(p.inner(), l.Inner)
 		return nil
 	}
-	return &LanguageError{version}
+	return &LanguageError{version: version, name: l.Name()}
 }
 
 // Get the parser's current language.
 func (p *Parser) Language() *Language {
-	ptr := C.ts_parser_language(p._inner)
+	ptr := C.ts_parser_language(p.inner())
 	if ptr == nil {
 		return nil
 	}
@@ -110,15 +183,17 @@ func logCallback(payload unsafe.Pointer, cLogType C.TSLogType, cMessage *C.char)
 }
 
 // Set the logging callback that a parser should use during parsing.
+//
+// Passing `nil` removes the current logger. The previous logger, if any, is
+// released so that it can be garbage collected.
 func (p *Parser) SetLogger(logger Logger) {
-	prevLogger := C.ts_parser_logger(p._inner)
-	if prevLogger.payload != nil {
-		// Clean up the old logger
-		oldLogger := (*Logger)(prevLogger.payload)
-		if oldLogger != nil {
-			oldLogger = nil
-		}
-	}
+	p.acquire()
+	defer p.release()
+	p.setLogger(logger)
+}
+
+func (p *Parser) setLogger(logger Logger) {
+	prevLogger := C.ts_parser_logger(p.inner())
 
 	// Prepare the new logger
 	var cLogger C.TSLogger
@@ -139,26 +214,79 @@ func (p *Parser) SetLogger(logger Logger) {
 	}
 
 	// Set the new logger in the parser
-	C.ts_parser_set_logger(p._inner, cLogger)
+	C.ts_parser_set_logger(p.inner(), cLogger)
+
+	// Clean up the old logger
+	if prevLogger.payload != nil {
+		pointer.Unref(prevLogger.payload)
+	}
 }
 
 // Get the parser's current logger.
+//
+// This returns `nil` if no logger has been set.
 func (p *Parser) Logger() *Logger {
-	logger := C.ts_parser_logger(p._inner)
-	return (*Logger)(logger.payload)
+	cLogger := C.ts_parser_logger(p.inner())
+	if cLogger.payload == nil {
+		return nil
+	}
+	logger := pointer.Restore(cLogger.payload).(Logger)
+	return &logger
 }
 
 // Set the destination to which the parser should write debugging graphs
 // during parsing. The graphs are formatted in the DOT language. You may
 // want to pipe these graphs directly to a `dot(1)` process in order to
 // generate SVG output.
-func (p *Parser) PrintDotGraphs(file *os.File) {
-	C.ts_parser_print_dot_graphs(p._inner, C.int(dupeFD(file.Fd())))
+//
+// If `w` is not an [*os.File], the graphs are copied into it from a pipe by a
+// background goroutine. Output is buffered, so it is only guaranteed to have
+// been written once [Parser.StopPrintingDotGraphs] or [Parser.Close] returns.
+func (p *Parser) PrintDotGraphs(w io.Writer) error {
+	p.acquire()
+	defer p.release()
+	p.stopPrintingDotGraphs()
+
+	if file, ok := w.(*os.File); ok {
+		C.ts_parser_print_dot_graphs(p.inner(), C.int(dupeFD(file.Fd())))
+		return nil
+	}
+
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer r.Close()
+		if _, err := io.Copy(w, r); err != nil {
+			// Keep draining so that the parser never blocks on a full pipe.
+			io.Copy(io.Discard, r)
+		}
+	}()
+	C.ts_parser_print_dot_graphs(p.inner(), C.int(dupeFD(pw.Fd())))
+	pw.Close()
+	p.dotGraphsDone = done
+	return nil
 }
 
 // Stop the parser from printing debugging graphs while parsing.
+//
+// This flushes any pending output and waits for it to be written to the
+// destination given to [Parser.PrintDotGraphs].
 func (p *Parser) StopPrintingDotGraphs() {
-	C.ts_parser_print_dot_graphs(p._inner, C.int(-1))
+	p.acquire()
+	defer p.release()
+	p.stopPrintingDotGraphs()
+}
+
+func (p *Parser) stopPrintingDotGraphs() {
+	C.ts_parser_print_dot_graphs(p.inner(), C.int(-1))
+	if p.dotGraphsDone != nil {
+		<-p.dotGraphsDone
+		p.dotGraphsDone = nil
+	}
 }
 
 // Parse a slice of UTF8 text.
@@ -170,42 +298,137 @@ func (p *Parser) StopPrintingDotGraphs() {
 //     the new text using [Tree.Edit].
 func (p *Parser) Parse(text []byte, oldTree *Tree) *Tree {
 	length := len(text)
-	return p.ParseWithOptions(func(i int, _ Point) []byte {
+	tree, _ := p.ParseWithOptions(func(i int, _ Point) []byte {
 		if i < length {
 			return text[i:]
 		}
 		return []byte{}
 	}, oldTree, nil)
+	return tree
 }
 
-// Deprecated: Use [Parser.ParseWithOptions] instead, and handle cancellation in the callback, this will be removed in 0.26.
+// Parse a slice of UTF8 text, returning a [*SyntaxError] if the resulting
+// tree contains any `ERROR` or `MISSING` nodes.
 //
-// Parse a slice of UTF8 text.
+// The arguments are the same as for [Parser.Parse]. The tree is returned
+// even when there are syntax errors, so that it can be inspected or used as
+// the `oldTree` of a later parse; it must be closed either way. If the
+// parser has no language, or parsing was halted, this returns a nil tree and
+// an error that gives the reason, as [Parser.ParseWithOptions] does.
+func (p *Parser) ParseStrict(text []byte, oldTree *Tree) (*Tree, error) {
+	length := len(text)
+	tree, err := p.ParseWithOptions(func(i int, _ Point) []byte {
+		if i < length {
+			return text[i:]
+		}
+		return []byte{}
+	}, oldTree, nil)
+	if err != nil {
+		return nil, err
+	}
+	if root := tree.RootNode(); root.HasError() {
+		return tree, &SyntaxError{sites: collectErrorSites(root)}
+	}
+	return tree, nil
+}
+
+// Parse a slice of UTF8 text, halting early if the given context is done.
 //
 // # Arguments:
-//   - `ctx` The context to parse with.
+//   - `ctx` The context to parse with. The parser checks it periodically
+//     while parsing, so a cancelled context or an expired deadline stops a
+//     long-running parse promptly.
 //   - `text` The UTF8-encoded text to parse.
 //   - `old_tree` A previous syntax tree parsed from the same document. If the text of the
 //     document has changed since `old_tree` was created, then you must edit `old_tree` to match
 //     the new text using [Tree.Edit].
-func (p *Parser) ParseCtx(ctx context.Context, text []byte, oldTree *Tree) *Tree {
-	finish := make(chan struct{})
-
-	if ctx.Done() != nil {
-		go func() {
-			select {
-			case <-ctx.Done():
-				atomic.StoreUintptr(p.CancellationFlag(), 1)
-			case <-finish:
-				return
-			}
-		}()
+//
+// If parsing was halted because of the context, the returned error is the
+// context's error ([context.Canceled] or [context.DeadlineExceeded]) and the
+// parser is reset, so it can be used to parse another document right away.
+// If parsing was halted because the parser's [Parser.CancelFlag] was set,
+// the returned error is [ErrParseCancelled]. If it was halted because of the
+// limit given to [SetMemoryLimit], it is [ErrMemoryLimitExceeded], and if
+// it was halted because of the parser's [Parser.Timeout], it is
+// [ErrParseTimeout]. If the parser has no language, it is [ErrNoLanguage].
+func (p *Parser) ParseCtx(ctx context.Context, text []byte, oldTree *Tree) (*Tree, error) {
+	length := len(text)
+	tree, err := p.ParseWithOptions(func(i int, _ Point) []byte {
+		if i < length {
+			return text[i:]
+		}
+		return []byte{}
+	}, oldTree, &ParseOptions{
+		ProgressCallback: func(ParseState) bool {
+			return ctx.Err() != nil
+		},
+	})
+	if err != nil && ctx.Err() != nil {
+		p.Reset()
+		return nil, ctx.Err()
 	}
+	return tree, err
+}
 
-	tree := p.Parse(text, oldTree)
-	close(finish)
+// The number of bytes that [Parser.ParseReader] tries to read at a time.
+const readerChunkSize = 4096
 
-	return tree
+// Parse UTF8 text read from an [io.ReaderAt].
+//
+// The text is read in chunks as the parser asks for it, so the document
+// never has to be loaded into memory as a whole. Short reads are retried
+// until a full chunk has been read or the reader reports [io.EOF].
+//
+// # Arguments:
+//   - `r` The reader to read UTF8-encoded text from.
+//   - `old_tree` A previous syntax tree parsed from the same document. If the text of the
+//     document has changed since `old_tree` was created, then you must edit `old_tree` to match
+//     the new text using [Tree.Edit].
+//
+// If reading fails with an error other than [io.EOF], no more text is read,
+// and the read error is returned instead of a tree built from truncated
+// text. If parsing is halted, the error is the one that
+// [Parser.ParseWithOptions] gives. Either way, the parser is left ready to
+// parse another document.
+func (p *Parser) ParseReader(r io.ReaderAt, oldTree *Tree) (*Tree, error) {
+	buffer := make([]byte, readerChunkSize)
+	var readErr error
+	tree, err := p.ParseWithOptions(func(offset int, _ Point) []byte {
+		if readErr != nil {
+			return []byte{}
+		}
+		n := 0
+		for n < len(buffer) {
+			read, err := r.ReadAt(buffer[n:], int64(offset+n))
+			n += read
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				readErr = err
+				return []byte{}
+			}
+			if read == 0 {
+				readErr = io.ErrNoProgress
+				return []byte{}
+			}
+		}
+		return buffer[:n]
+	}, oldTree, nil)
+	if err != nil {
+		if !errors.Is(err, ErrNoLanguage) {
+			p.Reset()
+		}
+		return nil, err
+	}
+	// The parse is left to finish on the truncated text rather than being
+	// halted, since a parse halted while it balances the tree cannot be
+	// resumed.
+	if readErr != nil {
+		tree.Close()
+		return nil, readErr
+	}
+	return tree, nil
 }
 
 // Deprecated: Use [Parser.ParseUTF16LE] or [Parser.ParseUTF16BE] instead.
@@ -226,13 +449,17 @@ func (p *Parser) ParseUTF16(text []uint16, oldTree *Tree) *Tree {
 	}, oldTree)
 }
 
-// / Parse a slice of UTF16 little-endian text.
-// /
-// / # Arguments:
-// / * `text` The UTF16-encoded text to parse.
-// / * `old_tree` A previous syntax tree parsed from the same document. If the text of the
-// /   document has changed since `old_tree` was created, then you must edit `old_tree` to match
-// /   the new text using [Tree.Edit].
+// Parse a slice of UTF16 little-endian text.
+//
+// # Arguments:
+//   - `text` The UTF16-encoded text to parse.
+//   - `old_tree` A previous syntax tree parsed from the same document. If the text of the
+//     document has changed since `old_tree` was created, then you must edit `old_tree` to match
+//     the new text using [Tree.Edit].
+//
+// Byte offsets and [Point] columns in the resulting tree count bytes, not
+// code units, so they are twice the index into `text`. Surrogate pairs take
+// up four bytes. Use [Node.Utf16Text] to get a node's text from `text`.
 func (p *Parser) ParseUTF16LE(text []uint16, oldTree *Tree) *Tree {
 	length := len(text)
 	return p.ParseUTF16LEWith(func(i int, _ Point) []uint16 {
@@ -243,13 +470,16 @@ func (p *Parser) ParseUTF16LE(text []uint16, oldTree *Tree) *Tree {
 	}, oldTree)
 }
 
-// / Parse a slice of UTF16 big-endian text.
-// /
-// / # Arguments:
-// / * `text` The UTF16-encoded text to parse.
-// / * `old_tree` A previous syntax tree parsed from the same document. If the text of the
-// /   document has changed since `old_tree` was created, then you must edit `old_tree` to match
-// /   the new text using [Tree.Edit].
+// Parse a slice of UTF16 big-endian text.
+//
+// # Arguments:
+//   - `text` The UTF16-encoded text to parse.
+//   - `old_tree` A previous syntax tree parsed from the same document. If the text of the
+//     document has changed since `old_tree` was created, then you must edit `old_tree` to match
+//     the new text using [Tree.Edit].
+//
+// As with [Parser.ParseUTF16LE], offsets and columns in the resulting tree
+// count bytes rather than code units.
 func (p *Parser) ParseUTF16BE(text []uint16, oldTree *Tree) *Tree {
 	length := len(text)
 	return p.ParseUTF16BEWith(func(i int, _ Point) []uint16 {
@@ -302,7 +532,8 @@ func parserProgressCallback(state *C.TSParseState) C.bool {
 //     document has changed since `old_tree` was created, then you must edit `old_tree` to match
 //     the new text using [Tree.Edit].
 func (p *Parser) ParseWith(callback func(int, Point) []byte, oldTree *Tree) *Tree {
-	return p.ParseWithOptions(callback, oldTree, nil)
+	tree, _ := p.ParseWithOptions(callback, oldTree, nil)
+	return tree
 }
 
 // Parse UTF8 text provided in chunks by a callback.
@@ -316,7 +547,14 @@ func (p *Parser) ParseWith(callback func(int, Point) []byte, oldTree *Tree) *Tre
 //     document has changed since `old_tree` was created, then you must edit `old_tree` to match
 //     the new text using [Tree.Edit].
 //   - `options` Options for parsing the text. This can be used to set a progress callback, or context.
-func (p *Parser) ParseWithOptions(callback func(int, Point) []byte, oldTree *Tree, options *ParseOptions) *Tree {
+//
+// If parsing was halted, the returned tree is nil and the error gives the
+// reason: [ErrParseAborted] if the progress callback returned `true`,
+// [ErrParseCancelled] if the parser's [Parser.CancelFlag] was set,
+// [ErrMemoryLimitExceeded] if the limit given to [SetMemoryLimit] was
+// exceeded, and [ErrParseTimeout] if the parser's [Parser.Timeout] elapsed.
+// If the parser has no language, the error is [ErrNoLanguage].
+func (p *Parser) ParseWithOptions(callback func(int, Point) []byte, oldTree *Tree, options *ParseOptions) (*Tree, error) {
 	payload := payload[byte]{
 		callback: callback,
 		text:     nil,
@@ -338,26 +576,46 @@ func (p *Parser) ParseWithOptions(callback func(int, Point) []byte, oldTree *Tre
 		encoding: C.TSInputEncodingUTF8,
 	}
 
-	var cOldTree *C.TSTree
-	if oldTree != nil {
-		cOldTree = oldTree._inner
+	start := time.Now()
+	aborted := false
+	if options != nil && options.ProgressCallback != nil {
+		progress := options.ProgressCallback
+		options = &ParseOptions{
+			ProgressCallback: func(state ParseState) bool {
+				aborted = aborted || progress(state)
+				return aborted
+			},
+		}
 	}
 
-	var cOptions C.TSParseOptions
-	if options != nil {
-		cOptions = C.TSParseOptions{
-			progress_callback: (*[0]byte)(C.parserProgressCallback),
-			payload:           pointer.Save(options),
+	tree := p.parseInput(cInput, oldTree, options, nil)
+	if tree == nil {
+		if aborted {
+			return nil, ErrParseAborted
 		}
+		return nil, p.haltError(start)
 	}
+	return tree, nil
+}
 
-	cNewTree := C.ts_parser_parse_with_options(p._inner, cOldTree, cInput, cOptions)
-
-	if cNewTree != nil {
-		return newTree(cNewTree)
+// Get the reason why a parse that started at `start` returned no tree,
+// other than its progress callback.
+func (p *Parser) haltError(start time.Time) error {
+	if p.Language() == nil {
+		return ErrNoLanguage
 	}
-
-	return nil
+	if p.cancelFlag != nil && p.cancelFlag.IsCancelled() {
+		return ErrParseCancelled
+	}
+	if memoryLimitExceeded() {
+		return ErrMemoryLimitExceeded
+	}
+	// The timeout applies to each call, so it can only have halted the
+	// parse if this call took at least that long.
+	if timeout := p.Timeout(); timeout > 0 && time.Since(start) >= timeout {
+		return ErrParseTimeout
+	}
+	return errParseHalted
 }
 
 func cStringUTF16(s []uint16) *C.char {
@@ -465,26 +723,7 @@ func (p *Parser) ParseUTF16LEWithOptions(callback func(int, Point) []uint16, old
 		encoding: C.TSInputEncodingUTF16LE,
 	}
 
-	var cOldTree *C.TSTree
-	if oldTree != nil {
-		cOldTree = oldTree._inner
-	}
-
-	var cOptions C.TSParseOptions
-	if options != nil {
-		cOptions = C.TSParseOptions{
-			progress_callback: (*[0]byte)(C.parserProgressCallback),
-			payload:           pointer.Save(options),
-		}
-	}
-
-	cNewTree := C.ts_parser_parse_with_options(p._inner, cOldTree, cInput, cOptions)
-
-	if cNewTree != nil {
-		return newTree(cNewTree)
-	}
-
-	return nil
+	return p.parseInput(cInput, oldTree, options, nil)
 }
 
 // Deprecated: Use [Parser.ParseUTF16BEWithOptions] instead, this will be removed in 0.26.
@@ -536,26 +775,7 @@ func (p *Parser) ParseUTF16BEWithOptions(callback func(int, Point) []uint16, old
 		encoding: C.TSInputEncodingUTF16BE,
 	}
 
-	var cOldTree *C.TSTree
-	if oldTree != nil {
-		cOldTree = oldTree._inner
-	}
-
-	var cOptions C.TSParseOptions
-	if options != nil {
-		cOptions = C.TSParseOptions{
-			progress_callback: (*[0]byte)(C.parserProgressCallback),
-			payload:           pointer.Save(options),
-		}
-	}
-
-	cNewTree := C.ts_parser_parse_with_options(p._inner, cOldTree, cInput, cOptions)
-
-	if cNewTree != nil {
-		return newTree(cNewTree)
-	}
-
-	return nil
+	return p.parseInput(cInput, oldTree, options, nil)
 }
 
 // Decoder interface defines the required method for custom decoding
@@ -609,6 +829,12 @@ func (p *Parser) ParseCustomEncoding(
 		cStrings: make([]*C.char, 0),
 	}
 
+	defer func() {
+		for _, cString := range payload.cStrings {
+			go_free(unsafe.Pointer(cString))
+		}
+	}()
+
 	cptr := pointer.Save(payload)
 	defer pointer.Unref(cptr)
 
@@ -619,20 +845,43 @@ func (p *Parser) ParseCustomEncoding(
 		decode:   (*[0]byte)(decode),
 	}
 
+	return p.parseInput(cInput, oldTree, options, nil)
+}
+
+// Run the parser over the given input, returning `nil` if parsing was halted.
+// `decoder` is a [Decoder] saved with [pointer.Save] that decodes the input,
+// or nil if the input is in an encoding that the C library decodes.
+func (p *Parser) parseInput(cInput C.TSInput, oldTree *Tree, options *ParseOptions, decoder unsafe.Pointer) *Tree {
+	p.acquire()
+	defer p.release()
+
 	var cOldTree *C.TSTree
 	if oldTree != nil {
-		cOldTree = oldTree._inner
+		cOldTree = oldTree.inner()
+	}
+
+	if memoryLimit.Load() > 0 {
+		options = withMemoryLimit(options)
 	}
 
 	var cOptions C.TSParseOptions
-	if options != nil {
+	if options != nil && options.ProgressCallback != nil {
+		optionsPtr := pointer.Save(options)
+		defer pointer.Unref(optionsPtr)
 		cOptions = C.TSParseOptions{
 			progress_callback: (*[0]byte)(C.parserProgressCallback),
-			payload:           pointer.Save(options),
+			payload:           optionsPtr,
 		}
 	}
 
-	cNewTree := C.ts_parser_parse_with_options(p._inner, cOldTree, cInput, cOptions)
+	var cNewTree *C.TSTree
+	if decoder != nil {
+		cNewTree = C.ts_go_parse_with_decoder(p.inner(), cOldTree, cInput, cOptions, decoder)
+	} else {
+		cNewTree = C.ts_parser_parse_with_options(p.inner(), cOldTree, cInput, cOptions)
+	}
+	// The old tree must not be cleaned up while the parser reads from it.
+	runtime.KeepAlive(oldTree)
 
 	if cNewTree != nil {
 		return newTree(cNewTree)
@@ -641,6 +890,20 @@ func (p *Parser) ParseCustomEncoding(
 	return nil
 }
 
+// Wrap parse options so that parsing also halts once the memory limit set
+// with [SetMemoryLimit] is exceeded.
+func withMemoryLimit(options *ParseOptions) *ParseOptions {
+	var progress func(ParseState) bool
+	if options != nil {
+		progress = options.ProgressCallback
+	}
+	return &ParseOptions{
+		ProgressCallback: func(state ParseState) bool {
+			return memoryLimitExceeded() || (progress != nil && progress(state))
+		},
+	}
+}
+
 // Instruct the parser to start the next parse from the beginning.
 //
 // If the parser previously failed because of a timeout or a cancellation,
@@ -649,7 +912,9 @@ func (p *Parser) ParseCustomEncoding(
 // want to resume, and instead intend to use this parser to parse some
 // other document, you must call `Reset` first.
 func (p *Parser) Reset() {
-	C.ts_parser_reset(p._inner)
+	p.acquire()
+	defer p.release()
+	C.ts_parser_reset(p.inner())
 }
 
 // Deprecated: Use [Parser.ParseWithOptions] and pass in a callback instead, this will be removed in 0.26.
@@ -658,7 +923,7 @@ func (p *Parser) Reset() {
 //
 // This is set via [Parser.SetTimeoutMicros].
 func (p *Parser) TimeoutMicros() uint64 {
-	return uint64(C.ts_parser_timeout_micros(p._inner))
+	return uint64(C.ts_parser_timeout_micros(p.inner()))
 }
 
 // Deprecated: Use [Parser.ParseWithOptions] and pass in a callback instead, this will be removed in 0.26.
@@ -667,15 +932,45 @@ func (p *Parser) TimeoutMicros() uint64 {
 // to take before halting.
 //
 // If parsing takes longer than this, it will halt early, returning `nil`.
-// See [Parser.Parse] for more information.
+// The next parse resumes where the halted one left off, so call
+// [Parser.Reset] before parsing a different document.
 func (p *Parser) SetTimeoutMicros(timeoutMicros uint64) {
-	C.ts_parser_set_timeout_micros(p._inner, C.uint64_t(timeoutMicros))
+	p.acquire()
+	defer p.release()
+	C.ts_parser_set_timeout_micros(p.inner(), C.uint64_t(timeoutMicros))
+}
+
+// Get the maximum duration that parsing is allowed to take.
+//
+// This is set via [Parser.SetTimeout]. A zero duration means there is no
+// timeout.
+func (p *Parser) Timeout() time.Duration {
+	return time.Duration(C.ts_parser_timeout_micros(p.inner())) * time.Microsecond
+}
+
+// Set the maximum duration that parsing should be allowed to take before
+// halting. The duration is rounded down to whole microseconds, except that
+// a duration shorter than a microsecond is rounded up to one, and a zero
+// or negative duration disables the timeout.
+//
+// If parsing takes longer than this, it will halt early, returning `nil`
+// from [Parser.Parse], or [ErrParseTimeout] from [Parser.ParseCtx]. By
+// default, the next parse resumes where the timed out one left off; call
+// [Parser.Reset] first to parse a different document instead.
+func (p *Parser) SetTimeout(timeout time.Duration) {
+	var micros uint64
+	if timeout > 0 {
+		micros = max(uint64(timeout.Microseconds()), 1)
+	}
+	p.acquire()
+	defer p.release()
+	C.ts_parser_set_timeout_micros(p.inner(), C.uint64_t(micros))
 }
 
 // Get the ranges of text that the parser will include when parsing.
 func (p *Parser) IncludedRanges() []Range {
 	var count C.uint
-	ptr := C.ts_parser_included_ranges(p._inner, &count)
+	ptr := C.ts_parser_included_ranges(p.inner(), &count)
 	ranges := make([]Range, int(count))
 	for i := uintptr(0); i < uintptr(count); i++ {
 		val := *(*C.TSRange)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + i*unsafe.Sizeof(*ptr)))
@@ -720,7 +1015,9 @@ func (p *Parser) SetIncludedRanges(ranges []Range) error {
 	if len(tsRanges) > 0 {
 		cPtr = &tsRanges[0]
 	}
-	result := C.ts_parser_set_included_ranges(p._inner, cPtr, C.uint32_t(len(tsRanges)))
+	p.acquire()
+	result := C.ts_parser_set_included_ranges(p.inner(), cPtr, C.uint32_t(len(tsRanges)))
+	p.release()
 	if result {
 		return nil
 	}
@@ -734,21 +1031,49 @@ func (p *Parser) SetIncludedRanges(ranges []Range) error {
 	return &IncludedRangesError{0}
 }
 
-// Deprecated: Use [Parser.ParseWithOptions] and pass in a callback instead, this will be removed in 0.26.
+// Deprecated: Use [Parser.CancelFlag] instead, this will be removed in 0.26.
 //
 // Get the parser's current cancellation flag pointer.
 func (p *Parser) CancellationFlag() *uintptr {
-	return (*uintptr)(unsafe.Pointer(C.ts_parser_cancellation_flag(p._inner)))
+	return (*uintptr)(unsafe.Pointer(C.ts_parser_cancellation_flag(p.inner())))
 }
 
-// Deprecated: Use [Parser.ParseWithOptions] and pass in a callback instead, this will be removed in 0.26.
+// Deprecated: Use [Parser.SetCancelFlag] instead, this will be removed in 0.26.
 //
 // Set the parser's current cancellation flag pointer.
 //
 // If a pointer is assigned, then the parser will periodically read from
 // this pointer during parsing. If it reads a non-zero value, it will halt
-// early, returning `nil`. See [Parser.Parse] for more
-// information.
+// early, returning `nil`. The next parse resumes where the halted one left
+// off, so call [Parser.Reset] before parsing a different document.
 func (p *Parser) SetCancellationFlag(flag *uintptr) {
-	C.ts_parser_set_cancellation_flag(p._inner, (*C.size_t)(unsafe.Pointer(flag)))
+	p.acquire()
+	defer p.release()
+	C.ts_parser_set_cancellation_flag(p.inner(), (*C.size_t)(unsafe.Pointer(flag)))
+	p.cancelFlag = nil
+}
+
+// Get the flag that was assigned with [Parser.SetCancelFlag], or `nil` if
+// there is none.
+func (p *Parser) CancelFlag() *CancelFlag {
+	return p.cancelFlag
+}
+
+// Assign a flag that halts parsing once it is set, or remove the current
+// one by passing `nil`.
+//
+// The parser checks the flag periodically while parsing. Once it is set,
+// parsing halts early, returning `nil` from [Parser.Parse], or
+// [ErrParseCancelled] from [Parser.ParseCtx]. Like other halted parses, the
+// next parse resumes where it left off unless [Parser.Reset] is called
+// first. The flag must stay open for as long as it is assigned.
+func (p *Parser) SetCancelFlag(flag *CancelFlag) {
+	var ptr *C.size_t
+	if flag != nil {
+		ptr = (*C.size_t)(unsafe.Pointer(flag.value()))
+	}
+	p.acquire()
+	defer p.release()
+	C.ts_parser_set_cancellation_flag(p.inner(), ptr)
+	p.cancelFlag = flag
 }
@@ -0,0 +1,177 @@
+package tree_sitter
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPoolClosed is returned by [ParserPool.Get] once the pool has been
+// closed.
+var ErrPoolClosed = errors.New("parser pool is closed")
+
+// A PoolPolicy determines what [ParserPool.Get] does when every parser in
+// the pool is already in use.
+type PoolPolicy int
+
+const (
+	// Wait for another caller to return a parser with [ParserPool.Put].
+	PoolBlock PoolPolicy = iota
+
+	// Create a new parser beyond the pool's maximum. Such parsers are
+	// closed, rather than kept, when they are returned and the pool is
+	// already holding its maximum number of idle parsers.
+	PoolGrow
+)
+
+// A ParserPool hands out parsers bound to a single [Language], so that
+// parsers can be reused across requests instead of being created and
+// destroyed for each one.
+//
+// Unlike a [Parser], a pool is safe for concurrent use.
+type ParserPool struct {
+	language *Language
+	max      int
+	policy   PoolPolicy
+
+	mu     sync.Mutex
+	idle   chan *Parser
+	size   int
+	closed bool
+}
+
+// Create a new pool of parsers for the given language, holding at most
+// `max` parsers. Parsers are created lazily, as they are needed.
+//
+// The pool uses the [PoolBlock] policy by default; see
+// [ParserPool.SetPolicy].
+func NewParserPool(language *Language, max int) *ParserPool {
+	if max < 1 {
+		max = 1
+	}
+	return &ParserPool{
+		language: language,
+		max:      max,
+		idle:     make(chan *Parser, max),
+	}
+}
+
+// Get the policy that determines what happens when the pool is exhausted.
+func (pp *ParserPool) Policy() PoolPolicy {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return pp.policy
+}
+
+// Set the policy that determines what [ParserPool.Get] does when every
+// parser in the pool is already in use.
+func (pp *ParserPool) SetPolicy(policy PoolPolicy) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	pp.policy = policy
+}
+
+// Get a parser from the pool, with the pool's language already assigned.
+//
+// If no parser is idle and the pool is at its maximum size, the pool's
+// [PoolPolicy] decides whether to wait or to create a new parser. While
+// waiting, Get returns the context's error if it is done first, or
+// [ErrPoolClosed] if the pool is closed.
+//
+// The parser must be given back with [ParserPool.Put] rather than closed.
+func (pp *ParserPool) Get(ctx context.Context) (*Parser, error) {
+	select {
+	case p, ok := <-pp.idle:
+		if ok {
+			return p, nil
+		}
+	default:
+	}
+
+	pp.mu.Lock()
+	if pp.closed {
+		pp.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	if pp.size < pp.max || pp.policy == PoolGrow {
+		pp.size++
+		pp.mu.Unlock()
+		return pp.newParser()
+	}
+	pp.mu.Unlock()
+
+	select {
+	case p, ok := <-pp.idle:
+		if !ok {
+			return nil, ErrPoolClosed
+		}
+		return p, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (pp *ParserPool) newParser() (*Parser, error) {
+	p := NewParser()
+	if err := p.SetLanguage(pp.language); err != nil {
+		p.Close()
+		pp.mu.Lock()
+		pp.size--
+		pp.mu.Unlock()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Return a parser obtained from [ParserPool.Get] to the pool.
+//
+// The parser is reset before it is handed out again: any partial parse is
+// discarded, and its timeout, included ranges, cancellation flag, logger
+// and DOT graph output are cleared. If it was given a different language,
+// the pool's language is assigned again.
+//
+// A parser returned to a closed or full pool is closed instead.
+func (pp *ParserPool) Put(p *Parser) {
+	if p == nil {
+		return
+	}
+	p.Reset()
+	p.SetTimeoutMicros(0)
+	p.SetCancelFlag(nil)
+	p.SetLogger(nil)
+	p.StopPrintingDotGraphs()
+	_ = p.SetIncludedRanges(nil)
+	if lang := p.Language(); lang == nil || lang.Inner != pp.language.Inner {
+		_ = p.SetLanguage(pp.language)
+	}
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if !pp.closed {
+		select {
+		case pp.idle <- p:
+			return
+		default:
+		}
+	}
+	pp.size--
+	p.Close()
+}
+
+// Close every idle parser in the pool. Parsers that are still in use are
+// closed when they are returned with [ParserPool.Put].
+//
+// After Close, [ParserPool.Get] returns [ErrPoolClosed].
+func (pp *ParserPool) Close() {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if pp.closed {
+		return
+	}
+	pp.closed = true
+	close(pp.idle)
+	for p := range pp.idle {
+		pp.size--
+		p.Close()
+	}
+}
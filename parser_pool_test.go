@@ -0,0 +1,161 @@
+package tree_sitter_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestParserPoolReusesParsers(t *testing.T) {
+	pool := NewParserPool(getLanguage("json"), 2)
+	defer pool.Close()
+
+	parser, err := pool.Get(context.Background())
+	assert.NoError(t, err)
+	tree := parser.Parse([]byte(`{"a": 1}`), nil)
+	assert.Equal(t, "(document (object (pair key: (string (string_content)) value: (number))))", tree.RootNode().ToSexp())
+	tree.Close()
+	pool.Put(parser)
+
+	again, err := pool.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, parser, again)
+	pool.Put(again)
+}
+
+func TestParserPoolResetsParsersOnPut(t *testing.T) {
+	pool := NewParserPool(getLanguage("json"), 1)
+	defer pool.Close()
+
+	parser, err := pool.Get(context.Background())
+	assert.NoError(t, err)
+
+	parser.SetTimeout(time.Second)
+	flag := uintptr(0)
+	parser.SetCancellationFlag(&flag)
+	assert.NoError(t, parser.SetIncludedRanges([]Range{simpleRange(1, 3)}))
+	parser.SetLanguage(getLanguage("rust"))
+
+	// Leave a parse unfinished.
+	parser.ParseWithOptions(func(int, Point) []byte { return []byte("[1, 2, 3, 4, 5]") }, nil, &ParseOptions{
+		ProgressCallback: func(ParseState) bool { return true },
+	})
+	pool.Put(parser)
+
+	parser, err = pool.Get(context.Background())
+	assert.NoError(t, err)
+	defer pool.Put(parser)
+
+	assert.Zero(t, parser.Timeout())
+	assert.Nil(t, parser.CancellationFlag())
+	assert.Len(t, parser.IncludedRanges(), 1)
+	assert.Equal(t, uint(0), parser.IncludedRanges()[0].StartByte)
+	assert.Equal(t, getLanguage("json").Inner, parser.Language().Inner)
+
+	tree := parser.Parse([]byte("true"), nil)
+	defer tree.Close()
+	assert.Equal(t, "(document (true))", tree.RootNode().ToSexp())
+}
+
+func TestParserPoolBlocksWhenExhausted(t *testing.T) {
+	pool := NewParserPool(getLanguage("json"), 1)
+	defer pool.Close()
+
+	parser, err := pool.Get(context.Background())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Get(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	done := make(chan *Parser)
+	go func() {
+		p, err := pool.Get(context.Background())
+		assert.NoError(t, err)
+		done <- p
+	}()
+	pool.Put(parser)
+	assert.Same(t, parser, <-done)
+	pool.Put(parser)
+}
+
+func TestParserPoolGrowsWhenExhausted(t *testing.T) {
+	pool := NewParserPool(getLanguage("json"), 1)
+	defer pool.Close()
+	pool.SetPolicy(PoolGrow)
+
+	first, err := pool.Get(context.Background())
+	assert.NoError(t, err)
+	second, err := pool.Get(context.Background())
+	assert.NoError(t, err)
+	assert.NotSame(t, first, second)
+
+	pool.Put(first)
+	pool.Put(second)
+
+	third, err := pool.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, first, third)
+	pool.Put(third)
+}
+
+func TestParserPoolClose(t *testing.T) {
+	pool := NewParserPool(getLanguage("json"), 1)
+
+	parser, err := pool.Get(context.Background())
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := pool.Get(context.Background())
+		assert.ErrorIs(t, err, ErrPoolClosed)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	pool.Close()
+	wg.Wait()
+
+	// A parser returned after closing is closed rather than kept.
+	pool.Put(parser)
+	_, err = pool.Get(context.Background())
+	assert.ErrorIs(t, err, ErrPoolClosed)
+}
+
+func BenchmarkParseWithFreshParsers(b *testing.B) {
+	language := getLanguage("json")
+	source := []byte(`{"name": "tree-sitter", "tags": ["parser", "incremental"]}`)
+	b.ResetTimer()
+	for range b.N {
+		for range 10_000 {
+			parser := NewParser()
+			parser.SetLanguage(language)
+			parser.Parse(source, nil).Close()
+			parser.Close()
+		}
+	}
+}
+
+func BenchmarkParseWithPooledParsers(b *testing.B) {
+	pool := NewParserPool(getLanguage("json"), 1)
+	defer pool.Close()
+	source := []byte(`{"name": "tree-sitter", "tags": ["parser", "incremental"]}`)
+	ctx := context.Background()
+	b.ResetTimer()
+	for range b.N {
+		for range 10_000 {
+			parser, err := pool.Get(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			parser.Parse(source, nil).Close()
+			pool.Put(parser)
+		}
+	}
+}
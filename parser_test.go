@@ -2,8 +2,12 @@ package tree_sitter_test
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"runtime"
@@ -12,11 +16,13 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+	"unicode"
 	"unicode/utf16"
 	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 	. "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/go-tree-sitter/internal/fakelang"
 	tree_sitter_c "github.com/tree-sitter/tree-sitter-c/bindings/go"
 	tree_sitter_cpp "github.com/tree-sitter/tree-sitter-cpp/bindings/go"
 	tree_sitter_embedded_template "github.com/tree-sitter/tree-sitter-embedded-template/bindings/go"
@@ -109,7 +115,7 @@ func ExampleParser_ParseWithOptions() {
 		return sourceCode[offset:]
 	}
 
-	tree := parser.ParseWithOptions(readCallback, nil, nil)
+	tree, _ := parser.ParseWithOptions(readCallback, nil, nil)
 	defer tree.Close()
 
 	rootNode := tree.RootNode()
@@ -178,6 +184,40 @@ func TestParsingWithLogging(t *testing.T) {
 	assert.True(t, rowStartsFrom0)
 }
 
+func TestParsingWithReplacedLogger(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	assert.Nil(t, parser.Logger())
+
+	source := []byte("package main\n\nfunc main() {}\n")
+
+	var firstLogTypes []LogType
+	parser.SetLogger(func(logType LogType, _ string) {
+		firstLogTypes = append(firstLogTypes, logType)
+	})
+	assert.NotNil(t, parser.Logger())
+	parser.Parse(source, nil).Close()
+	assert.Contains(t, firstLogTypes, LogTypeParse)
+	assert.Contains(t, firstLogTypes, LogTypeLex)
+
+	secondCount := 0
+	parser.SetLogger(func(LogType, string) {
+		secondCount++
+	})
+	firstCount := len(firstLogTypes)
+	(*parser.Logger())(LogTypeParse, "manual")
+	parser.Parse(source, nil).Close()
+	assert.Equal(t, firstCount, len(firstLogTypes))
+	assert.Greater(t, secondCount, 1)
+
+	parser.SetLogger(nil)
+	assert.Nil(t, parser.Logger())
+	secondCount = 0
+	parser.Parse(source, nil).Close()
+	assert.Zero(t, secondCount)
+}
+
 func TestParsingWithDebugGraphEnabled(t *testing.T) {
 	hasZeroIndexedRow := func(s string) bool {
 		return strings.Contains(s, "position: 0,")
@@ -207,6 +247,33 @@ func TestParsingWithDebugGraphEnabled(t *testing.T) {
 	os.Remove(debugGraphFile.Name())
 }
 
+func TestParsingWithDebugGraphWriter(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("javascript"))
+
+	var buffer bytes.Buffer
+	assert.Nil(t, parser.PrintDotGraphs(&buffer))
+	parser.Parse([]byte("const zero = 0"), nil).Close()
+	parser.StopPrintingDotGraphs()
+
+	assert.Contains(t, buffer.String(), "digraph stack {")
+
+	// Once stopped, nothing more is written.
+	length := buffer.Len()
+	parser.Parse([]byte("const one = 1"), nil).Close()
+	assert.Equal(t, length, buffer.Len())
+
+	// Closing the parser flushes the output as well.
+	var other bytes.Buffer
+	closingParser := NewParser()
+	closingParser.SetLanguage(getLanguage("javascript"))
+	assert.Nil(t, closingParser.PrintDotGraphs(&other))
+	closingParser.Parse([]byte("let x"), nil).Close()
+	closingParser.Close()
+	assert.Contains(t, other.String(), "digraph stack {")
+}
+
 func TestParsingWithCustomUTF8Input(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -214,7 +281,7 @@ func TestParsingWithCustomUTF8Input(t *testing.T) {
 
 	lines := []string{"pub fn foo() {", "  1", "}"}
 
-	tree := parser.ParseWithOptions(func(_ int, position Point) []byte {
+	tree, _ := parser.ParseWithOptions(func(_ int, position Point) []byte {
 		row := position.Row
 		column := position.Column
 		if row < uint(len(lines)) {
@@ -314,6 +381,59 @@ func TestParsingWithCustomUTF16BEInput(t *testing.T) {
 	assert.Equal(t, root.Child(0).Kind(), "function_item")
 }
 
+func TestParsingWithGoDecoders(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	// "café" and "naïve" in Latin-1, where é and ï are single bytes.
+	latin1Source := []byte("[\"caf\xe9\", \"na\xefve\"]")
+	tree := parser.ParseWithDecoder(latin1Source, Latin1Decoder, nil)
+	defer tree.Close()
+	assert.Equal(t, "(document (array (string (string_content)) (string (string_content))))", tree.RootNode().ToSexp())
+
+	array := tree.RootNode().NamedChild(0)
+	firstContent := array.NamedChild(0).NamedChild(0)
+	assert.Equal(t, uint(2), firstContent.StartByte())
+	assert.Equal(t, uint(6), firstContent.EndByte())
+	assert.Equal(t, []byte("caf\xe9"), latin1Source[firstContent.StartByte():firstContent.EndByte()])
+	secondContent := array.NamedChild(1).NamedChild(0)
+	assert.Equal(t, []byte("na\xefve"), latin1Source[secondContent.StartByte():secondContent.EndByte()])
+	assert.Equal(t, Point{0, 10}, secondContent.StartPosition())
+
+	// UTF16 text with a surrogate pair, decoded from raw bytes.
+	units := utf16.Encode([]rune(`["😀", "ü"]`))
+	leSource := make([]byte, 2*len(units))
+	beSource := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(leSource[2*i:], unit)
+		binary.BigEndian.PutUint16(beSource[2*i:], unit)
+	}
+	for _, testCase := range []struct {
+		source  []byte
+		decoder Decoder
+	}{{leSource, UTF16LEDecoder}, {beSource, UTF16BEDecoder}} {
+		tree := parser.ParseWithDecoder(testCase.source, testCase.decoder, nil)
+		assert.False(t, tree.RootNode().HasError())
+		array := tree.RootNode().NamedChild(0)
+		emoji := array.NamedChild(0).NamedChild(0)
+		assert.Equal(t, uint(4), emoji.StartByte())
+		assert.Equal(t, uint(8), emoji.EndByte())
+		umlaut := array.NamedChild(1).NamedChild(0)
+		assert.Equal(t, uint(16), umlaut.StartByte())
+		assert.Equal(t, uint(18), umlaut.EndByte())
+		tree.Close()
+	}
+
+	// A decoder can also be a plain function.
+	toLower := DecodeFunc(func(data []byte) (int32, uint32) {
+		return int32(unicode.ToLower(rune(data[0]))), 1
+	})
+	tree = parser.ParseWithDecoder([]byte("[TRUE, NULL]"), toLower, nil)
+	defer tree.Close()
+	assert.Equal(t, "(document (array (true) (null)))", tree.RootNode().ToSexp())
+}
+
 func TestParsingWithCallbackReturningOwnedStrings(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -321,7 +441,7 @@ func TestParsingWithCallbackReturningOwnedStrings(t *testing.T) {
 
 	text := []byte("pub fn foo() { 1 }")
 
-	tree := parser.ParseWithOptions(func(i int, _ Point) []byte {
+	tree, _ := parser.ParseWithOptions(func(i int, _ Point) []byte {
 		return text[i:]
 	}, nil, nil)
 
@@ -329,6 +449,137 @@ func TestParsingWithCallbackReturningOwnedStrings(t *testing.T) {
 	assert.Equal(t, root.ToSexp(), "(source_file (function_item (visibility_modifier) name: (identifier) parameters: (parameters) body: (block (integer_literal))))")
 }
 
+func TestParsingUTF16WithSurrogatePairs(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("javascript"))
+
+	sourceCode := "let a = \"😀\";\nlet b = \"𝄞x\";"
+	source := utf16.Encode([]rune(sourceCode))
+
+	tree := parser.ParseUTF16LE(source, nil)
+	defer tree.Close()
+	root := tree.RootNode()
+	assert.False(t, root.HasError())
+
+	firstString := root.NamedChild(0).NamedChild(0).ChildByFieldName("value")
+	assert.Equal(t, "string", firstString.Kind())
+	assert.Equal(t, uint(2*8), firstString.StartByte())
+	// The emoji is a surrogate pair, so the string spans four code units.
+	assert.Equal(t, uint(2*12), firstString.EndByte())
+	assert.Equal(t, "\"😀\"", string(utf16.Decode(firstString.Utf16Text(source))))
+
+	secondDeclaration := root.NamedChild(1)
+	assert.Equal(t, Point{1, 0}, secondDeclaration.StartPosition())
+	assert.Equal(t, uint(2*14), secondDeclaration.StartByte())
+
+	secondName := secondDeclaration.NamedChild(0).ChildByFieldName("name")
+	assert.Equal(t, Point{1, 2 * 4}, secondName.StartPosition())
+	assert.Equal(t, "b", string(utf16.Decode(secondName.Utf16Text(source))))
+
+	secondString := secondDeclaration.NamedChild(0).ChildByFieldName("value")
+	assert.Equal(t, Point{1, 2 * 13}, secondString.EndPosition())
+	assert.Equal(t, "\"𝄞x\"", string(utf16.Decode(secondString.Utf16Text(source))))
+
+	// Big-endian input yields the same offsets.
+	swapped := make([]uint16, len(source))
+	for i, unit := range source {
+		swapped[i] = unit<<8 | unit>>8
+	}
+	i := 1
+	if (*[int(unsafe.Sizeof(0))]byte)(unsafe.Pointer(&i))[0] == 0 {
+		swapped = source
+	}
+	beTree := parser.ParseUTF16BE(swapped, nil)
+	defer beTree.Close()
+	assert.Equal(t, root.ToSexp(), beTree.RootNode().ToSexp())
+	assert.Equal(t, secondString.Range(), beTree.RootNode().NamedChild(1).NamedChild(0).ChildByFieldName("value").Range())
+}
+
+type shortReaderAt struct {
+	r         io.ReaderAt
+	size      int
+	failAfter int64
+}
+
+func (s *shortReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if s.failAfter > 0 && off >= s.failAfter {
+		return 0, errors.New("disk on fire")
+	}
+	if len(p) > s.size {
+		p = p[:s.size]
+	}
+	return s.r.ReadAt(p, off)
+}
+
+func TestParsingFromReader(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+
+	var builder strings.Builder
+	builder.WriteString("package main\n\n")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&builder, "func f%d(a int) string {\n\treturn \"héllo wörld\" + a\n}\n\n", i)
+	}
+	sourceCode := builder.String()
+
+	file, err := os.CreateTemp("", "tree-sitter-reader-*.go")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+	_, err = file.WriteString(sourceCode)
+	assert.Nil(t, err)
+
+	expectedTree := parser.Parse([]byte(sourceCode), nil)
+	defer expectedTree.Close()
+
+	tree, err := parser.ParseReader(&shortReaderAt{r: file, size: 7}, nil)
+	assert.Nil(t, err)
+	defer tree.Close()
+
+	assert.Equal(t, expectedTree.RootNode().ToSexp(), tree.RootNode().ToSexp())
+	expectedNodes := getAllNodes(expectedTree)
+	nodes := getAllNodes(tree)
+	assert.Equal(t, len(expectedNodes), len(nodes))
+	for i := range expectedNodes {
+		assert.Equal(t, expectedNodes[i].Range(), nodes[i].Range())
+	}
+	assert.Equal(t, uint(len(sourceCode)), tree.RootNode().EndByte())
+
+	// Read errors are returned rather than producing a truncated tree.
+	tree, err = parser.ParseReader(&shortReaderAt{r: file, size: 7, failAfter: 5000}, nil)
+	assert.Nil(t, tree)
+	assert.EqualError(t, err, "disk on fire")
+
+	// The parser is still usable afterwards.
+	tree, err = parser.ParseReader(strings.NewReader("package other\n"), nil)
+	assert.Nil(t, err)
+	defer tree.Close()
+	assert.Equal(t, "(source_file (package_clause (package_identifier)))", tree.RootNode().ToSexp())
+}
+
+func TestParsingFromReaderWithCancelFlag(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	flag := NewCancelFlag()
+	defer flag.Close()
+	flag.Cancel()
+	parser.SetCancelFlag(flag)
+	tree, err := parser.ParseReader(strings.NewReader("["+strings.Repeat("0,", 100_000)+"0]"), nil)
+	assert.Nil(t, tree)
+	assert.ErrorIs(t, err, ErrParseCancelled)
+
+	// The halted parse is not resumed by the next one.
+	parser.SetCancelFlag(nil)
+	tree, err = parser.ParseReader(strings.NewReader("[1]"), nil)
+	assert.Nil(t, err)
+	defer tree.Close()
+	assert.Equal(t, "(document (array (number)))", tree.RootNode().ToSexp())
+}
+
 func TestParsingTextWithByteOrderMark(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -397,7 +648,7 @@ func TestParsingEndsWhenInputCallbackReturnsEmpty(t *testing.T) {
 	parser.SetLanguage(getLanguage("javascript"))
 
 	source := []byte("abcdefghijklmnoqrs")
-	tree := parser.ParseWithOptions(func(offset int, _ Point) []byte {
+	tree, _ := parser.ParseWithOptions(func(offset int, _ Point) []byte {
 		if offset >= 6 {
 			return []byte{}
 		} else {
@@ -436,7 +687,7 @@ func TestParsingAfterEditingBeginningOfCode(t *testing.T) {
 	)
 
 	recorder := newReadRecorder(code)
-	tree = parser.ParseWithOptions(func(i int, _ Point) []byte {
+	tree, _ = parser.ParseWithOptions(func(i int, _ Point) []byte {
 		return recorder.Read(i)
 	}, tree, nil)
 	assert.Equal(
@@ -454,6 +705,53 @@ func TestParsingAfterEditingBeginningOfCode(t *testing.T) {
 	assert.Equal(t, []string{"123 || 5 "}, recorder.StringsRead())
 }
 
+func TestParsingAfterInsertingStatementReusesUnchangedNodes(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+
+	code := []byte("package main\n\nfunc a() {\n\tx := 1\n}\n\nfunc b() {\n\ty := 2\n}\n")
+	tree := parser.Parse(code, nil)
+	defer tree.Close()
+	functionB := tree.RootNode().NamedChild(2)
+	assert.Equal(t, "function_declaration", functionB.Kind())
+	functionBId := functionB.Id()
+
+	// Insert a statement into the body of `a`.
+	position := uint(strings.Index(string(code), "x := 1") + len("x := 1"))
+	edit, err := performEdit(tree, &code, &testEdit{
+		position:     position,
+		insertedText: []byte("\n\tx++"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, NewPoint(3, 7), edit.StartPosition)
+	assert.Equal(t, NewPoint(4, 4), edit.NewEndPosition)
+
+	recorder := newReadRecorder(code)
+	newTree, _ := parser.ParseWithOptions(func(i int, _ Point) []byte {
+		return recorder.Read(i)
+	}, tree, nil)
+	defer newTree.Close()
+
+	assert.Equal(t,
+		"(source_file (package_clause (package_identifier)) "+
+			"(function_declaration name: (identifier) parameters: (parameter_list) body: (block "+
+			"(short_var_declaration left: (expression_list (identifier)) right: (expression_list (int_literal))) "+
+			"(inc_statement (identifier)))) "+
+			"(function_declaration name: (identifier) parameters: (parameter_list) body: (block "+
+			"(short_var_declaration left: (expression_list (identifier)) right: (expression_list (int_literal))))))",
+		newTree.RootNode().ToSexp(),
+	)
+
+	// The function after the edit is reused rather than parsed again.
+	newFunctionB := newTree.RootNode().NamedChild(2)
+	assert.Equal(t, functionBId, newFunctionB.Id())
+	assert.Equal(t, functionB.StartByte()+uint(len("\n\tx++")), newFunctionB.StartByte())
+	for _, s := range recorder.StringsRead() {
+		assert.NotContains(t, s, "y := 2")
+	}
+}
+
 func TestParsingAfterEditingEndOfCode(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -482,7 +780,7 @@ func TestParsingAfterEditingEndOfCode(t *testing.T) {
 	)
 
 	recorder := newReadRecorder(code)
-	tree = parser.ParseWithOptions(func(i int, _ Point) []byte {
+	tree, _ = parser.ParseWithOptions(func(i int, _ Point) []byte {
 		return recorder.Read(i)
 	}, tree, nil)
 	assert.Equal(
@@ -603,6 +901,50 @@ func TestParsingOnMultipleThreads(t *testing.T) {
 	assert.Equal(t, []int{1, 2, 3, 4}, childCountDifferences)
 }
 
+func TestParsingConcurrentlyWithOneParserPanics(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		once := false
+		tree, _ := parser.ParseWithOptions(func(offset int, _ Point) []byte {
+			if !once {
+				once = true
+				close(started)
+				<-finish
+			}
+			if offset == 0 {
+				return []byte("[1, 2]")
+			}
+			return []byte{}
+		}, nil, nil)
+		tree.Close()
+	}()
+
+	<-started
+	message := "tree_sitter: Parser used concurrently from multiple goroutines"
+	assert.PanicsWithValue(t, message, func() { parser.Parse([]byte("[]"), nil) })
+	assert.PanicsWithValue(t, message, func() { parser.SetLanguage(getLanguage("json")) })
+	assert.PanicsWithValue(t, message, func() { parser.Reset() })
+	assert.PanicsWithValue(t, message, func() { parser.SetLogger(nil) })
+	assert.PanicsWithValue(t, message, func() { parser.SetTimeout(time.Second) })
+	assert.PanicsWithValue(t, message, func() { parser.SetCancelFlag(nil) })
+	assert.PanicsWithValue(t, message, func() { parser.PrintDotGraphs(io.Discard) })
+	assert.PanicsWithValue(t, message, func() { parser.Close() })
+	close(finish)
+	<-done
+
+	// Once the first parse is done, the parser can be used again.
+	tree := parser.Parse([]byte("[]"), nil)
+	defer tree.Close()
+	assert.Equal(t, "(document (array))", tree.RootNode().ToSexp())
+}
+
 func TestParsingCancelledByAnotherThread(t *testing.T) {
 	var cancellationFlag atomic.Value
 	flag := uintptr(0)
@@ -616,7 +958,7 @@ func TestParsingCancelledByAnotherThread(t *testing.T) {
 	parser.SetLanguage(getLanguage("javascript"))
 
 	// Long input - parsing succeeds
-	tree := parser.ParseWithOptions(
+	tree, _ := parser.ParseWithOptions(
 		func(offset int, _ Point) []byte {
 			if offset == 0 {
 				return []byte(" [")
@@ -639,7 +981,7 @@ func TestParsingCancelledByAnotherThread(t *testing.T) {
 	}()
 
 	// Infinite input
-	tree = parser.ParseWithOptions(
+	tree, _ = parser.ParseWithOptions(
 		func(offset int, _ Point) []byte {
 			runtime.Gosched()
 			time.Sleep(10 * time.Millisecond)
@@ -657,6 +999,34 @@ func TestParsingCancelledByAnotherThread(t *testing.T) {
 	assert.Nil(t, tree)
 }
 
+func TestParsingCancelledByContext(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	source := []byte("[" + strings.Repeat("0,", 2_000_000) + "0]")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	startTime := time.Now()
+	tree, err := parser.ParseCtx(ctx, source, nil)
+	assert.Nil(t, tree)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(startTime), time.Second)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	tree, err = parser.ParseCtx(ctx, source, nil)
+	assert.Nil(t, tree)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// The parser is reset after cancellation, so a different document parses from scratch.
+	tree, err = parser.ParseCtx(context.Background(), []byte("[1, 2]"), nil)
+	assert.Nil(t, err)
+	defer tree.Close()
+	assert.Equal(t, "(document (array (number) (number)))", tree.RootNode().ToSexp())
+}
+
 func TestParsingWithTimeout(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Skipping test on Windows due to millisecond timer resolution limitations")
@@ -668,7 +1038,7 @@ func TestParsingWithTimeout(t *testing.T) {
 
 	// Parse an infinitely-long array, but pause after 1ms of processing.
 	startTime := time.Now()
-	tree := parser.ParseWithOptions(
+	tree, _ := parser.ParseWithOptions(
 		func(offset int, _ Point) []byte {
 			if offset == 0 {
 				return []byte(" [")
@@ -686,7 +1056,7 @@ func TestParsingWithTimeout(t *testing.T) {
 
 	// Continue parsing, but pause after 5 ms of processing.
 	startTime = time.Now()
-	tree = parser.ParseWithOptions(
+	tree, _ = parser.ParseWithOptions(
 		func(offset int, _ Point) []byte {
 			if offset == 0 {
 				return []byte(" [")
@@ -704,7 +1074,7 @@ func TestParsingWithTimeout(t *testing.T) {
 	assert.True(t, time.Since(startTime) < 10000*time.Microsecond)
 
 	// Finish parsing
-	tree = parser.ParseWithOptions(
+	tree, _ = parser.ParseWithOptions(
 		func(offset int, _ Point) []byte {
 			if offset >= 5001 {
 				return []byte{}
@@ -738,7 +1108,7 @@ func TestParsingWithTimeoutAndReset(t *testing.T) {
 			return code[offset:]
 		}
 	}
-	tree := parser.ParseWithOptions(
+	tree, _ := parser.ParseWithOptions(
 		callback,
 		nil,
 		&ParseOptions{ProgressCallback: func(ParseState) bool {
@@ -752,11 +1122,11 @@ func TestParsingWithTimeoutAndReset(t *testing.T) {
 	// Without calling reset, the parser continues from where it left off, so
 	// it does not see the changes to the beginning of the source code.
 	code = []byte("[null, 1, 2, 3, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32]")
-	tree = parser.ParseWithOptions(callback, nil, nil)
+	tree, _ = parser.ParseWithOptions(callback, nil, nil)
 	assert.Equal(t, "string", tree.RootNode().NamedChild(0).NamedChild(0).Kind())
 
 	code = []byte("[\"ok\", 1, 2, 3, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32]")
-	tree = parser.ParseWithOptions(
+	tree, _ = parser.ParseWithOptions(
 		callback,
 		nil,
 		&ParseOptions{ProgressCallback: func(ParseState) bool {
@@ -769,10 +1139,48 @@ func TestParsingWithTimeoutAndReset(t *testing.T) {
 	// that it sees the changes to the beginning of the source code.
 	parser.Reset()
 	code = []byte("[null, 1, 2, 3, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32]")
-	tree = parser.ParseWithOptions(callback, nil, nil)
+	tree, _ = parser.ParseWithOptions(callback, nil, nil)
 	assert.Equal(t, "null", tree.RootNode().NamedChild(0).NamedChild(0).Kind())
 }
 
+func TestParsingDifferentDocumentAfterCancellationAndReset(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+
+	var builder strings.Builder
+	builder.WriteString("package first\n\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&builder, "var v%d = []int{%d, %d}\n", i, i, i+1)
+	}
+	firstDocument := []byte(builder.String())
+
+	// Halt the parse of the first document half way through.
+	tree, _ := parser.ParseWithOptions(chunkedInput(string(firstDocument), 64), nil, &ParseOptions{
+		ProgressCallback: func(state ParseState) bool {
+			return state.CurrentByteOffset > uint32(len(firstDocument)/2)
+		},
+	})
+	assert.Nil(t, tree)
+
+	parser.Reset()
+
+	secondDocument := []byte("package second\n\nfunc main() {\n\tprintln(\"hi\")\n}\n")
+	tree = parser.Parse(secondDocument, nil)
+	defer tree.Close()
+
+	freshParser := NewParser()
+	defer freshParser.Close()
+	freshParser.SetLanguage(getLanguage("go"))
+	expectedTree := freshParser.Parse(secondDocument, nil)
+	defer expectedTree.Close()
+
+	assert.Equal(t, expectedTree.RootNode().ToSexp(), tree.RootNode().ToSexp())
+	assert.Equal(t, expectedTree.RootNode().Range(), tree.RootNode().Range())
+	assert.False(t, tree.RootNode().HasError())
+	assert.Equal(t, "second", tree.RootNode().NamedChild(0).NamedChild(0).Utf8Text(secondDocument))
+}
+
 func TestParsingWithTimeoutAndImplicitReset(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Skipping test on Windows due to millisecond timer resolution limitations")
@@ -791,7 +1199,7 @@ func TestParsingWithTimeoutAndImplicitReset(t *testing.T) {
 			return code[offset:]
 		}
 	}
-	tree := parser.ParseWithOptions(
+	tree, _ := parser.ParseWithOptions(
 		callback,
 		nil,
 		&ParseOptions{ProgressCallback: func(ParseState) bool {
@@ -804,7 +1212,7 @@ func TestParsingWithTimeoutAndImplicitReset(t *testing.T) {
 	// Changing the parser's language implicitly resets, discarding the previous partial parse.
 	parser.SetLanguage(getLanguage("json"))
 	code = []byte("[null, 1, 2, 3, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32]")
-	tree = parser.ParseWithOptions(callback, nil, nil)
+	tree, _ = parser.ParseWithOptions(callback, nil, nil)
 	assert.Equal(t, "null", tree.RootNode().NamedChild(0).NamedChild(0).Kind())
 }
 
@@ -826,7 +1234,7 @@ func TestParsingWithTimeoutAndNoCompletion(t *testing.T) {
 			return code[offset:]
 		}
 	}
-	tree := parser.ParseWithOptions(
+	tree, _ := parser.ParseWithOptions(
 		callback,
 		nil,
 		&ParseOptions{ProgressCallback: func(ParseState) bool {
@@ -851,7 +1259,7 @@ func TestParsingWithTimeoutDuringBalancing(t *testing.T) {
 	code := strings.Repeat("function() {}\n", functionCount)
 	currentByteOffset := uint32(0)
 	inBalancing := false
-	tree := parser.ParseWithOptions(
+	tree, _ := parser.ParseWithOptions(
 		func(offset int, _ Point) []byte {
 			if offset >= len(code) {
 				return []byte{}
@@ -884,7 +1292,7 @@ func TestParsingWithTimeoutDuringBalancing(t *testing.T) {
 
 	// If we resume parsing (implying we didn't call `parser.reset()`), we should be able to
 	// finish parsing the tree, continuing from where we left off.
-	tree = parser.ParseWithOptions(
+	tree, _ = parser.ParseWithOptions(
 		func(offset int, _ Point) []byte {
 			if offset >= len(code) {
 				return []byte{}
@@ -917,7 +1325,7 @@ func TestParsingWithTimeoutWhenErrorDetected(t *testing.T) {
 	// Parse an infinitely-long array, but insert an error after 1000 characters.
 	offset := uint32(0)
 	erroneousCode := "!,"
-	tree := parser.ParseWithOptions(
+	tree, _ := parser.ParseWithOptions(
 		func(i int, _ Point) []byte {
 			if i == 0 {
 				return []byte("[")
@@ -942,6 +1350,150 @@ func TestParsingWithTimeoutWhenErrorDetected(t *testing.T) {
 	assert.Nil(t, tree)
 }
 
+func TestParsingWithCancelFlag(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	flag := NewCancelFlag()
+	defer flag.Close()
+	assert.Nil(t, parser.CancelFlag())
+	parser.SetCancelFlag(flag)
+	assert.Same(t, flag, parser.CancelFlag())
+
+	// Set the flag from another goroutine while the parser is busy with a
+	// huge array.
+	source := []byte("[" + strings.Repeat("0,", 2_000_000) + "0]")
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		flag.Cancel()
+	}()
+	startTime := time.Now()
+	tree := parser.Parse(source, nil)
+	assert.Nil(t, tree)
+	assert.Less(t, time.Since(startTime), time.Second)
+	assert.True(t, flag.IsCancelled())
+
+	tree, err := parser.ParseCtx(context.Background(), source, nil)
+	assert.Nil(t, tree)
+	assert.ErrorIs(t, err, ErrParseCancelled)
+
+	// Once the flag is cleared, parsing works again.
+	flag.Reset()
+	parser.Reset()
+	tree = parser.Parse([]byte("[null, 1]"), nil)
+	assert.NotNil(t, tree)
+	defer tree.Close()
+	assert.Equal(t, "(document (array (null) (number)))", tree.RootNode().ToSexp())
+
+	parser.SetCancelFlag(nil)
+	assert.Nil(t, parser.CancelFlag())
+}
+
+func TestParsingWithParserTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test on Windows due to millisecond timer resolution limitations")
+	}
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	assert.Equal(t, time.Duration(0), parser.Timeout())
+	parser.SetTimeout(time.Millisecond)
+	assert.Equal(t, time.Millisecond, parser.Timeout())
+
+	// A huge array cannot be parsed within a millisecond.
+	source := []byte("[" + strings.Repeat("0,", 500_000) + "0]")
+	startTime := time.Now()
+	tree := parser.Parse(source, nil)
+	assert.Nil(t, tree)
+	assert.Less(t, time.Since(startTime), 500*time.Millisecond)
+
+	tree, err := parser.ParseCtx(context.Background(), source, nil)
+	assert.Nil(t, tree)
+	assert.ErrorIs(t, err, ErrParseTimeout)
+
+	// After a reset, a small document parses well within the timeout.
+	parser.Reset()
+	tree = parser.Parse([]byte("[null, 1]"), nil)
+	assert.NotNil(t, tree)
+	assert.Equal(t, "(document (array (null) (number)))", tree.RootNode().ToSexp())
+	tree.Close()
+
+	// Durations shorter than a microsecond still set a timeout.
+	parser.SetTimeout(time.Nanosecond)
+	assert.Equal(t, time.Microsecond, parser.Timeout())
+
+	// Parses that are halted for another reason are not reported as timed
+	// out.
+	unconfigured := NewParser()
+	defer unconfigured.Close()
+	unconfigured.SetTimeout(time.Hour)
+	tree, err = unconfigured.ParseCtx(context.Background(), source, nil)
+	assert.Nil(t, tree)
+	assert.NotErrorIs(t, err, ErrParseTimeout)
+
+	// Setting a zero timeout disables it, so the huge array parses completely.
+	parser.SetTimeout(0)
+	assert.Equal(t, time.Duration(0), parser.Timeout())
+	parser.Reset()
+	tree = parser.Parse(source, nil)
+	assert.NotNil(t, tree)
+	defer tree.Close()
+	assert.False(t, tree.RootNode().HasError())
+}
+
+func TestParsingWithProgressCallback(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	source := []byte("[" + strings.Repeat("0,", 750_000) + "0]")
+	callback := func(offset int, _ Point) []byte {
+		if offset >= len(source) {
+			return []byte{}
+		}
+		return source[offset:]
+	}
+
+	// The callback is invoked periodically while the whole document is parsed.
+	calls := 0
+	lastOffset := uint32(0)
+	offsetsIncrease := true
+	tree, err := parser.ParseWithOptions(callback, nil, &ParseOptions{ProgressCallback: func(state ParseState) bool {
+		calls++
+		if state.CurrentByteOffset < lastOffset {
+			offsetsIncrease = false
+		}
+		lastOffset = state.CurrentByteOffset
+		assert.False(t, state.HasError)
+		return false
+	}})
+	assert.NoError(t, err)
+	assert.NotNil(t, tree)
+	tree.Close()
+	assert.Greater(t, calls, 100)
+	assert.True(t, offsetsIncrease)
+
+	// Returning true stops the parse early.
+	tree, err = parser.ParseWithOptions(callback, nil, &ParseOptions{ProgressCallback: func(state ParseState) bool {
+		lastOffset = state.CurrentByteOffset
+		return state.CurrentByteOffset > 500_000
+	}})
+	assert.Nil(t, tree)
+	assert.True(t, errors.Is(err, ErrParseAborted))
+	assert.Less(t, lastOffset, uint32(len(source)/2))
+	parser.Reset()
+
+	// Options without a callback behave like no options at all.
+	tree, err = parser.ParseWithOptions(chunkedInput("[1]", 3), nil, &ParseOptions{})
+	assert.NoError(t, err)
+	assert.NotNil(t, tree)
+	defer tree.Close()
+	assert.Equal(t, "(document (array (number)))", tree.RootNode().ToSexp())
+}
+
 // Included Ranges
 
 func TestParsingWithOneIncludedRange(t *testing.T) {
@@ -1046,6 +1598,51 @@ func TestParsingWithMultipleIncludedRanges(t *testing.T) {
 	assert.Equal(t, uint(strings.Index(sourceCode, ".</div>")), bEndTagNode.EndByte())
 }
 
+func TestParsingWithDisjointIncludedRangesInFillerText(t *testing.T) {
+	sourceCode := "filler {{package main;}} more filler {{func f() {};}} trailing filler"
+	firstStart := strings.Index(sourceCode, "package")
+	firstEnd := strings.Index(sourceCode, "}}")
+	secondStart := strings.Index(sourceCode, "func")
+	secondEnd := strings.LastIndex(sourceCode, "}}")
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+
+	ranges := []Range{simpleRange(firstStart, firstEnd), simpleRange(secondStart, secondEnd)}
+	assert.Nil(t, parser.SetIncludedRanges(ranges))
+	assert.Equal(t, ranges, parser.IncludedRanges())
+
+	tree := parser.Parse([]byte(sourceCode), nil)
+	defer tree.Close()
+	assert.Equal(t, ranges, tree.IncludedRanges())
+	root := tree.RootNode()
+	assert.Equal(t, "(source_file (package_clause (package_identifier)) (function_declaration name: (identifier) parameters: (parameter_list) body: (block)))", root.ToSexp())
+
+	packageClause := root.NamedChild(0)
+	assert.Equal(t, uint(firstStart), packageClause.StartByte())
+	assert.Equal(t, uint(strings.Index(sourceCode, ";}}")), packageClause.EndByte())
+	assert.Equal(t, Point{0, uint(firstStart)}, packageClause.StartPosition())
+
+	functionDeclaration := root.NamedChild(1)
+	assert.Equal(t, uint(secondStart), functionDeclaration.StartByte())
+	assert.Equal(t, uint(strings.LastIndex(sourceCode, ";}}")), functionDeclaration.EndByte())
+	assert.Equal(t, Point{0, uint(secondStart)}, functionDeclaration.StartPosition())
+
+	// Passing an empty slice goes back to parsing the whole document.
+	assert.Nil(t, parser.SetIncludedRanges([]Range{}))
+	fullRanges := parser.IncludedRanges()
+	assert.Len(t, fullRanges, 1)
+	assert.Equal(t, uint(0), fullRanges[0].StartByte)
+	assert.Equal(t, uint(math.MaxUint32), fullRanges[0].EndByte)
+
+	fullTree := parser.Parse([]byte(sourceCode), nil)
+	defer fullTree.Close()
+	assert.Equal(t, fullRanges, fullTree.IncludedRanges())
+	assert.True(t, fullTree.RootNode().HasError())
+	assert.Equal(t, uint(0), fullTree.RootNode().StartByte())
+}
+
 func TestParsingWithIncludedRangeContainingMismatchedPositions(t *testing.T) {
 	sourceCode := "<div>test</div>{_ignore_this_part_}"
 
@@ -1064,7 +1661,7 @@ func TestParsingWithIncludedRangeContainingMismatchedPositions(t *testing.T) {
 
 	parser.SetIncludedRanges([]Range{rangeToParse})
 
-	htmlTree := parser.ParseWithOptions(chunkedInput(sourceCode, 3), nil, nil)
+	htmlTree, _ := parser.ParseWithOptions(chunkedInput(sourceCode, 3), nil, nil)
 
 	assert.Equal(t, rangeToParse, htmlTree.RootNode().Range())
 	assert.Equal(
@@ -1186,7 +1783,7 @@ func TestParsingWithANewlyExcludedRange(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
 	parser.SetLanguage(getLanguage("html"))
-	firstTree := parser.ParseWithOptions(chunkedInput(sourceCode, 3), nil, nil)
+	firstTree, _ := parser.ParseWithOptions(chunkedInput(sourceCode, 3), nil, nil)
 
 	// Insert code at the beginning of the document.
 	prefix := "a very very long line of plain text. "
@@ -1221,7 +1818,7 @@ func TestParsingWithANewlyExcludedRange(t *testing.T) {
 		},
 	})
 
-	tree := parser.ParseWithOptions(chunkedInput(sourceCode, 3), firstTree, nil)
+	tree, _ := parser.ParseWithOptions(chunkedInput(sourceCode, 3), firstTree, nil)
 
 	assert.Equal(
 		t,
@@ -1267,7 +1864,7 @@ func TestParsingWithANewlyIncludedRange(t *testing.T) {
 	defer parser.Close()
 	parser.SetLanguage(getLanguage("javascript"))
 	parser.SetIncludedRanges([]Range{simpleRange(range1Start, range1End)})
-	tree := parser.ParseWithOptions(chunkedInput(sourceCode, 3), nil, nil)
+	tree, _ := parser.ParseWithOptions(chunkedInput(sourceCode, 3), nil, nil)
 	assert.Equal(
 		t,
 		"(program "+
@@ -1281,7 +1878,7 @@ func TestParsingWithANewlyIncludedRange(t *testing.T) {
 		simpleRange(range1Start, range1End),
 		simpleRange(range3Start, range3End),
 	})
-	tree2 := parser.ParseWithOptions(chunkedInput(sourceCode, 3), tree, nil)
+	tree2, _ := parser.ParseWithOptions(chunkedInput(sourceCode, 3), tree, nil)
 	assert.Equal(
 		t,
 		"(program "+
@@ -1450,3 +2047,115 @@ func (r *readRecorder) StringsRead() []string {
 	}
 	return result
 }
+
+func TestParserRejectsIncompatibleLanguage(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+
+	rust := getLanguage("rust")
+	assert.NoError(t, parser.SetLanguage(rust))
+
+	// A zeroed TSLanguage whose only populated fields are its ABI version
+	// and its name, which is only read from ABI 15 on.
+	for _, version := range []uint32{1, 99} {
+		fake := fakelang.New(version, "fake")
+		defer fakelang.Delete(fake)
+		language := NewLanguage(fake)
+
+		err := parser.SetLanguage(language)
+		var languageErr *LanguageError
+		assert.ErrorAs(t, err, &languageErr)
+		var ofLanguage string
+		if version >= 15 {
+			ofLanguage = ` of language "fake"`
+			assert.Contains(t, err.Error(), "fake")
+		}
+		assert.Equal(t, fmt.Sprintf(
+			"Incompatible language version %d%s. Expected minimum %d, maximum %d",
+			version, ofLanguage, MIN_COMPATIBLE_LANGUAGE_VERSION, LANGUAGE_VERSION,
+		), err.Error())
+
+		// The previous language stays assigned.
+		assert.Equal(t, rust.Inner, parser.Language().Inner)
+	}
+}
+
+func TestParserLanguageName(t *testing.T) {
+	// The bundled grammars predate ABI 15, so they don't record their name.
+	language := getLanguage("rust")
+	assert.Less(t, language.AbiVersion(), uint32(15))
+	assert.Equal(t, "", language.Name())
+}
+
+func TestParsingStrictlyWithMultipleSyntaxErrors(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("c"))
+
+	source := "int main() {\n  int a = 1\n  int b = 2;\n  b = a @ 3;\n  return 0\n}\nint x = (1 + 2;\n"
+	tree, err := parser.ParseStrict([]byte(source), nil)
+	defer tree.Close()
+
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, `missing ";" at 2:12, syntax error at 4:9, missing ";" at 5:11, and 1 more`, err.Error())
+
+	var sites ErrorSites
+	assert.ErrorAs(t, err, &sites)
+	assert.Equal(t, []ErrorSite{
+		{Kind: ";", Missing: true, Range: Range{
+			StartByte: 24, EndByte: 24,
+			StartPoint: NewPoint(1, 11), EndPoint: NewPoint(1, 11),
+		}},
+		{Kind: "ERROR", Range: Range{
+			StartByte: 46, EndByte: 49,
+			StartPoint: NewPoint(3, 8), EndPoint: NewPoint(3, 11),
+		}},
+		{Kind: ";", Missing: true, Range: Range{
+			StartByte: 61, EndByte: 61,
+			StartPoint: NewPoint(4, 10), EndPoint: NewPoint(4, 10),
+		}},
+		{Kind: ")", Missing: true, Range: Range{
+			StartByte: 78, EndByte: 78,
+			StartPoint: NewPoint(6, 14), EndPoint: NewPoint(6, 14),
+		}},
+	}, sites.Sites())
+
+	errorRange := sites.Sites()[1].Range
+	assert.Equal(t, "@ 3", source[errorRange.StartByte:errorRange.EndByte])
+
+	tree, err = parser.ParseStrict([]byte("int main() { return 0; }"), nil)
+	defer tree.Close()
+	assert.NoError(t, err)
+	assert.NotNil(t, tree)
+}
+
+func TestParsingStrictlyWithoutATree(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+
+	tree, err := parser.ParseStrict([]byte("[1]"), nil)
+	assert.Nil(t, tree)
+	assert.ErrorIs(t, err, ErrNoLanguage)
+
+	parser.SetLanguage(getLanguage("json"))
+	flag := NewCancelFlag()
+	defer flag.Close()
+	flag.Cancel()
+	parser.SetCancelFlag(flag)
+	defer parser.SetCancelFlag(nil)
+	tree, err = parser.ParseStrict([]byte("["+strings.Repeat("0,", 100_000)+"0]"), nil)
+	assert.Nil(t, tree)
+	assert.ErrorIs(t, err, ErrParseCancelled)
+}
+
+func TestParserUseAfterClose(t *testing.T) {
+	parser := NewParser()
+	parser.SetLanguage(getLanguage("json"))
+	parser.Close()
+	parser.Close()
+
+	assert.PanicsWithValue(t, "tree_sitter: use of closed Parser", func() {
+		parser.Parse([]byte("[]"), nil)
+	})
+}
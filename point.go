@@ -29,3 +29,8 @@ func (p *Point) fromTSPoint(tp C.TSPoint) {
 	p.Row = uint(tp.row)
 	p.Column = uint(tp.column)
 }
+
+// Check whether this point comes before `other` in the document.
+func (p Point) less(other Point) bool {
+	return p.Row < other.Row || (p.Row == other.Row && p.Column < other.Column)
+}
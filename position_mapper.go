@@ -0,0 +1,157 @@
+package tree_sitter
+
+import (
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// A PositionMapper converts between the points of a tree parsed from UTF-8
+// source, whose columns count bytes, and the positions of the Language
+// Server Protocol, whose characters count UTF-16 code units.
+//
+// Tree-sitter only starts a new row after a `\n`, while LSP also ends
+// lines at a lone `\r`. The mapper converts through byte offsets, so both
+// are handled, and a `\r\n` pair counts as a single line ending in both.
+type PositionMapper struct {
+	source []byte
+
+	// The byte offsets at which each row, as Tree-sitter counts them,
+	// starts.
+	rowStarts []uint
+
+	// The byte offsets at which each line, as LSP counts them, starts.
+	lineStarts []uint
+}
+
+// Create a [PositionMapper] for the UTF-8 source code that a tree was
+// parsed from. The mapper keeps a reference to `source`, which must not be
+// changed while it is in use.
+func NewPositionMapper(source []byte) *PositionMapper {
+	m := &PositionMapper{
+		source:     source,
+		rowStarts:  []uint{0},
+		lineStarts: []uint{0},
+	}
+	for i, b := range source {
+		switch {
+		case b == '\n':
+			m.rowStarts = append(m.rowStarts, uint(i+1))
+			m.lineStarts = append(m.lineStarts, uint(i+1))
+		case b == '\r' && (i+1 == len(source) || source[i+1] != '\n'):
+			m.lineStarts = append(m.lineStarts, uint(i+1))
+		}
+	}
+	return m
+}
+
+// Convert a point to an LSP position: a zero-based line and a character
+// offset in UTF-16 code units.
+//
+// A column past the end of its row is clamped to the end of the row, and a
+// row past the end of the source to the end of the source. A column in the
+// middle of a UTF-8 sequence is rounded down to the start of the sequence.
+func (m *PositionMapper) PointToUTF16(point Point) (line, character uint32) {
+	offset := m.pointToByte(point)
+	lineIndex := lastStartAtOrBefore(m.lineStarts, offset)
+	units := 0
+	for i := m.lineStarts[lineIndex]; i < offset; {
+		// Invalid UTF-8 decodes to U+FFFD, which takes a single unit.
+		r, size := utf8.DecodeRune(m.source[i:])
+		if i+uint(size) > offset {
+			break
+		}
+		units += utf16.RuneLen(r)
+		i += uint(size)
+	}
+	return uint32(lineIndex), uint32(units)
+}
+
+// Convert an LSP position to a point, the inverse of
+// [PositionMapper.PointToUTF16].
+//
+// A character past the end of its line is clamped to the end of the line,
+// as the protocol requires, and a line past the end of the source to the
+// end of the source. A character between the two halves of a surrogate pair
+// is rounded down to the start of the pair.
+func (m *PositionMapper) UTF16ToPoint(line, character uint32) Point {
+	if int(line) >= len(m.lineStarts) {
+		return m.byteToPoint(uint(len(m.source)))
+	}
+	offset := m.lineStarts[line]
+	for units := 0; offset < uint(len(m.source)); {
+		b := m.source[offset]
+		if b == '\n' || b == '\r' {
+			break
+		}
+		r, size := utf8.DecodeRune(m.source[offset:])
+		if units+utf16.RuneLen(r) > int(character) {
+			break
+		}
+		units += utf16.RuneLen(r)
+		offset += uint(size)
+	}
+	return m.byteToPoint(offset)
+}
+
+func (m *PositionMapper) pointToByte(point Point) uint {
+	if int(point.Row) >= len(m.rowStarts) {
+		return uint(len(m.source))
+	}
+	start := m.rowStarts[point.Row]
+	end := uint(len(m.source))
+	if int(point.Row)+1 < len(m.rowStarts) {
+		// The row ends at its `\n`, which a point may still refer to.
+		end = m.rowStarts[point.Row+1] - 1
+	}
+	return min(start+point.Column, end)
+}
+
+func (m *PositionMapper) byteToPoint(offset uint) Point {
+	row := lastStartAtOrBefore(m.rowStarts, offset)
+	return Point{Row: uint(row), Column: offset - m.rowStarts[row]}
+}
+
+// Find the index of the last of the sorted `starts` that is not after
+// `offset`. The first start is always zero.
+func lastStartAtOrBefore(starts []uint, offset uint) int {
+	return sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+}
+
+// Get the LSP position of the start of this node. See
+// [PositionMapper.PointToUTF16].
+func (n *Node) StartUTF16Position(m *PositionMapper) (line, character uint32) {
+	if n.IsNull() {
+		return 0, 0
+	}
+	return m.PointToUTF16(n.StartPosition())
+}
+
+// Get the LSP position of the end of this node. See
+// [PositionMapper.PointToUTF16].
+func (n *Node) EndUTF16Position(m *PositionMapper) (line, character uint32) {
+	if n.IsNull() {
+		return 0, 0
+	}
+	return m.PointToUTF16(n.EndPosition())
+}
+
+// Get this node's start position with a column that counts UTF16 code units,
+// for a tree parsed from the UTF8 source that `m` was created from. This is
+// the position from [Node.StartUTF16Position] as a [Point], so its row is an
+// LSP line, which only differs from the node's row if the source has lone
+// `\r` line endings.
+//
+// For a tree parsed from UTF16 text, use [Node.Utf16StartPosition], which
+// needs no mapper.
+func (n *Node) StartPointUTF16(m *PositionMapper) Point {
+	line, character := n.StartUTF16Position(m)
+	return Point{Row: uint(line), Column: uint(character)}
+}
+
+// Get this node's end position with a column that counts UTF16 code units,
+// like [Node.StartPointUTF16].
+func (n *Node) EndPointUTF16(m *PositionMapper) Point {
+	line, character := n.EndUTF16Position(m)
+	return Point{Row: uint(line), Column: uint(character)}
+}
@@ -0,0 +1,133 @@
+package tree_sitter_test
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestPositionMapperPointToUTF16(t *testing.T) {
+	// Rows start at bytes 0, 8 and 12, and LSP lines at 0, 8, 10 and 12.
+	mapper := NewPositionMapper([]byte("a😀b\r\nc\rd\n日x"))
+
+	tests := []struct {
+		point           Point
+		line, character uint32
+	}{
+		{Point{0, 0}, 0, 0},
+		{Point{0, 1}, 0, 1},
+		{Point{0, 3}, 0, 1}, // inside the emoji
+		{Point{0, 5}, 0, 3}, // after the surrogate pair
+		{Point{0, 6}, 0, 4}, // at the \r of \r\n
+		{Point{0, 7}, 0, 5}, // at the \n of \r\n
+		{Point{0, 99}, 0, 5},
+		{Point{1, 0}, 1, 0},
+		{Point{1, 2}, 2, 0}, // after a lone \r
+		{Point{1, 3}, 2, 1},
+		{Point{2, 3}, 3, 1},
+		{Point{2, 4}, 3, 2}, // at the end of the source
+		{Point{9, 0}, 3, 2},
+	}
+	for _, test := range tests {
+		line, character := mapper.PointToUTF16(test.point)
+		assert.Equal(t, [2]uint32{test.line, test.character}, [2]uint32{line, character}, "point %v", test.point)
+	}
+}
+
+func TestPositionMapperUTF16ToPoint(t *testing.T) {
+	mapper := NewPositionMapper([]byte("a😀b\r\nc\rd\n日x"))
+
+	tests := []struct {
+		line, character uint32
+		point           Point
+	}{
+		{0, 0, Point{0, 0}},
+		{0, 2, Point{0, 1}}, // between the two halves of the surrogate pair
+		{0, 3, Point{0, 5}},
+		{0, 99, Point{0, 6}}, // clamped to before the \r\n
+		{2, 0, Point{1, 2}},
+		{2, 5, Point{1, 3}},
+		{3, 1, Point{2, 3}},
+		{3, 2, Point{2, 4}},
+		{7, 0, Point{2, 4}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.point, mapper.UTF16ToPoint(test.line, test.character), "position %d:%d", test.line, test.character)
+	}
+
+	empty := NewPositionMapper(nil)
+	assert.Equal(t, Point{0, 0}, empty.UTF16ToPoint(0, 5))
+	line, character := empty.PointToUTF16(Point{3, 3})
+	assert.Equal(t, [2]uint32{0, 0}, [2]uint32{line, character})
+}
+
+func TestNodeUTF16Positions(t *testing.T) {
+	source := []byte("package main\r\n\r\nvar s, t = \"日本😀\", x\r\n")
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	mapper := NewPositionMapper(source)
+
+	var x *Node
+	for node := range Walk(*tree.RootNode()) {
+		if node.Kind() == "identifier" && node.Utf8Text(source) == "x" {
+			x = &node
+			break
+		}
+	}
+	if !assert.NotNil(t, x) {
+		return
+	}
+	assert.Equal(t, Point{2, 25}, x.StartPosition())
+
+	line, character := x.StartUTF16Position(mapper)
+	assert.Equal(t, [2]uint32{2, 19}, [2]uint32{line, character})
+	line, character = x.EndUTF16Position(mapper)
+	assert.Equal(t, [2]uint32{2, 20}, [2]uint32{line, character})
+	assert.Equal(t, x.StartPosition(), mapper.UTF16ToPoint(2, 19))
+
+	str := x.PrevNamedSibling()
+	line, character = str.StartUTF16Position(mapper)
+	assert.Equal(t, [2]uint32{2, 11}, [2]uint32{line, character})
+	line, character = str.EndUTF16Position(mapper)
+	assert.Equal(t, [2]uint32{2, 17}, [2]uint32{line, character})
+}
+
+func TestNodeUTF16PointsInBothEncodings(t *testing.T) {
+	text := "{\"😀\": [\"𝄞x\", 1],\n \"é\": \"日本\"}"
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	source := []byte(text)
+	utf8Tree := parser.Parse(source, nil)
+	defer utf8Tree.Close()
+	utf16Tree := parser.ParseUTF16LE(utf16.Encode([]rune(text)), nil)
+	defer utf16Tree.Close()
+	mapper := NewPositionMapper(source)
+
+	// The string "𝄞x" starts after `{"😀": [`, which is 10 bytes of UTF8 or 8
+	// UTF16 code units, and the clef takes two units.
+	str := firstNodeOfKind(*utf8Tree.RootNode(), "array").NamedChild(0)
+	assert.Equal(t, Point{0, 10}, str.StartPosition())
+	assert.Equal(t, Point{0, 8}, str.StartPointUTF16(mapper))
+	assert.Equal(t, Point{0, 13}, str.EndPointUTF16(mapper))
+	str16 := firstNodeOfKind(*utf16Tree.RootNode(), "array").NamedChild(0)
+	assert.Equal(t, Point{0, 16}, str16.StartPosition())
+	assert.Equal(t, Point{0, 8}, str16.Utf16StartPosition())
+	assert.Equal(t, Point{0, 13}, str16.Utf16EndPosition())
+
+	// Every node has the same UTF16 positions either way.
+	var fromUTF8, fromUTF16 [][2]Point
+	for node := range Walk(*utf8Tree.RootNode()) {
+		fromUTF8 = append(fromUTF8, [2]Point{node.StartPointUTF16(mapper), node.EndPointUTF16(mapper)})
+	}
+	for node := range Walk(*utf16Tree.RootNode()) {
+		fromUTF16 = append(fromUTF16, [2]Point{node.Utf16StartPosition(), node.Utf16EndPosition()})
+	}
+	assert.Equal(t, fromUTF8, fromUTF16)
+}
@@ -10,10 +10,15 @@ import "C"
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"math"
 	"regexp"
+	"runtime"
+	"slices"
 	"strings"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/mattn/go-pointer"
@@ -22,23 +27,59 @@ import (
 type Query struct {
 	_inner             *C.TSQuery
 	captureNames       []string
+	captureIDs         map[string]CaptureID
+	stringValues       []string
 	captureQuantifiers [][]CaptureQuantifier
 	TextPredicates     [][]TextPredicateCapture
 	propertySettings   [][]QueryProperty
 	propertyPredicates [][]PropertyPredicate
 	generalPredicates  [][]QueryPredicate
+	predicates         map[string]PredicateFunc
+	patternOrigins     []queryPatternOrigin
+
+	liveObject
+}
+
+// Where a pattern of a query was written, as given by [Query.PatternOrigin].
+type queryPatternOrigin struct {
+	name string
+	line int
 }
 
+// The number of nodes that a capture can have in a single match of a
+// pattern, which tells whether it is best held in a single value or in a
+// slice.
 type CaptureQuantifier int
 
 const (
+	// The capture does not occur in the pattern.
 	CaptureQuantifierZero CaptureQuantifier = iota
+	// The capture is optional, as in `(identifier)? @name`.
 	CaptureQuantifierZeroOrOne
+	// The capture is repeated and optional, as in `(identifier)* @names`.
 	CaptureQuantifierZeroOrMore
+	// The capture occurs exactly once, as in `(identifier) @name`.
 	CaptureQuantifierOne
+	// The capture is repeated, as in `(identifier)+ @names`.
 	CaptureQuantifierOneOrMore
 )
 
+func (q CaptureQuantifier) String() string {
+	switch q {
+	case CaptureQuantifierZero:
+		return "zero"
+	case CaptureQuantifierZeroOrOne:
+		return "zero or one"
+	case CaptureQuantifierZeroOrMore:
+		return "zero or more"
+	case CaptureQuantifierOne:
+		return "one"
+	case CaptureQuantifierOneOrMore:
+		return "one or more"
+	}
+	return "unknown"
+}
+
 func newCaptureQuantifier(raw C.TSQuantifier) CaptureQuantifier {
 	switch raw {
 	case C.TSQuantifierZero:
@@ -59,6 +100,20 @@ func newCaptureQuantifier(raw C.TSQuantifier) CaptureQuantifier {
 // A stateful object for executing a [Query] on a syntax [Tree].
 type QueryCursor struct {
 	_inner *C.TSQueryCursor
+	// The options of the current execution, if it has any. The C cursor
+	// keeps the pointer to them while the query is executed, so they are
+	// in C memory.
+	options *C.TSQueryCursorOptions
+
+	liveObject
+}
+
+// The payload of the progress callback of an execution of a query.
+type queryCursorProgress struct {
+	callback func(QueryCursorState) bool
+	// The error that halted the execution, for executions that are given a
+	// context.
+	err error
 }
 
 // A stateful object that is passed into the progress callback [QueryOptions.ProgressCallback].
@@ -79,23 +134,52 @@ type QueryCursorOptions struct {
 }
 
 // A key-value pair associated with a particular pattern in a [Query].
+//
+// Properties come from `#set!` directives in one of three forms: a key on
+// its own, as in `(#set! injection.combined)`, a key and a value, as in
+// `(#set! priority 110)`, or either of those scoped to a capture, as in
+// `(#set! @name role "callee")`. Value and CaptureId are nil when the
+// directive has no value or no capture.
 type QueryProperty struct {
 	Key       string
 	Value     *string
 	CaptureId *uint
 }
 
+// An argument of a [QueryPredicate], which is either a capture or a string,
+// with the other field set to nil. Unquoted words and numbers, such as the
+// `0` and `-1` in `(#offset! @name 0 1 0 -1)`, are strings as well.
 type QueryPredicateArg struct {
 	CaptureId *uint
 	String    *string
 }
 
-// A key-value pair associated with a particular pattern in a [Query].
+// Get the name of the capture that the argument refers to, without the
+// leading `@`, or an empty string if the argument is a string.
+func (arg QueryPredicateArg) CaptureName(query *Query) string {
+	if arg.CaptureId == nil {
+		return ""
+	}
+	return query.captureNames[*arg.CaptureId]
+}
+
+// A predicate that is not built into the query engine, such as a directive
+// like `#offset!` or `#gsub!`, with its operator and arguments in the order
+// in which they are written.
 type QueryPredicate struct {
 	Operator string
 	Args     []QueryPredicateArg
 }
 
+// A function that decides whether a match satisfies a user-defined predicate,
+// as registered with [Query.RegisterPredicate].
+//
+// It receives the match, the predicate's arguments, and a function that
+// returns the text of a node. Returning an error stops the iteration over
+// matches or captures, and the error is then reported by [QueryMatches.Err]
+// or [QueryCaptures.Err].
+type PredicateFunc func(match *QueryMatch, args []QueryPredicateArg, text func(Node) []byte) (bool, error)
+
 // A match of a [Query] to a particular set of [Node]s.
 type QueryMatch struct {
 	cursor       *C.TSQueryCursor
@@ -106,35 +190,58 @@ type QueryMatch struct {
 
 // A sequence of [QueryMatch]es associated with a given [QueryCursor].
 type QueryMatches struct {
-	_inner   *C.TSQueryCursor
+	cursor   *QueryCursor
 	query    *Query
 	callback func(int, Point) []byte
 	buffer1  []byte
 	buffer2  []byte
+	err      error
+	progress *queryCursorProgress
 }
 
 // A sequence of [QueryCapture]s associated with a given [QueryCursor].
 type QueryCaptures struct {
-	_inner   *C.TSQueryCursor
+	cursor   *QueryCursor
 	query    *Query
 	callback func(int, Point) []byte
 	buffer1  []byte
 	buffer2  []byte
+	err      error
+	progress *queryCursorProgress
 }
 
 // A particular [Node] that has been captured with a particular name within a [Query].
 // Note that this is a C-compatible struct
 type QueryCapture struct {
-	Node  Node
-	Index uint32
+	Node Node
+	// The capture's index in [Query.CaptureNames], which can be compared
+	// with an ID from [Query.CaptureID].
+	Index CaptureID
 }
 
+// The ID of a capture name of a [Query], which is its index in
+// [Query.CaptureNames]. Looking up an ID once with [Query.CaptureID] and
+// comparing it with [QueryCapture.Index] is faster than comparing the
+// names of captures.
+type CaptureID uint32
+
+// An error that occurred when creating a [Query] with [NewQuery].
 type QueryError struct {
+	// The details of the error: the invalid name for errors of the kinds
+	// [QueryErrorNodeType], [QueryErrorField] and [QueryErrorCapture], and
+	// otherwise a description or the line of the query with a caret under
+	// the error's position.
 	Message string
-	Row     uint
-	Column  uint
-	Offset  uint
-	Kind    QueryErrorKind
+	// The zero-based row and column of the error's position in the query.
+	Row    uint
+	Column uint
+	// The byte offset of the error's position in the query.
+	Offset uint
+	// The text of the query at the error's position that caused it, such as
+	// a misspelled node type or the operator of an invalid predicate. This
+	// is empty if the error is at the end of the query.
+	Token string
+	Kind  QueryErrorKind
 }
 
 type TextPredicateCapture struct {
@@ -143,45 +250,65 @@ type TextPredicateCapture struct {
 	CaptureId     uint
 	Positive      bool
 	MatchAllNodes bool
+
+	// The strings of a [TextPredicateTypeAnyString] predicate, which are
+	// also its Value, as a set, so that long lists are not scanned, and the
+	// length of the longest of them, so that longer texts are not read.
+	anyOf        map[string]struct{}
+	anyOfLongest int
 }
 
 type TextPredicateType int
 
 const (
+	// `#eq?` and its variants with a capture as the second argument. The
+	// nodes of the two captures are compared in order, so a quantified
+	// capture only equals another one with the same number of nodes.
 	TextPredicateTypeEqCapture TextPredicateType = iota
+	// `#eq?` and its variants with a string as the second argument.
 	TextPredicateTypeEqString
+	// `#match?` and its variants.
 	TextPredicateTypeMatchString
+	// `#any-of?` and `#not-any-of?`.
 	TextPredicateTypeAnyString
 )
 
+// An assertion about a property made with `#is?` or `#is-not?`, such as
+// `(#is-not? local)`, which highlighters use to leave identifiers that
+// refer to local variables to the results of a locals query.
+//
+// The query cursor does not evaluate these assertions: it returns matches
+// regardless of them, and it is up to the consumer to check them.
 type PropertyPredicate struct {
 	Property QueryProperty
+	// Whether the assertion is `#is?` rather than `#is-not?`.
 	Positive bool
 }
 
 func (e QueryError) Error() string {
-	var msg string
+	if e.Kind == QueryErrorLanguage {
+		return e.Message
+	}
+	return fmt.Sprintf("query error at %d:%d: %s", e.Row+1, e.Column+1, e.describe())
+}
+
+// Describe the error without its position.
+func (e QueryError) describe() string {
 	switch e.Kind {
 	case QueryErrorField:
-		msg = "Invalid field name "
+		return fmt.Sprintf("invalid field name %q", e.Message)
 	case QueryErrorNodeType:
-		msg = "Invalid node type "
+		return fmt.Sprintf("invalid node type %q", e.Message)
 	case QueryErrorCapture:
-		msg = "Invalid capture name "
+		return fmt.Sprintf("invalid capture name %q", e.Message)
 	case QueryErrorPredicate:
-		msg = "Invalid predicate: "
+		return "invalid predicate: " + e.Message
 	case QueryErrorStructure:
-		msg = "Impossible pattern:\n"
+		return "impossible pattern:\n" + e.Message
 	case QueryErrorSyntax:
-		msg = "Invalid syntax:\n"
-	case QueryErrorLanguage:
-		msg = ""
+		return "invalid syntax:\n" + e.Message
 	}
-
-	if msg == "" {
-		return e.Message
-	}
-	return fmt.Sprintf("Query error at %d:%d. %s%s", e.Row+1, e.Column+1, msg, e.Message)
+	return e.Message
 }
 
 type QueryErrorKind int
@@ -222,6 +349,7 @@ func NewQuery(language *Language, source string) (*Query, *QueryError) {
 		if errorType == C.TSQueryErrorLanguage {
 			lErr := &LanguageError{
 				version: language.AbiVersion(),
+				name:    language.Name(),
 			}
 			return nil, &QueryError{
 				Row:     0,
@@ -248,7 +376,7 @@ func NewQuery(language *Language, source string) (*Query, *QueryError) {
 		column := offset - lineStart
 
 		var kind QueryErrorKind
-		var message string
+		var message, token string
 		switch errorType {
 		// Error types that report names
 		case C.TSQueryErrorNodeType, C.TSQueryErrorField, C.TSQueryErrorCapture:
@@ -280,6 +408,7 @@ func NewQuery(language *Language, source string) (*Query, *QueryError) {
 			}
 
 			message = suffix[:endOffset]
+			token = message
 			switch errorType {
 			case C.TSQueryErrorNodeType:
 				kind = QueryErrorNodeType
@@ -296,6 +425,7 @@ func NewQuery(language *Language, source string) (*Query, *QueryError) {
 			} else {
 				message = lineContainingError + "\n" + strings.Repeat(" ", int(offset-lineStart)) + "^"
 			}
+			token = queryTokenAt(source, offset)
 			switch errorType {
 			case C.TSQueryErrorStructure:
 				kind = QueryErrorStructure
@@ -307,6 +437,7 @@ func NewQuery(language *Language, source string) (*Query, *QueryError) {
 			Row:     row,
 			Column:  column,
 			Offset:  offset,
+			Token:   token,
 			Message: message,
 			Kind:    kind,
 		}
@@ -316,23 +447,57 @@ func NewQuery(language *Language, source string) (*Query, *QueryError) {
 	return res, err
 }
 
+// Get the token of the query source that starts at the given offset: a
+// quoted string, a run of the characters that make up names, captures and
+// predicate operators, or otherwise a single character.
+func queryTokenAt(source string, offset uint) string {
+	rest := source[min(offset, uint(len(source))):]
+	if rest == "" {
+		return ""
+	}
+	if rest[0] == '"' {
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				i++
+			case '"':
+				return rest[:i+1]
+			}
+		}
+		return rest
+	}
+	if end := strings.IndexFunc(rest, func(r rune) bool {
+		return !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.?!@#", r)
+	}); end != 0 {
+		if end < 0 {
+			return rest
+		}
+		return rest[:end]
+	}
+	_, size := utf8.DecodeRuneInString(rest)
+	return rest[:size]
+}
+
 func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 	stringCount := int(C.ts_query_string_count(ptr))
 	captureCount := int(C.ts_query_capture_count(ptr))
 	patternCount := int(C.ts_query_pattern_count(ptr))
 
 	captureNames := make([]string, captureCount)
+	captureIDs := make(map[string]CaptureID, captureCount)
 	captureQuantifiersVec := make([][]CaptureQuantifier, patternCount)
 	textPredicatesVec := make([][]TextPredicateCapture, patternCount)
 	propertyPredicatesVec := make([][]PropertyPredicate, patternCount)
 	propertySettingsVec := make([][]QueryProperty, patternCount)
 	generalPredicatesVec := make([][]QueryPredicate, patternCount)
+	patternOrigins := make([]queryPatternOrigin, patternCount)
 
 	// Build a vector of strings to store the capture names.
 	for i := 0; i < captureCount; i++ {
 		var length C.uint32_t
 		name := C.ts_query_capture_name_for_id(ptr, C.uint32_t(i), &length)
 		captureNames[i] = C.GoStringN(name, C.int(length))
+		captureIDs[captureNames[i]] = CaptureID(i)
 	}
 
 	// Build a vector to store capture qunatifiers.
@@ -359,16 +524,9 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 		rawPredicates := C.ts_query_predicates_for_pattern(ptr, C.uint32_t(i), &length)
 		predicateSteps := unsafe.Slice(rawPredicates, int(length))
 
-		byteOffset := C.ts_query_start_byte_for_pattern(ptr, C.uint32_t(i))
-		row := 0
-		for i, c := range source {
-			if i >= int(byteOffset) {
-				break
-			}
-			if c == '\n' {
-				row++
-			}
-		}
+		patternStart := uint(C.ts_query_start_byte_for_pattern(ptr, C.uint32_t(i)))
+		patternEnd := uint(C.ts_query_end_byte_for_pattern(ptr, C.uint32_t(i)))
+		patternOrigins[i].line = strings.Count(source[:patternStart], "\n") + 1
 		const (
 			TYPE_DONE    = C.TSQueryPredicateStepTypeDone
 			TYPE_CAPTURE = C.TSQueryPredicateStepTypeCapture
@@ -405,20 +563,23 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 
 			if p[0]._type != TYPE_STRING {
 				C.ts_query_delete(ptr)
-				return nil, predicateError(uint(row), fmt.Sprintf("Expected predicate to start with a function name. Got @%s.", captureNames[p[0].value_id]))
+				return nil, predicateError(source, patternStart, patternEnd, fmt.Sprintf("Expected predicate to start with a function name. Got @%s.", captureNames[p[0].value_id]), "@"+captureNames[p[0].value_id])
 			}
 
 			// Build a predicate for each of the known predicate function names.
 			operatorName := stringValues[p[0].value_id]
+			fail := func(message string, arguments ...string) *QueryError {
+				return predicateError(source, patternStart, patternEnd, message, append([]string{"#" + operatorName}, arguments...)...)
+			}
 			switch operatorName {
 			case "eq?", "not-eq?", "any-eq?", "any-not-eq?":
 				if len(p) != 3 {
 					C.ts_query_delete(ptr)
-					return nil, predicateError(uint(row), fmt.Sprintf("Wrong number of arguments to #eq? predicate. Expected 2, got %d.", len(p)-1))
+					return nil, fail(fmt.Sprintf("Wrong number of arguments to #eq? predicate. Expected 2, got %d.", len(p)-1))
 				}
 				if p[1]._type != TYPE_CAPTURE {
 					C.ts_query_delete(ptr)
-					return nil, predicateError(uint(row), fmt.Sprintf("First argument to #eq? predicate must be a capture name. Got literal %s.", stringValues[p[1].value_id]))
+					return nil, fail(fmt.Sprintf("First argument to #eq? predicate must be a capture name. Got literal %s.", stringValues[p[1].value_id]))
 				}
 
 				isPositive := operatorName == "eq?" || operatorName == "any-eq?"
@@ -444,15 +605,15 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 			case "match?", "not-match?", "any-match?", "any-not-match?":
 				if len(p) != 3 {
 					C.ts_query_delete(ptr)
-					return nil, predicateError(uint(row), fmt.Sprintf("Wrong number of arguments to #match? predicate. Expected 2, got %d.", len(p)-1))
+					return nil, fail(fmt.Sprintf("Wrong number of arguments to #match? predicate. Expected 2, got %d.", len(p)-1))
 				}
 				if p[1]._type != TYPE_CAPTURE {
 					C.ts_query_delete(ptr)
-					return nil, predicateError(uint(row), fmt.Sprintf("First argument to #match? predicate must be a capture name. Got literal %s.", stringValues[p[1].value_id]))
+					return nil, fail(fmt.Sprintf("First argument to #match? predicate must be a capture name. Got literal %s.", stringValues[p[1].value_id]))
 				}
 				if p[2]._type == TYPE_CAPTURE {
 					C.ts_query_delete(ptr)
-					return nil, predicateError(uint(row), fmt.Sprintf("Second argument to #match? predicate must be a literal. Got capture @%s.", captureNames[p[2].value_id]))
+					return nil, fail(fmt.Sprintf("Second argument to #match? predicate must be a literal. Got capture @%s.", captureNames[p[2].value_id]))
 				}
 
 				isPositive := operatorName == "match?" || operatorName == "any-match?"
@@ -460,7 +621,7 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 				regex, err := regexp.Compile(stringValues[p[2].value_id])
 				if err != nil {
 					C.ts_query_delete(ptr)
-					return nil, predicateError(uint(row), fmt.Sprintf("Invalid regex: '%s'", stringValues[p[2].value_id]))
+					return nil, fail(fmt.Sprintf("Invalid regex: '%s'", stringValues[p[2].value_id]), stringValues[p[2].value_id])
 				}
 				textPredicates = append(textPredicates, TextPredicateCapture{
 					Type:          TextPredicateTypeMatchString,
@@ -471,7 +632,7 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 				})
 
 			case "set!":
-				property, err := parseProperty(uint(row), operatorName, captureNames, stringValues, p[1:])
+				property, err := parseProperty(fail, operatorName, captureNames, stringValues, p[1:])
 				if err != nil {
 					C.ts_query_delete(ptr)
 					return nil, err
@@ -479,7 +640,7 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 				propertySettings = append(propertySettings, property)
 
 			case "is?", "is-not?":
-				property, err := parseProperty(uint(row), operatorName, captureNames, stringValues, p[1:])
+				property, err := parseProperty(fail, operatorName, captureNames, stringValues, p[1:])
 				if err != nil {
 					C.ts_query_delete(ptr)
 					return nil, err
@@ -492,22 +653,26 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 			case "any-of?", "not-any-of?":
 				if len(p) < 2 {
 					C.ts_query_delete(ptr)
-					return nil, predicateError(uint(row), fmt.Sprintf("Wrong number of arguments to #any-of? predicate. Expected at least 1, got %d.", len(p)-1))
+					return nil, fail(fmt.Sprintf("Wrong number of arguments to #any-of? predicate. Expected at least 1, got %d.", len(p)-1))
 				}
 				if p[1]._type != TYPE_CAPTURE {
 					C.ts_query_delete(ptr)
-					return nil, predicateError(uint(row), fmt.Sprintf("First argument to #any-of? predicate must be a capture name. Got literal %s.", stringValues[p[1].value_id]))
+					return nil, fail(fmt.Sprintf("First argument to #any-of? predicate must be a capture name. Got literal %s.", stringValues[p[1].value_id]))
 				}
 
 				isPositive := operatorName == "any-of?"
 				values := make([]string, 0)
+				anyOf := make(map[string]struct{}, len(p)-2)
+				anyOfLongest := 0
 
 				for _, arg := range p[2:] {
 					if arg._type == TYPE_CAPTURE {
 						C.ts_query_delete(ptr)
-						return nil, predicateError(uint(row), fmt.Sprintf("Arguments to #any-of? predicate must be literals. Got capture @%s.", captureNames[arg.value_id]))
+						return nil, fail(fmt.Sprintf("Arguments to #any-of? predicate must be literals. Got capture @%s.", captureNames[arg.value_id]))
 					}
 					values = append(values, stringValues[arg.value_id])
+					anyOf[stringValues[arg.value_id]] = struct{}{}
+					anyOfLongest = max(anyOfLongest, len(stringValues[arg.value_id]))
 				}
 				textPredicates = append(textPredicates, TextPredicateCapture{
 					Type:          TextPredicateTypeAnyString,
@@ -515,6 +680,8 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 					Value:         values,
 					Positive:      isPositive,
 					MatchAllNodes: true,
+					anyOf:         anyOf,
+					anyOfLongest:  anyOfLongest,
 				})
 
 			default:
@@ -544,17 +711,35 @@ func fromRawParts(ptr *C.TSQuery, source string) (*Query, *QueryError) {
 	query := &Query{
 		_inner:             ptr,
 		captureNames:       captureNames,
+		captureIDs:         captureIDs,
+		stringValues:       stringValues,
 		captureQuantifiers: captureQuantifiersVec,
 		TextPredicates:     textPredicatesVec,
 		propertyPredicates: propertyPredicatesVec,
 		propertySettings:   propertySettingsVec,
 		generalPredicates:  generalPredicatesVec,
+		patternOrigins:     patternOrigins,
 	}
-	return query, nil
+	return addCleanup(query, (*Query).Close), nil
 }
 
+// Delete the query, freeing all of the memory that it used.
+//
+// Closing a query more than once has no effect, but calling any other
+// method that needs the underlying query panics once it is closed.
 func (q *Query) Close() {
-	C.ts_query_delete(q._inner)
+	if q._inner != nil {
+		removeCleanup(q)
+		C.ts_query_delete(q._inner)
+		q._inner = nil
+	}
+}
+
+func (q *Query) inner() *C.TSQuery {
+	if q._inner == nil {
+		panic("tree_sitter: use of closed Query")
+	}
+	return q._inner
 }
 
 // Get the byte offset where the given pattern starts in the query's source.
@@ -562,7 +747,7 @@ func (q *Query) StartByteForPattern(index uint) uint {
 	if index >= uint(len(q.TextPredicates)) {
 		panic(fmt.Sprintf("Pattern index is %d but the pattern count is %d", index, len(q.TextPredicates)))
 	}
-	return uint(C.ts_query_start_byte_for_pattern(q._inner, C.uint32_t(index)))
+	return uint(C.ts_query_start_byte_for_pattern(q.inner(), C.uint32_t(index)))
 }
 
 // Get the byte offset where the given pattern ends in the query's source.
@@ -570,12 +755,26 @@ func (q *Query) EndByteForPattern(index uint) uint {
 	if index >= uint(len(q.TextPredicates)) {
 		panic(fmt.Sprintf("Pattern index is %d but the pattern count is %d", index, len(q.TextPredicates)))
 	}
-	return uint(C.ts_query_end_byte_for_pattern(q._inner, C.uint32_t(index)))
+	return uint(C.ts_query_end_byte_for_pattern(q.inner(), C.uint32_t(index)))
+}
+
+// Get where the given pattern was written: the name of the source that it
+// came from and the one-based line on which it starts in that source.
+//
+// The name is the one given to [NewQueryFromSources], or the path of the
+// file for [LoadQuery]. For a query made with [NewQuery], it is empty, and
+// the line is the one in the query's source.
+func (q *Query) PatternOrigin(index uint) (name string, line int) {
+	if index >= uint(len(q.patternOrigins)) {
+		panic(fmt.Sprintf("Pattern index is %d but the pattern count is %d", index, len(q.patternOrigins)))
+	}
+	origin := q.patternOrigins[index]
+	return origin.name, origin.line
 }
 
 // Get the number of patterns in the query.
 func (q *Query) PatternCount() uint {
-	return uint(C.ts_query_pattern_count(q._inner))
+	return uint(C.ts_query_pattern_count(q.inner()))
 }
 
 // Get the names of the captures used in the query.
@@ -583,24 +782,57 @@ func (q *Query) CaptureNames() []string {
 	return q.captureNames
 }
 
-// Get the quantifiers of the captures used in the query.
+// Get the number of string literals in the query, which are the strings
+// and the unquoted words used as predicate arguments.
+func (q *Query) StringCount() uint {
+	return uint(len(q.stringValues))
+}
+
+// Get the string literal with the given id, as used by the
+// `TSQueryPredicateStep`s of the Tree-sitter library. Each distinct string
+// has a single id, however many times it occurs in the query.
+func (q *Query) StringValueForId(id uint) string {
+	if id >= uint(len(q.stringValues)) {
+		panic(fmt.Sprintf("String id is %d but the string count is %d", id, len(q.stringValues)))
+	}
+	return q.stringValues[id]
+}
+
+// Get the quantifiers of the captures used in the query, for the pattern
+// with the given index, indexed by capture.
 func (q *Query) CaptureQuantifiers(index uint) []CaptureQuantifier {
 	return q.captureQuantifiers[index]
 }
 
+// Get the quantifier of a capture in the pattern with the given index.
+// Across the alternatives of a pattern, such as `[(a) @x (b)* @x]`, the
+// quantifier covers all of them.
+func (q *Query) CaptureQuantifier(patternIndex, captureIndex uint) CaptureQuantifier {
+	if patternIndex >= q.PatternCount() {
+		panic(fmt.Sprintf("Pattern index is %d but the pattern count is %d", patternIndex, q.PatternCount()))
+	}
+	if captureIndex >= uint(len(q.captureNames)) {
+		panic(fmt.Sprintf("Capture index is %d but the capture count is %d", captureIndex, len(q.captureNames)))
+	}
+	return newCaptureQuantifier(C.ts_query_capture_quantifier_for_id(q.inner(), C.uint32_t(patternIndex), C.uint32_t(captureIndex)))
+}
+
 // Get the index for a given capture name.
 func (q *Query) CaptureIndexForName(name string) (uint, bool) {
-	for i, n := range q.captureNames {
-		if n == name {
-			return uint(i), true
-		}
-	}
-	return 0, false
+	id, ok := q.captureIDs[name]
+	return uint(id), ok
+}
+
+// Get the ID of the capture with the given name, without the leading `@`.
+func (q *Query) CaptureID(name string) (CaptureID, bool) {
+	id, ok := q.captureIDs[name]
+	return id, ok
 }
 
 // Get the properties that are checked for the given pattern index.
 //
-// This includes predicates with the operators `is?` and `is-not?`.
+// This includes predicates with the operators `is?` and `is-not?`, which do
+// not filter the matches of the pattern.
 func (q *Query) PropertyPredicates(index uint) []PropertyPredicate {
 	return q.propertyPredicates[index]
 }
@@ -615,53 +847,131 @@ func (q *Query) PropertySettings(index uint) []QueryProperty {
 // Get the other user-defined predicates associated with the given index.
 //
 // This includes predicate with operators other than:
-// * `match?`
-// * `eq?` and `not-eq?`
+// * `match?`, `not-match?`, `any-match?` and `any-not-match?`
+// * `eq?`, `not-eq?`, `any-eq?` and `any-not-eq?`
+// * `any-of?` and `not-any-of?`
 // * `is?` and `is-not?`
 // * `set!`
 func (q *Query) GeneralPredicates(index uint) []QueryPredicate {
 	return q.generalPredicates[index]
 }
 
+// Register a function that evaluates the predicate with the given operator,
+// such as `has-parent?` for `(#has-parent? @name "call_expression")`.
+//
+// Matches for which the function returns `false` are left out of
+// [QueryCursor.Matches] and [QueryCursor.Captures], along with their
+// variants. Registering a function for an operator that already has one
+// replaces it. The built-in predicates listed in [Query.GeneralPredicates]
+// cannot be overridden.
+func (q *Query) RegisterPredicate(name string, fn PredicateFunc) {
+	if q.predicates == nil {
+		q.predicates = make(map[string]PredicateFunc)
+	}
+	q.predicates[name] = fn
+}
+
+// Get the operators of the general predicates in the query that have no
+// function registered with [Query.RegisterPredicate], in sorted order.
+//
+// These predicates are not evaluated when the query is executed, so matches
+// are returned regardless of them.
+func (q *Query) UnhandledPredicates() []string {
+	var operators []string
+	for _, predicates := range q.generalPredicates {
+		for _, predicate := range predicates {
+			if _, ok := q.predicates[predicate.Operator]; !ok && !slices.Contains(operators, predicate.Operator) {
+				operators = append(operators, predicate.Operator)
+			}
+		}
+	}
+	slices.Sort(operators)
+	return operators
+}
+
 // Disable a certain capture within a query.
 //
 // This prevents the capture from being returned in matches, and also
-// avoids any resource usage associated with recording the capture.
-func (q *Query) DisableCapture(captureName string) {
+// avoids any resource usage associated with recording the capture. A
+// disabled capture cannot be enabled again, so create a new query to use
+// it again.
+//
+// This returns an error if the query has no capture with the given name.
+func (q *Query) DisableCapture(captureName string) error {
+	if _, ok := q.CaptureIndexForName(captureName); !ok {
+		return fmt.Errorf("query has no capture named %q", captureName)
+	}
 	cstr := C.CString(captureName)
-	C.ts_query_disable_capture(q._inner, cstr, C.uint32_t(len(captureName)))
+	C.ts_query_disable_capture(q.inner(), cstr, C.uint32_t(len(captureName)))
 	go_free(unsafe.Pointer(cstr))
+	return nil
 }
 
 // Disable a certain pattern within a query.
 //
 // This prevents the pattern from matching, and also avoids any resource
-// usage associated with the pattern.
-func (q *Query) DisablePattern(index uint) {
-	C.ts_query_disable_pattern(q._inner, C.uint32_t(index))
+// usage associated with the pattern. As with [Query.DisableCapture], this
+// cannot be undone.
+//
+// This returns an error if the index is not less than the pattern count.
+func (q *Query) DisablePattern(index uint) error {
+	if count := q.PatternCount(); index >= count {
+		return fmt.Errorf("pattern index is %d but the pattern count is %d", index, count)
+	}
+	C.ts_query_disable_pattern(q.inner(), C.uint32_t(index))
+	return nil
 }
 
 // Check if a given pattern within a query has a single root node.
+//
+// Patterns such as `(identifier)` and `[(block) (comment)]` are rooted,
+// since every match is contained in the node that the pattern's root
+// matches. Patterns with top-level siblings, such as `((comment) (block))`,
+// or a top-level repetition, such as `(comment)+`, are not.
+//
+// A rooted pattern only needs to be matched against the nodes in a range to
+// find all of its matches there, which is what an incremental highlighter
+// relies on when it restricts a query to the changed ranges of a tree.
 func (q *Query) IsPatternRooted(index uint) bool {
-	return bool(C.ts_query_is_pattern_rooted(q._inner, C.uint32_t(index)))
+	if index >= uint(len(q.TextPredicates)) {
+		panic(fmt.Sprintf("Pattern index is %d but the pattern count is %d", index, len(q.TextPredicates)))
+	}
+	return bool(C.ts_query_is_pattern_rooted(q.inner(), C.uint32_t(index)))
 }
 
-// Check if a given pattern within a query has a single root node.
+// Check if a given pattern within a query is 'non-local'.
+//
+// A non-local pattern has multiple root nodes and can match within a
+// repeating sequence of nodes, as specified by the grammar. For example,
+// `((return_statement) (return_statement))` is non-local because the two
+// statements can be anywhere in a block, while `("{" "}")` is not, since
+// those tokens have fixed places in the grammar. Non-local patterns
+// disable certain optimizations that would otherwise be possible when
+// executing a query on a specific range of a syntax tree, so a match can
+// start before the range.
 func (q *Query) IsPatternNonLocal(index uint) bool {
-	return bool(C.ts_query_is_pattern_non_local(q._inner, C.uint32_t(index)))
+	if index >= uint(len(q.TextPredicates)) {
+		panic(fmt.Sprintf("Pattern index is %d but the pattern count is %d", index, len(q.TextPredicates)))
+	}
+	return bool(C.ts_query_is_pattern_non_local(q.inner(), C.uint32_t(index)))
 }
 
 // Check if a given step in a query is 'definite'.
 //
 // A query step is 'definite' if its parent pattern will be guaranteed to
-// match successfully once it reaches the step.
+// match successfully once it reaches the step. The step is identified by
+// the byte offset of its node in the query's source. In `(object "{" "}")`,
+// the brace steps are definite, since every object has them once the
+// object node is reached, while the `(identifier)` step of
+// `(call_expression function: (identifier))` is not, since a call's
+// function can be another kind of node.
 func (q *Query) IsPatternGuaranteedAtStep(byteOffset uint) bool {
-	return bool(C.ts_query_is_pattern_guaranteed_at_step(q._inner, C.uint32_t(byteOffset)))
+	return bool(C.ts_query_is_pattern_guaranteed_at_step(q.inner(), C.uint32_t(byteOffset)))
 }
 
-func parseProperty(row uint, functionName string, captureNames []string, stringValues []string, args []C.TSQueryPredicateStep) (QueryProperty, *QueryError) {
+func parseProperty(fail func(string, ...string) *QueryError, functionName string, captureNames []string, stringValues []string, args []C.TSQueryPredicateStep) (QueryProperty, *QueryError) {
 	if len(args) == 0 || len(args) > 3 {
-		return QueryProperty{}, predicateError(row, fmt.Sprintf("Wrong number of arguments to %s predicate. Expected 1 to 3, got %d.", functionName, len(args)))
+		return QueryProperty{}, fail(fmt.Sprintf("Wrong number of arguments to %s predicate. Expected 1 to 3, got %d.", functionName, len(args)))
 	}
 
 	var captureId *uint
@@ -671,7 +981,7 @@ func parseProperty(row uint, functionName string, captureNames []string, stringV
 	for _, arg := range args {
 		if arg._type == C.TSQueryPredicateStepTypeCapture {
 			if captureId != nil {
-				return QueryProperty{}, predicateError(row, fmt.Sprintf("Invalid arguments to %s predicate. Unexpected second capture name @%s", functionName, captureNames[arg.value_id]))
+				return QueryProperty{}, fail(fmt.Sprintf("Invalid arguments to %s predicate. Unexpected second capture name @%s", functionName, captureNames[arg.value_id]))
 			}
 			captureId = new(uint)
 			*captureId = uint(arg.value_id)
@@ -682,12 +992,12 @@ func parseProperty(row uint, functionName string, captureNames []string, stringV
 			v := stringValues[arg.value_id]
 			value = &v
 		} else {
-			return QueryProperty{}, predicateError(row, fmt.Sprintf("Invalid arguments to %s predicate. Unexpected third argument @%s", functionName, stringValues[arg.value_id]))
+			return QueryProperty{}, fail(fmt.Sprintf("Invalid arguments to %s predicate. Unexpected third argument @%s", functionName, stringValues[arg.value_id]))
 		}
 	}
 
 	if key == nil {
-		return QueryProperty{}, predicateError(row, fmt.Sprintf("Invalid arguments to %s predicate. Missing key argument", functionName))
+		return QueryProperty{}, fail(fmt.Sprintf("Invalid arguments to %s predicate. Missing key argument", functionName))
 	}
 
 	return QueryProperty{
@@ -702,23 +1012,80 @@ func parseProperty(row uint, functionName string, captureNames []string, stringV
 // The cursor stores the state that is needed to iteratively search for
 // matches.
 func NewQueryCursor() *QueryCursor {
-	return &QueryCursor{_inner: C.ts_query_cursor_new()}
+	return addCleanup(&QueryCursor{_inner: C.ts_query_cursor_new()}, (*QueryCursor).Close)
 }
 
 // Delete the underlying memory for a query cursor.
+//
+// Closing a cursor more than once has no effect, but calling any other
+// method on a closed cursor panics.
 func (qc *QueryCursor) Close() {
-	C.ts_query_cursor_delete(qc._inner)
+	if qc._inner != nil {
+		removeCleanup(qc)
+		C.ts_query_cursor_delete(qc._inner)
+		qc._inner = nil
+		freeQueryCursorOptions(qc.options)
+		qc.options = nil
+	}
+}
+
+// Execute a query, with a progress callback if one is given.
+func (qc *QueryCursor) exec(query *Query, node *Node, progress *queryCursorProgress) {
+	previous := qc.options
+	qc.options = nil
+	if progress == nil {
+		C.ts_query_cursor_exec(qc.inner(), query.inner(), node._inner)
+	} else {
+		qc.options = (*C.TSQueryCursorOptions)(C.malloc(C.sizeof_TSQueryCursorOptions))
+		qc.options.payload = pointer.Save(progress)
+		qc.options.progress_callback = (*[0]byte)(C.queryProgressCallback)
+		C.ts_query_cursor_exec_with_options(qc.inner(), query.inner(), node._inner, qc.options)
+	}
+	runtime.KeepAlive(qc)
+	runtime.KeepAlive(query)
+
+	// The C cursor no longer refers to the options of the previous
+	// execution.
+	freeQueryCursorOptions(previous)
+}
+
+func freeQueryCursorOptions(options *C.TSQueryCursorOptions) {
+	if options != nil {
+		pointer.Unref(options.payload)
+		C.free(unsafe.Pointer(options))
+	}
+}
+
+func (qc *QueryCursor) inner() *C.TSQueryCursor {
+	if qc._inner == nil {
+		panic("tree_sitter: use of closed QueryCursor")
+	}
+	return qc._inner
 }
 
 // Return the maximum number of in-progress matches for this cursor.
+//
+// A new cursor has no limit other than the most that it can hold.
 func (qc *QueryCursor) MatchLimit() uint {
-	return uint(C.ts_query_cursor_match_limit(qc._inner))
+	return uint(C.ts_query_cursor_match_limit(qc.inner()))
 }
 
 // Set the maximum number of in-progress matches for this cursor.
 // The limit must be > 0 and <= 65536.
+//
+// A pattern that can match in many ways at once, such as one with several
+// quantified or repeated siblings, can have a great many matches in
+// progress over a repetitive source, each of which holds memory. Once the
+// limit is reached, the cursor drops the match in progress that started
+// earliest to make room for a new one, so that the matches that it returns
+// may be incomplete. [QueryCursor.DidExceedMatchLimit], or the method of the
+// same name on the iterator, tells whether that happened.
+//
+// The limit should be set before the cursor is first executed: the cursor
+// keeps the memory for the matches that it has had in progress, and
+// lowering the limit afterwards does not limit those.
 func (qc *QueryCursor) SetMatchLimit(limit uint) {
-	C.ts_query_cursor_set_match_limit(qc._inner, C.uint32_t(limit))
+	C.ts_query_cursor_set_match_limit(qc.inner(), C.uint32_t(limit))
 }
 
 // Set the maximum duration in microseconds that query execution should be allowed to
@@ -726,20 +1093,20 @@ func (qc *QueryCursor) SetMatchLimit(limit uint) {
 //
 // If query execution takes longer than this, it will halt early, returning None.
 func (qc *QueryCursor) SetTimeoutMicros(timeoutMicros uint64) {
-	C.ts_query_cursor_set_timeout_micros(qc._inner, C.uint64_t(timeoutMicros))
+	C.ts_query_cursor_set_timeout_micros(qc.inner(), C.uint64_t(timeoutMicros))
 }
 
 // Get the duration in microseconds that query execution is allowed to take.
 //
 // This is set via [QueryCursor.SetTimeoutMicros]
 func (qc *QueryCursor) TimeoutMicros() uint64 {
-	return uint64(C.ts_query_cursor_timeout_micros(qc._inner))
+	return uint64(C.ts_query_cursor_timeout_micros(qc.inner()))
 }
 
 // Check if, on its last execution, this cursor exceeded its maximum number
 // of in-progress matches.
 func (qc *QueryCursor) DidExceedMatchLimit() bool {
-	return bool(C.ts_query_cursor_did_exceed_match_limit(qc._inner))
+	return bool(C.ts_query_cursor_did_exceed_match_limit(qc.inner()))
 }
 
 // Iterate over all of the matches in the order that they were found.
@@ -748,6 +1115,11 @@ func (qc *QueryCursor) DidExceedMatchLimit() bool {
 // captures. Because multiple patterns can match the same set of nodes,
 // one match may contain captures that appear *before* some of the
 // captures from a previous match.
+//
+// Unlike [QueryCursor.Captures], which gives each capture of a match on
+// its own, this gives all the captures of a match together, which suits
+// patterns whose captures are used together, such as the name and the body
+// of a definition. The matches are filtered by the same predicates.
 func (qc *QueryCursor) Matches(query *Query, node *Node, text []byte) QueryMatches {
 	return qc.MatchesWith(query, node, func(offset int, position Point) []byte {
 		if offset >= len(text) {
@@ -767,16 +1139,46 @@ func (qc *QueryCursor) Matches(query *Query, node *Node, text []byte) QueryMatch
 // If the given offset is at or beyond the end of the text, the callback
 // should return an empty slice.
 func (qc *QueryCursor) MatchesWith(query *Query, node *Node, callback func(int, Point) []byte) QueryMatches {
-	C.ts_query_cursor_exec(qc._inner, query._inner, node._inner)
+	return qc.matchesWithProgress(query, node, callback, nil)
+}
+
+// Iterate over all of the matches in the order that they were found,
+// halting early if the given context is done.
+//
+// The cursor checks the context periodically while it looks for matches,
+// so a cancelled context or an expired deadline stops a long-running query
+// promptly. The matches found before then are still returned, after which
+// [QueryMatches.Next] returns nil and [QueryMatches.Err] returns the
+// context's error ([context.Canceled] or [context.DeadlineExceeded]), since
+// there may have been more matches.
+func (qc *QueryCursor) MatchesCtx(ctx context.Context, query *Query, node *Node, text []byte) QueryMatches {
+	return qc.matchesWithProgress(query, node, func(offset int, position Point) []byte {
+		if offset >= len(text) {
+			return []byte{}
+		}
+		return text[offset:]
+	}, newQueryContextProgress(ctx))
+}
+
+// Get a progress callback that halts an execution once the context is done.
+func newQueryContextProgress(ctx context.Context) *queryCursorProgress {
+	progress := &queryCursorProgress{}
+	progress.callback = func(QueryCursorState) bool {
+		progress.err = ctx.Err()
+		return progress.err != nil
+	}
+	return progress
+}
+
+func (qc *QueryCursor) matchesWithProgress(query *Query, node *Node, callback func(int, Point) []byte, progress *queryCursorProgress) QueryMatches {
+	qc.exec(query, node, progress)
 	qm := QueryMatches{
-		_inner:   qc._inner,
+		cursor:   qc,
 		query:    query,
 		callback: callback,
 		buffer1:  []byte{},
 		buffer2:  []byte{},
-	}
-	if qm._inner != qc._inner {
-		panic("inner pointers of `QueryCursor` and `QueryMatches` are not equal")
+		progress: progress,
 	}
 	if qm.query != query {
 		panic("query pointers of `QueryCursor` and `QueryMatches` are not equal")
@@ -789,8 +1191,8 @@ func (qc *QueryCursor) MatchesWith(query *Query, node *Node, callback func(int,
 //
 //export queryProgressCallback
 func queryProgressCallback(state *C.TSQueryCursorState) C.bool {
-	payload := pointer.Restore(state.payload).(*QueryCursorOptions)
-	return C.bool(payload.ProgressCallback(QueryCursorState{
+	payload := pointer.Restore(state.payload).(*queryCursorProgress)
+	return C.bool(payload.callback(QueryCursorState{
 		CurrentByteOffset: uint32(state.current_byte_offset),
 	}))
 }
@@ -802,34 +1204,16 @@ func queryProgressCallback(state *C.TSQueryCursorState) C.bool {
 // one match may contain captures that appear *before* some of the
 // captures from a previous match.
 func (qc *QueryCursor) MatchesWithOptions(query *Query, node *Node, text []byte, options QueryCursorOptions) QueryMatches {
-	cOptions := &C.TSQueryCursorOptions{
-		payload:           pointer.Save(&options),
-		progress_callback: (*[0]byte)(C.queryProgressCallback),
+	var progress *queryCursorProgress
+	if options.ProgressCallback != nil {
+		progress = &queryCursorProgress{callback: options.ProgressCallback}
 	}
-
-	C.ts_query_cursor_exec_with_options(qc._inner, query._inner, node._inner, cOptions)
-
-	callback := func(offset int, position Point) []byte {
+	return qc.matchesWithProgress(query, node, func(offset int, position Point) []byte {
 		if offset >= len(text) {
 			return []byte{}
 		}
 		return text[offset:]
-	}
-	qm := QueryMatches{
-		_inner:   qc._inner,
-		query:    query,
-		callback: callback,
-		buffer1:  []byte{},
-		buffer2:  []byte{},
-	}
-	if qm._inner != qc._inner {
-		panic("inner pointers of `QueryCursor` and `QueryMatches` are not equal")
-	}
-	if qm.query != query {
-		panic("query pointers of `QueryCursor` and `QueryMatches` are not equal")
-	}
-
-	return qm
+	}, progress)
 }
 
 // Iterate over all of the individual captures in the order that they
@@ -855,19 +1239,40 @@ func (qc *QueryCursor) Captures(query *Query, node *Node, text []byte) QueryCapt
 // offset and position. If the given offset is at or beyond the end of the
 // text, the callback should return an empty slice.
 func (qc *QueryCursor) CapturesWith(query *Query, node *Node, callback func(int, Point) []byte) QueryCaptures {
-	C.ts_query_cursor_exec(qc._inner, query._inner, node._inner)
+	return qc.capturesWithProgress(query, node, callback, nil)
+}
+
+// Iterate over all of the individual captures in the order that they
+// appear, halting early if the given context is done, like
+// [QueryCursor.MatchesCtx].
+//
+// The captures found before the context is done are still returned, after
+// which [QueryCaptures.Next] returns nil and [QueryCaptures.Err] returns
+// the context's error.
+func (qc *QueryCursor) CapturesCtx(ctx context.Context, query *Query, node *Node, text []byte) QueryCaptures {
+	return qc.capturesWithProgress(query, node, func(offset int, position Point) []byte {
+		if offset >= len(text) {
+			return []byte{}
+		}
+		return text[offset:]
+	}, newQueryContextProgress(ctx))
+}
+
+func (qc *QueryCursor) capturesWithProgress(query *Query, node *Node, callback func(int, Point) []byte, progress *queryCursorProgress) QueryCaptures {
+	qc.exec(query, node, progress)
 	return QueryCaptures{
-		_inner:   qc._inner,
+		cursor:   qc,
 		query:    query,
 		callback: callback,
 		buffer1:  []byte{},
 		buffer2:  []byte{},
+		progress: progress,
 	}
 }
 
 // Set the range of bytes in which the query will be executed.
 //
-// The query cursor will return matches that intersect with the given point range.
+// The query cursor will return matches that intersect with the given byte range.
 // This means that a match may be returned even if some of its captures fall
 // outside the specified range, as long as at least part of the match
 // overlaps with the range.
@@ -876,9 +1281,14 @@ func (qc *QueryCursor) CapturesWith(query *Query, node *Node, callback func(int,
 // than the specified range, but part of that node intersects with the range,
 // the entire match will be returned.
 //
+// The range applies to every later execution of the cursor, with
+// [QueryCursor.Matches] or [QueryCursor.Captures], until it is set again. An
+// end byte of zero stands for the end of the document, so that
+// `SetByteRange(0, 0)` executes the query over the whole document again.
+//
 // This will have no effect if the start byte is greater than the end byte.
 func (qc *QueryCursor) SetByteRange(startByte uint, endByte uint) *QueryCursor {
-	C.ts_query_cursor_set_byte_range(qc._inner, C.uint32_t(startByte), C.uint32_t(endByte))
+	C.ts_query_cursor_set_byte_range(qc.inner(), C.uint32_t(startByte), C.uint32_t(endByte))
 	return qc
 }
 
@@ -893,31 +1303,36 @@ func (qc *QueryCursor) SetByteRange(startByte uint, endByte uint) *QueryCursor {
 // than the specified range, but part of that node intersects with the range,
 // the entire match will be returned.
 //
+// Like the byte range, the range applies to every later execution of the
+// cursor, and an end point of zero stands for the end of the document. The
+// byte range and the point range are separate, and a match must intersect
+// both of them.
+//
 // This will have no effect if the start point is greater than the end point.
 func (qc *QueryCursor) SetPointRange(startPoint Point, endPoint Point) *QueryCursor {
-	C.ts_query_cursor_set_point_range(qc._inner, startPoint.toTSPoint(), endPoint.toTSPoint())
+	C.ts_query_cursor_set_point_range(qc.inner(), startPoint.toTSPoint(), endPoint.toTSPoint())
 	return qc
 }
 
-// Set the maximum start depth for a query cursor.
-//
-// This prevents cursors from exploring children nodes at a certain depth.
-// Note if a pattern includes many children, then they will still be
-// checked.
+// Set the maximum depth, below the node that the query is executed on, at
+// which a match of a pattern can start, so that the cursor does not look
+// for matches deeper in the tree. This suits queries for the top-level
+// declarations of a file, which can be executed on the root node with a
+// depth of one, without the cursor going into every function body.
 //
-// The zero max start depth value can be used as a special behavior and
-// it helps to destructure a subtree by staying on a node and using
-// captures for interested parts. Note that the zero max start depth
-// only limit a search depth for a pattern's root node but other nodes
-// that are parts of the pattern may be searched at any depth what
-// defined by the pattern structure.
+// A depth of zero only lets matches start at the node itself, and a depth
+// of one also at its children. The depth only limits where a match starts:
+// the rest of the pattern, such as its child patterns, still matches nodes
+// at any depth below that. A pattern made of a sequence of sibling
+// patterns, which is not rooted (see [Query.IsPatternRooted]), starts at
+// its first sibling, so its siblings must all be within the depth.
 //
 // Set to `nil` to remove the maximum start depth.
 func (qc *QueryCursor) SetMaxStartDepth(depth *uint) *QueryCursor {
 	if depth == nil {
-		C.ts_query_cursor_set_max_start_depth(qc._inner, C.uint32_t(math.MaxUint32))
+		C.ts_query_cursor_set_max_start_depth(qc.inner(), C.uint32_t(math.MaxUint32))
 	} else {
-		C.ts_query_cursor_set_max_start_depth(qc._inner, C.uint32_t(*depth))
+		C.ts_query_cursor_set_max_start_depth(qc.inner(), C.uint32_t(*depth))
 	}
 	return qc
 }
@@ -955,56 +1370,213 @@ func (qm *QueryMatch) NodesForCaptureIndex(captureIndex uint) []Node {
 	return nodes
 }
 
+// Get the nodes of the match for the capture with the given ID, in the
+// order in which they were captured.
+func (qm *QueryMatch) NodesForCaptureID(id CaptureID) []Node {
+	var nodes []Node
+	for _, capture := range qm.Captures {
+		if capture.Index == id {
+			nodes = append(nodes, capture.Node)
+		}
+	}
+	return nodes
+}
+
+// Get the first node of the match for the capture with the given ID, or nil
+// if the match has none.
+func (qm *QueryMatch) FirstNodeForCaptureID(id CaptureID) *Node {
+	for i := range qm.Captures {
+		if qm.Captures[i].Index == id {
+			node := qm.Captures[i].Node
+			return &node
+		}
+	}
+	return nil
+}
+
+// Get the properties that are set for the match's pattern, keyed by their
+// names, as a convenience over [Query.PropertySettings].
+//
+// A property scoped to a capture is only included if the capture has a node
+// in this match. If a pattern sets the same key more than once, the last
+// setting wins.
+func (qm *QueryMatch) Properties(query *Query) map[string]QueryProperty {
+	settings := query.PropertySettings(qm.PatternIndex)
+	properties := make(map[string]QueryProperty, len(settings))
+	for _, property := range settings {
+		if property.CaptureId != nil && !slices.ContainsFunc(qm.Captures, func(capture QueryCapture) bool {
+			return uint(capture.Index) == *property.CaptureId
+		}) {
+			continue
+		}
+		properties[property.Key] = property
+	}
+	return properties
+}
+
 // getTextForNode retrieves text for a node using the callback, making multiple
 // calls if necessary to get the complete node text
 func (qm *QueryMatch) getTextForNode(node Node, callback func(int, Point) []byte) []byte {
-	if callback == nil {
-		return []byte{}
+	reader := newNodeTextReader(node, callback)
+	result := make([]byte, 0, reader.remaining)
+	for chunk := reader.next(); chunk != nil; chunk = reader.next() {
+		result = append(result, chunk...)
 	}
-	
-	startByte := int(node.StartByte())
-	endByte := int(node.EndByte())
-	totalLength := endByte - startByte
-	
-	if totalLength == 0 {
-		return []byte{}
+	return result
+}
+
+// A nodeTextReader reads the text of a node from a text callback one chunk
+// at a time, so that predicates can look at the text without copying all of
+// it.
+type nodeTextReader struct {
+	callback func(int, Point) []byte
+	offset   int
+	position Point
+	// The number of bytes of the node's text that have not been gotten from
+	// the callback yet.
+	remaining int
+	// The part of the last chunk that has not been read, which only
+	// [nodeTextReader.ReadRune] leaves.
+	unread []byte
+}
+
+func newNodeTextReader(node Node, callback func(int, Point) []byte) nodeTextReader {
+	r := nodeTextReader{
+		callback: callback,
+		offset:   int(node.StartByte()),
+		position: node.StartPosition(),
 	}
-	
-	result := make([]byte, 0, totalLength)
-	currentByte := startByte
-	currentPosition := node.StartPosition()
-	
-	for len(result) < totalLength {
-		chunk := callback(currentByte, currentPosition)
-		if len(chunk) == 0 {
-			// No more data available, return what we have
-			break
+	if callback != nil {
+		r.remaining = int(node.EndByte()) - r.offset
+	}
+	return r
+}
+
+// Get the next chunk of the text, or nil at its end. The text ends early if
+// the callback runs out of text.
+func (r *nodeTextReader) next() []byte {
+	if len(r.unread) > 0 {
+		chunk := r.unread
+		r.unread = nil
+		return chunk
+	}
+	if r.remaining == 0 {
+		return nil
+	}
+	chunk := r.callback(r.offset, r.position)
+	if len(chunk) == 0 {
+		r.remaining = 0
+		return nil
+	}
+	if len(chunk) > r.remaining {
+		chunk = chunk[:r.remaining]
+	}
+	r.remaining -= len(chunk)
+	r.offset += len(chunk)
+	for _, b := range chunk {
+		if b == '\n' {
+			r.position.Row++
+			r.position.Column = 0
+		} else {
+			r.position.Column++
 		}
-		
-		// Take only what we need
-		remainingNeeded := totalLength - len(result)
-		if len(chunk) > remainingNeeded {
-			chunk = chunk[:remainingNeeded]
+	}
+	return chunk
+}
+
+// Get the whole text if it is in a single chunk, without copying it.
+func (r *nodeTextReader) single() ([]byte, bool) {
+	chunk := r.next()
+	if r.remaining == 0 {
+		return chunk, true
+	}
+	r.unread = chunk
+	return nil, false
+}
+
+// ReadRune implements [io.RuneReader], decoding runes that are split
+// between chunks.
+func (r *nodeTextReader) ReadRune() (rune, int, error) {
+	if len(r.unread) == 0 {
+		if r.unread = r.next(); r.unread == nil {
+			return 0, 0, io.EOF
 		}
-		
-		result = append(result, chunk...)
-		
-		// Update position for next callback
-		if len(result) < totalLength {
-			currentByte += len(chunk)
-			// Update position by counting newlines in the chunk we just consumed
-			for _, b := range chunk {
-				if b == '\n' {
-					currentPosition.Row++
-					currentPosition.Column = 0
-				} else {
-					currentPosition.Column++
-				}
+	}
+	if utf8.FullRune(r.unread) {
+		c, size := utf8.DecodeRune(r.unread)
+		r.unread = r.unread[size:]
+		return c, size, nil
+	}
+
+	var buf [utf8.UTFMax]byte
+	n := copy(buf[:], r.unread)
+	r.unread = nil
+	for !utf8.FullRune(buf[:n]) {
+		if len(r.unread) == 0 {
+			if r.unread = r.next(); r.unread == nil {
+				break
 			}
 		}
+		buf[n] = r.unread[0]
+		r.unread = r.unread[1:]
+		n++
 	}
-	
-	return result
+	c, size := utf8.DecodeRune(buf[:n])
+	if size < n {
+		// The bytes after an invalid one are read again.
+		r.unread = append(buf[size:n:n], r.unread...)
+	}
+	return c, size, nil
+}
+
+// Check whether the text is the given string, reading no further than the
+// first difference.
+func (r *nodeTextReader) equal(s []byte) bool {
+	for chunk := r.next(); chunk != nil; chunk = r.next() {
+		if len(chunk) > len(s) || !bytes.Equal(chunk, s[:len(chunk)]) {
+			return false
+		}
+		s = s[len(chunk):]
+	}
+	return len(s) == 0
+}
+
+// Check whether two texts are equal, reading no further than the first
+// difference.
+func (r *nodeTextReader) equalReader(other *nodeTextReader) bool {
+	var a, b []byte
+	for {
+		if len(a) == 0 {
+			a = r.next()
+		}
+		if len(b) == 0 {
+			b = other.next()
+		}
+		if a == nil || b == nil {
+			return a == nil && b == nil
+		}
+		n := min(len(a), len(b))
+		if !bytes.Equal(a[:n], b[:n]) {
+			return false
+		}
+		a, b = a[n:], b[n:]
+	}
+}
+
+// Get the text if it is at most `limit` bytes long, reading no further
+// than that otherwise.
+func (r *nodeTextReader) atMost(limit int) ([]byte, bool) {
+	if text, ok := r.single(); ok {
+		return text, len(text) <= limit
+	}
+	var text []byte
+	for chunk := r.next(); chunk != nil; chunk = r.next() {
+		if len(text)+len(chunk) > limit {
+			return nil, false
+		}
+		text = append(text, chunk...)
+	}
+	return text, true
 }
 
 func (qm *QueryMatch) SatisfiesTextPredicate(query *Query, buffer1, buffer2 []byte, text []byte) bool {
@@ -1017,6 +1589,30 @@ func (qm *QueryMatch) SatisfiesTextPredicate(query *Query, buffer1, buffer2 []by
 	return qm.SatisfiesTextPredicateWith(query, buffer1, buffer2, callback)
 }
 
+// Check the match against both the text predicates and the registered
+// predicates of its pattern.
+func (qm *QueryMatch) satisfiesPredicates(query *Query, buffer1, buffer2 []byte, callback func(int, Point) []byte) (bool, error) {
+	if !qm.SatisfiesTextPredicateWith(query, buffer1, buffer2, callback) {
+		return false, nil
+	}
+	if len(query.predicates) == 0 {
+		return true, nil
+	}
+	text := func(node Node) []byte {
+		return qm.getTextForNode(node, callback)
+	}
+	for _, predicate := range query.generalPredicates[qm.PatternIndex] {
+		fn, ok := query.predicates[predicate.Operator]
+		if !ok {
+			continue
+		}
+		if satisfies, err := fn(qm, predicate.Args, text); err != nil || !satisfies {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
 func (qm *QueryMatch) SatisfiesTextPredicateWith(query *Query, buffer1, buffer2 []byte, callback func(int, Point) []byte) bool {
 	satisfies := true
 	
@@ -1034,9 +1630,9 @@ func (qm *QueryMatch) SatisfiesTextPredicateWith(query *Query, buffer1, buffer2
 			for len(nodes1) > 0 && len(nodes2) > 0 {
 				node1 := nodes1[0]
 				node2 := nodes2[0]
-				nodeText1 := qm.getTextForNode(node1, callback)
-				nodeText2 := qm.getTextForNode(node2, callback)
-				isPositiveMatch := bytes.Equal(nodeText1, nodeText2)
+				text1 := newNodeTextReader(node1, callback)
+				text2 := newNodeTextReader(node2, callback)
+				isPositiveMatch := text1.equalReader(&text2)
 				if isPositiveMatch != predicate.Positive && predicate.MatchAllNodes {
 					return false
 				}
@@ -1046,15 +1642,17 @@ func (qm *QueryMatch) SatisfiesTextPredicateWith(query *Query, buffer1, buffer2
 				nodes1 = nodes1[1:]
 				nodes2 = nodes2[1:]
 			}
-			return len(nodes1) == 0 && len(nodes2) == 0
+			// The any- variants need at least one pair of nodes to match, and
+			// the others a counterpart for every node.
+			return predicate.MatchAllNodes && len(nodes1) == 0 && len(nodes2) == 0
 
 		case TextPredicateTypeEqString:
 			i := predicate.CaptureId
 			s := predicate.Value.(string)
 			nodes := qm.NodesForCaptureIndex(i)
 			for _, node := range nodes {
-				nodeText := qm.getTextForNode(node, callback)
-				isPositiveMatch := bytes.Equal(nodeText, []byte(s))
+				text := newNodeTextReader(node, callback)
+				isPositiveMatch := text.equal([]byte(s))
 				if isPositiveMatch != predicate.Positive && predicate.MatchAllNodes {
 					return false
 				}
@@ -1062,7 +1660,8 @@ func (qm *QueryMatch) SatisfiesTextPredicateWith(query *Query, buffer1, buffer2
 					return true
 				}
 			}
-			return true
+			// The any- variants need at least one of the nodes to match.
+			return predicate.MatchAllNodes
 
 		case TextPredicateTypeMatchString:
 			i := predicate.CaptureId
@@ -1070,8 +1669,13 @@ func (qm *QueryMatch) SatisfiesTextPredicateWith(query *Query, buffer1, buffer2
 
 			nodes := qm.NodesForCaptureIndex(i)
 			for _, node := range nodes {
-				nodeText := qm.getTextForNode(node, callback)
-				isPositiveMatch := r.Match(nodeText)
+				text := newNodeTextReader(node, callback)
+				var isPositiveMatch bool
+				if chunk, ok := text.single(); ok {
+					isPositiveMatch = r.Match(chunk)
+				} else {
+					isPositiveMatch = r.MatchReader(&text)
+				}
 				if isPositiveMatch != predicate.Positive && predicate.MatchAllNodes {
 					return false
 				}
@@ -1079,19 +1683,18 @@ func (qm *QueryMatch) SatisfiesTextPredicateWith(query *Query, buffer1, buffer2
 					return true
 				}
 			}
-			return true
+			// The any- variants need at least one of the nodes to match.
+			return predicate.MatchAllNodes
 		case TextPredicateTypeAnyString:
 			i := predicate.CaptureId
-			v := predicate.Value.([]string)
 			nodes := qm.NodesForCaptureIndex(i)
 			for _, node := range nodes {
-				nodeText := qm.getTextForNode(node, callback)
+				// A text longer than every string cannot be one of them.
+				text := newNodeTextReader(node, callback)
+				nodeText, ok := text.atMost(predicate.anyOfLongest)
 				isPositiveMatch := false
-				for _, s := range v {
-					if bytes.Equal(nodeText, []byte(s)) {
-						isPositiveMatch = true
-						break
-					}
+				if ok {
+					_, isPositiveMatch = predicate.anyOf[string(nodeText)]
 				}
 				if isPositiveMatch != predicate.Positive {
 					return false
@@ -1131,17 +1734,28 @@ func (qm *QueryMatches) Next() *QueryMatch {
 	for {
 		m := (*C.TSQueryMatch)(C.malloc(C.sizeof_TSQueryMatch))
 		defer C.free(unsafe.Pointer(m))
-		if C.ts_query_cursor_next_match(qm._inner, m) {
-			result := newQueryMatch(m, qm._inner)
-			if result.SatisfiesTextPredicateWith(
+		hasMatch := C.ts_query_cursor_next_match(qm.cursor.inner(), m)
+		runtime.KeepAlive(qm.cursor)
+		runtime.KeepAlive(qm.query)
+		if hasMatch {
+			result := newQueryMatch(m, qm.cursor.inner())
+			satisfies, err := result.satisfiesPredicates(
 				qm.query,
 				qm.buffer1,
 				qm.buffer2,
 				qm.callback,
-			) {
+			)
+			if err != nil {
+				qm.err = err
+				return nil
+			}
+			if satisfies {
 				return &result
 			}
 		} else {
+			if qm.progress != nil && qm.progress.err != nil {
+				qm.err = qm.progress.err
+			}
 			return nil
 		}
 	}
@@ -1157,45 +1771,104 @@ func (qc *QueryCaptures) Next() (*QueryMatch, uint) {
 	for {
 		m := (*C.TSQueryMatch)(C.malloc(C.sizeof_TSQueryMatch))
 		var captureIndex C.uint32_t
-		if C.ts_query_cursor_next_capture(qc._inner, m, &captureIndex) {
-			result := newQueryMatch(m, qc._inner)
-			if result.SatisfiesTextPredicateWith(
+		hasCapture := C.ts_query_cursor_next_capture(qc.cursor.inner(), m, &captureIndex)
+		runtime.KeepAlive(qc.cursor)
+		runtime.KeepAlive(qc.query)
+		if hasCapture {
+			result := newQueryMatch(m, qc.cursor.inner())
+			satisfies, err := result.satisfiesPredicates(
 				qc.query,
 				qc.buffer1,
 				qc.buffer2,
 				qc.callback,
-			) {
+			)
+			if err != nil {
+				qc.err = err
+				return nil, 0
+			}
+			if satisfies {
 				return &result, uint(captureIndex)
 			}
 			result.Remove()
 		} else {
+			if qc.progress != nil && qc.progress.err != nil {
+				qc.err = qc.progress.err
+			}
 			return nil, 0
 		}
 	}
 }
 
+// Get the error that ended the sequence of matches early, if any: either
+// one returned by a registered predicate, or the error of the context given
+// to [QueryCursor.MatchesCtx].
+func (qm *QueryMatches) Err() error {
+	return qm.err
+}
+
+// Get the error that ended the sequence of captures early, if any: either
+// one returned by a registered predicate, or the error of the context given
+// to [QueryCursor.CapturesCtx].
+func (qc *QueryCaptures) Err() error {
+	return qc.err
+}
+
+// Check whether the cursor has exceeded its match limit while finding the
+// matches so far, so that some matches may have been dropped. Once
+// [QueryMatches.Next] has returned nil, this tells whether the matches were
+// complete.
+//
+// This is the same as [QueryCursor.DidExceedMatchLimit], so it reflects the
+// last execution of the cursor.
+func (qm *QueryMatches) DidExceedMatchLimit() bool {
+	return bool(C.ts_query_cursor_did_exceed_match_limit(qm.cursor.inner()))
+}
+
+// Check whether the cursor has exceeded its match limit while finding the
+// captures so far, like [QueryMatches.DidExceedMatchLimit].
+func (qc *QueryCaptures) DidExceedMatchLimit() bool {
+	return bool(C.ts_query_cursor_did_exceed_match_limit(qc.cursor.inner()))
+}
+
 func (qm *QueryMatches) SetByteRange(startByte uint, endByte uint) {
-	C.ts_query_cursor_set_byte_range(qm._inner, C.uint32_t(startByte), C.uint32_t(endByte))
+	C.ts_query_cursor_set_byte_range(qm.cursor.inner(), C.uint32_t(startByte), C.uint32_t(endByte))
 }
 
 func (qm *QueryMatches) SetPointRange(startPoint Point, endPoint Point) {
-	C.ts_query_cursor_set_point_range(qm._inner, startPoint.toTSPoint(), endPoint.toTSPoint())
+	C.ts_query_cursor_set_point_range(qm.cursor.inner(), startPoint.toTSPoint(), endPoint.toTSPoint())
 }
 
 func (qc *QueryCaptures) SetByteRange(startByte uint, endByte uint) {
-	C.ts_query_cursor_set_byte_range(qc._inner, C.uint32_t(startByte), C.uint32_t(endByte))
+	C.ts_query_cursor_set_byte_range(qc.cursor.inner(), C.uint32_t(startByte), C.uint32_t(endByte))
 }
 
 func (qc *QueryCaptures) SetPointRange(startPoint Point, endPoint Point) {
-	C.ts_query_cursor_set_point_range(qc._inner, startPoint.toTSPoint(), endPoint.toTSPoint())
-}
-
-func predicateError(row uint, message string) *QueryError {
+	C.ts_query_cursor_set_point_range(qc.cursor.inner(), startPoint.toTSPoint(), endPoint.toTSPoint())
+}
+
+// Build an error for an invalid predicate in the pattern between the given
+// byte offsets. The tokens are looked for in the pattern one after another,
+// such as a predicate's operator and then one of its arguments, and the
+// error is located at the last one that is found, or at the pattern's start
+// if none is.
+func predicateError(source string, patternStart, patternEnd uint, message string, tokens ...string) *QueryError {
+	offset := patternStart
+	var token string
+	for _, t := range tokens {
+		i := strings.Index(source[offset:patternEnd], t)
+		if i < 0 {
+			break
+		}
+		offset += uint(i)
+		token = t
+	}
+	row := uint(strings.Count(source[:offset], "\n"))
 	return &QueryError{
 		Kind:    QueryErrorPredicate,
 		Row:     row,
-		Column:  0,
-		Offset:  0,
+		Column:  offset - uint(strings.LastIndexByte(source[:offset], '\n')+1),
+		Offset:  offset,
+		Token:   token,
 		Message: message,
 	}
 }
@@ -0,0 +1,330 @@
+package tree_sitter
+
+import (
+	"strings"
+)
+
+// A QueryPattern is a pattern of a [Query] built in code rather than
+// written in the query syntax, so that node kinds, strings and captures
+// that come from elsewhere need no escaping, and the parentheses are always
+// balanced.
+//
+// Patterns are created with [NodePattern], [AnonymousPattern],
+// [WildcardPattern], [AlternativesPattern] and [GroupPattern], extended
+// with the methods of QueryPattern, and compiled with
+// [NewQueryFromPatterns]. The methods change the pattern that they are
+// called on and return it, so that they can be chained:
+//
+//	pattern := NodePattern("call_expression").
+//		Field("function", NodePattern("identifier").Capture("fn")).
+//		Predicate("eq?", CaptureArg("fn"), StringArg("Println"))
+//
+// which is the pattern
+//
+//	((call_expression function: (identifier) @fn) (#eq? @fn "Println"))
+type QueryPattern struct {
+	kind queryPatternKind
+	// The node kind of a node pattern, or the text of an anonymous one.
+	text         string
+	children     []queryPatternChild
+	alternatives []*QueryPattern
+	quantifier   string
+	captures     []string
+	predicates   []queryPatternPredicate
+}
+
+type queryPatternKind int
+
+const (
+	queryPatternNode queryPatternKind = iota
+	queryPatternAnonymous
+	queryPatternWildcard
+	queryPatternAlternatives
+	queryPatternGroup
+)
+
+type queryPatternChild struct {
+	field   string
+	negated bool
+	anchor  bool
+	pattern *QueryPattern
+}
+
+type queryPatternPredicate struct {
+	operator string
+	args     []PatternArg
+}
+
+// An argument of a predicate of a [QueryPattern], created with
+// [CaptureArg] or [StringArg].
+type PatternArg struct {
+	capture bool
+	value   string
+}
+
+// Create a predicate argument that refers to the capture with the given
+// name, without the leading `@`.
+func CaptureArg(name string) PatternArg {
+	return PatternArg{capture: true, value: name}
+}
+
+// Create a predicate argument that is the given string.
+func StringArg(value string) PatternArg {
+	return PatternArg{value: value}
+}
+
+// Create a pattern that matches a named node of the given kind, such as
+// `(identifier)`. The kind `_` matches any named node.
+func NodePattern(kind string) *QueryPattern {
+	return &QueryPattern{kind: queryPatternNode, text: kind}
+}
+
+// Create a pattern that matches an anonymous node with the given text, such
+// as `"+"`.
+func AnonymousPattern(text string) *QueryPattern {
+	return &QueryPattern{kind: queryPatternAnonymous, text: text}
+}
+
+// Create a pattern that matches any node, named or anonymous, which is
+// `_` in the query syntax.
+func WildcardPattern() *QueryPattern {
+	return &QueryPattern{kind: queryPatternWildcard}
+}
+
+// Create a pattern that matches any of the given patterns, such as
+// `[(identifier) (number)]`.
+func AlternativesPattern(alternatives ...*QueryPattern) *QueryPattern {
+	return &QueryPattern{kind: queryPatternAlternatives, alternatives: alternatives}
+}
+
+// Create a pattern that matches a sequence of sibling nodes, such as
+// `((comment) (function_declaration))`.
+func GroupPattern(siblings ...*QueryPattern) *QueryPattern {
+	p := &QueryPattern{kind: queryPatternGroup}
+	return p.Child(siblings...)
+}
+
+// Add child patterns, which only node and group patterns can have.
+func (p *QueryPattern) Child(children ...*QueryPattern) *QueryPattern {
+	for _, child := range children {
+		p.addChild(queryPatternChild{pattern: child})
+	}
+	return p
+}
+
+// Add a child pattern for the field with the given name, such as
+// `name: (identifier)`.
+func (p *QueryPattern) Field(name string, child *QueryPattern) *QueryPattern {
+	p.addChild(queryPatternChild{field: name, pattern: child})
+	return p
+}
+
+// Require the node to have no child for the field with the given name,
+// which is `!name` in the query syntax.
+func (p *QueryPattern) NegatedField(name string) *QueryPattern {
+	p.addChild(queryPatternChild{field: name, negated: true})
+	return p
+}
+
+// Add an anchor, which is `.` in the query syntax. Before the first child
+// pattern, it makes the child match the first named child, after the last
+// one it makes the child match the last named child, and between two child
+// patterns it makes them match immediate siblings.
+func (p *QueryPattern) Anchor() *QueryPattern {
+	p.addChild(queryPatternChild{anchor: true})
+	return p
+}
+
+func (p *QueryPattern) addChild(child queryPatternChild) {
+	if p.kind != queryPatternNode && p.kind != queryPatternGroup {
+		panic("tree_sitter: children can only be added to node and group patterns")
+	}
+	p.children = append(p.children, child)
+}
+
+// Capture the nodes that the pattern matches with the given name, without
+// the leading `@`. A pattern can have several captures.
+func (p *QueryPattern) Capture(name string) *QueryPattern {
+	p.captures = append(p.captures, name)
+	return p
+}
+
+// Make the pattern optional, which is `?` in the query syntax.
+func (p *QueryPattern) Optional() *QueryPattern {
+	p.quantifier = "?"
+	return p
+}
+
+// Make the pattern match zero or more times, which is `*` in the query
+// syntax.
+func (p *QueryPattern) ZeroOrMore() *QueryPattern {
+	p.quantifier = "*"
+	return p
+}
+
+// Make the pattern match one or more times, which is `+` in the query
+// syntax.
+func (p *QueryPattern) OneOrMore() *QueryPattern {
+	p.quantifier = "+"
+	return p
+}
+
+// Add a predicate with the given operator, with or without the leading
+// `#`, such as `eq?` or `set!`.
+//
+// Since a predicate can only refer to captures that come before it, the
+// predicates of all the nested patterns are written at the end of the
+// outermost pattern, in the order in which they were added to each
+// pattern.
+func (p *QueryPattern) Predicate(operator string, args ...PatternArg) *QueryPattern {
+	p.predicates = append(p.predicates, queryPatternPredicate{
+		operator: strings.TrimPrefix(operator, "#"),
+		args:     args,
+	})
+	return p
+}
+
+// Get the pattern in the query syntax.
+func (p *QueryPattern) String() string {
+	var sb strings.Builder
+	var predicates []queryPatternPredicate
+	p.collectPredicates(&predicates)
+	if len(predicates) == 0 {
+		p.write(&sb)
+		return sb.String()
+	}
+
+	sb.WriteByte('(')
+	if p.kind == queryPatternWildcard {
+		// `(_` would start a named wildcard instead.
+		sb.WriteString("[_]")
+		p.writeSuffix(&sb)
+	} else {
+		p.write(&sb)
+	}
+	for _, predicate := range predicates {
+		sb.WriteString(" (#")
+		sb.WriteString(predicate.operator)
+		for _, arg := range predicate.args {
+			sb.WriteByte(' ')
+			if arg.capture {
+				sb.WriteByte('@')
+				sb.WriteString(arg.value)
+			} else {
+				writeQueryString(&sb, arg.value)
+			}
+		}
+		sb.WriteByte(')')
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+func (p *QueryPattern) collectPredicates(predicates *[]queryPatternPredicate) {
+	for _, child := range p.children {
+		if child.pattern != nil {
+			child.pattern.collectPredicates(predicates)
+		}
+	}
+	for _, alternative := range p.alternatives {
+		alternative.collectPredicates(predicates)
+	}
+	*predicates = append(*predicates, p.predicates...)
+}
+
+func (p *QueryPattern) write(sb *strings.Builder) {
+	switch p.kind {
+	case queryPatternNode, queryPatternGroup:
+		sb.WriteByte('(')
+		if p.kind == queryPatternNode {
+			sb.WriteString(p.text)
+		}
+		for i, child := range p.children {
+			if i > 0 || p.kind == queryPatternNode {
+				sb.WriteByte(' ')
+			}
+			switch {
+			case child.anchor:
+				sb.WriteByte('.')
+			case child.negated:
+				sb.WriteByte('!')
+				sb.WriteString(child.field)
+			default:
+				if child.field != "" {
+					sb.WriteString(child.field)
+					sb.WriteString(": ")
+				}
+				child.pattern.write(sb)
+			}
+		}
+		sb.WriteByte(')')
+	case queryPatternAnonymous:
+		writeQueryString(sb, p.text)
+	case queryPatternWildcard:
+		sb.WriteByte('_')
+	case queryPatternAlternatives:
+		sb.WriteByte('[')
+		for i, alternative := range p.alternatives {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			alternative.write(sb)
+		}
+		sb.WriteByte(']')
+	}
+	p.writeSuffix(sb)
+}
+
+// Write the quantifier and the captures that follow the pattern.
+func (p *QueryPattern) writeSuffix(sb *strings.Builder) {
+	sb.WriteString(p.quantifier)
+	for _, capture := range p.captures {
+		sb.WriteString(" @")
+		sb.WriteString(capture)
+	}
+}
+
+// Write a string literal of the query syntax, which has fewer escape
+// sequences than a Go string literal.
+func writeQueryString(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case 0:
+			sb.WriteString(`\0`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// Create a new query for the given language from patterns built in code,
+// with a pattern index for each of the patterns in the order given.
+//
+// Along with the query, this returns the index of each capture name used
+// in the patterns, as [Query.CaptureIndexForName] would find it.
+func NewQueryFromPatterns(language *Language, patterns ...*QueryPattern) (*Query, map[string]uint, *QueryError) {
+	sources := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		sources[i] = pattern.String()
+	}
+	query, err := NewQuery(language, strings.Join(sources, "\n"))
+	if err != nil {
+		return nil, nil, err
+	}
+	captures := make(map[string]uint, len(query.CaptureNames()))
+	for i, name := range query.CaptureNames() {
+		captures[name] = uint(i)
+	}
+	return query, captures, nil
+}
@@ -0,0 +1,153 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestQueryPatternString(t *testing.T) {
+	pattern := NodePattern("call_expression").
+		Field("function", NodePattern("identifier").Capture("fn")).
+		Predicate("eq?", CaptureArg("fn"), StringArg("Println"))
+	assert.Equal(t, `((call_expression function: (identifier) @fn) (#eq? @fn "Println"))`, pattern.String())
+
+	assert.Equal(t, `"\"\\\n\t" @token`, AnonymousPattern("\"\\\n\t").Capture("token").String())
+	assert.Equal(t, `([_] @any (#not-eq? @any "x"))`, WildcardPattern().Capture("any").Predicate("#not-eq?", CaptureArg("any"), StringArg("x")).String())
+	assert.Equal(t, `(_ . (comment)* @c .)`, NodePattern("_").Anchor().Child(NodePattern("comment").ZeroOrMore().Capture("c")).Anchor().String())
+	assert.Equal(t, `((comment)+ [(function_declaration) (method_declaration)]? @decl @def)`,
+		GroupPattern(
+			NodePattern("comment").OneOrMore(),
+			AlternativesPattern(NodePattern("function_declaration"), NodePattern("method_declaration")).
+				Optional().Capture("decl").Capture("def"),
+		).String())
+
+	assert.Panics(t, func() { AnonymousPattern("+").Child(NodePattern("identifier")) })
+	assert.Panics(t, func() { WildcardPattern().Field("name", NodePattern("identifier")) })
+}
+
+func TestNewQueryFromPatterns(t *testing.T) {
+	language := getLanguage("go")
+	source := `package main
+
+import "fmt"
+
+// Greet says hello.
+func Greet(name string) {
+	fmt.Println("hello", name)
+	fmt.Printf("%s\n", name)
+}
+
+// Add adds.
+func Add(a, b int) int { return a + b }
+
+func (s *S) String() string { return "s" }
+
+func bare() {}
+`
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	rows := []struct {
+		description string
+		pattern     *QueryPattern
+		handWritten string
+	}{
+		{
+			description: "calls of a method on a package",
+			pattern: NodePattern("call_expression").
+				Field("function", NodePattern("selector_expression").
+					Field("operand", NodePattern("identifier").Capture("pkg")).
+					Field("field", NodePattern("field_identifier").Capture("fn"))).
+				Predicate("eq?", CaptureArg("pkg"), StringArg("fmt")).
+				Predicate("match?", CaptureArg("fn"), StringArg("^Print(ln)?$")),
+			handWritten: `((call_expression
+				function: (selector_expression
+					operand: (identifier) @pkg
+					field: (field_identifier) @fn))
+				(#eq? @pkg "fmt")
+				(#match? @fn "^Print(ln)?$"))`,
+		},
+		{
+			description: "documented functions without a receiver",
+			pattern: GroupPattern(NodePattern("comment").OneOrMore().Capture("doc")).
+				Anchor().
+				Child(NodePattern("function_declaration").
+					Field("name", NodePattern("identifier").Capture("name"))),
+			handWritten: `((comment)+ @doc . (function_declaration name: (identifier) @name))`,
+		},
+		{
+			description: "functions with an optional result and methods without type parameters",
+			pattern: AlternativesPattern(
+				NodePattern("function_declaration").
+					Field("name", NodePattern("identifier").Capture("name")).
+					Field("result", WildcardPattern().Optional().Capture("result")),
+				NodePattern("method_declaration").
+					Field("name", NodePattern("field_identifier").Capture("name")).
+					NegatedField("type_parameters"),
+			).Capture("decl"),
+			handWritten: `[
+				(function_declaration name: (identifier) @name result: _? @result)
+				(method_declaration name: (field_identifier) @name !type_parameters)
+			] @decl`,
+		},
+		{
+			description: "the first parameter of each function",
+			pattern: NodePattern("parameter_list").
+				Anchor().
+				Child(NodePattern("parameter_declaration").
+					Field("name", NodePattern("identifier").Capture("first"))),
+			handWritten: `(parameter_list . (parameter_declaration name: (identifier) @first))`,
+		},
+		{
+			description: "string arguments that need escaping",
+			pattern: NodePattern("argument_list").
+				Child(NodePattern("interpreted_string_literal").Capture("format")).
+				Predicate("eq?", CaptureArg("format"), StringArg(`"%s\n"`)),
+			handWritten: `((argument_list (interpreted_string_literal) @format) (#eq? @format "\"%s\\n\""))`,
+		},
+	}
+
+	for _, row := range rows {
+		built, captures, err := NewQueryFromPatterns(language, row.pattern)
+		if !assert.Nil(t, err, row.description) {
+			continue
+		}
+		handWritten, err := NewQuery(language, row.handWritten)
+		if !assert.Nil(t, err, row.description) {
+			built.Close()
+			continue
+		}
+
+		expected := collectMatches(cursor.Matches(handWritten, tree.RootNode(), []byte(source)), handWritten, source)
+		assert.NotEmpty(t, expected, row.description)
+		assert.Equal(t, expected, collectMatches(cursor.Matches(built, tree.RootNode(), []byte(source)), built, source), row.description)
+		for i, name := range built.CaptureNames() {
+			assert.Equal(t, uint(i), captures[name], row.description)
+		}
+		assert.Len(t, captures, len(built.CaptureNames()), row.description)
+		built.Close()
+		handWritten.Close()
+	}
+
+	// Several patterns get their indices in order.
+	query, captures, err := NewQueryFromPatterns(language,
+		NodePattern("function_declaration").Capture("function"),
+		NodePattern("method_declaration").Capture("method"),
+	)
+	assert.Nil(t, err)
+	defer query.Close()
+	assert.EqualValues(t, 2, query.PatternCount())
+	assert.Equal(t, map[string]uint{"function": 0, "method": 1}, captures)
+
+	_, _, err = NewQueryFromPatterns(language, NodePattern("function_defintion"))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, QueryErrorNodeType, err.Kind)
+	}
+}
@@ -0,0 +1,201 @@
+package tree_sitter
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+// ErrQueryCacheClosed is returned by [QueryCache.Get] once the cache has
+// been closed.
+var ErrQueryCacheClosed = errors.New("query cache is closed")
+
+// A QueryCache holds compiled queries, keyed by their language and source,
+// so that a query is compiled once and then shared, instead of being
+// compiled again wherever it is needed.
+//
+// The cache holds at most a fixed number of queries, and evicts the least
+// recently used one to make room for another. A query that has been
+// evicted is only closed once every caller that got it from
+// [QueryCache.Get] has given it back with [QueryCache.Put], so it stays
+// usable until then.
+//
+// Unlike a [Query], a cache is safe for concurrent use. The queries it
+// hands out are shared between callers, so they must not be modified, such
+// as with [Query.DisableCapture] or [Query.RegisterPredicate], and must not
+// be closed other than by the cache.
+type QueryCache struct {
+	max int
+
+	mu       sync.Mutex
+	entries  map[queryCacheKey]*queryCacheEntry
+	borrowed map[*Query]*queryCacheEntry
+	// The cached entries, from the most to the least recently used.
+	recent *list.List
+	closed bool
+}
+
+type queryCacheKey struct {
+	// The language's C pointer, since separate [Language] values can refer
+	// to the same language.
+	language unsafe.Pointer
+	source   string
+}
+
+type queryCacheEntry struct {
+	key queryCacheKey
+
+	// Closed once the query has been compiled, after which query and err
+	// no longer change.
+	ready chan struct{}
+	query *Query
+	err   error
+
+	// The number of callers that have the query, including the one that
+	// compiles it.
+	refs    int
+	evicted bool
+	element *list.Element
+}
+
+// Create a new cache that holds at most `max` compiled queries.
+func NewQueryCache(max int) *QueryCache {
+	if max < 1 {
+		max = 1
+	}
+	return &QueryCache{
+		max:      max,
+		entries:  make(map[queryCacheKey]*queryCacheEntry),
+		borrowed: make(map[*Query]*queryCacheEntry),
+		recent:   list.New(),
+	}
+}
+
+// Get the query for the given language and source, compiling it if it is
+// not in the cache.
+//
+// If several callers ask for the same query at once, it is compiled only
+// once, and they all wait for it. An error from compiling the query, which
+// is a [*QueryError], is returned to each of them, and the failed query is
+// not cached.
+//
+// The query must be given back with [QueryCache.Put] rather than closed.
+func (qc *QueryCache) Get(language *Language, source string) (*Query, error) {
+	key := queryCacheKey{language: unsafe.Pointer(language.Inner), source: source}
+
+	qc.mu.Lock()
+	if qc.closed {
+		qc.mu.Unlock()
+		return nil, ErrQueryCacheClosed
+	}
+	if entry, ok := qc.entries[key]; ok {
+		entry.refs++
+		qc.recent.MoveToFront(entry.element)
+		qc.mu.Unlock()
+
+		<-entry.ready
+		if entry.err != nil {
+			qc.release(entry)
+			return nil, entry.err
+		}
+		return entry.query, nil
+	}
+
+	entry := &queryCacheEntry{key: key, ready: make(chan struct{}), refs: 1}
+	entry.element = qc.recent.PushFront(entry)
+	qc.entries[key] = entry
+	qc.evict()
+	qc.mu.Unlock()
+
+	query, err := NewQuery(language, source)
+
+	qc.mu.Lock()
+	if err != nil {
+		entry.err = err
+		qc.remove(entry)
+	} else {
+		entry.query = query
+		qc.borrowed[query] = entry
+	}
+	close(entry.ready)
+	qc.mu.Unlock()
+
+	if err != nil {
+		qc.release(entry)
+		return nil, err
+	}
+	return query, nil
+}
+
+// Give back a query obtained from [QueryCache.Get]. Each query that was
+// gotten must be given back exactly once.
+//
+// The query must not be used afterwards, since it is closed once it has
+// been evicted and every caller has given it back. Queries that did not
+// come from the cache are ignored.
+func (qc *QueryCache) Put(query *Query) {
+	qc.mu.Lock()
+	entry, ok := qc.borrowed[query]
+	qc.mu.Unlock()
+	if ok {
+		qc.release(entry)
+	}
+}
+
+// Get the number of queries in the cache, not counting evicted queries that
+// are still in use.
+func (qc *QueryCache) Len() int {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	return len(qc.entries)
+}
+
+// Evict every query from the cache, closing those that are not in use.
+// Queries that are still in use are closed when they are given back with
+// [QueryCache.Put].
+//
+// After Close, [QueryCache.Get] returns [ErrQueryCacheClosed].
+func (qc *QueryCache) Close() {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.closed = true
+	for _, entry := range qc.entries {
+		qc.remove(entry)
+	}
+}
+
+func (qc *QueryCache) release(entry *queryCacheEntry) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	entry.refs--
+	qc.closeIfUnused(entry)
+}
+
+// Evict the least recently used entries until the cache is within its
+// size. This must be called with the mutex held.
+func (qc *QueryCache) evict() {
+	for len(qc.entries) > qc.max {
+		qc.remove(qc.recent.Back().Value.(*queryCacheEntry))
+	}
+}
+
+// Remove an entry from the cache, closing its query if it is not in use.
+// This must be called with the mutex held.
+func (qc *QueryCache) remove(entry *queryCacheEntry) {
+	if entry.evicted {
+		return
+	}
+	entry.evicted = true
+	delete(qc.entries, entry.key)
+	qc.recent.Remove(entry.element)
+	qc.closeIfUnused(entry)
+}
+
+// This must be called with the mutex held.
+func (qc *QueryCache) closeIfUnused(entry *queryCacheEntry) {
+	if entry.evicted && entry.refs == 0 && entry.query != nil {
+		delete(qc.borrowed, entry.query)
+		entry.query.Close()
+	}
+}
@@ -0,0 +1,162 @@
+package tree_sitter_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestQueryCacheSharesQueries(t *testing.T) {
+	cache := NewQueryCache(4)
+	defer cache.Close()
+
+	query, err := cache.Get(getLanguage("json"), "(string) @string")
+	assert.NoError(t, err)
+	// Another value for the same language finds the same query.
+	again, err := cache.Get(NewLanguage(unsafe.Pointer(getLanguage("json").Inner)), "(string) @string")
+	assert.NoError(t, err)
+	assert.Same(t, query, again)
+	cache.Put(again)
+
+	other, err := cache.Get(getLanguage("json"), "(number) @number")
+	assert.NoError(t, err)
+	assert.NotSame(t, query, other)
+	assert.Equal(t, 2, cache.Len())
+	cache.Put(other)
+	cache.Put(query)
+
+	// A query that fails to compile returns its error and is not cached.
+	_, err = cache.Get(getLanguage("json"), "(strin) @string")
+	var queryErr *QueryError
+	if assert.ErrorAs(t, err, &queryErr) {
+		assert.Equal(t, QueryErrorNodeType, queryErr.Kind)
+	}
+	assert.Equal(t, 2, cache.Len())
+
+	// Queries that did not come from the cache are ignored.
+	cache.Put(nil)
+	cache.Put(&Query{})
+}
+
+func TestQueryCacheEviction(t *testing.T) {
+	cache := NewQueryCache(2)
+	language := getLanguage("json")
+	source := `{"a": [1, "b"]}`
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	captures := func(query *Query) []formattedCapture {
+		return collectCaptures(cursor.Captures(query, tree.RootNode(), []byte(source)), query, source)
+	}
+
+	stringQuery, err := cache.Get(language, "(string) @string")
+	assert.NoError(t, err)
+	numberQuery, err := cache.Get(language, "(number) @number")
+	assert.NoError(t, err)
+	cache.Put(numberQuery)
+
+	// Using the stringQuery query makes the numberQuery query the least recent one.
+	again, err := cache.Get(language, "(string) @string")
+	assert.NoError(t, err)
+	cache.Put(again)
+	pairQuery, err := cache.Get(language, "(pair) @pair")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cache.Len())
+	// The evicted query was not in use, so it is closed right away.
+	assert.Panics(t, func() { numberQuery.PatternCount() })
+
+	// Evicting a query that is in use leaves it usable until it is put back.
+	arrayQuery, err := cache.Get(language, "(array) @array")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, []formattedCapture{{"string", `"a"`}, {"string", `"b"`}}, captures(stringQuery))
+	cache.Put(stringQuery)
+	assert.Panics(t, func() { stringQuery.PatternCount() })
+
+	// Queries that are in use when the cache is closed are closed when they
+	// are put back.
+	cache.Close()
+	assert.Equal(t, 0, cache.Len())
+	assert.Equal(t, []formattedCapture{{"array", `[1, "b"]`}}, captures(arrayQuery))
+	cache.Put(arrayQuery)
+	cache.Put(pairQuery)
+	assert.Panics(t, func() { arrayQuery.PatternCount() })
+	assert.Panics(t, func() { pairQuery.PatternCount() })
+
+	_, err = cache.Get(language, "(string) @string")
+	assert.ErrorIs(t, err, ErrQueryCacheClosed)
+}
+
+func TestQueryCacheFromManyGoroutines(t *testing.T) {
+	language := getLanguage("json")
+	source := `{"a": [1, "b", true, null]}`
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+
+	kinds := []string{"string", "number", "true", "null", "pair", "array"}
+	expected := map[string]int{"string": 2, "number": 1, "true": 1, "null": 1, "pair": 1, "array": 1}
+
+	// The cache is smaller than the number of queries, so that queries are
+	// evicted while they are in use.
+	cache := NewQueryCache(3)
+	defer cache.Close()
+	var wg sync.WaitGroup
+	for i := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tree := tree.Clone()
+			defer tree.Close()
+			cursor := NewQueryCursor()
+			defer cursor.Close()
+			for j := range 200 {
+				kind := kinds[(i+j)%len(kinds)]
+				query, err := cache.Get(language, fmt.Sprintf("(%s) @node", kind))
+				if !assert.NoError(t, err) {
+					return
+				}
+				count := 0
+				captures := cursor.Captures(query, tree.RootNode(), []byte(source))
+				for match, _ := captures.Next(); match != nil; match, _ = captures.Next() {
+					count++
+				}
+				assert.Equal(t, expected[kind], count, kind)
+				cache.Put(query)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, cache.Len(), 3)
+}
+
+func TestQueryCacheCompilesOnce(t *testing.T) {
+	cache := NewQueryCache(1)
+	defer cache.Close()
+
+	// Every caller that asks for the query at once gets the same one.
+	queries := make([]*Query, 16)
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queries[i], _ = cache.Get(getLanguage("rust"), "(function_item name: (identifier) @name)")
+		}()
+	}
+	wg.Wait()
+	for _, query := range queries {
+		assert.Same(t, queries[0], query)
+		cache.Put(query)
+	}
+}
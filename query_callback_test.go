@@ -1,6 +1,7 @@
 package tree_sitter_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -55,7 +56,7 @@ func TestCapturesWithPartialCallbacks(t *testing.T) {
 		}
 		matchCount++
 		for _, capture := range match.Captures {
-			captureText := string(getTextForTestNode(capture.Node, sourceCode))
+			captureText := capture.Node.Utf8Text(sourceCode)
 			results = append(results, captureText)
 		}
 	}
@@ -108,7 +109,7 @@ func TestCapturesWithSingleByteCallbacks(t *testing.T) {
 			break
 		}
 		for _, capture := range match.Captures {
-			captureText := string(getTextForTestNode(capture.Node, sourceCode))
+			captureText := capture.Node.Utf8Text(sourceCode)
 			results = append(results, captureText)
 		}
 	}
@@ -161,7 +162,7 @@ func TestCapturesWithEmptyCallback(t *testing.T) {
 			break
 		}
 		for _, capture := range match.Captures {
-			captureText := string(getTextForTestNode(capture.Node, sourceCode))
+			captureText := capture.Node.Utf8Text(sourceCode)
 			results = append(results, captureText)
 		}
 	}
@@ -171,7 +172,88 @@ func TestCapturesWithEmptyCallback(t *testing.T) {
 	assert.GreaterOrEqual(t, len(results), 0) // At minimum, no panic/error
 }
 
-// Helper function to get text for a node (for comparison)
-func getTextForTestNode(node Node, source []byte) []byte {
-	return source[node.StartByte():node.EndByte()]
-}
\ No newline at end of file
+// Get a text callback that gives the source at most `size` bytes at a time.
+func chunkedText(source []byte, size int) func(int, Point) []byte {
+	return func(offset int, position Point) []byte {
+		if offset >= len(source) {
+			return []byte{}
+		}
+		return source[offset:min(offset+size, len(source))]
+	}
+}
+
+func TestCapturesWithChunkedPredicateText(t *testing.T) {
+	language := getLanguage("go")
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+
+	source := "package main\nvar x = x\nvar y = z\nvar a = \"héllo wörld\"\nvar b = \"hello\"\nvar c = \"\xe2llo\"\n"
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	queries := []string{
+		`((var_spec name: (identifier) @name value: (expression_list (identifier) @value)) (#eq? @name @value))`,
+		`((var_spec name: (identifier) @name value: (expression_list (identifier) @value)) (#not-eq? @name @value))`,
+		`((interpreted_string_literal) @s (#eq? @s "\"hello\""))`,
+		`((interpreted_string_literal) @s (#not-eq? @s "\"hello\""))`,
+		`((interpreted_string_literal) @s (#match? @s "^\"h.llo( w.rld)?\"$"))`,
+		`((interpreted_string_literal) @s (#not-match? @s "ö"))`,
+		`((interpreted_string_literal) @s (#match? @s "^\".llo\"$"))`,
+		`((interpreted_string_literal) @s (#any-of? @s "\"hello\"" "\"héllo wörld\"" "x"))`,
+		`((interpreted_string_literal) @s (#not-any-of? @s "\"hello\"" "x"))`,
+	}
+	for _, querySource := range queries {
+		query, err := NewQuery(language, querySource)
+		if !assert.Nil(t, err, querySource) {
+			continue
+		}
+		expected := collectCaptures(cursor.Captures(query, tree.RootNode(), []byte(source)), query, source)
+		assert.NotEmpty(t, expected, querySource)
+		for _, size := range []int{1, 2, 3, 7} {
+			captures := cursor.CapturesWith(query, tree.RootNode(), chunkedText([]byte(source), size))
+			assert.Equal(t, expected, collectCaptures(captures, query, source), "%s in chunks of %d", querySource, size)
+		}
+		query.Close()
+	}
+}
+
+func benchmarkLargeCapturePredicate(b *testing.B, predicate string) {
+	language := getLanguage("go")
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	source := []byte("package main\n\nvar s = \"" + strings.Repeat("x", 1<<20) + "\"\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	query, err := NewQuery(language, "((interpreted_string_literal) @s "+predicate+")")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer query.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	text := chunkedText(source, 4096)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		captures := cursor.CapturesWith(query, tree.RootNode(), text)
+		for match, _ := captures.Next(); match != nil; match, _ = captures.Next() {
+		}
+	}
+}
+
+func BenchmarkCapturesWithLargeEqPredicate(b *testing.B) {
+	benchmarkLargeCapturePredicate(b, `(#eq? @s "\"xxxx\"")`)
+}
+
+func BenchmarkCapturesWithLargeAnyOfPredicate(b *testing.B) {
+	benchmarkLargeCapturePredicate(b, `(#any-of? @s "\"a\"" "\"b\"")`)
+}
+
+func BenchmarkCapturesWithLargeMatchPredicate(b *testing.B) {
+	benchmarkLargeCapturePredicate(b, `(#match? @s "^\"y")`)
+}
@@ -0,0 +1,184 @@
+package tree_sitter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// One of the sources of a query made by [NewQueryFromSources].
+type NamedQuerySource struct {
+	// The name that errors and [Query.PatternOrigin] refer to the source
+	// by, such as the path of the file that it was read from.
+	Name   string
+	Source string
+}
+
+// An error in one of the sources of a query made by [NewQueryFromSources]
+// or [LoadQuery]. Its row, column and offset are those in that source,
+// rather than in the query made from all of the sources.
+type QueryFileError struct {
+	// The name of the source, which for [LoadQuery] is the path of the file
+	// in its file system, such as "queries/c/highlights.scm".
+	Name string
+	QueryError
+}
+
+func (e *QueryFileError) Error() string {
+	if e.Kind == QueryErrorLanguage {
+		return fmt.Sprintf("%s: %s", e.Name, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.Name, e.Row+1, e.Column+1, e.describe())
+}
+
+func (e *QueryFileError) Unwrap() error {
+	return &e.QueryError
+}
+
+// Create a new query for the given language from several sources, which
+// are compiled together as one query, in the order given, so that their
+// patterns can be run in a single pass of a [QueryCursor].
+//
+// The pattern indices of each source follow those of the sources before
+// it, and [Query.PatternOrigin] tells which source, and which line of it,
+// each pattern came from. An error in the query is returned as a
+// [*QueryFileError], with the name of the source that it is in.
+func NewQueryFromSources(language *Language, sources []NamedQuerySource) (*Query, error) {
+	// The byte offset and the row at which each source starts.
+	offsets := make([]uint, len(sources))
+	rows := make([]uint, len(sources))
+	var sb strings.Builder
+	var row uint
+	for i, source := range sources {
+		offsets[i] = uint(sb.Len())
+		rows[i] = row
+		sb.WriteString(source.Source)
+		// Each source starts on a line of its own.
+		if source.Source != "" && !strings.HasSuffix(source.Source, "\n") {
+			sb.WriteByte('\n')
+			row++
+		}
+		row += uint(strings.Count(source.Source, "\n"))
+	}
+
+	// Find the last source that starts at or before the offset.
+	sourceAt := func(offset uint) int {
+		i := len(sources) - 1
+		for i > 0 && offsets[i] > offset {
+			i--
+		}
+		return i
+	}
+
+	query, err := NewQuery(language, sb.String())
+	if err != nil {
+		if len(sources) == 0 {
+			return nil, err
+		}
+		i := sourceAt(err.Offset)
+		fileErr := &QueryFileError{Name: sources[i].Name, QueryError: *err}
+		if err.Kind != QueryErrorLanguage {
+			fileErr.Row -= rows[i]
+			fileErr.Offset -= offsets[i]
+		}
+		return nil, fileErr
+	}
+
+	for i := range query.patternOrigins {
+		j := sourceAt(query.StartByteForPattern(uint(i)))
+		query.patternOrigins[i].name = sources[j].Name
+		query.patternOrigins[i].line -= int(rows[j])
+	}
+	return query, nil
+}
+
+// Load a query of the given kind, such as "highlights", for the named
+// language from a file system laid out the way nvim-treesitter and Helix
+// lay out their queries, with the query in `queries/<language>/<kind>.scm`.
+//
+// If the first line of the file is a directive such as
+//
+//	; inherits: c,cpp
+//
+// then the queries of the same kind for the listed languages are put
+// before it, in the order in which they are listed, and their own
+// directives are followed in the same way. A language whose name is in
+// parentheses, such as `(jsx)`, is optional, and is left out if it has no
+// such file. A language that is inherited more than once is only put in the
+// query the first time, and a language that inherits from itself through
+// others is an error.
+//
+// The query is compiled for the language that `resolve` returns for the
+// named language; the inherited languages are not resolved. An error in
+// the query is returned as a [*QueryFileError] with the file that it came
+// from, which is also the name that [Query.PatternOrigin] gives.
+func LoadQuery(fsys fs.FS, language string, kind string, resolve func(language string) *Language) (*Query, error) {
+	lang := resolve(language)
+	if lang == nil {
+		return nil, fmt.Errorf("no language named %q", language)
+	}
+
+	var sources []NamedQuerySource
+	included := make(map[string]bool)
+
+	var load func(name string, optional bool, chain []string) error
+	load = func(name string, optional bool, chain []string) error {
+		if slices.Contains(chain, name) {
+			return fmt.Errorf("queries inherit from each other: %s -> %s", strings.Join(chain, " -> "), name)
+		}
+		if included[name] {
+			return nil
+		}
+		filePath := path.Join("queries", name, kind+".scm")
+		data, err := fs.ReadFile(fsys, filePath)
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		chain = append(chain, name)
+		for _, parent := range queryInherits(data) {
+			parent, optional := strings.CutPrefix(parent, "(")
+			if optional {
+				parent = strings.TrimSuffix(parent, ")")
+			}
+			if err := load(parent, optional, chain); err != nil {
+				return err
+			}
+		}
+
+		included[name] = true
+		sources = append(sources, NamedQuerySource{Name: filePath, Source: string(data)})
+		return nil
+	}
+	if err := load(language, false, nil); err != nil {
+		return nil, err
+	}
+	return NewQueryFromSources(lang, sources)
+}
+
+// Get the languages listed by the `inherits` directive on the first line of
+// a query file, if it has one.
+func queryInherits(data []byte) []string {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	directive := strings.TrimLeft(strings.TrimSpace(string(line)), ";")
+	directive, ok := strings.CutPrefix(strings.TrimSpace(directive), "inherits")
+	if !ok {
+		return nil
+	}
+	directive = strings.TrimPrefix(strings.TrimSpace(directive), ":")
+
+	var languages []string
+	for _, language := range strings.Split(directive, ",") {
+		if language = strings.TrimSpace(language); language != "" {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
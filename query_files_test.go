@@ -0,0 +1,131 @@
+package tree_sitter_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestLoadQuery(t *testing.T) {
+	fsys := fstest.MapFS{
+		"queries/json/highlights.scm":    {Data: []byte("; inherits: values,(comments),keys\n\n(pair value: (_) @value)\n")},
+		"queries/values/highlights.scm":  {Data: []byte("; inherits: scalars\n(array) @array")},
+		"queries/scalars/highlights.scm": {Data: []byte("(number) @number\n(string) @string\n")},
+		"queries/keys/highlights.scm":    {Data: []byte(";; inherits: scalars\n(pair key: (string) @key)\n")},
+
+		"queries/broken/highlights.scm": {Data: []byte("; inherits: values, typo\n(pair) @pair\n")},
+		"queries/typo/highlights.scm":   {Data: []byte("; A comment.\n(number) @number\n\n  (strin) @string\n")},
+
+		"queries/loop/highlights.scm":  {Data: []byte("; inherits: loop2\n")},
+		"queries/loop2/highlights.scm": {Data: []byte("; inherits: scalars, loop\n")},
+	}
+	resolve := func(name string) *Language {
+		switch name {
+		case "json", "broken", "loop":
+			return getLanguage("json")
+		}
+		return nil
+	}
+
+	query, err := LoadQuery(fsys, "json", "highlights", resolve)
+	if assert.NoError(t, err) {
+		defer query.Close()
+		// The scalars are only included once, before the first language
+		// that inherits them; the optional comments are left out.
+		assert.Equal(t, []string{"number", "string", "array", "key", "value"}, query.CaptureNames())
+		assert.EqualValues(t, 5, query.PatternCount())
+		name, line := query.PatternOrigin(2)
+		assert.Equal(t, "queries/values/highlights.scm", name)
+		assert.Equal(t, 2, line)
+	}
+
+	// Errors are reported in the file they come from.
+	_, err = LoadQuery(fsys, "broken", "highlights", resolve)
+	var fileErr *QueryFileError
+	if assert.ErrorAs(t, err, &fileErr) {
+		assert.Equal(t, "queries/typo/highlights.scm", fileErr.Name)
+		assert.Equal(t, QueryErrorNodeType, fileErr.Kind)
+		assert.EqualValues(t, 3, fileErr.Row)
+		assert.EqualValues(t, 3, fileErr.Column)
+		assert.EqualValues(t, 34, fileErr.Offset)
+		assert.Equal(t, `queries/typo/highlights.scm:4:4: invalid node type "strin"`, err.Error())
+	}
+	var queryErr *QueryError
+	assert.ErrorAs(t, err, &queryErr)
+
+	_, err = LoadQuery(fsys, "loop", "highlights", resolve)
+	assert.EqualError(t, err, "queries inherit from each other: loop -> loop2 -> loop")
+
+	_, err = LoadQuery(fsys, "json", "injections", resolve)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = LoadQuery(fsys, "yaml", "highlights", resolve)
+	assert.EqualError(t, err, `no language named "yaml"`)
+}
+
+func TestNewQueryFromSources(t *testing.T) {
+	language := getLanguage("json")
+	highlights := NamedQuerySource{
+		Name:   "highlights.scm",
+		Source: "(string) @string\n\n; Numbers.\n(number) @number",
+	}
+
+	query, err := NewQueryFromSources(language, []NamedQuerySource{
+		highlights,
+		{Name: "lint.scm", Source: "\n((pair key: (string) @key)\n  (#eq? @key \"\\\"TODO\\\"\"))\n"},
+	})
+	if assert.NoError(t, err) {
+		defer query.Close()
+		assert.EqualValues(t, 3, query.PatternCount())
+		origins := make([][2]any, query.PatternCount())
+		for i := range origins {
+			name, line := query.PatternOrigin(uint(i))
+			origins[i] = [2]any{name, line}
+		}
+		assert.Equal(t, [][2]any{{"highlights.scm", 1}, {"highlights.scm", 4}, {"lint.scm", 2}}, origins)
+		assert.Panics(t, func() { query.PatternOrigin(3) })
+
+		// The patterns of both sources match in one pass.
+		source := `{"TODO": "x", "b": 1}`
+		parser := NewParser()
+		defer parser.Close()
+		parser.SetLanguage(language)
+		tree := parser.Parse([]byte(source), nil)
+		defer tree.Close()
+		cursor := NewQueryCursor()
+		defer cursor.Close()
+		assert.Equal(t, []formattedCapture{
+			{"string", `"TODO"`},
+			{"key", `"TODO"`},
+			{"string", `"x"`},
+			{"string", `"b"`},
+			{"number", "1"},
+		}, collectCaptures(cursor.Captures(query, tree.RootNode(), []byte(source)), query, source))
+	}
+
+	// An error is reported in the source that it is in.
+	_, err = NewQueryFromSources(language, []NamedQuerySource{
+		highlights,
+		{Name: "lint.scm", Source: "(pair) @pair\n(pair vaule: (_))\n"},
+	})
+	var fileErr *QueryFileError
+	if assert.ErrorAs(t, err, &fileErr) {
+		assert.Equal(t, "lint.scm", fileErr.Name)
+		assert.Equal(t, QueryErrorField, fileErr.Kind)
+		assert.EqualValues(t, 1, fileErr.Row)
+		assert.EqualValues(t, 6, fileErr.Column)
+		assert.EqualValues(t, 19, fileErr.Offset)
+		assert.Equal(t, `lint.scm:2:7: invalid field name "vaule"`, err.Error())
+	}
+
+	// A query made from a single source gives its lines too.
+	query, queryErr := NewQuery(language, "\n\n(string) @string")
+	assert.Nil(t, queryErr)
+	defer query.Close()
+	name, line := query.PatternOrigin(0)
+	assert.Equal(t, "", name)
+	assert.Equal(t, 3, line)
+}
@@ -0,0 +1,138 @@
+package tree_sitter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// A QuerySet holds the queries compiled from a set of query files, such as
+// ones embedded with `go:embed`, by their names. It is made with
+// [NewQuerySet], which compiles every file up front, so that an error in
+// any of them is found as soon as the set is made.
+type QuerySet struct {
+	queries map[string]*Query
+}
+
+// The error returned by [NewQuerySet] when some of its files fail to
+// compile. There is an error for each of those files, in the order of
+// their paths.
+type QuerySetError struct {
+	Files []*QueryFileError
+}
+
+func (e *QuerySetError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d query files failed to compile:", len(e.Files))
+	for _, err := range e.Files {
+		sb.WriteString("\n\t")
+		sb.WriteString(strings.ReplaceAll(err.Error(), "\n", "\n\t"))
+	}
+	return sb.String()
+}
+
+// Unwrap returns the error of each file that failed to compile, so that
+// [errors.As] finds the first of them.
+func (e *QuerySetError) Unwrap() []error {
+	errs := make([]error, len(e.Files))
+	for i, err := range e.Files {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Create a new query set for the given language from the files of a file
+// system that match any of the given patterns, in the syntax of
+// [fs.Glob], such as "queries/*.scm".
+//
+// Each query is named after its file, without the directory and the
+// extension, so that "queries/highlights.scm" is named "highlights". It is
+// an error for two files to have the same name, or for a pattern to match
+// no files.
+//
+// If any of the files fails to compile, none of the queries are kept, and
+// the error is a [*QuerySetError] with the error of every such file.
+func NewQuerySet(language *Language, fsys fs.FS, patterns ...string) (*QuerySet, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no query files match %q", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	qs := &QuerySet{queries: make(map[string]*Query, len(paths))}
+	names := make(map[string]string, len(paths))
+	var setErr QuerySetError
+	for _, filePath := range paths {
+		name := strings.TrimSuffix(path.Base(filePath), path.Ext(filePath))
+		if other, ok := names[name]; ok {
+			qs.Close()
+			return nil, fmt.Errorf("query files %s and %s have the same name %q", other, filePath, name)
+		}
+		names[name] = filePath
+
+		data, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			qs.Close()
+			return nil, err
+		}
+		query, err := NewQueryFromSources(language, []NamedQuerySource{{Name: filePath, Source: string(data)}})
+		var fileErr *QueryFileError
+		if errors.As(err, &fileErr) {
+			setErr.Files = append(setErr.Files, fileErr)
+			continue
+		} else if err != nil {
+			qs.Close()
+			return nil, err
+		}
+		qs.queries[name] = query
+	}
+
+	if len(setErr.Files) > 0 {
+		qs.Close()
+		return nil, &setErr
+	}
+	return qs, nil
+}
+
+// Create a new query set like [NewQuerySet], but panic if it fails, so
+// that it can be used to initialize a variable.
+func MustQuerySet(language *Language, fsys fs.FS, patterns ...string) *QuerySet {
+	qs, err := NewQuerySet(language, fsys, patterns...)
+	if err != nil {
+		panic("tree_sitter: " + err.Error())
+	}
+	return qs
+}
+
+// Get the query with the given name, or nil if the set has none.
+func (qs *QuerySet) Get(name string) *Query {
+	return qs.queries[name]
+}
+
+// Get the names of the queries in the set, in sorted order.
+func (qs *QuerySet) Names() []string {
+	names := make([]string, 0, len(qs.queries))
+	for name := range qs.queries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Close every query in the set.
+func (qs *QuerySet) Close() {
+	for _, query := range qs.queries {
+		query.Close()
+	}
+}
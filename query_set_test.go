@@ -0,0 +1,48 @@
+package tree_sitter_test
+
+import (
+	"embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+//go:embed testdata/queries
+var queryFiles embed.FS
+
+func TestQuerySet(t *testing.T) {
+	language := getLanguage("json")
+
+	qs, err := NewQuerySet(language, queryFiles, "testdata/queries/json/highlights.scm")
+	if assert.NoError(t, err) {
+		defer qs.Close()
+		assert.Equal(t, []string{"highlights"}, qs.Names())
+		assert.Equal(t, []string{"string", "number"}, qs.Get("highlights").CaptureNames())
+		assert.Nil(t, qs.Get("locals"))
+	}
+
+	// Every file that fails to compile is reported.
+	_, err = NewQuerySet(language, queryFiles, "testdata/queries/json/*.scm")
+	var setErr *QuerySetError
+	if assert.ErrorAs(t, err, &setErr) && assert.Len(t, setErr.Files, 2) {
+		assert.Equal(t, "testdata/queries/json/folds.scm", setErr.Files[0].Name)
+		assert.Equal(t, QueryErrorNodeType, setErr.Files[0].Kind)
+		assert.Equal(t, "testdata/queries/json/locals.scm", setErr.Files[1].Name)
+		assert.Equal(t, QueryErrorField, setErr.Files[1].Kind)
+		assert.EqualValues(t, 2, setErr.Files[1].Row)
+	}
+	assert.EqualError(t, err, `2 query files failed to compile:
+	testdata/queries/json/folds.scm:2:2: invalid node type "arary"
+	testdata/queries/json/locals.scm:3:7: invalid field name "vaule"`)
+	var fileErr *QueryFileError
+	if assert.ErrorAs(t, err, &fileErr) {
+		assert.Equal(t, "testdata/queries/json/folds.scm", fileErr.Name)
+	}
+
+	_, err = NewQuerySet(language, queryFiles, "testdata/queries/json/highlights.scm", "testdata/queries/*.scm")
+	assert.EqualError(t, err, `no query files match "testdata/queries/*.scm"`)
+
+	assert.Panics(t, func() { MustQuerySet(language, queryFiles, "testdata/queries/json/locals.scm") })
+	assert.NotPanics(t, func() { MustQuerySet(language, queryFiles, "testdata/queries/json/highlights.scm").Close() })
+}
@@ -1,8 +1,11 @@
 package tree_sitter_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -361,6 +364,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  1,
 			Kind:    QueryErrorNodeType,
 			Message: ">>>>",
+			Token:   ">>>>",
 		},
 	)
 
@@ -374,6 +378,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  1,
 			Kind:    QueryErrorNodeType,
 			Message: "te\\\"st",
+			Token:   "te\\\"st",
 		},
 	)
 
@@ -387,6 +392,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  1,
 			Kind:    QueryErrorNodeType,
 			Message: "\\\\",
+			Token:   "\\\\",
 		},
 	)
 
@@ -400,6 +406,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  1,
 			Kind:    QueryErrorNodeType,
 			Message: "clas",
+			Token:   "clas",
 		},
 	)
 
@@ -413,6 +420,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  15,
 			Kind:    QueryErrorNodeType,
 			Message: "arrayyyyy",
+			Token:   "arrayyyyy",
 		},
 	)
 
@@ -426,6 +434,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  26,
 			Kind:    QueryErrorNodeType,
 			Message: "non_existent3",
+			Token:   "non_existent3",
 		},
 	)
 
@@ -439,6 +448,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  14,
 			Kind:    QueryErrorField,
 			Message: "condit",
+			Token:   "condit",
 		},
 	)
 
@@ -452,6 +462,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  14,
 			Kind:    QueryErrorField,
 			Message: "conditioning",
+			Token:   "conditioning",
 		},
 	)
 
@@ -465,6 +476,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  15,
 			Kind:    QueryErrorField,
 			Message: "alternativ",
+			Token:   "alternativ",
 		},
 	)
 
@@ -478,6 +490,7 @@ func TestQueryErrorsOnInvalidSymbols(t *testing.T) {
 			Column:  15,
 			Kind:    QueryErrorField,
 			Message: "alternatives",
+			Token:   "alternatives",
 		},
 	)
 }
@@ -499,6 +512,7 @@ func TestQueryErrorsOnInvalidPredicates(t *testing.T) {
 			Row:    0,
 			Offset: 19,
 			Column: 19,
+			Token:  "@id",
 			Kind:   QueryErrorSyntax,
 			Message: strings.Join([]string{
 				"((identifier) @id (@id))",
@@ -513,8 +527,9 @@ func TestQueryErrorsOnInvalidPredicates(t *testing.T) {
 		err,
 		&QueryError{
 			Row:     0,
-			Offset:  0,
-			Column:  0,
+			Offset:  19,
+			Column:  19,
+			Token:   "#eq?",
 			Kind:    QueryErrorPredicate,
 			Message: "Wrong number of arguments to #eq? predicate. Expected 2, got 1.",
 		},
@@ -530,6 +545,7 @@ func TestQueryErrorsOnInvalidPredicates(t *testing.T) {
 			Column:  29,
 			Kind:    QueryErrorCapture,
 			Message: "ok",
+			Token:   "ok",
 		},
 	)
 }
@@ -558,6 +574,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 			Row:    0,
 			Offset: 51,
 			Column: 51,
+			Token:  "left",
 			Kind:   QueryErrorStructure,
 			Message: strings.Join(
 				[]string{
@@ -581,6 +598,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 			Row:    0,
 			Offset: 22,
 			Column: 22,
+			Token:  "name",
 			Kind:   QueryErrorStructure,
 			Message: strings.Join(
 				[]string{
@@ -602,6 +620,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 			Row:    0,
 			Offset: 6,
 			Column: 6,
+			Token:  "receiver",
 			Kind:   QueryErrorStructure,
 			Message: strings.Join(
 				[]string{
@@ -639,6 +658,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 			Row:    2,
 			Offset: 99,
 			Column: 42,
+			Token:  "(",
 			Kind:   QueryErrorStructure,
 			Message: strings.Join(
 				[]string{
@@ -658,6 +678,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 			Row:    0,
 			Offset: 12,
 			Column: 12,
+			Token:  "(",
 			Kind:   QueryErrorStructure,
 			Message: strings.Join(
 				[]string{
@@ -677,6 +698,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 			Row:    0,
 			Offset: 6,
 			Column: 6,
+			Token:  "(",
 			Kind:   QueryErrorStructure,
 			Message: strings.Join(
 				[]string{
@@ -700,6 +722,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 			Row:    0,
 			Offset: 14,
 			Column: 14,
+			Token:  "condition",
 			Kind:   QueryErrorStructure,
 			Message: strings.Join(
 				[]string{
@@ -719,6 +742,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 			Row:    0,
 			Offset: 0,
 			Column: 0,
+			Token:  "(",
 			Kind:   QueryErrorStructure,
 			Message: strings.Join(
 				[]string{
@@ -739,6 +763,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 				Row:    0,
 				Offset: 0,
 				Column: 0,
+				Token:  "(",
 				Kind:   QueryErrorStructure,
 				Message: strings.Join(
 					[]string{
@@ -758,6 +783,7 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 				Row:    0,
 				Offset: 0,
 				Column: 0,
+				Token:  "(",
 				Kind:   QueryErrorStructure,
 				Message: strings.Join(
 					[]string{
@@ -771,6 +797,84 @@ func TestQueryErrorsOnImpossiblePatterns(t *testing.T) {
 	}
 }
 
+func TestQueryErrorPositions(t *testing.T) {
+	language := getLanguage("javascript")
+	// Every query has a valid first pattern, so that the errors are not on
+	// the first line.
+	const valid = "(identifier) @id\n\n"
+	rows := []struct {
+		pattern string
+		kind    QueryErrorKind
+		offset  uint
+		token   string
+		message string
+	}{
+		{
+			pattern: "(function_defintion) @fn",
+			kind:    QueryErrorNodeType,
+			offset:  1,
+			token:   "function_defintion",
+			message: `query error at 3:2: invalid node type "function_defintion"`,
+		},
+		{
+			pattern: "(if_statement conditon: (_))",
+			kind:    QueryErrorField,
+			offset:  14,
+			token:   "conditon",
+			message: `query error at 3:15: invalid field name "conditon"`,
+		},
+		{
+			pattern: "((identifier) @a (#eq? @a @b))",
+			kind:    QueryErrorCapture,
+			offset:  27,
+			token:   "b",
+			message: `query error at 3:28: invalid capture name "b"`,
+		},
+		{
+			pattern: "((identifier) @a\n  (#any-of? @a @a))",
+			kind:    QueryErrorPredicate,
+			offset:  20,
+			token:   "#any-of?",
+			message: "query error at 4:4: invalid predicate: Arguments to #any-of? predicate must be literals. Got capture @a.",
+		},
+		{
+			pattern: "(identifier (identifier))",
+			kind:    QueryErrorStructure,
+			offset:  12,
+			token:   "(",
+			message: "query error at 3:13: impossible pattern:\n(identifier (identifier))\n            ^",
+		},
+		{
+			pattern: "(if_statement @cond)",
+			kind:    QueryErrorSyntax,
+			offset:  14,
+			token:   "@cond",
+			message: "query error at 3:15: invalid syntax:\n(if_statement @cond)\n              ^",
+		},
+		{
+			pattern: "(if_statement",
+			kind:    QueryErrorSyntax,
+			offset:  13,
+			token:   "",
+			message: "query error at 3:14: invalid syntax:\n(if_statement\n             ^",
+		},
+	}
+
+	for _, row := range rows {
+		source := valid + row.pattern
+		query, err := NewQuery(language, source)
+		assert.Nil(t, query, row.pattern)
+		if !assert.NotNil(t, err, row.pattern) {
+			continue
+		}
+		assert.Equal(t, row.kind, err.Kind, row.pattern)
+		assert.Equal(t, uint(len(valid))+row.offset, err.Offset, row.pattern)
+		assert.Equal(t, row.token, err.Token, row.pattern)
+		assert.Equal(t, row.token, source[err.Offset:err.Offset+uint(len(err.Token))], row.pattern)
+		assert.Equal(t, row.message, err.Error(), row.pattern)
+	}
+}
+
 func TestQueryVerifiesPossiblePatternsWithAliasedParentNodes(t *testing.T) {
 	language := getLanguage("ruby")
 
@@ -788,6 +892,7 @@ func TestQueryVerifiesPossiblePatternsWithAliasedParentNodes(t *testing.T) {
 		Row:    0,
 		Offset: 24,
 		Column: 24,
+		Token:  "(",
 		Message: strings.Join(
 			[]string{
 				"(destructured_parameter (string))",
@@ -2027,6 +2132,48 @@ func TestQueryMatchesWithTooManyPermutationsToTrack(t *testing.T) {
 	assert.True(t, cursor.DidExceedMatchLimit())
 }
 
+func TestQueryIteratorsReportExceededMatchLimit(t *testing.T) {
+	language := getLanguage("javascript")
+	query, err := NewQuery(language, "(array (identifier) @pre (identifier) @post)")
+	assert.Nil(t, err)
+	defer query.Close()
+
+	source := "[" + strings.Repeat("hello, ", 200) + "];"
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	// Without a limit, every pair of identifiers is matched.
+	matches := cursor.Matches(query, tree.RootNode(), []byte(source))
+	assert.Len(t, collectMatches(matches, query, source), 200*199/2)
+	assert.False(t, matches.DidExceedMatchLimit())
+
+	// The limit is set on a new cursor, since the first one already has
+	// the memory for many matches.
+	cursor = NewQueryCursor()
+	defer cursor.Close()
+	cursor.SetMatchLimit(4)
+	assert.EqualValues(t, 4, cursor.MatchLimit())
+	matches = cursor.Matches(query, tree.RootNode(), []byte(source))
+	count := 0
+	for match := matches.Next(); match != nil; match = matches.Next() {
+		count++
+	}
+	assert.Positive(t, count)
+	assert.Less(t, count, 200*199/2)
+	assert.True(t, matches.DidExceedMatchLimit())
+
+	captures := cursor.Captures(query, tree.RootNode(), []byte(source))
+	for match, _ := captures.Next(); match != nil; match, _ = captures.Next() {
+	}
+	assert.True(t, captures.DidExceedMatchLimit())
+	assert.True(t, cursor.DidExceedMatchLimit())
+}
+
 func TestQuerySiblingPatternsDontMatchChildrenOfAnError(t *testing.T) {
 	language := getLanguage("rust")
 	query, err := NewQuery(
@@ -2325,6 +2472,57 @@ func TestQueryMatchesWithinPointRange(t *testing.T) {
 	})
 }
 
+func TestQueryMatchesWithinEditedRange(t *testing.T) {
+	language := getLanguage("go")
+	query, err := NewQuery(language, "(function_declaration name: (identifier) @name) @function")
+	assert.Nil(t, err)
+	defer query.Close()
+
+	source := `package main
+
+func first() {
+	a()
+}
+
+func second() {
+	b()
+}
+
+func third() {
+	c()
+}
+`
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	// Only the function around the edited line is matched, although its
+	// name is outside of the range.
+	line := strings.Index(source, "\tb()")
+	expected := []formattedMatch{
+		fmtMatch(0, fmtCapture("function", "func second() {\n\tb()\n}"), fmtCapture("name", "second")),
+	}
+	matches := cursor.SetByteRange(uint(line), uint(line+len("\tb()"))).Matches(query, tree.RootNode(), []byte(source))
+	assert.Equal(t, expected, collectMatches(matches, query, source))
+
+	cursor.SetByteRange(0, 0)
+	matches = cursor.SetPointRange(NewPoint(7, 0), NewPoint(8, 0)).Matches(query, tree.RootNode(), []byte(source))
+	assert.Equal(t, expected, collectMatches(matches, query, source))
+
+	// A range that starts in one function and ends in the next one
+	// matches both.
+	matches = cursor.SetPointRange(NewPoint(3, 0), NewPoint(6, 5)).Matches(query, tree.RootNode(), []byte(source))
+	assert.Len(t, collectMatches(matches, query, source), 2)
+
+	// Clearing the range matches every function again.
+	matches = cursor.SetPointRange(NewPoint(0, 0), NewPoint(0, 0)).Matches(query, tree.RootNode(), []byte(source))
+	assert.Len(t, collectMatches(matches, query, source), 3)
+}
+
 func TestQueryCapturesWithinByteRange(t *testing.T) {
 	language := getLanguage("c")
 	query, err := NewQuery(
@@ -2792,7 +2990,7 @@ func TestQueryMatchesWithMultipleCapturesOnANode(t *testing.T) {
 
 	// disabling captures still works when there are multiple captures on a
 	// single node.
-	query.DisableCapture("name2")
+	assert.NoError(t, query.DisableCapture("name2"))
 	matches = cursor.Matches(query, tree.RootNode(), []byte(source))
 	assert.Equal(
 		t,
@@ -3939,6 +4137,58 @@ func TestQueryCaptureNames(t *testing.T) {
 	assert.Equal(t, []string{"left-operand", "right-operand", "body", "loop-condition"}, query.CaptureNames())
 }
 
+func TestQueryIntrospection(t *testing.T) {
+	language := getLanguage("javascript")
+	patterns := []string{
+		"(function_declaration name: (identifier) @name) @definition\n",
+		"((identifier) @name (#eq? @name \"self\"))\n",
+		"(call_expression function: (identifier) @call (#set! role \"call\"))\n",
+		"\"=>\" @operator ((string) @string (#eq? @string \"self\"))\n",
+	}
+	source := strings.Join(patterns, "")
+	query, err := NewQuery(language, source)
+	assert.Nil(t, err)
+	defer query.Close()
+
+	assert.EqualValues(t, 5, query.PatternCount())
+	// A capture that is used in several patterns has a single name.
+	assert.Equal(t, []string{"name", "definition", "call", "operator", "string"}, query.CaptureNames())
+	index, ok := query.CaptureIndexForName("string")
+	assert.True(t, ok)
+	assert.EqualValues(t, 4, index)
+	_, ok = query.CaptureIndexForName("missing")
+	assert.False(t, ok)
+
+	// The strings of the predicates, including their operators, each once.
+	var strs []string
+	for id := range query.StringCount() {
+		strs = append(strs, query.StringValueForId(id))
+	}
+	assert.ElementsMatch(t, []string{"eq?", "self", "set!", "role", "call"}, strs)
+	assert.Panics(t, func() { query.StringValueForId(query.StringCount()) })
+
+	// The patterns that declare each capture, with their positions.
+	var declarations []string
+	for i := range query.PatternCount() {
+		for capture, quantifier := range query.CaptureQuantifiers(i) {
+			if quantifier != CaptureQuantifierZero {
+				declarations = append(declarations, fmt.Sprintf("@%s in pattern %d at %d-%d",
+					query.CaptureNames()[capture], i, query.StartByteForPattern(i), query.EndByteForPattern(i)))
+			}
+		}
+	}
+	line := func(i int) int { return len(strings.Join(patterns[:i], "")) }
+	operator := line(3) + len(`"=>" @operator `)
+	assert.Equal(t, []string{
+		fmt.Sprintf("@name in pattern 0 at 0-%d", line(1)),
+		fmt.Sprintf("@definition in pattern 0 at 0-%d", line(1)),
+		fmt.Sprintf("@name in pattern 1 at %d-%d", line(1), line(2)),
+		fmt.Sprintf("@call in pattern 2 at %d-%d", line(2), line(3)),
+		fmt.Sprintf("@operator in pattern 3 at %d-%d", line(3), operator),
+		fmt.Sprintf("@string in pattern 4 at %d-%d", operator, line(4)),
+	}, declarations)
+}
+
 func TestQueryWithNoPatterns(t *testing.T) {
 	language := getLanguage("javascript")
 	query, err := NewQuery(language, "")
@@ -4000,8 +4250,9 @@ func TestQueryDisablePattern(t *testing.T) {
 	defer query.Close()
 
 	// disable the patterns that match names
-	query.DisablePattern(0)
-	query.DisablePattern(2)
+	assert.NoError(t, query.DisablePattern(0))
+	assert.NoError(t, query.DisablePattern(2))
+	assert.EqualError(t, query.DisablePattern(4), "pattern index is 4 but the pattern count is 4")
 
 	source := "class A { constructor() {} } function b() { return 1; }"
 	parser := NewParser()
@@ -4025,6 +4276,52 @@ func TestQueryDisablePattern(t *testing.T) {
 	)
 }
 
+func TestQueryDisableCapture(t *testing.T) {
+	language := getLanguage("javascript")
+	query, err := NewQuery(language, `
+		(comment) @comment
+		(function_declaration name: (identifier) @function)
+		((function_declaration) @function (#set! kind "declaration"))
+	`)
+	assert.Nil(t, err)
+	defer query.Close()
+
+	assert.NoError(t, query.DisableCapture("comment"))
+	assert.EqualError(t, query.DisableCapture("commment"), `query has no capture named "commment"`)
+
+	source := "// a\nfunction f() {}\n/* b */\nfunction g() { // c\n}\n"
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	captures := cursor.Captures(query, tree.RootNode(), []byte(source))
+	assert.Equal(
+		t,
+		[]formattedCapture{
+			{"function", "function f() {}"},
+			{"function", "f"},
+			{"function", "function g() { // c\n}"},
+			{"function", "g"},
+		},
+		collectCaptures(captures, query, source),
+	)
+
+	// The pattern of the disabled capture still matches, without captures.
+	matches := cursor.Matches(query, tree.RootNode(), []byte(source))
+	var comments int
+	for match := matches.Next(); match != nil; match = matches.Next() {
+		if match.PatternIndex == 0 {
+			assert.Empty(t, match.Captures)
+			comments++
+		}
+	}
+	assert.Equal(t, 3, comments)
+}
+
 func TestQueryAlternativePredicatePrefix(t *testing.T) {
 	language := getLanguage("c")
 	query, err := NewQuery(
@@ -4390,6 +4687,30 @@ func TestQueryIsPatternNonLocal(t *testing.T) {
 	}
 }
 
+func TestQueryPatternAnalysis(t *testing.T) {
+	source := strings.Join([]string{
+		"(call_expression function: (identifier) arguments: (arguments)) @call",
+		"((return_statement) (return_statement)) @returns",
+		"(\"{\" \"}\") @braces",
+	}, "\n")
+	query, err := NewQuery(getLanguage("javascript"), source)
+	assert.Nil(t, err)
+	defer query.Close()
+
+	assert.True(t, query.IsPatternRooted(0))
+	assert.False(t, query.IsPatternNonLocal(0))
+	assert.False(t, query.IsPatternRooted(1))
+	assert.True(t, query.IsPatternNonLocal(1))
+	// Siblings are not rooted, but these can only occur in fixed places.
+	assert.False(t, query.IsPatternRooted(2))
+	assert.False(t, query.IsPatternNonLocal(2))
+	assert.Panics(t, func() { query.IsPatternRooted(3) })
+	assert.Panics(t, func() { query.IsPatternNonLocal(3) })
+
+	// Not every call's function is an identifier.
+	assert.False(t, query.IsPatternGuaranteedAtStep(uint(strings.Index(source, "(identifier)"))))
+}
+
 func TestCaptureQuantifiers(t *testing.T) {
 	type captureQuantifier struct {
 		pattern    uint
@@ -4598,6 +4919,166 @@ func TestCaptureQuantifiers(t *testing.T) {
 	}
 }
 
+func TestQueryCaptureQuantifier(t *testing.T) {
+	query, err := NewQuery(getLanguage("go"), `
+		(function_declaration
+			name: (identifier) @name
+			parameters: (parameter_list (parameter_declaration)* @params)
+			result: (_)? @result)
+		(call_expression arguments: (argument_list (_)+ @args))
+		[
+			(identifier) @name
+			(call_expression function: (_)? @name)
+		]
+	`)
+	assert.Nil(t, err)
+	defer query.Close()
+
+	index := func(name string) uint {
+		i, ok := query.CaptureIndexForName(name)
+		assert.True(t, ok)
+		return i
+	}
+	assert.Equal(t, CaptureQuantifierOne, query.CaptureQuantifier(0, index("name")))
+	assert.Equal(t, CaptureQuantifierZeroOrMore, query.CaptureQuantifier(0, index("params")))
+	assert.Equal(t, CaptureQuantifierZeroOrOne, query.CaptureQuantifier(0, index("result")))
+	assert.Equal(t, CaptureQuantifierZero, query.CaptureQuantifier(0, index("args")))
+	assert.Equal(t, CaptureQuantifierOneOrMore, query.CaptureQuantifier(1, index("args")))
+	assert.Equal(t, CaptureQuantifierZero, query.CaptureQuantifier(1, index("name")))
+	assert.Equal(t, CaptureQuantifierZeroOrOne, query.CaptureQuantifier(2, index("name")))
+	for i := range query.PatternCount() {
+		for j := range uint(len(query.CaptureNames())) {
+			assert.Equal(t, query.CaptureQuantifiers(i)[j], query.CaptureQuantifier(i, j))
+		}
+	}
+	assert.Panics(t, func() { query.CaptureQuantifier(3, 0) })
+	assert.Panics(t, func() { query.CaptureQuantifier(0, 4) })
+	assert.Equal(t, "zero or more", CaptureQuantifierZeroOrMore.String())
+}
+
+func TestQueryCaptureID(t *testing.T) {
+	language := getLanguage("json")
+	query, err := NewQuery(language, `
+		(array . (number) @first (number) @rest (number) @rest .) @array
+		(pair key: (string) @key value: (string) @value)
+	`)
+	assert.Nil(t, err)
+	defer query.Close()
+
+	first, ok := query.CaptureID("first")
+	assert.True(t, ok)
+	rest, ok := query.CaptureID("rest")
+	assert.True(t, ok)
+	array, _ := query.CaptureID("array")
+	value, _ := query.CaptureID("value")
+	_, ok = query.CaptureID("missing")
+	assert.False(t, ok)
+	assert.Equal(t, "rest", query.CaptureNames()[rest])
+
+	source := `[1, 2, 3]`
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	matches := cursor.Matches(query, tree.RootNode(), []byte(source))
+	match := matches.Next()
+	if assert.NotNil(t, match) {
+		texts := func(nodes []Node) []string {
+			var result []string
+			for _, node := range nodes {
+				result = append(result, node.Utf8Text([]byte(source)))
+			}
+			return result
+		}
+		// A capture can have several nodes in a match.
+		assert.Equal(t, []string{"2", "3"}, texts(match.NodesForCaptureID(rest)))
+		assert.Equal(t, []string{"1"}, texts(match.NodesForCaptureID(first)))
+		assert.Equal(t, "2", match.FirstNodeForCaptureID(rest).Utf8Text([]byte(source)))
+		assert.Equal(t, source, match.FirstNodeForCaptureID(array).Utf8Text([]byte(source)))
+		assert.Nil(t, match.FirstNodeForCaptureID(value))
+		assert.Empty(t, match.NodesForCaptureID(value))
+		for _, capture := range match.Captures {
+			assert.Equal(t, capture.Index == rest, query.CaptureNames()[capture.Index] == "rest")
+		}
+	}
+}
+
+func BenchmarkQueryCapturesFilteredByName(b *testing.B) {
+	query, captures := functionNameCaptures(b)
+	defer query.Close()
+
+	b.ResetTimer()
+	for range b.N {
+		count := 0
+		for _, capture := range captures {
+			if query.CaptureNames()[capture.Index] == "function.name" {
+				count++
+			}
+		}
+		if count != 500 {
+			b.Fatalf("found %d function names", count)
+		}
+	}
+}
+
+func BenchmarkQueryCapturesFilteredByID(b *testing.B) {
+	query, captures := functionNameCaptures(b)
+	defer query.Close()
+	id, _ := query.CaptureID("function.name")
+
+	b.ResetTimer()
+	for range b.N {
+		count := 0
+		for _, capture := range captures {
+			if capture.Index == id {
+				count++
+			}
+		}
+		if count != 500 {
+			b.Fatalf("found %d function names", count)
+		}
+	}
+}
+
+// Get the captures of a query with a capture named "function.name" over
+// 500 functions. Only the indices of the captures are for use, since the
+// tree of their nodes is closed.
+func functionNameCaptures(b *testing.B) (*Query, []QueryCapture) {
+	language := getLanguage("go")
+	var sb strings.Builder
+	sb.WriteString("package main\n")
+	for i := range 500 {
+		fmt.Fprintf(&sb, "func function%d(a, b int) int { return a + b }\n", i)
+	}
+	source := []byte(sb.String())
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	query, err := NewQuery(language, `
+		(function_declaration name: (identifier) @function.name) @function
+		(parameter_declaration name: (identifier) @parameter)
+		(identifier) @variable
+	`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	var captures []QueryCapture
+	iter := cursor.Captures(query, tree.RootNode(), source)
+	for match, index := iter.Next(); match != nil; match, index = iter.Next() {
+		captures = append(captures, match.Captures[index])
+	}
+	return query, captures
+}
+
 func TestQueryQuantifiedCaptures(t *testing.T) {
 	type row struct {
 		description string
@@ -4674,80 +5155,641 @@ void main() {}
 	}
 }
 
-func TestQueryMaxStartDepth(t *testing.T) {
-	type row struct {
+func TestQueryRegexPredicates(t *testing.T) {
+	language := getLanguage("c")
+	code := `
+/// foo
+/// bar
+
+int main() {}
+
+// qux
+// quux
+
+int 名前 = 1, Größe = 2, ÄÖ_X = 3;
+`
+	rows := []struct {
 		description string
 		pattern     string
-		depth       uint
-		matches     []formattedMatch
-	}
-	source := strings.TrimSpace(`
-if (a1 && a2) {
-    if (b1 && b2) { }
-    if (c) { }
-}
-if (d) {
-    if (e1 && e2) { }
-    if (f) { }
-}
-	`)
-
-	rows := []row{
+		captures    []formattedCapture
+	}{
 		{
-			description: "depth 0: match translation unit",
-			depth:       0,
-			pattern:     `(translation_unit) @capture`,
-			matches: []formattedMatch{
-				fmtMatch(0, fmtCapture("capture", source)),
+			description: "no node matches an any-match",
+			pattern: `((comment)+ @comment
+                      (#any-match? @comment "^///"))`,
+			captures: []formattedCapture{
+				{"comment", "/// foo"},
+				{"comment", "/// bar"},
 			},
 		},
 		{
-			description: "depth 0: match none",
-			depth:       0,
-			pattern:     `(if_statement) @capture`,
-			matches:     []formattedMatch{},
+			description: "every node must not match",
+			pattern: `((comment)+ @comment
+                      (#not-match? @comment "^///"))`,
+			captures: []formattedCapture{
+				{"comment", "// qux"},
+				{"comment", "// quux"},
+			},
 		},
 		{
-			description: "depth 1: match 2 if statements at the top level",
-			depth:       1,
-			pattern:     `(if_statement) @capture`,
-			matches: []formattedMatch{
-				fmtMatch(0, fmtCapture("capture", "if (a1 && a2) {\n    if (b1 && b2) { }\n    if (c) { }\n}")),
-				fmtMatch(0, fmtCapture("capture", "if (d) {\n    if (e1 && e2) { }\n    if (f) { }\n}")),
+			description: "one node must not match",
+			pattern: `((comment)+ @comment
+                      (#any-not-match? @comment "qux$"))`,
+			captures: []formattedCapture{
+				{"comment", "/// foo"},
+				{"comment", "/// bar"},
+				{"comment", "// qux"},
+				{"comment", "// quux"},
 			},
 		},
 		{
-			description: "depth 1 with deep pattern: match only the first if statement",
-			depth:       1,
-			pattern: `(if_statement
-                        condition: (parenthesized_expression
-                            (binary_expression)
-                        )
-                    ) @capture`,
-			matches: []formattedMatch{
-				fmtMatch(0, fmtCapture("capture", "if (a1 && a2) {\n    if (b1 && b2) { }\n    if (c) { }\n}")),
-			},
+			description: "non-ASCII text",
+			pattern:     `((identifier) @constant (#match? @constant "^\\p{Lu}[\\p{Lu}_]+$"))`,
+			captures:    []formattedCapture{{"constant", "ÄÖ_X"}},
 		},
 		{
-			description: "depth 3 with deep pattern: match all if statements with a binexpr condition",
-			depth:       3,
-			pattern: `(if_statement
-                        condition: (parenthesized_expression
-                            (binary_expression)
-                        )
-                    ) @capture`,
-			matches: []formattedMatch{
-				fmtMatch(0, fmtCapture("capture", "if (a1 && a2) {\n    if (b1 && b2) { }\n    if (c) { }\n}")),
-				fmtMatch(0, fmtCapture("capture", "if (b1 && b2) { }")),
-				fmtMatch(0, fmtCapture("capture", "if (e1 && e2) { }")),
+			description: "non-ASCII pattern",
+			pattern:     `((identifier) @name (#not-match? @name "^[a-zA-Z_]+$") (#not-match? @name "ö"))`,
+			captures: []formattedCapture{
+				{"name", "名前"},
+				{"name", "ÄÖ_X"},
 			},
 		},
 	}
 
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(code), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
 	for _, row := range rows {
-		language := getLanguage("c")
-		parser := NewParser()
-		defer parser.Close()
+		query, err := NewQuery(language, row.pattern)
+		if !assert.Nil(t, err, row.description) {
+			continue
+		}
+		captures := cursor.Captures(query, tree.RootNode(), []byte(code))
+		assert.Equal(t, row.captures, collectCaptures(captures, query, code), row.description)
+		query.Close()
+	}
+
+	// An invalid regex is reported with its position.
+	source := "(comment) @comment\n\n  ((identifier) @name (#match? @name \"[a-z\"))"
+	_, err := NewQuery(language, source)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, QueryErrorPredicate, err.Kind)
+		assert.EqualValues(t, 2, err.Row)
+		assert.EqualValues(t, 38, err.Column)
+		assert.EqualValues(t, strings.Index(source, "[a-z"), err.Offset)
+		assert.Equal(t, "[a-z", err.Token)
+	}
+}
+
+func TestQueryAnyOfPredicates(t *testing.T) {
+	language := getLanguage("javascript")
+	code := "// if\n// else\nfoo(a, b); bar(a, c);\n// if\n// while\nbaz(d, e); qux();\n"
+	keywords := make([]string, 0, 200)
+	for i := range 200 {
+		keywords = append(keywords, fmt.Sprintf("\"k%d\"", i))
+	}
+	rows := []struct {
+		description string
+		pattern     string
+		captures    []formattedCapture
+	}{
+		{
+			description: "quoted literals",
+			pattern:     `((identifier) @function (#any-of? @function "foo" "baz"))`,
+			captures:    []formattedCapture{{"function", "foo"}, {"function", "baz"}},
+		},
+		{
+			description: "bare literals",
+			pattern:     `((identifier) @function (#not-any-of? @function foo bar a b c d e))`,
+			captures:    []formattedCapture{{"function", "baz"}, {"function", "qux"}},
+		},
+		{
+			description: "a long list",
+			pattern:     "((identifier) @function (#any-of? @function " + strings.Join(keywords, " ") + " \"qux\"))",
+			captures:    []formattedCapture{{"function", "qux"}},
+		},
+		{
+			description: "every comment must be in the list",
+			pattern:     `((comment)+ @comment (#any-of? @comment "// if" "// else"))`,
+			captures:    []formattedCapture{{"comment", "// if"}, {"comment", "// else"}},
+		},
+		{
+			description: "no comment may be in the list",
+			pattern:     `((comment)+ @comment (#not-any-of? @comment "// else"))`,
+			captures:    []formattedCapture{{"comment", "// if"}, {"comment", "// while"}},
+		},
+	}
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(code), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	for _, row := range rows {
+		query, err := NewQuery(language, row.pattern)
+		if !assert.Nil(t, err, row.description) {
+			continue
+		}
+		captures := cursor.Captures(query, tree.RootNode(), []byte(code))
+		assert.Equal(t, row.captures, collectCaptures(captures, query, code), row.description)
+
+		// The text is the same when it is read in small chunks.
+		captures = cursor.CapturesWith(query, tree.RootNode(), func(offset int, position Point) []byte {
+			return []byte(code[offset:min(offset+3, len(code))])
+		})
+		assert.Equal(t, row.captures, collectCaptures(captures, query, code), row.description)
+		query.Close()
+	}
+}
+
+func TestQueryEqCapturePredicates(t *testing.T) {
+	language := getLanguage("go")
+	code := `package main
+
+func main() {
+	x = x
+	x = y
+	a, b = a, b
+	a, b = b, a
+	s.f = s.f
+}
+`
+	rows := []struct {
+		description string
+		pattern     string
+		captures    []formattedCapture
+	}{
+		{
+			description: "self-assignment",
+			pattern: `((assignment_statement
+                         left: (expression_list . (_) @left .)
+                         right: (expression_list . (_) @right .))
+                       (#eq? @left @right))`,
+			captures: []formattedCapture{
+				{"left", "x"}, {"right", "x"},
+				{"left", "s.f"}, {"right", "s.f"},
+			},
+		},
+		{
+			description: "assignment of another value",
+			pattern: `((assignment_statement
+                         left: (expression_list . (_) @left .)
+                         right: (expression_list . (_) @right .))
+                       (#not-eq? @left @right))`,
+			captures: []formattedCapture{{"left", "x"}, {"right", "y"}},
+		},
+		{
+			description: "every quantified node equals its counterpart",
+			pattern: `((assignment_statement
+                         left: (expression_list (identifier) @left "," (identifier) @left)
+                         right: (expression_list (identifier) @right "," (identifier) @right))
+                       (#eq? @left @right))`,
+			captures: []formattedCapture{
+				{"left", "a"}, {"left", "b"}, {"right", "a"}, {"right", "b"},
+			},
+		},
+		{
+			description: "one quantified node differs from its counterpart",
+			pattern: `((assignment_statement
+                         left: (expression_list (identifier) @left "," (identifier) @left)
+                         right: (expression_list (identifier) @right "," (identifier) @right))
+                       (#any-not-eq? @left @right))`,
+			captures: []formattedCapture{
+				{"left", "a"}, {"left", "b"}, {"right", "b"}, {"right", "a"},
+			},
+		},
+		{
+			description: "a different number of nodes",
+			pattern: `((assignment_statement
+                         left: (expression_list . (identifier) @left)
+                         right: (expression_list (identifier) @right "," (identifier) @right))
+                       (#eq? @left @right))`,
+			captures: []formattedCapture{},
+		},
+	}
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(code), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	for _, row := range rows {
+		query, err := NewQuery(language, row.pattern)
+		if !assert.Nil(t, err, row.description) {
+			continue
+		}
+		captures := cursor.Captures(query, tree.RootNode(), []byte(code))
+		assert.Equal(t, row.captures, collectCaptures(captures, query, code), row.description)
+
+		captures = cursor.CapturesWith(query, tree.RootNode(), func(offset int, position Point) []byte {
+			return []byte(code[offset:min(offset+2, len(code))])
+		})
+		assert.Equal(t, row.captures, collectCaptures(captures, query, code), row.description)
+		query.Close()
+	}
+}
+
+func TestQueryAnyEqPredicatesWithString(t *testing.T) {
+	language := getLanguage("rust")
+	source := "fn main() { let a = b; }"
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	rows := []struct {
+		pattern string
+		matches []formattedMatch
+	}{
+		{`((identifier) @x (#any-eq? @x "nope"))`, []formattedMatch{}},
+		{`((identifier) @x (#any-eq? @x "a"))`, []formattedMatch{fmtMatch(0, formattedCapture{"x", "a"})}},
+		{`((identifier) @x (#any-not-eq? @x "a"))`, []formattedMatch{
+			fmtMatch(0, formattedCapture{"x", "main"}),
+			fmtMatch(0, formattedCapture{"x", "b"}),
+		}},
+	}
+	for _, row := range rows {
+		query, err := NewQuery(language, row.pattern)
+		if !assert.Nil(t, err, row.pattern) {
+			continue
+		}
+		matches := cursor.Matches(query, tree.RootNode(), []byte(source))
+		assert.Equal(t, row.matches, collectMatches(matches, query, source), row.pattern)
+		query.Close()
+	}
+}
+
+func TestQueryMatchesGroupCaptures(t *testing.T) {
+	language := getLanguage("go")
+	source := `package main
+
+func alpha() { a() }
+
+func _skipped() { s() }
+
+func beta() { b() }
+`
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	query, err := NewQuery(language, `
+		((function_declaration name: (identifier) @name body: (block) @body)
+			(#not-match? @name "^_"))
+	`)
+	assert.Nil(t, err)
+	defer query.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	// Captures gives each of the two captures of a match on its own.
+	assert.Equal(t, []formattedCapture{
+		{"name", "alpha"},
+		{"body", "{ a() }"},
+		{"name", "beta"},
+		{"body", "{ b() }"},
+	}, collectCaptures(cursor.Captures(query, tree.RootNode(), []byte(source)), query, source))
+
+	// Matches gives them together.
+	expected := []formattedMatch{
+		fmtMatch(0, fmtCapture("name", "alpha"), fmtCapture("body", "{ a() }")),
+		fmtMatch(0, fmtCapture("name", "beta"), fmtCapture("body", "{ b() }")),
+	}
+	assert.Equal(t, expected, collectMatches(cursor.Matches(query, tree.RootNode(), []byte(source)), query, source))
+	matches := cursor.MatchesWith(query, tree.RootNode(), func(offset int, position Point) []byte {
+		if offset >= len(source) {
+			return []byte{}
+		}
+		return []byte(source[offset : offset+1])
+	})
+	assert.Equal(t, expected, collectMatches(matches, query, source))
+}
+
+func TestQueryRegisteredPredicates(t *testing.T) {
+	language := getLanguage("javascript")
+	source := "f(a);\nconst b = a;\ng(b, c);\n"
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+
+	query, err := NewQuery(language, `
+		((identifier) @name (#has-parent? @name "arguments" "variable_declarator"))
+		((identifier) @callee (#is-callee? @callee) (#unknown! @callee))
+	`)
+	assert.Nil(t, err)
+	defer query.Close()
+	assert.Equal(t, []string{"has-parent?", "is-callee?", "unknown!"}, query.UnhandledPredicates())
+
+	// The parent of the first argument's capture must have one of the other arguments' kinds.
+	query.RegisterPredicate("has-parent?", func(match *QueryMatch, args []QueryPredicateArg, text func(Node) []byte) (bool, error) {
+		for _, node := range match.NodesForCaptureIndex(*args[0].CaptureId) {
+			kind := node.Parent().Kind()
+			if !slices.ContainsFunc(args[1:], func(arg QueryPredicateArg) bool { return *arg.String == kind }) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	query.RegisterPredicate("is-callee?", func(match *QueryMatch, args []QueryPredicateArg, text func(Node) []byte) (bool, error) {
+		node := match.NodesForCaptureIndex(*args[0].CaptureId)[0]
+		callee := node.Parent().ChildByFieldName("function")
+		return callee != nil && callee.Equals(node) && len(text(node)) == 1, nil
+	})
+	assert.Equal(t, []string{"unknown!"}, query.UnhandledPredicates())
+
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	matches := cursor.Matches(query, tree.RootNode(), []byte(source))
+	assert.Equal(
+		t,
+		[]formattedMatch{
+			fmtMatch(1, fmtCapture("callee", "f")),
+			fmtMatch(0, fmtCapture("name", "a")),
+			fmtMatch(0, fmtCapture("name", "b")),
+			fmtMatch(0, fmtCapture("name", "a")),
+			fmtMatch(1, fmtCapture("callee", "g")),
+			fmtMatch(0, fmtCapture("name", "b")),
+			fmtMatch(0, fmtCapture("name", "c")),
+		},
+		collectMatches(matches, query, source),
+	)
+	captures := cursor.Captures(query, tree.RootNode(), []byte(source))
+	assert.Equal(
+		t,
+		[]formattedCapture{
+			{"callee", "f"}, {"name", "a"}, {"name", "b"}, {"name", "a"},
+			{"callee", "g"}, {"name", "b"}, {"name", "c"},
+		},
+		collectCaptures(captures, query, source),
+	)
+
+	// An error from a predicate ends the iteration and is reported afterwards.
+	errPredicate := errors.New("predicate failed")
+	query.RegisterPredicate("is-callee?", func(match *QueryMatch, args []QueryPredicateArg, text func(Node) []byte) (bool, error) {
+		return false, errPredicate
+	})
+	matches = cursor.Matches(query, tree.RootNode(), []byte(source))
+	assert.Nil(t, matches.Next())
+	assert.ErrorIs(t, matches.Err(), errPredicate)
+	captures = cursor.Captures(query, tree.RootNode(), []byte(source))
+	match, _ := captures.Next()
+	assert.Nil(t, match)
+	assert.ErrorIs(t, captures.Err(), errPredicate)
+}
+
+func TestQueryMatchProperties(t *testing.T) {
+	language := getLanguage("javascript")
+	source := "sql`SELECT 1`;\nconsole.log(a);\nf(b);\n"
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	strptr := func(s string) *string { return &s }
+
+	injections, err := NewQuery(language, `
+		(call_expression
+			function: (identifier) @_tag (#eq? @_tag "sql")
+			arguments: (template_string) @injection.content
+			(#set! injection.language "sql")
+			(#set! injection.include-children))
+	`)
+	assert.Nil(t, err)
+	defer injections.Close()
+	matches := cursor.Matches(injections, tree.RootNode(), []byte(source))
+	match := matches.Next()
+	assert.NotNil(t, match)
+	assert.Equal(t, map[string]QueryProperty{
+		"injection.language":         {Key: "injection.language", Value: strptr("sql")},
+		"injection.include-children": {Key: "injection.include-children"},
+	}, match.Properties(injections))
+	assert.Nil(t, matches.Next())
+
+	highlights, err := NewQuery(language, `
+		((identifier) @variable.builtin
+			(#eq? @variable.builtin "console")
+			(#set! priority 110))
+		(call_expression
+			function: (member_expression property: (property_identifier) @method)?
+			arguments: (arguments (identifier)? @argument)
+			(#set! @method role "callee"))
+	`)
+	assert.Nil(t, err)
+	defer highlights.Close()
+	var priorities []string
+	var roles []QueryProperty
+	calls := 0
+	matches = cursor.Matches(highlights, tree.RootNode(), []byte(source))
+	for match := matches.Next(); match != nil; match = matches.Next() {
+		if match.PatternIndex == 1 {
+			calls++
+		}
+		properties := match.Properties(highlights)
+		if priority, ok := properties["priority"]; ok {
+			priorities = append(priorities, *priority.Value)
+		}
+		if role, ok := properties["role"]; ok {
+			roles = append(roles, role)
+		}
+	}
+	assert.Equal(t, []string{"110"}, priorities)
+	// The role is only set on the call whose member expression was captured.
+	assert.Equal(t, 2, calls)
+	methodIndex, _ := highlights.CaptureIndexForName("method")
+	assert.Equal(t, []QueryProperty{NewQueryProperty("role", strptr("callee"), &methodIndex)}, roles)
+}
+
+func TestQueryPropertyPredicates(t *testing.T) {
+	language := getLanguage("javascript")
+	source := "function f(a) { return a + B; }"
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+
+	query, err := NewQuery(language, `
+		(formal_parameters (identifier) @variable.parameter
+			(#is? @variable.parameter local.scope "parameter"))
+		((identifier) @constant
+			(#match? @constant "^[A-Z]")
+			(#is-not? local))
+		((identifier) @variable
+			(#is-not? local)
+			(#set! priority 90))
+	`)
+	assert.Nil(t, err)
+	defer query.Close()
+
+	strptr := func(s string) *string { return &s }
+	parameterIndex, _ := query.CaptureIndexForName("variable.parameter")
+	assert.Equal(t, []PropertyPredicate{
+		{NewQueryProperty("local.scope", strptr("parameter"), &parameterIndex), true},
+	}, query.PropertyPredicates(0))
+	assert.Equal(t, []PropertyPredicate{
+		{NewQueryProperty("local", nil, nil), false},
+	}, query.PropertyPredicates(1))
+	assert.Equal(t, query.PropertyPredicates(1), query.PropertyPredicates(2))
+	// The assertions are kept apart from the settings and the general predicates.
+	assert.Equal(t, []QueryProperty{NewQueryProperty("priority", strptr("90"), nil)}, query.PropertySettings(2))
+	for i := range query.PatternCount() {
+		assert.Empty(t, query.GeneralPredicates(i))
+	}
+
+	// The assertions are left to the consumer, so every identifier matches.
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	captures := cursor.Captures(query, tree.RootNode(), []byte(source))
+	assert.Equal(
+		t,
+		[]formattedCapture{
+			{"variable", "f"},
+			{"variable.parameter", "a"},
+			{"variable", "a"},
+			{"variable", "a"},
+			{"constant", "B"},
+			{"variable", "B"},
+		},
+		collectCaptures(captures, query, source),
+	)
+}
+
+func TestQueryGeneralPredicateArgs(t *testing.T) {
+	language := getLanguage("javascript")
+	query, err := NewQuery(language, `
+		((template_string) @injection.content
+			(#offset! @injection.content 0 1 0 -1)
+			(#set! injection.language "sql"))
+		((string) @string
+			(#eq? @string "''")
+			(#gsub! @string "^." "")
+			(#lua-match? @string "%a+"))
+	`)
+	assert.Nil(t, err)
+	defer query.Close()
+
+	predicates := query.GeneralPredicates(0)
+	if assert.Len(t, predicates, 1) {
+		offset := predicates[0]
+		assert.Equal(t, "offset!", offset.Operator)
+		if assert.Len(t, offset.Args, 5) {
+			assert.Equal(t, "injection.content", offset.Args[0].CaptureName(query))
+			assert.Nil(t, offset.Args[0].String)
+			var numbers []string
+			for _, arg := range offset.Args[1:] {
+				assert.Nil(t, arg.CaptureId)
+				assert.Empty(t, arg.CaptureName(query))
+				numbers = append(numbers, *arg.String)
+			}
+			assert.Equal(t, []string{"0", "1", "0", "-1"}, numbers)
+		}
+	}
+
+	// The built-in #eq? is excluded, and the others are kept in their order.
+	var operators []string
+	for _, predicate := range query.GeneralPredicates(1) {
+		operators = append(operators, predicate.Operator)
+		assert.Equal(t, "string", predicate.Args[0].CaptureName(query))
+	}
+	assert.Equal(t, []string{"gsub!", "lua-match?"}, operators)
+	assert.Equal(t, "^.", *query.GeneralPredicates(1)[0].Args[1].String)
+}
+
+func TestQueryMaxStartDepth(t *testing.T) {
+	type row struct {
+		description string
+		pattern     string
+		depth       uint
+		matches     []formattedMatch
+	}
+	source := strings.TrimSpace(`
+if (a1 && a2) {
+    if (b1 && b2) { }
+    if (c) { }
+}
+if (d) {
+    if (e1 && e2) { }
+    if (f) { }
+}
+	`)
+
+	rows := []row{
+		{
+			description: "depth 0: match translation unit",
+			depth:       0,
+			pattern:     `(translation_unit) @capture`,
+			matches: []formattedMatch{
+				fmtMatch(0, fmtCapture("capture", source)),
+			},
+		},
+		{
+			description: "depth 0: match none",
+			depth:       0,
+			pattern:     `(if_statement) @capture`,
+			matches:     []formattedMatch{},
+		},
+		{
+			description: "depth 1: match 2 if statements at the top level",
+			depth:       1,
+			pattern:     `(if_statement) @capture`,
+			matches: []formattedMatch{
+				fmtMatch(0, fmtCapture("capture", "if (a1 && a2) {\n    if (b1 && b2) { }\n    if (c) { }\n}")),
+				fmtMatch(0, fmtCapture("capture", "if (d) {\n    if (e1 && e2) { }\n    if (f) { }\n}")),
+			},
+		},
+		{
+			description: "depth 1 with deep pattern: match only the first if statement",
+			depth:       1,
+			pattern: `(if_statement
+                        condition: (parenthesized_expression
+                            (binary_expression)
+                        )
+                    ) @capture`,
+			matches: []formattedMatch{
+				fmtMatch(0, fmtCapture("capture", "if (a1 && a2) {\n    if (b1 && b2) { }\n    if (c) { }\n}")),
+			},
+		},
+		{
+			description: "depth 3 with deep pattern: match all if statements with a binexpr condition",
+			depth:       3,
+			pattern: `(if_statement
+                        condition: (parenthesized_expression
+                            (binary_expression)
+                        )
+                    ) @capture`,
+			matches: []formattedMatch{
+				fmtMatch(0, fmtCapture("capture", "if (a1 && a2) {\n    if (b1 && b2) { }\n    if (c) { }\n}")),
+				fmtMatch(0, fmtCapture("capture", "if (b1 && b2) { }")),
+				fmtMatch(0, fmtCapture("capture", "if (e1 && e2) { }")),
+			},
+		},
+	}
+
+	for _, row := range rows {
+		language := getLanguage("c")
+		parser := NewParser()
+		defer parser.Close()
 		parser.SetLanguage(language)
 
 		tree := parser.Parse([]byte(source), nil)
@@ -4772,7 +5814,7 @@ func TestQueryErrorDoesNotOob(t *testing.T) {
 	_, err := NewQuery(language, "(clas")
 	assert.Equal(
 		t,
-		&QueryError{Row: 0, Offset: 1, Column: 1, Kind: QueryErrorNodeType, Message: "clas"},
+		&QueryError{Row: 0, Offset: 1, Column: 1, Kind: QueryErrorNodeType, Message: "clas", Token: "clas"},
 		err,
 	)
 }
@@ -4892,6 +5934,86 @@ func TestQueryMaxStartDepthMore(t *testing.T) {
 	}
 }
 
+func TestQueryMaxStartDepthWithNestedFunctions(t *testing.T) {
+	language := getLanguage("go")
+	source := `package main
+
+// Run runs.
+func Run() {
+	f := func() {
+		g := func() {}
+		g()
+	}
+	f()
+}
+
+var h = func() {}
+`
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+	root := tree.RootNode()
+	run := root.NamedChild(2)
+	assert.Equal(t, "function_declaration", run.Kind())
+
+	query, err := NewQuery(language, `
+		(function_declaration name: (identifier) @function)
+		(func_literal) @literal
+		((comment) @doc . (function_declaration))
+	`)
+	assert.Nil(t, err)
+	defer query.Close()
+	assert.False(t, query.IsPatternRooted(2))
+
+	zero, one := uint(0), uint(1)
+	rows := []struct {
+		description string
+		node        *Node
+		depth       *uint
+		captures    []formattedCapture
+	}{
+		{
+			description: "depth 0 only matches the root",
+			node:        root,
+			depth:       &zero,
+			captures:    []formattedCapture{},
+		},
+		{
+			description: "depth 0 on the function matches the function",
+			node:        run,
+			depth:       &zero,
+			captures:    []formattedCapture{{"function", "Run"}},
+		},
+		{
+			description: "depth 1 matches the top-level declarations and the comment before one",
+			node:        root,
+			depth:       &one,
+			captures:    []formattedCapture{{"doc", "// Run runs."}, {"function", "Run"}},
+		},
+		{
+			description: "no limit also matches the function literals",
+			node:        root,
+			captures: []formattedCapture{
+				{"doc", "// Run runs."},
+				{"function", "Run"},
+				{"literal", "func() {\n\t\tg := func() {}\n\t\tg()\n\t}"},
+				{"literal", "func() {}"},
+				{"literal", "func() {}"},
+			},
+		},
+	}
+
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	for _, row := range rows {
+		cursor.SetMaxStartDepth(row.depth)
+		captures := cursor.Captures(query, row.node, []byte(source))
+		assert.Equal(t, row.captures, collectCaptures(captures, query, source), row.description)
+	}
+}
+
 func TestQueryWithFirstChildInGroupIsAnchor(t *testing.T) {
 	language := getLanguage("c")
 	sourceCode := "void fun(int a, char b, int c) { };"
@@ -4998,6 +6120,67 @@ func TestQueryExecutionWithTimeout(t *testing.T) {
 	assert.Equal(t, 1000, count)
 }
 
+func TestQueryExecutionWithContext(t *testing.T) {
+	language := getLanguage("javascript")
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+
+	sourceCode := strings.Repeat("function foo() { while (true) { } }\n", 1000)
+	tree := parser.Parse([]byte(sourceCode), nil)
+	defer tree.Close()
+
+	query, err := NewQuery(language, "(function_declaration) @function")
+	assert.Nil(t, err)
+	defer query.Close()
+
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+
+	// A deadline that has passed halts the query early.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
+	defer cancel()
+	<-ctx.Done()
+	matches := cursor.MatchesCtx(ctx, query, tree.RootNode(), []byte(sourceCode))
+	count := 0
+	for matches.Next() != nil {
+		count++
+	}
+	assert.Less(t, count, 1000)
+	assert.ErrorIs(t, matches.Err(), context.DeadlineExceeded)
+
+	// The matches found before the context is cancelled are still returned.
+	ctx, cancelCaptures := context.WithCancel(context.Background())
+	defer cancelCaptures()
+	captures := cursor.CapturesCtx(ctx, query, tree.RootNode(), []byte(sourceCode))
+	count = 0
+	for match, _ := captures.Next(); match != nil; match, _ = captures.Next() {
+		if count++; count == 10 {
+			cancelCaptures()
+		}
+	}
+	assert.GreaterOrEqual(t, count, 10)
+	assert.Less(t, count, 1000)
+	assert.ErrorIs(t, captures.Err(), context.Canceled)
+
+	// A context that is not done lets the query run to the end.
+	matches = cursor.MatchesCtx(context.Background(), query, tree.RootNode(), []byte(sourceCode))
+	count = 0
+	for matches.Next() != nil {
+		count++
+	}
+	assert.Equal(t, 1000, count)
+	assert.NoError(t, matches.Err())
+
+	captures = cursor.Captures(query, tree.RootNode(), []byte(sourceCode))
+	count = 0
+	for match, _ := captures.Next(); match != nil; match, _ = captures.Next() {
+		count++
+	}
+	assert.Equal(t, 1000, count)
+	assert.NoError(t, captures.Err())
+}
+
 func TestQueryExecutionWithPointsCausingUnderflow(t *testing.T) {
 	language := getLanguage("rust")
 	parser := NewParser()
@@ -5291,3 +6474,60 @@ func fmtMatch(
 func fmtCapture(name, value string) formattedCapture {
 	return formattedCapture{name, value}
 }
+
+func TestQueryUseAfterClose(t *testing.T) {
+	language := getLanguage("json")
+	query, err := NewQuery(language, "(number) @number")
+	assert.Nil(t, err)
+	query.Close()
+	query.Close()
+
+	assert.PanicsWithValue(t, "tree_sitter: use of closed Query", func() {
+		query.PatternCount()
+	})
+}
+
+func TestQueryCursorUseAfterClose(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	tree := parser.Parse([]byte("[1]"), nil)
+	defer tree.Close()
+
+	query, err := NewQuery(getLanguage("json"), "(number) @number")
+	assert.Nil(t, err)
+	defer query.Close()
+
+	cursor := NewQueryCursor()
+	cursor.Close()
+	cursor.Close()
+
+	assert.PanicsWithValue(t, "tree_sitter: use of closed QueryCursor", func() {
+		cursor.Matches(query, tree.RootNode(), []byte("[1]"))
+	})
+}
+
+func TestQueryMatchesUseAfterCursorClose(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	source := []byte("[1, 2]")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	query, err := NewQuery(getLanguage("json"), "(number) @number")
+	assert.Nil(t, err)
+	defer query.Close()
+
+	cursor := NewQueryCursor()
+	matches := cursor.Matches(query, tree.RootNode(), source)
+	captures := cursor.Captures(query, tree.RootNode(), source)
+	cursor.Close()
+
+	assert.PanicsWithValue(t, "tree_sitter: use of closed QueryCursor", func() {
+		matches.Next()
+	})
+	assert.PanicsWithValue(t, "tree_sitter: use of closed QueryCursor", func() {
+		captures.Next()
+	})
+}
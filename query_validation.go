@@ -0,0 +1,219 @@
+package tree_sitter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The largest number of suggestions in a [QueryDiagnostic].
+const maxQuerySuggestions = 3
+
+// A problem in the source of a query, found by [ValidateQuerySource].
+type QueryDiagnostic struct {
+	QueryError
+
+	// For an invalid node type or field name, the names that the language
+	// has which are closest to it, from the closest one on. This is empty
+	// if none of them is close.
+	Suggestions []string
+}
+
+func (d QueryDiagnostic) Error() string {
+	msg := d.QueryError.Error()
+	if len(d.Suggestions) == 0 {
+		return msg
+	}
+	quoted := make([]string, len(d.Suggestions))
+	for i, suggestion := range d.Suggestions {
+		quoted[i] = fmt.Sprintf("%q", suggestion)
+	}
+	return fmt.Sprintf("%s, did you mean %s?", msg, strings.Join(quoted, " or "))
+}
+
+// Check the source of a query for the given language, returning every
+// problem that is found, or nil if the query is valid.
+//
+// Unlike [NewQuery], this does not stop at the first error: the pattern
+// that contains it is left out, and the rest of the source is checked
+// again, so that the errors of several patterns are reported at once. The
+// positions of the errors are those in the given source. A syntax error
+// that leaves a pattern without its closing parenthesis also hides the
+// patterns after it.
+func ValidateQuerySource(language *Language, source string) []QueryDiagnostic {
+	var diagnostics []QueryDiagnostic
+	masked := []byte(source)
+	for {
+		query, err := NewQuery(language, string(masked))
+		if err == nil {
+			query.Close()
+			return diagnostics
+		}
+
+		diagnostic := QueryDiagnostic{QueryError: *err}
+		switch err.Kind {
+		case QueryErrorNodeType:
+			quoted := err.Offset > 0 && source[err.Offset-1] == '"'
+			diagnostic.Suggestions = closestNames(err.Message, nodeKindNames(language, !quoted))
+		case QueryErrorField:
+			diagnostic.Suggestions = closestNames(err.Message, fieldNames(language))
+		case QueryErrorSyntax, QueryErrorStructure:
+			// Show the line as it is in the given source, rather than with
+			// the patterns that have been left out.
+			if line := strings.Split(err.Message, "\n"); len(line) == 2 {
+				lineStart := err.Offset - err.Column
+				lineEnd := lineStart + uint(len(line[0]))
+				diagnostic.Message = source[lineStart:lineEnd] + "\n" + line[1]
+			}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+
+		start, end, ok := queryPatternBounds(masked, err.Offset)
+		if err.Kind == QueryErrorLanguage || !ok {
+			return diagnostics
+		}
+		for i := start; i < end; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+}
+
+// Find the top-level pattern of a query's source that contains the given
+// offset, along with its quantifier and captures, which is all that must be
+// left out of the source to leave the pattern out. A pattern that is not
+// closed before the end of the source contains the end as well.
+//
+// An offset between two patterns is taken to be in the second one, since
+// errors such as a bare word that is not a pattern are reported there.
+func queryPatternBounds(source []byte, offset uint) (start, end int, ok bool) {
+	next := func(i int) (int, string) {
+		for i < len(source) {
+			switch {
+			case source[i] == ';':
+				for i < len(source) && source[i] != '\n' {
+					i++
+				}
+			case strings.IndexByte(" \t\r\n", source[i]) >= 0:
+				i++
+			default:
+				return i, queryTokenAt(string(source), uint(i))
+			}
+		}
+		return i, ""
+	}
+
+	for i := 0; ; {
+		start, token := next(i)
+		if token == "" {
+			return 0, 0, false
+		}
+		end = start + len(token)
+		if token == "(" || token == "[" {
+			for depth := 1; depth > 0; {
+				j, token := next(end)
+				if token == "" {
+					end = len(source)
+					break
+				}
+				switch token {
+				case "(", "[":
+					depth++
+				case ")", "]":
+					depth--
+				}
+				end = j + len(token)
+			}
+		}
+		for {
+			j, token := next(end)
+			if !strings.HasPrefix(token, "@") && token != "?" && token != "*" && token != "+" {
+				break
+			}
+			end = j + len(token)
+		}
+
+		if offset < uint(end) || end == len(source) {
+			return start, end, true
+		}
+		i = end
+	}
+}
+
+// The names of a language's node kinds that can be used in queries, either
+// the named ones or the anonymous ones.
+func nodeKindNames(language *Language, named bool) []string {
+	var names []string
+	for id := range uint16(language.NodeKindCount()) {
+		if language.NodeKindIsNamed(id) != named {
+			continue
+		}
+		if !language.NodeKindIsVisible(id) && !language.NodeKindIsSupertype(id) {
+			continue
+		}
+		if name := language.NodeKindForId(id); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func fieldNames(language *Language) []string {
+	names := make([]string, 0, language.FieldCount())
+	for id := uint16(1); uint32(id) <= language.FieldCount(); id++ {
+		names = append(names, language.FieldNameForId(id))
+	}
+	return names
+}
+
+// Get the candidates that are closest to the given name by their edit
+// distance, if they are close enough to be a likely typo of it.
+func closestNames(name string, candidates []string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	limit := max(2, len(name)/3)
+	var closest []candidate
+	for _, c := range candidates {
+		if d := editDistance(name, c); d <= limit {
+			closest = append(closest, candidate{c, d})
+		}
+	}
+	slices.SortFunc(closest, func(a, b candidate) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+		return strings.Compare(a.name, b.name)
+	})
+
+	var names []string
+	for _, c := range closest[:min(len(closest), maxQuerySuggestions)] {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Get the Levenshtein distance between two strings, which is the number of
+// bytes that must be inserted, deleted or replaced to turn one into the
+// other.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,60 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestValidateQuerySource(t *testing.T) {
+	language := getLanguage("go")
+
+	source := `(function_declaraton name: (identifier) @name)
+
+; A call of a function.
+(call_expression functon: (identifier) @callee)
+
+(method_declaration name: (field_identifier) @method)
+`
+	diagnostics := ValidateQuerySource(language, source)
+	if assert.Len(t, diagnostics, 2) {
+		assert.Equal(t, QueryErrorNodeType, diagnostics[0].Kind)
+		assert.Equal(t, "function_declaraton", diagnostics[0].Message)
+		assert.EqualValues(t, 0, diagnostics[0].Row)
+		assert.EqualValues(t, 1, diagnostics[0].Offset)
+		assert.Equal(t, "function_declaration", diagnostics[0].Suggestions[0])
+
+		assert.Equal(t, QueryErrorField, diagnostics[1].Kind)
+		assert.Equal(t, "functon", diagnostics[1].Message)
+		assert.EqualValues(t, 3, diagnostics[1].Row)
+		assert.EqualValues(t, 17, diagnostics[1].Column)
+		assert.Equal(t, []string{"function"}, diagnostics[1].Suggestions)
+		assert.Equal(t, `query error at 4:18: invalid field name "functon", did you mean "function"?`, diagnostics[1].Error())
+	}
+
+	// Anonymous nodes get suggestions from the anonymous node kinds.
+	diagnostics = ValidateQuerySource(language, `(binary_expression operator: "&&&") ("retrun")`)
+	if assert.Len(t, diagnostics, 2) {
+		assert.Equal(t, "&&&", diagnostics[0].Message)
+		assert.Contains(t, diagnostics[0].Suggestions, "&&")
+		assert.Equal(t, []string{"return"}, diagnostics[1].Suggestions)
+	}
+
+	// A name that is nothing like any other gets no suggestions.
+	diagnostics = ValidateQuerySource(language, "(zzzzzzzzzzzz)")
+	if assert.Len(t, diagnostics, 1) {
+		assert.Empty(t, diagnostics[0].Suggestions)
+		assert.Equal(t, `query error at 1:2: invalid node type "zzzzzzzzzzzz"`, diagnostics[0].Error())
+	}
+
+	// Syntax errors are reported with the line from the given source.
+	diagnostics = ValidateQuerySource(language, "(identifier) @a\n(identifer) @b\n(identifier @c\n")
+	if assert.Len(t, diagnostics, 2) {
+		assert.Equal(t, QueryErrorNodeType, diagnostics[0].Kind)
+		assert.Equal(t, QueryErrorSyntax, diagnostics[1].Kind)
+		assert.EqualValues(t, 2, diagnostics[1].Row)
+	}
+
+	assert.Nil(t, ValidateQuerySource(language, "(function_declaration name: (identifier) @name)"))
+}
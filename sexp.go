@@ -0,0 +1,230 @@
+package tree_sitter
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// An option that changes what [Node.PrettySExp] and [WriteSExp] include.
+type SExpOption func(*sexpOptions)
+
+type sexpOptions struct {
+	byteRanges  bool
+	pointRanges bool
+	anonymous   bool
+	compact     bool
+	source      []byte
+}
+
+// Follow each node's kind with its byte range, as in `[3, 6]`.
+func SExpByteRanges() SExpOption {
+	return func(o *sexpOptions) {
+		o.byteRanges = true
+	}
+}
+
+// Follow each node's kind with its start and end points, as in
+// `[0, 3] - [0, 6]`.
+func SExpPointRanges() SExpOption {
+	return func(o *sexpOptions) {
+		o.pointRanges = true
+	}
+}
+
+// Include anonymous nodes, such as punctuation and keywords, which are
+// printed as quoted strings.
+func SExpAnonymousNodes() SExpOption {
+	return func(o *sexpOptions) {
+		o.anonymous = true
+	}
+}
+
+// Put the whole s-expression on one line, separating the nodes with spaces
+// as [Node.ToSexp] does, rather than putting each child on its own line.
+func SExpCompact() SExpOption {
+	return func(o *sexpOptions) {
+		o.compact = true
+	}
+}
+
+// Follow the kind of each named leaf node with its text, read from
+// `source`.
+func SExpText(source []byte) SExpOption {
+	return func(o *sexpOptions) {
+		o.source = source
+	}
+}
+
+// Get an s-expression representing the subtree rooted at this node, with
+// each child on its own line, indented by two spaces per level and preceded
+// by its field name, if it has one.
+//
+// Without options this contains the same nodes as [Node.ToSexp]: the named
+// nodes and the missing nodes.
+func (n *Node) PrettySExp(opts ...SExpOption) string {
+	var sb strings.Builder
+	WriteSExp(&sb, *n, opts...)
+	return sb.String()
+}
+
+// Write the s-expression of the subtree rooted at `node` to `w`, as
+// [Node.PrettySExp] would return it.
+//
+// The s-expression is written as the tree is walked, through a buffer, so
+// even a tree that is far too large to hold as a string can be written
+// without allocating memory for each node.
+func WriteSExp(w io.Writer, node Node, opts ...SExpOption) error {
+	if node.IsNull() {
+		return nil
+	}
+	var o sexpOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	sw := sexpWriter{sexpOptions: &o, w: bufio.NewWriter(w)}
+
+	cursor := acquireCursor(node)
+	defer releaseCursor(cursor)
+	sw.write(cursor)
+	return sw.w.Flush()
+}
+
+type sexpWriter struct {
+	*sexpOptions
+	w *bufio.Writer
+
+	// Whether anything has been written yet.
+	started bool
+
+	// Space for formatting numbers and quoted strings.
+	scratch []byte
+}
+
+// How the s-expression of a node that the cursor has descended into is to
+// be finished.
+type sexpAncestor struct {
+	// Whether the node was written, which indents its children.
+	included bool
+	// Whether the node was written with an opening parenthesis.
+	open bool
+}
+
+// Write the node at the cursor and its descendants, leaving the cursor on
+// the node. A bufio.Writer remembers the first error, so it is only
+// checked once, when the writer is flushed.
+func (sw *sexpWriter) write(cursor *TreeCursor) {
+	var ancestors []sexpAncestor
+	depth := 0
+	for {
+		node := cursor.currentNode()
+		included := node.IsNamed() || node.IsMissing() || sw.anonymous
+		open := included && (node.IsNamed() || node.IsMissing())
+		if included {
+			sw.writeSeparator(depth)
+			if field := cursor.FieldName(); field != "" {
+				sw.w.WriteString(field)
+				sw.w.WriteString(": ")
+			}
+			sw.writeNode(&node)
+		}
+
+		if cursor.GotoFirstChild() {
+			ancestors = append(ancestors, sexpAncestor{included: included, open: open})
+			if included {
+				depth++
+			}
+			continue
+		}
+		if open {
+			sw.w.WriteByte(')')
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				return
+			}
+			parent := ancestors[len(ancestors)-1]
+			ancestors = ancestors[:len(ancestors)-1]
+			if parent.included {
+				depth--
+			}
+			if parent.open {
+				sw.w.WriteByte(')')
+			}
+		}
+	}
+}
+
+// Separate a node from the one before it, if there is one, with a space or
+// with a newline and the indentation for the given depth.
+func (sw *sexpWriter) writeSeparator(depth int) {
+	if !sw.started {
+		sw.started = true
+		return
+	}
+	if sw.compact {
+		sw.w.WriteByte(' ')
+		return
+	}
+	sw.w.WriteByte('\n')
+	for range depth {
+		sw.w.WriteString("  ")
+	}
+}
+
+// Write a node's label, leaving its s-expression open for its children if
+// it is named or missing.
+func (sw *sexpWriter) writeNode(node *Node) {
+	switch {
+	case node.IsMissing() && node.IsNamed():
+		sw.w.WriteString("(MISSING ")
+		sw.w.WriteString(node.Kind())
+	case node.IsMissing():
+		sw.w.WriteString("(MISSING ")
+		sw.writeQuoted(node.Kind())
+	case node.IsNamed():
+		sw.w.WriteByte('(')
+		sw.w.WriteString(node.Kind())
+	default:
+		sw.writeQuoted(node.Kind())
+	}
+
+	if sw.byteRanges {
+		sw.w.WriteString(" [")
+		sw.writeUint(node.StartByte())
+		sw.w.WriteString(", ")
+		sw.writeUint(node.EndByte())
+		sw.w.WriteByte(']')
+	}
+	if sw.pointRanges {
+		start, end := node.StartPosition(), node.EndPosition()
+		sw.w.WriteString(" [")
+		sw.writeUint(start.Row)
+		sw.w.WriteString(", ")
+		sw.writeUint(start.Column)
+		sw.w.WriteString("] - [")
+		sw.writeUint(end.Row)
+		sw.w.WriteString(", ")
+		sw.writeUint(end.Column)
+		sw.w.WriteByte(']')
+	}
+	if sw.source != nil && node.IsNamed() && node.ChildCount() == 0 {
+		text := node.Utf8Bytes(sw.source)
+		sw.w.WriteByte(' ')
+		// The text is only read while it is quoted, so it need not be copied
+		// into a string.
+		sw.writeQuoted(unsafe.String(unsafe.SliceData(text), len(text)))
+	}
+}
+
+func (sw *sexpWriter) writeUint(value uint) {
+	sw.scratch = strconv.AppendUint(sw.scratch[:0], uint64(value), 10)
+	sw.w.Write(sw.scratch)
+}
+
+func (sw *sexpWriter) writeQuoted(s string) {
+	sw.scratch = strconv.AppendQuote(sw.scratch[:0], s)
+	sw.w.Write(sw.scratch)
+}
@@ -0,0 +1,130 @@
+package tree_sitter_test
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestNodePrettySExp(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+	source := []byte("fn add(a: i32) -> i32 {\n    a + 1\n}\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	function := tree.RootNode().NamedChild(0)
+
+	assert.Equal(t, `(function_item
+  name: (identifier)
+  parameters: (parameters
+    (parameter
+      pattern: (identifier)
+      type: (primitive_type)))
+  return_type: (primitive_type)
+  body: (block
+    (binary_expression
+      left: (identifier)
+      right: (integer_literal))))`, function.PrettySExp())
+
+	// Without options, the same nodes are included as in ToSexp.
+	oneLine := regexp.MustCompile(`\n +`).ReplaceAllString(function.PrettySExp(), " ")
+	assert.Equal(t, function.ToSexp(), oneLine)
+
+	body := function.ChildByFieldName("body")
+	assert.Equal(t, `(block [22, 35]
+  (binary_expression [28, 33]
+    left: (identifier [28, 29])
+    right: (integer_literal [32, 33])))`, body.PrettySExp(SExpByteRanges()))
+
+	assert.Equal(t, `(block [0, 22] - [2, 1]
+  "{" [0, 22] - [0, 23]
+  (binary_expression [1, 4] - [1, 9]
+    left: (identifier [1, 4] - [1, 5])
+    operator: "+" [1, 6] - [1, 7]
+    right: (integer_literal [1, 8] - [1, 9]))
+  "}" [2, 0] - [2, 1])`, body.PrettySExp(SExpPointRanges(), SExpAnonymousNodes()))
+
+	assert.Equal(t, `(block
+  (binary_expression
+    left: (identifier "a")
+    right: (integer_literal "1")))`, body.PrettySExp(SExpText(source)))
+}
+
+func TestNodePrettySExpWithMissingNode(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("c"))
+	tree := parser.Parse([]byte("int a = 1"), nil)
+	defer tree.Close()
+
+	assert.Equal(t, `(translation_unit
+  (declaration
+    type: (primitive_type)
+    declarator: (init_declarator
+      declarator: (identifier)
+      value: (number_literal))
+    (MISSING ";")))`, tree.RootNode().PrettySExp())
+	assert.Equal(t, tree.RootNode().ToSexp(), regexp.MustCompile(`\n +`).ReplaceAllString(tree.RootNode().PrettySExp(), " "))
+}
+
+func TestWriteSExp(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := []byte(walkExample + "var s = f(\"a\", 'b')\nvar x\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	root := *tree.RootNode()
+
+	for _, opts := range [][]SExpOption{
+		nil,
+		{SExpByteRanges()},
+		{SExpPointRanges(), SExpAnonymousNodes()},
+		{SExpText(source), SExpByteRanges()},
+		{SExpCompact()},
+	} {
+		var sb strings.Builder
+		assert.NoError(t, WriteSExp(&sb, root, opts...))
+		assert.Equal(t, root.PrettySExp(opts...), sb.String())
+	}
+
+	var sb strings.Builder
+	assert.NoError(t, WriteSExp(&sb, root, SExpCompact()))
+	assert.Equal(t, root.ToSexp(), sb.String())
+	sb.Reset()
+	assert.NoError(t, WriteSExp(&sb, Node{}))
+	assert.Empty(t, sb.String())
+
+	assert.ErrorIs(t, WriteSExp(failingWriter{}, root), errWriteFailed)
+}
+
+func TestWriteSExpAllocations(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+
+	// The allocations do not grow with the size of the tree.
+	allocations := func(functions int) float64 {
+		var source strings.Builder
+		source.WriteString("package main\n")
+		for i := range functions {
+			fmt.Fprintf(&source, "func f%d(a int) string {\n\treturn g(a+%d, \"x\")\n}\n", i, i)
+		}
+		tree := parser.Parse([]byte(source.String()), nil)
+		defer tree.Close()
+		root := *tree.RootNode()
+		opts := []SExpOption{SExpByteRanges(), SExpPointRanges(), SExpText([]byte(source.String()))}
+		return testing.AllocsPerRun(5, func() {
+			assert.NoError(t, WriteSExp(io.Discard, root, opts...))
+		})
+	}
+	medium := allocations(100)
+	assert.LessOrEqual(t, medium, 10.0)
+	assert.Equal(t, medium, allocations(5000))
+}
@@ -0,0 +1,103 @@
+package tree_sitter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Get the first and last rows of source code that this node covers. Unlike
+// the row of [Node.EndPosition], the last row is the one holding the
+// node's last byte, so a node that ends with a newline does not cover the
+// row after it.
+func (n *Node) LineRange() (startRow, endRow uint) {
+	start, end := n.StartPosition(), n.EndPosition()
+	if end.Column == 0 && end.Row > start.Row {
+		return start.Row, end.Row - 1
+	}
+	return start.Row, end.Row
+}
+
+// Get the lines of source code that `node` covers, as given by
+// [Node.LineRange]. Each line is complete, containing the text before the
+// node on its first line and after it on its last one, but not the line
+// ending. The lines are slices of `source`, which must be the source that
+// the node was parsed from; rows that it is too short to contain are left
+// out.
+func SourceLines(source []byte, node Node) [][]byte {
+	startRow, endRow := node.LineRange()
+	start := node.StartByte() - min(node.StartPosition().Column, node.StartByte())
+	if start > uint(len(source)) {
+		return [][]byte{}
+	}
+
+	lines := make([][]byte, 0, endRow-startRow+1)
+	rest := source[start:]
+	for row := startRow; row <= endRow; row++ {
+		line, after, found := bytes.Cut(rest, []byte("\n"))
+		lines = append(lines, bytes.TrimSuffix(line, []byte("\r")))
+		if !found {
+			break
+		}
+		rest = after
+	}
+	return lines
+}
+
+// Render the lines of source code that `node` covers, each preceded by its
+// one-based line number and followed by a line of carets under the part that
+// belongs to the node, in the style of compiler diagnostics:
+//
+//	2 | 	x := add(1,
+//	  | 	     ^^^^^^
+//	3 | 		2)
+//	  | 		^^
+//
+// A zero-width node, such as a missing node, is marked with a single caret.
+// Tabs are kept in the padding before the carets, so that they line up with
+// the text above them. The result has no trailing newline.
+func SnippetWithCaret(source []byte, node Node) string {
+	startRow, _ := node.LineRange()
+	lines := SourceLines(source, node)
+	start, end := node.StartPosition(), node.EndPosition()
+	width := len(fmt.Sprint(startRow + uint(len(lines))))
+
+	var sb strings.Builder
+	for i, line := range lines {
+		row := startRow + uint(i)
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&sb, "%*d | %s", width, row+1, line)
+
+		// The part of the line that belongs to the node, in bytes. Lines
+		// after the first are marked from their indentation onward.
+		from := len(line) - len(bytes.TrimLeft(line, " \t"))
+		if row == start.Row {
+			from = min(int(start.Column), len(line))
+		}
+		to := len(line)
+		if row == end.Row {
+			to = min(int(end.Column), len(line))
+		}
+		carets := utf8.RuneCount(line[from:max(from, to)])
+		if carets == 0 {
+			if node.StartByte() != node.EndByte() {
+				continue
+			}
+			carets = 1
+		}
+
+		fmt.Fprintf(&sb, "\n%*s | ", width, "")
+		for _, r := range string(line[:from]) {
+			if r == '\t' {
+				sb.WriteByte('\t')
+			} else {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(strings.Repeat("^", carets))
+	}
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+const sourceLinesExample = "package main\n\nfunc main() {\n\tx := add(1,\n\t\t2,\n\t)\r\n}\n"
+
+func firstNodeOfKind(root Node, kind string) *Node {
+	for node := range Walk(root) {
+		if node.Kind() == kind {
+			return &node
+		}
+	}
+	return nil
+}
+
+func TestSourceLines(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := []byte(sourceLinesExample)
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	call := firstNodeOfKind(*tree.RootNode(), "call_expression")
+	startRow, endRow := call.LineRange()
+	assert.Equal(t, [2]uint{3, 5}, [2]uint{startRow, endRow})
+	assert.Equal(t, [][]byte{
+		[]byte("\tx := add(1,"),
+		[]byte("\t\t2,"),
+		[]byte("\t)"),
+	}, SourceLines(source, *call))
+
+	// The source file ends with a newline, which ends the last line rather
+	// than starting another one.
+	root := tree.RootNode()
+	startRow, endRow = root.LineRange()
+	assert.Equal(t, [2]uint{0, 6}, [2]uint{startRow, endRow})
+	assert.Len(t, SourceLines(source, *root), 7)
+	assert.Equal(t, []byte("}"), SourceLines(source, *root)[6])
+
+	// Without a trailing newline, the last line runs to the end of the file.
+	unterminated := source[:len(source)-1]
+	tree = parser.Parse(unterminated, nil)
+	defer tree.Close()
+	root = tree.RootNode()
+	startRow, endRow = root.LineRange()
+	assert.Equal(t, [2]uint{0, 6}, [2]uint{startRow, endRow})
+	assert.Equal(t, []byte("}"), SourceLines(unterminated, *root)[6])
+
+	assert.Empty(t, SourceLines(source[:10], *call))
+}
+
+func TestSnippetWithCaret(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := []byte(sourceLinesExample)
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	call := firstNodeOfKind(*tree.RootNode(), "call_expression")
+	assert.Equal(t, ""+
+		"4 | \tx := add(1,\n"+
+		"  | \t     ^^^^^^\n"+
+		"5 | \t\t2,\n"+
+		"  | \t\t^^\n"+
+		"6 | \t)\n"+
+		"  | \t^", SnippetWithCaret(source, *call))
+
+	// The carets are counted in characters, and a missing node gets one.
+	parser.SetLanguage(getLanguage("json"))
+	source = []byte("[\"日本語\", [1,\n  22")
+	tree = parser.Parse(source, nil)
+	defer tree.Close()
+	str := firstNodeOfKind(*tree.RootNode(), "string")
+	assert.Equal(t, "1 | [\"日本語\", [1,\n  |  ^^^^^", SnippetWithCaret(source, *str))
+	var missing *Node
+	for node := range Walk(*tree.RootNode()) {
+		if node.IsMissing() {
+			missing = &node
+			break
+		}
+	}
+	if assert.NotNil(t, missing) {
+		assert.Equal(t, "2 |   22\n  |     ^", SnippetWithCaret(source, *missing))
+	}
+}
@@ -0,0 +1,252 @@
+package tree_sitter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The number of sites that [SyntaxError.Error] describes before summarizing
+// the rest.
+const syntaxErrorSitesShown = 3
+
+// A place in a syntax tree where the parser had to recover from a syntax
+// error: either an `ERROR` node wrapping text that could not be parsed, or
+// a `MISSING` node that the parser inserted.
+type ErrorSite struct {
+	// The node's kind. For an `ERROR` node this is "ERROR"; for a missing
+	// node it is the kind of the node that was expected, e.g. ";".
+	Kind string
+
+	// Whether this is a missing node rather than an `ERROR` node.
+	Missing bool
+
+	// The node's position. Missing nodes are zero-width.
+	Range Range
+}
+
+func (s ErrorSite) String() string {
+	if s.Missing {
+		return fmt.Sprintf("missing %q at %d:%d", s.Kind, s.Range.StartPoint.Row+1, s.Range.StartPoint.Column+1)
+	}
+	return fmt.Sprintf("syntax error at %d:%d", s.Range.StartPoint.Row+1, s.Range.StartPoint.Column+1)
+}
+
+// ErrorSites is implemented by errors that describe every place in a
+// syntax tree where a syntax error was found.
+type ErrorSites interface {
+	error
+
+	// Sites returns the error sites in document order.
+	Sites() []ErrorSite
+}
+
+// An error returned by [Parser.ParseStrict] when the parsed tree contains
+// syntax errors.
+//
+// Its message describes the first few error sites; use [SyntaxError.Sites]
+// to enumerate all of them.
+type SyntaxError struct {
+	sites []ErrorSite
+}
+
+var _ ErrorSites = (*SyntaxError)(nil)
+
+// Sites returns every error site in the tree, in document order.
+func (e *SyntaxError) Sites() []ErrorSite {
+	return e.sites
+}
+
+func (e *SyntaxError) Error() string {
+	var sb strings.Builder
+	for i, site := range e.sites {
+		if i == syntaxErrorSitesShown {
+			fmt.Fprintf(&sb, ", and %d more", len(e.sites)-i)
+			break
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(site.String())
+	}
+	return sb.String()
+}
+
+// An error site found by [Tree.Errors], along with the syntactic context
+// that it was found in.
+type SyntaxErrorInfo struct {
+	ErrorSite
+
+	// The kind of the nearest named node that encloses the error site, e.g.
+	// "compound_statement", or an empty string if the site is the root node.
+	Parent string
+}
+
+// Collect the `ERROR` and `MISSING` nodes beneath `node`, in document
+// order. The contents of an `ERROR` node are not searched.
+func collectErrors(node *Node) []SyntaxErrorInfo {
+	errors := []SyntaxErrorInfo{}
+	cursor := acquireCursor(*node)
+	defer releaseCursor(cursor)
+
+	// The kinds of the named nodes that enclose the cursor's node, with an
+	// entry for every unnamed ancestor too, so that it can be popped when
+	// the cursor moves back up to the ancestor's parent.
+	var parents []string
+	parent := func() string {
+		for i := len(parents) - 1; i >= 0; i-- {
+			if parents[i] != "" {
+				return parents[i]
+			}
+		}
+		return ""
+	}
+
+	for {
+		current := cursor.Node()
+		descend := false
+		switch {
+		case current.IsError():
+			errors = append(errors, SyntaxErrorInfo{
+				ErrorSite: ErrorSite{Kind: current.Kind(), Range: current.Range()},
+				Parent:    parent(),
+			})
+		case current.IsMissing():
+			errors = append(errors, SyntaxErrorInfo{
+				ErrorSite: ErrorSite{Kind: current.Kind(), Missing: true, Range: current.Range()},
+				Parent:    parent(),
+			})
+		default:
+			descend = current.HasError()
+		}
+		if descend && cursor.GotoFirstChild() {
+			if current.IsNamed() {
+				parents = append(parents, current.Kind())
+			} else {
+				parents = append(parents, "")
+			}
+			continue
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				return errors
+			}
+			parents = parents[:len(parents)-1]
+		}
+	}
+}
+
+// Collect the error sites beneath `node`, as [collectErrors] does, without
+// their context.
+func collectErrorSites(node *Node) []ErrorSite {
+	errors := collectErrors(node)
+	sites := make([]ErrorSite, len(errors))
+	for i, info := range errors {
+		sites[i] = info.ErrorSite
+	}
+	return sites
+}
+
+// Get this node if it is an `ERROR` or `MISSING` node, or else its closest
+// `ERROR` ancestor, or `nil` if it is not part of a syntax error. Missing
+// nodes have no children, so they are never ancestors.
+func (n *Node) NearestError() *Node {
+	if n.IsError() || n.IsMissing() {
+		return n
+	}
+	for ancestor := range n.Ancestors() {
+		if ancestor.IsError() {
+			return &ancestor
+		}
+	}
+	return nil
+}
+
+// Get the kind of the token that the parser inserted if this is a `MISSING`
+// node, e.g. ";", or an empty string otherwise. This is the same as
+// [Node.Kind] for missing nodes.
+func (n *Node) MissingKind() string {
+	if !n.IsMissing() {
+		return ""
+	}
+	return n.Kind()
+}
+
+// A description of a syntax error, for telling the user what was expected
+// where it was found.
+type ErrorDetail struct {
+	// The `ERROR` or `MISSING` node.
+	Node Node
+
+	// The node's position. Missing nodes are zero-width.
+	Range Range
+
+	// Whether the node is a missing node rather than an `ERROR` node.
+	Missing bool
+
+	// The kinds of the tokens that would have been valid at the error,
+	// starting with the kind of a missing node.
+	Expected []string
+
+	// The text of the token before the error, or an empty string if the
+	// error is at the start of the document.
+	PrecedingText string
+}
+
+// Describe the syntax error that this node is part of, as found with
+// [Node.NearestError], or return `nil` if there is none. `source` is the
+// source code that the tree was parsed from.
+//
+// The expected tokens are those that the language allows after the token
+// before the error, as listed by its [LookaheadIterator], so they are a
+// hint of what could have come next rather than a complete list of fixes.
+func (n *Node) ErrorDetail(source []byte) *ErrorDetail {
+	node := n.NearestError()
+	if node == nil {
+		return nil
+	}
+	detail := &ErrorDetail{Node: *node, Range: node.Range(), Missing: node.IsMissing(), Expected: []string{}}
+	seen := map[string]bool{}
+	expect := func(kind string) {
+		if !seen[kind] {
+			seen[kind] = true
+			detail.Expected = append(detail.Expected, kind)
+		}
+	}
+	if detail.Missing {
+		expect(node.Kind())
+	}
+
+	preceding := precedingLeaf(node)
+	if preceding == nil {
+		return detail
+	}
+	detail.PrecedingText = preceding.Utf8Text(source)
+	language := node.Language()
+	// A token whose next parse state is not valid, which can happen within
+	// an error, has no lookahead iterator to list the expected tokens.
+	lookahead := preceding.LookaheadIterator()
+	if lookahead == nil {
+		return detail
+	}
+	defer lookahead.Close()
+	for _, symbol := range lookahead.Iter() {
+		if kind := language.NodeKindForId(symbol); kind != "" && language.NodeKindIsVisible(symbol) {
+			expect(kind)
+		}
+	}
+	return detail
+}
+
+// Get the last leaf node that ends before `node` starts, or `nil` if `node`
+// is at the start of the tree.
+func precedingLeaf(node *Node) *Node {
+	for current := node; current != nil; current = current.Parent() {
+		if prev := current.PrevSibling(); prev != nil {
+			for prev.ChildCount() > 0 {
+				prev = prev.Child(prev.ChildCount() - 1)
+			}
+			return prev
+		}
+	}
+	return nil
+}
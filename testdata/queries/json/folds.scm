@@ -0,0 +1,2 @@
+(object) @scope
+(arary) @scope
@@ -0,0 +1,3 @@
+; Strings and numbers.
+(string) @string
+(number) @number
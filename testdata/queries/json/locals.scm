@@ -0,0 +1,3 @@
+(pair key: (string) @key)
+
+(pair vaule: (_) @value)
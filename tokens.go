@@ -0,0 +1,60 @@
+package tree_sitter
+
+import "iter"
+
+// A leaf node of a syntax tree, as yielded by [Tokens].
+type Token struct {
+	// The kind and kind id of the leaf, as given by [Node.Kind] and
+	// [Node.KindId].
+	Kind   string
+	KindId uint16
+
+	StartByte  uint
+	EndByte    uint
+	StartPoint Point
+	EndPoint   Point
+
+	// Whether the leaf is a named node, such as an identifier, rather than
+	// an anonymous one, such as a keyword or punctuation.
+	IsNamed bool
+}
+
+// Iterate over the leaves of the subtree rooted at `root` in document order,
+// both named ones and anonymous ones. The text between tokens, which is
+// usually whitespace, is not part of any token, and a node such as a
+// comment or a string's contents is a single token.
+//
+// Missing nodes are leaves of zero width, so they are yielded as empty
+// tokens.
+func Tokens(root Node) iter.Seq[Token] {
+	return func(yield func(Token) bool) {
+		if root.IsNull() {
+			return
+		}
+		cursor := acquireCursor(root)
+		defer releaseCursor(cursor)
+		for {
+			if cursor.GotoFirstChild() {
+				continue
+			}
+			node := cursor.currentNode()
+			token := Token{
+				Kind:       node.Kind(),
+				KindId:     node.KindId(),
+				StartByte:  node.StartByte(),
+				EndByte:    node.EndByte(),
+				StartPoint: node.StartPosition(),
+				EndPoint:   node.EndPosition(),
+				IsNamed:    node.IsNamed(),
+			}
+			if !yield(token) {
+				return
+			}
+			for !cursor.GotoNextSibling() {
+				if !cursor.GotoParent() {
+					return
+				}
+			}
+		}
+	}
+}
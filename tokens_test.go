@@ -0,0 +1,88 @@
+package tree_sitter_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestTokensReconstructSource(t *testing.T) {
+	for _, language := range []string{"go", "json", "javascript"} {
+		source := map[string]string{
+			"go":         walkExample + "// comment\nvar s = \"a b\"\n",
+			"json":       JSON_EXAMPLE,
+			"javascript": "const x = { a: [1, 2], b: `t ${y}` }; // done\n",
+		}[language]
+
+		parser := NewParser()
+		parser.SetLanguage(getLanguage(language))
+		tree := parser.Parse([]byte(source), nil)
+
+		var sb strings.Builder
+		var previousEnd uint
+		for token := range Tokens(*tree.RootNode()) {
+			assert.LessOrEqual(t, previousEnd, token.StartByte, language)
+			sb.WriteString(source[token.StartByte:token.EndByte])
+			previousEnd = token.EndByte
+		}
+		assert.Equal(t, withoutSpace(source), withoutSpace(sb.String()), language)
+		tree.Close()
+		parser.Close()
+	}
+}
+
+func TestTokens(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	language := getLanguage("json")
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(`{"a": [1, true]}`), nil)
+	defer tree.Close()
+
+	var kinds, named []string
+	for token := range Tokens(*tree.RootNode()) {
+		assert.Equal(t, language.NodeKindForId(token.KindId), token.Kind)
+		kinds = append(kinds, token.Kind)
+		if token.IsNamed {
+			named = append(named, token.Kind)
+		}
+	}
+	assert.Equal(t, []string{"{", "\"", "string_content", "\"", ":", "[", "number", ",", "true", "]", "}"}, kinds)
+	assert.Equal(t, []string{"string_content", "number", "true"}, named)
+
+	number := tree.RootNode().DescendantForByteRange(7, 8)
+	for token := range Tokens(*number) {
+		assert.Equal(t, Token{
+			Kind:       "number",
+			KindId:     number.KindId(),
+			StartByte:  7,
+			EndByte:    8,
+			StartPoint: Point{0, 7},
+			EndPoint:   Point{0, 8},
+			IsNamed:    true,
+		}, token)
+	}
+
+	var count int
+	for range Tokens(*tree.RootNode()) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, count)
+	assert.Empty(t, slices.Collect(Tokens(Node{})))
+}
+
+func withoutSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -3,38 +3,111 @@ package tree_sitter
 /*
 #cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
 #include <tree_sitter/api.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+// Print a tree's DOT graph into a temporary file, and read it back into a
+// buffer that must be freed by the caller. This avoids handing file
+// descriptors from Go to C, which are not portable to Windows.
+static char *ts_go_tree_dot_graph(const TSTree *tree, size_t *length) {
+  FILE *file = tmpfile();
+  if (!file) return NULL;
+  ts_tree_print_dot_graph(tree, fileno(file));
+  if (fseek(file, 0, SEEK_END) != 0) {
+    fclose(file);
+    return NULL;
+  }
+  long size = ftell(file);
+  rewind(file);
+  char *buffer = malloc(size > 0 ? size : 1);
+  *length = buffer ? fread(buffer, 1, size, file) : 0;
+  fclose(file);
+  return buffer;
+}
 */
 import "C"
 
 import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
+// The sources retained with [Tree.SetSource], keyed by the C tree that they
+// belong to. Nodes only carry a pointer to their C tree, so this is how
+// [Node.Text] finds their source.
+var treeSources sync.Map
+
+// The number of the last edit made to each tree that has been edited, keyed
+// by its C tree, from a count of the edits made to any tree. A
+// [CursorBookmark] remembers this to tell whether its tree has been edited
+// since.
+var (
+	treeEdits sync.Map
+	editCount atomic.Uint64
+)
+
+// Get the number of the last edit made to the tree, or zero if it has not
+// been edited.
+func lastEdit(tree *C.TSTree) uint64 {
+	if edit, ok := treeEdits.Load(tree); ok {
+		return edit.(uint64)
+	}
+	return 0
+}
+
 // A stateful object that this is used to produce a [Tree] based on some
 // source code.
+//
+// The nodes of a tree do not keep it reachable. If a tree is dropped without
+// being closed while its nodes are still in use, automatic cleanup (see
+// [EnableAutoCleanup]) may free it under them, so keep the tree in use, for
+// example with `defer tree.Close()`, for as long as its nodes are:
+//
+//	tree := parser.Parse(source, nil)
+//	defer tree.Close()
+//	root := tree.RootNode()
+//
+// A tree must not be copied by value, since the copy and the original would
+// both free the same C tree.
 type Tree struct {
 	_inner *C.TSTree
+
+	liveObject
 }
 
 // Create a new tree from a raw pointer.
 func newTree(inner *C.TSTree) *Tree {
-	return &Tree{_inner: inner}
+	return addCleanup(&Tree{_inner: inner}, (*Tree).Close)
 }
 
 // Get the root node of the syntax tree.
 func (t *Tree) RootNode() *Node {
-	return &Node{_inner: C.ts_tree_root_node(t._inner)}
+	return &Node{_inner: C.ts_tree_root_node(t.inner())}
 }
 
 // Get the root node of the syntax tree, but with its position shifted
 // forward by the given offset.
+//
+// This is useful when the tree was parsed from a snippet extracted from a
+// larger document, such as a code block in Markdown: passing the snippet's
+// position in the outer document reports every node reached through the
+// returned root at its position in that document. Columns are only shifted
+// on the snippet's first row.
 func (t *Tree) RootNodeWithOffset(offsetBytes int, offsetExtent Point) *Node {
-	return &Node{_inner: C.ts_tree_root_node_with_offset(t._inner, C.uint(offsetBytes), offsetExtent.toTSPoint())}
+	return &Node{_inner: C.ts_tree_root_node_with_offset(t.inner(), C.uint(offsetBytes), offsetExtent.toTSPoint())}
 }
 
 // Get the language that was used to parse the syntax tree.
+//
+// The returned value is a new [Language], but it wraps the same underlying
+// grammar as the one given to [Parser.SetLanguage], so the two have equal
+// [Language.Inner] pointers and can be used interchangeably.
 func (t *Tree) Language() *Language {
-	return &Language{Inner: C.ts_tree_language(t._inner)}
+	return &Language{Inner: C.ts_tree_language(t.inner())}
 }
 
 // Edit the syntax tree to keep it in sync with source code that has been
@@ -42,13 +115,60 @@ func (t *Tree) Language() *Language {
 //
 // You must describe the edit both in terms of byte offsets and in terms of
 // row/column coordinates.
+//
+// Editing a tree forgets the source retained with [Tree.SetSource], since it
+// no longer matches the tree.
 func (t *Tree) Edit(edit *InputEdit) {
-	C.ts_tree_edit(t._inner, edit.toTSInputEdit())
+	C.ts_tree_edit(t.inner(), edit.toTSInputEdit())
+	treeSources.Delete(t._inner)
+	treeEdits.Store(t._inner, editCount.Add(1))
+}
+
+// Edit the syntax tree to match a change of its source code from `oldText` to
+// `newText`, using the edits computed by [EditsForChange].
+func (t *Tree) EditFromChange(oldText, newText []byte) {
+	for _, edit := range EditsForChange(oldText, newText) {
+		t.Edit(&edit)
+	}
+}
+
+// Retain the source code that the tree was parsed from, so that the text of
+// its nodes can be read with [Node.Text] and [Node.Content].
+//
+// The slice is not copied, so it must not be modified while the tree
+// retains it. Passing `nil` forgets the retained source.
+func (t *Tree) SetSource(source []byte) {
+	if source == nil {
+		treeSources.Delete(t.inner())
+	} else {
+		treeSources.Store(t.inner(), source)
+	}
+}
+
+// Get the source code retained with [Tree.SetSource], or `nil` if there is
+// none.
+func (t *Tree) Source() []byte {
+	if source, ok := treeSources.Load(t.inner()); ok {
+		return source.([]byte)
+	}
+	return nil
+}
+
+// Get every place in this tree where the parser recovered from a syntax
+// error, in document order. The result is empty if the tree has no errors.
+//
+// This walks the tree with a [TreeCursor], skipping subtrees that contain
+// no errors. An `ERROR` node is reported as a whole, without the errors
+// that it may contain.
+func (t *Tree) Errors() []SyntaxErrorInfo {
+	return collectErrors(t.RootNode())
 }
 
 // Create a new [TreeCursor] starting from the root of the tree.
 func (t *Tree) Walk() *TreeCursor {
-	return t.RootNode().Walk()
+	cursor := t.RootNode().Walk()
+	cursor.tree = t
+	return cursor
 }
 
 // Compare this old edited syntax tree to a new syntax tree representing
@@ -57,7 +177,7 @@ func (t *Tree) Walk() *TreeCursor {
 //
 // For this to work correctly, this syntax tree must have been edited such
 // that its ranges match up to the new tree. Generally, you'll want to
-// call this method right after calling one of the [Parser.parse]
+// call this method right after calling one of the [Parser.Parse]
 // functions. Call it on the old tree that was passed to parse, and
 // pass the new tree that was returned from `parse`.
 //
@@ -69,7 +189,9 @@ func (t *Tree) Walk() *TreeCursor {
 // but Tree-sitter attempts to make them as small as possible.
 func (t *Tree) ChangedRanges(other *Tree) []Range {
 	var count C.uint
-	ptr := C.ts_tree_get_changed_ranges(t._inner, other._inner, &count)
+	ptr := C.ts_tree_get_changed_ranges(t.inner(), other.inner(), &count)
+	runtime.KeepAlive(t)
+	runtime.KeepAlive(other)
 	ranges := make([]Range, int(count))
 	for i := uintptr(0); i < uintptr(count); i++ {
 		val := *(*C.TSRange)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + i*unsafe.Sizeof(*ptr)))
@@ -85,9 +207,12 @@ func (t *Tree) ChangedRanges(other *Tree) []Range {
 }
 
 // Get the included ranges that were used to parse the syntax tree.
+//
+// A tree parsed without calling [Parser.SetIncludedRanges] has a single
+// range that covers the whole document.
 func (t *Tree) IncludedRanges() []Range {
 	var count C.uint
-	ptr := C.ts_tree_included_ranges(t._inner, &count)
+	ptr := C.ts_tree_included_ranges(t.inner(), &count)
 	ranges := make([]Range, int(count))
 	for i := uintptr(0); i < uintptr(count); i++ {
 		val := *(*C.TSRange)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + i*unsafe.Sizeof(*ptr)))
@@ -102,20 +227,54 @@ func (t *Tree) IncludedRanges() []Range {
 	return ranges
 }
 
-// Print a graph of the tree to the given file descriptor.
-// The graph is formatted in the DOT language. You may want to pipe this
-// graph directly to a `dot(1)` process in order to generate SVG
-// output.
-func (t *Tree) PrintDotGraph(file int) {
-	C.ts_tree_print_dot_graph(t._inner, C.int(file))
+// Write a graph of the tree to the given writer. The graph is formatted in
+// the DOT language. You may want to pipe this graph directly to a `dot(1)`
+// process in order to generate SVG output.
+func (t *Tree) PrintDotGraph(w io.Writer) error {
+	var length C.size_t
+	buffer := C.ts_go_tree_dot_graph(t.inner(), &length)
+	if buffer == nil {
+		return errors.New("failed to buffer the DOT graph in a temporary file")
+	}
+	defer C.free(unsafe.Pointer(buffer))
+	_, err := w.Write(C.GoBytes(unsafe.Pointer(buffer), C.int(length)))
+	return err
 }
 
+// Delete the syntax tree, freeing all of the memory that it used.
+//
+// Closing a tree more than once has no effect, but calling any other method
+// on a closed tree panics. Nodes that belong to the tree must not be used
+// once it is closed.
 func (t *Tree) Close() {
-	if t != nil {
+	if t != nil && t._inner != nil {
+		removeCleanup(t)
+		treeSources.Delete(t._inner)
+		treeEdits.Delete(t._inner)
 		C.ts_tree_delete(t._inner)
+		t._inner = nil
 	}
 }
 
+func (t *Tree) inner() *C.TSTree {
+	if t._inner == nil {
+		panic("tree_sitter: use of closed Tree")
+	}
+	return t._inner
+}
+
+// Create a shallow copy of the syntax tree. This is very fast.
+//
+// You need to copy a syntax tree in order to use it on more than one
+// goroutine at a time, as syntax trees are not thread safe. The copy must be
+// closed separately, and the two can be closed in either order.
+//
+// The copy retains the same source as the original, if any.
 func (t *Tree) Clone() *Tree {
-	return newTree(C.ts_tree_copy(t._inner))
+	clone := newTree(C.ts_tree_copy(t.inner()))
+	runtime.KeepAlive(t)
+	if source := t.Source(); source != nil {
+		clone.SetSource(source)
+	}
+	return clone
 }
@@ -3,52 +3,105 @@ package tree_sitter
 /*
 #cgo CFLAGS: -Iinclude -Isrc -std=c11 -D_POSIX_C_SOURCE=200112L -D_DEFAULT_SOURCE
 #include <tree_sitter/api.h>
+#include "names.h"
 */
 import "C"
 
 // A stateful object for walking a syntax [Tree] efficiently.
+//
+// Moving a cursor is much cheaper than looking up children with
+// [Node.Child]. A cursor does not keep its tree alive: the tree must not be
+// closed while the cursor is in use, and the cursor must be closed with
+// [TreeCursor.Close] once it is no longer needed.
 type TreeCursor struct {
 	_inner C.TSTreeCursor
+
+	// The tree that the cursor was created from with [Tree.Walk], kept
+	// reachable so that it is not cleaned up while the cursor is in use.
+	tree *Tree
+
+	// Whether the cursor has been closed, so that closing it again does
+	// not free its memory twice.
+	closed bool
+
+	liveObject
 }
 
 func newTreeCursor(node Node) *TreeCursor {
-	return &TreeCursor{_inner: C.ts_tree_cursor_new(node._inner)}
+	return addCleanup(&TreeCursor{_inner: C.ts_tree_cursor_new(node._inner)}, (*TreeCursor).Close)
 }
 
+// Delete the tree cursor, freeing all of the memory that it used.
 func (tc *TreeCursor) Close() {
-	C.ts_tree_cursor_delete(&tc._inner)
+	if tc != nil && !tc.closed {
+		removeCleanup(tc)
+		C.ts_tree_cursor_delete(&tc._inner)
+		tc._inner = C.TSTreeCursor{}
+		tc.closed = true
+	}
+}
+
+// Report whether the cursor was made from the zero [Node], so that it has
+// no tree for the C library to move it over.
+func (tc *TreeCursor) isNull() bool {
+	return tc._inner.tree == nil
 }
 
+// Create an independent copy of the tree cursor, at the same position.
 func (tc *TreeCursor) Copy() *TreeCursor {
-	return &TreeCursor{_inner: C.ts_tree_cursor_copy(&tc._inner)}
+	return addCleanup(&TreeCursor{_inner: C.ts_tree_cursor_copy(&tc._inner), tree: tc.tree}, (*TreeCursor).Close)
 }
 
 // Get the tree cursor's current [Node].
 func (tc *TreeCursor) Node() *Node {
+	if tc.isNull() {
+		return nil
+	}
 	return newNode(C.ts_tree_cursor_current_node(&tc._inner))
 }
 
+// Get the cursor's current node as a value, which unlike [TreeCursor.Node]
+// does not need to be allocated.
+func (tc *TreeCursor) currentNode() Node {
+	if tc.isNull() {
+		return Node{}
+	}
+	return Node{_inner: C.ts_tree_cursor_current_node(&tc._inner)}
+}
+
 // Get the numerical field id of this tree cursor's current node.
 //
 // See also [TreeCursor.FieldName].
 func (tc *TreeCursor) FieldId() uint16 {
+	if tc.isNull() {
+		return 0
+	}
 	return uint16(C.ts_tree_cursor_current_field_id(&tc._inner))
 }
 
 // Get the field name of this tree cursor's current node.
 func (tc *TreeCursor) FieldName() string {
-	return C.GoString(C.ts_tree_cursor_current_field_name(&tc._inner))
+	if tc.isNull() {
+		return ""
+	}
+	return nameOfField(C.ts_go_tree_cursor_field(&tc._inner))
 }
 
 // Get the depth of the cursor's current node relative to the original
 // node that the cursor was constructed with.
 func (tc *TreeCursor) Depth() uint32 {
+	if tc.isNull() {
+		return 0
+	}
 	return uint32(C.ts_tree_cursor_current_depth(&tc._inner))
 }
 
 // Get the index of the cursor's current node out of all of the
 // descendants of the original node that the cursor was constructed with.
 func (tc *TreeCursor) DescendantIndex() uint32 {
+	if tc.isNull() {
+		return 0
+	}
 	return uint32(C.ts_tree_cursor_current_descendant_index(&tc._inner))
 }
 
@@ -57,6 +110,9 @@ func (tc *TreeCursor) DescendantIndex() uint32 {
 // This returns `true` if the cursor successfully moved, and returns
 // `false` if there were no children.
 func (tc *TreeCursor) GotoFirstChild() bool {
+	if tc.isNull() {
+		return false
+	}
 	return bool(C.ts_tree_cursor_goto_first_child(&tc._inner))
 }
 
@@ -69,6 +125,9 @@ func (tc *TreeCursor) GotoFirstChild() bool {
 // [TreeCursor.GotoFirstChild] because it needs to
 // iterate through all the children to compute the child's position.
 func (tc *TreeCursor) GotoLastChild() bool {
+	if tc.isNull() {
+		return false
+	}
 	return bool(C.ts_tree_cursor_goto_last_child(&tc._inner))
 }
 
@@ -81,6 +140,9 @@ func (tc *TreeCursor) GotoLastChild() bool {
 // Note that the given node is considered the root of the cursor,
 // and the cursor cannot walk outside this node.
 func (tc *TreeCursor) GotoParent() bool {
+	if tc.isNull() {
+		return false
+	}
 	return bool(C.ts_tree_cursor_goto_parent(&tc._inner))
 }
 
@@ -92,6 +154,9 @@ func (tc *TreeCursor) GotoParent() bool {
 // Note that the given node is considered the root of the cursor,
 // and the cursor cannot walk outside this node.
 func (tc *TreeCursor) GotoNextSibling() bool {
+	if tc.isNull() {
+		return false
+	}
 	return bool(C.ts_tree_cursor_goto_next_sibling(&tc._inner))
 }
 
@@ -99,6 +164,9 @@ func (tc *TreeCursor) GotoNextSibling() bool {
 // the original node that the cursor was constructed with, where
 // zero represents the original node itself.
 func (tc *TreeCursor) GotoDescendant(descendantIndex uint32) {
+	if tc.isNull() {
+		return
+	}
 	C.ts_tree_cursor_goto_descendant(&tc._inner, C.uint32_t(descendantIndex))
 }
 
@@ -115,6 +183,9 @@ func (tc *TreeCursor) GotoDescendant(descendantIndex uint32) {
 // is considered the root of the cursor, and the cursor cannot
 // walk outside this node.
 func (tc *TreeCursor) GotoPreviousSibling() bool {
+	if tc.isNull() {
+		return false
+	}
 	return bool(C.ts_tree_cursor_goto_previous_sibling(&tc._inner))
 }
 
@@ -124,6 +195,9 @@ func (tc *TreeCursor) GotoPreviousSibling() bool {
 // This returns the index of the child node if one was found, and returns
 // `nil` if no such child was found.
 func (tc *TreeCursor) GotoFirstChildForByte(byteIndex uint32) *uint {
+	if tc.isNull() {
+		return nil
+	}
 	res := C.ts_tree_cursor_goto_first_child_for_byte(&tc._inner, C.uint32_t(byteIndex))
 	if res < 0 {
 		return nil
@@ -133,11 +207,14 @@ func (tc *TreeCursor) GotoFirstChildForByte(byteIndex uint32) *uint {
 }
 
 // Move this cursor to the first child of its current node that extends
-// beyond the given byte offset.
+// beyond the given point.
 //
 // This returns the index of the child node if one was found, and returns
 // `nil` if no such child was found.
 func (tc *TreeCursor) GotoFirstChildForPoint(point Point) *uint {
+	if tc.isNull() {
+		return nil
+	}
 	res := C.ts_tree_cursor_goto_first_child_for_point(&tc._inner, point.toTSPoint())
 	if res < 0 {
 		return nil
@@ -146,8 +223,8 @@ func (tc *TreeCursor) GotoFirstChildForPoint(point Point) *uint {
 	return &index
 }
 
-// Re-initialize this tree cursor to start at the original node that the
-// cursor was constructed with.
+// Re-initialize this tree cursor to start at the given node, which becomes
+// the root that the cursor cannot walk outside of.
 func (tc *TreeCursor) Reset(node Node) {
 	C.ts_tree_cursor_reset(&tc._inner, node._inner)
 }
@@ -158,4 +235,5 @@ func (tc *TreeCursor) Reset(node Node) {
 // information and allows reusing already created cursors.
 func (tc *TreeCursor) ResetTo(cursor *TreeCursor) {
 	C.ts_tree_cursor_reset_to(&tc._inner, &cursor._inner)
+	tc.tree = cursor.tree
 }
@@ -2,7 +2,10 @@ package tree_sitter_test
 
 import (
 	"fmt"
+	"strings"
+	"testing"
 
+	"github.com/stretchr/testify/assert"
 	. "github.com/tree-sitter/go-tree-sitter"
 	tree_sitter_go "github.com/tree-sitter/tree-sitter-go/bindings/go"
 )
@@ -67,3 +70,165 @@ func ExampleTreeCursor() {
 	// false
 	// function_declaration
 }
+
+func largeGoTree(b *testing.B) (*Parser, *Tree) {
+	var source strings.Builder
+	source.WriteString("package main\n\n")
+	for i := range 1000 {
+		fmt.Fprintf(&source, "func f%d(a, b int) int {\n\tif a > b {\n\t\treturn a - b\n\t}\n\treturn f%d(b, a) * 2\n}\n\n", i, i)
+	}
+
+	parser := NewParser()
+	parser.SetLanguage(getLanguage("go"))
+	return parser, parser.Parse([]byte(source.String()), nil)
+}
+
+func BenchmarkTraversalWithTreeCursor(b *testing.B) {
+	parser, tree := largeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+
+	visit := func(cursor *TreeCursor) int {
+		count := 1
+		for {
+			if cursor.GotoFirstChild() {
+				count++
+				continue
+			}
+			for !cursor.GotoNextSibling() {
+				if !cursor.GotoParent() {
+					return count
+				}
+			}
+			count++
+		}
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		cursor := tree.Walk()
+		visit(cursor)
+		cursor.Close()
+	}
+}
+
+func BenchmarkTraversalWithChild(b *testing.B) {
+	parser, tree := largeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+
+	var visit func(node *Node) int
+	visit = func(node *Node) int {
+		count := 1
+		for i := range node.ChildCount() {
+			count += visit(node.Child(i))
+		}
+		return count
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		visit(tree.RootNode())
+	}
+}
+
+func TestTreeCursorBookmark(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := []byte(walkExample)
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	// Move to the next node in pre-order, returning false after the last one.
+	next := func(cursor *TreeCursor) bool {
+		if cursor.GotoFirstChild() {
+			return true
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				return false
+			}
+		}
+		return true
+	}
+
+	var expected []Node
+	cursor := tree.Walk()
+	for ok := true; ok; ok = next(cursor) {
+		expected = append(expected, *cursor.Node())
+	}
+	cursor.Close()
+
+	// Visit the first half of the nodes, then pick up where that left off
+	// with a new cursor.
+	var visited []Node
+	cursor = tree.Walk()
+	for range len(expected) / 2 {
+		visited = append(visited, *cursor.Node())
+		next(cursor)
+	}
+	bookmark := cursor.Bookmark()
+	cursor.Close()
+
+	resumed := tree.Walk()
+	defer resumed.Close()
+	assert.NoError(t, resumed.ResumeAt(bookmark))
+	for ok := true; ok; ok = next(resumed) {
+		visited = append(visited, *resumed.Node())
+	}
+	assert.Equal(t, expected, visited)
+
+	// A cursor below another root cannot use the bookmark, and neither can
+	// one on another tree.
+	subtree := tree.RootNode().NamedChild(1).Walk()
+	defer subtree.Close()
+	assert.ErrorIs(t, subtree.ResumeAt(bookmark), ErrBookmarkOtherRoot)
+	assert.Equal(t, "function_declaration", subtree.Node().Kind())
+	other := parser.Parse(source, nil)
+	defer other.Close()
+	otherCursor := other.Walk()
+	defer otherCursor.Close()
+	assert.ErrorIs(t, otherCursor.ResumeAt(bookmark), ErrBookmarkOtherRoot)
+
+	// Nor can a cursor on the same tree once it has been edited.
+	tree.Edit(&InputEdit{
+		StartByte:      0,
+		OldEndByte:     0,
+		NewEndByte:     1,
+		StartPosition:  Point{0, 0},
+		OldEndPosition: Point{0, 0},
+		NewEndPosition: Point{0, 1},
+	})
+	edited := tree.Walk()
+	defer edited.Close()
+	assert.ErrorIs(t, edited.ResumeAt(bookmark), ErrBookmarkTreeEdited)
+	assert.NoError(t, edited.ResumeAt(edited.Bookmark()))
+
+	// A cursor made from the zero Node has the zero bookmark, which no other
+	// cursor can resume at.
+	null := (&Node{}).Walk()
+	defer null.Close()
+	assert.Equal(t, CursorBookmark{}, null.Bookmark())
+	assert.NoError(t, null.ResumeAt(null.Bookmark()))
+	assert.ErrorIs(t, null.ResumeAt(edited.Bookmark()), ErrBookmarkOtherRoot)
+	assert.ErrorIs(t, edited.ResumeAt(null.Bookmark()), ErrBookmarkOtherRoot)
+}
+
+func TestTreeCursorCloseTwice(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	tree := parser.Parse([]byte(`[1, 2]`), nil)
+	defer tree.Close()
+
+	SetMemoryTracking(true)
+	defer SetMemoryTracking(false)
+	before := ReadMemoryStats().LiveAllocations
+
+	cursor := tree.Walk()
+	assert.True(t, cursor.GotoFirstChild())
+	cursor.Close()
+	assert.NotPanics(t, cursor.Close)
+	assert.Equal(t, before, ReadMemoryStats().LiveAllocations)
+}
@@ -0,0 +1,368 @@
+package tree_sitter
+
+import (
+	"cmp"
+	"encoding/binary"
+	"hash/maphash"
+	"slices"
+)
+
+// The kind of change that a [TreeChange] describes.
+type TreeChangeKind int
+
+const (
+	// A node of the new tree has no counterpart in the old one.
+	TreeChangeInserted TreeChangeKind = iota
+	// A node of the old tree has no counterpart in the new one.
+	TreeChangeDeleted
+	// A node was matched between the trees, but its own text or anonymous
+	// children, such as an operator, have changed. Changes to its named
+	// descendants are reported for the descendants.
+	TreeChangeModified
+)
+
+func (k TreeChangeKind) String() string {
+	switch k {
+	case TreeChangeInserted:
+		return "inserted"
+	case TreeChangeDeleted:
+		return "deleted"
+	case TreeChangeModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// A change to a named node found by [StructuralDiff].
+type TreeChange struct {
+	Kind TreeChangeKind
+
+	// The node in the old tree, or nil for an inserted node.
+	Old *Node
+
+	// The node in the new tree, or nil for a deleted node.
+	New *Node
+
+	// The ranges of `Old` and `New`, or zero when there is no such node.
+	OldRange Range
+	NewRange Range
+}
+
+// Find the named nodes that were inserted, deleted or modified between two
+// versions of a document, parsed into the trees rooted at `oldRoot` and
+// `newRoot` from `oldSource` and `newSource`.
+//
+// Unlike a text diff, nodes are matched by their structure. Starting from
+// the roots, the children of each pair of matched nodes are matched in
+// order, first those whose whole subtrees are unchanged and then, between
+// those, the remaining ones of the same kind, which are compared in turn.
+// Children that are left over are reported as deleted or inserted, along
+// with their descendants, so a moved node may show up as one of each.
+//
+// The changes are returned in the order in which the trees are walked. A
+// null root, such as that of a [Node] zero value, stands for a document
+// without any nodes, so if only one of the roots is null, the other is
+// reported as inserted or deleted.
+func StructuralDiff(oldRoot, newRoot Node, oldSource, newSource []byte) []TreeChange {
+	d := structuralDiff{seed: maphash.MakeSeed()}
+	switch {
+	case oldRoot.IsNull() && newRoot.IsNull():
+		return nil
+	case oldRoot.IsNull():
+		d.insert(d.build(newRoot, newSource))
+		return d.changes
+	case newRoot.IsNull():
+		d.delete(d.build(oldRoot, oldSource))
+		return d.changes
+	}
+	oldTree := d.build(oldRoot, oldSource)
+	newTree := d.build(newRoot, newSource)
+	if oldTree.node.Kind() != newTree.node.Kind() {
+		d.delete(oldTree)
+		d.insert(newTree)
+	} else {
+		d.match(oldTree, newTree)
+	}
+	return d.changes
+}
+
+type structuralDiff struct {
+	seed    maphash.Seed
+	changes []TreeChange
+}
+
+// A named node along with its named children and hashes of its contents.
+type diffNode struct {
+	node     Node
+	children []*diffNode
+
+	// A hash of the node's kind, its anonymous children and, for a leaf,
+	// its text.
+	localHash uint64
+
+	// A hash of the node's whole subtree.
+	hash uint64
+}
+
+func (d *structuralDiff) build(root Node, source []byte) *diffNode {
+	cursor := acquireCursor(root)
+	defer releaseCursor(cursor)
+	return d.buildAt(cursor, source)
+}
+
+// Build the diff node for the node at the cursor, leaving the cursor on it.
+// Anonymous descendants of anonymous children are not looked at, since they
+// only occur in error recovery.
+func (d *structuralDiff) buildAt(cursor *TreeCursor, source []byte) *diffNode {
+	node := *cursor.Node()
+	result := &diffNode{node: node}
+
+	var local, whole maphash.Hash
+	local.SetSeed(d.seed)
+	local.WriteString(node.Kind())
+	if node.ChildCount() == 0 {
+		local.WriteByte(0)
+		local.Write(node.Utf8Bytes(source))
+	}
+	whole.SetSeed(d.seed)
+
+	if cursor.GotoFirstChild() {
+		for {
+			if child := cursor.Node(); child.IsNamed() {
+				childNode := d.buildAt(cursor, source)
+				result.children = append(result.children, childNode)
+				writeHash(&whole, childNode.hash)
+			} else {
+				local.WriteByte(0)
+				local.WriteString(child.Kind())
+			}
+			if !cursor.GotoNextSibling() {
+				break
+			}
+		}
+		cursor.GotoParent()
+	}
+
+	result.localHash = local.Sum64()
+	writeHash(&whole, result.localHash)
+	result.hash = whole.Sum64()
+	return result
+}
+
+func writeHash(h *maphash.Hash, value uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], value)
+	h.Write(buf[:])
+}
+
+// Compare two nodes of the same kind, which have been matched.
+func (d *structuralDiff) match(old, new *diffNode) {
+	if old.hash == new.hash {
+		return
+	}
+	if old.localHash != new.localHash {
+		d.changes = append(d.changes, TreeChange{
+			Kind:     TreeChangeModified,
+			Old:      &old.node,
+			New:      &new.node,
+			OldRange: old.node.Range(),
+			NewRange: new.node.Range(),
+		})
+	}
+
+	// Match the unchanged children, then pair up the others of the same
+	// kind in the gaps between them.
+	oldIndex, newIndex := 0, 0
+	for _, pair := range matchUnchanged(old.children, new.children) {
+		d.matchGap(old.children[oldIndex:pair[0]], new.children[newIndex:pair[1]])
+		oldIndex, newIndex = pair[0]+1, pair[1]+1
+	}
+	d.matchGap(old.children[oldIndex:], new.children[newIndex:])
+}
+
+// Match the runs of children between two unchanged ones, pairing each old
+// child with the next new child of the same kind.
+func (d *structuralDiff) matchGap(old, new []*diffNode) {
+	for len(old) > 0 || len(new) > 0 {
+		if len(old) == 0 {
+			d.insert(new[0])
+			new = new[1:]
+			continue
+		}
+		index := -1
+		for i, candidate := range new {
+			if candidate.node.Kind() == old[0].node.Kind() {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			d.delete(old[0])
+			old = old[1:]
+			continue
+		}
+		for _, inserted := range new[:index] {
+			d.insert(inserted)
+		}
+		d.match(old[0], new[index])
+		old, new = old[1:], new[index+1:]
+	}
+}
+
+func (d *structuralDiff) insert(node *diffNode) {
+	d.changes = append(d.changes, TreeChange{Kind: TreeChangeInserted, New: &node.node, NewRange: node.node.Range()})
+}
+
+func (d *structuralDiff) delete(node *diffNode) {
+	d.changes = append(d.changes, TreeChange{Kind: TreeChangeDeleted, Old: &node.node, OldRange: node.node.Range()})
+}
+
+// The largest number of pairs of old and new children that are compared
+// with each other to find a longest common subsequence of their hashes.
+// Longer runs of changed children are first split up by the children whose
+// hashes are unique, so that very wide nodes, such as one with thousands of
+// statements, do not take quadratic time and memory.
+const maxDiffCells = 1 << 16
+
+// Find a long sequence of pairs of old and new children with the same
+// subtree hashes, in order. The common prefix and suffix are matched
+// directly, so only the children in between are compared with each other.
+func matchUnchanged(old, new []*diffNode) [][2]int {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix].hash == new[prefix].hash {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix].hash == new[len(new)-1-suffix].hash {
+		suffix++
+	}
+
+	pairs := make([][2]int, 0, prefix+suffix)
+	for i := range prefix {
+		pairs = append(pairs, [2]int{i, i})
+	}
+
+	middleOld, middleNew := old[prefix:len(old)-suffix], new[prefix:len(new)-suffix]
+	var middle [][2]int
+	if len(middleOld)*len(middleNew) <= maxDiffCells {
+		middle = longestCommonHashes(middleOld, middleNew)
+	} else {
+		middle = matchUniqueHashes(middleOld, middleNew)
+	}
+	for _, pair := range middle {
+		pairs = append(pairs, [2]int{prefix + pair[0], prefix + pair[1]})
+	}
+
+	for i := range suffix {
+		pairs = append(pairs, [2]int{len(old) - suffix + i, len(new) - suffix + i})
+	}
+	return pairs
+}
+
+// Find a longest common subsequence of the hashes of the old and new
+// children.
+func longestCommonHashes(old, new []*diffNode) [][2]int {
+	lengths := make([][]int, len(old)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i].hash == new[j].hash {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(old) && j < len(new); {
+		switch {
+		case old[i].hash == new[j].hash:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// Match the children whose hashes occur exactly once among the old children
+// and once among the new ones, keeping a longest sequence of these pairs
+// that are in the same order in both, and then match the children between
+// them with [matchUnchanged]. Children that have no unique hashes between
+// them are left unmatched.
+func matchUniqueHashes(old, new []*diffNode) [][2]int {
+	counts := make(map[uint64][2]int, len(old))
+	newIndices := make(map[uint64]int, len(new))
+	for _, child := range old {
+		count := counts[child.hash]
+		count[0]++
+		counts[child.hash] = count
+	}
+	for j, child := range new {
+		count := counts[child.hash]
+		count[1]++
+		counts[child.hash] = count
+		newIndices[child.hash] = j
+	}
+	var anchors [][2]int
+	for i, child := range old {
+		if count := counts[child.hash]; count[0] == 1 && count[1] == 1 {
+			anchors = append(anchors, [2]int{i, newIndices[child.hash]})
+		}
+	}
+	anchors = longestIncreasingPairs(anchors)
+	if len(anchors) == 0 {
+		return nil
+	}
+
+	var pairs [][2]int
+	oldStart, newStart := 0, 0
+	for _, anchor := range append(anchors, [2]int{len(old), len(new)}) {
+		for _, pair := range matchUnchanged(old[oldStart:anchor[0]], new[newStart:anchor[1]]) {
+			pairs = append(pairs, [2]int{oldStart + pair[0], newStart + pair[1]})
+		}
+		if anchor[0] < len(old) {
+			pairs = append(pairs, anchor)
+		}
+		oldStart, newStart = anchor[0]+1, anchor[1]+1
+	}
+	return pairs
+}
+
+// Find a longest subsequence of pairs, which are in the order of their old
+// indices, whose new indices are in order as well.
+func longestIncreasingPairs(pairs [][2]int) [][2]int {
+	// The last pair of the sequence of each length that ends with the
+	// lowest new index, and the pair before each pair in its sequence.
+	var tails []int
+	previous := make([]int, len(pairs))
+	for i, pair := range pairs {
+		length, _ := slices.BinarySearchFunc(tails, pair[1], func(tail, newIndex int) int {
+			return cmp.Compare(pairs[tail][1], newIndex)
+		})
+		previous[i] = -1
+		if length > 0 {
+			previous[i] = tails[length-1]
+		}
+		if length == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[length] = i
+		}
+	}
+
+	result := make([][2]int, len(tails))
+	if len(tails) > 0 {
+		for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, previous[k] {
+			result[i] = pairs[k]
+		}
+	}
+	return result
+}
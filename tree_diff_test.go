@@ -0,0 +1,159 @@
+package tree_sitter_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+// A change from StructuralDiff with its nodes replaced by their text.
+type textChange struct {
+	Kind     TreeChangeKind
+	NodeKind string
+	Old, New string
+}
+
+func structuralDiffOfGo(t *testing.T, oldSource, newSource string) []textChange {
+	t.Helper()
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	oldTree := parser.Parse([]byte(oldSource), nil)
+	defer oldTree.Close()
+	newTree := parser.Parse([]byte(newSource), nil)
+	defer newTree.Close()
+
+	var result []textChange
+	for _, change := range StructuralDiff(*oldTree.RootNode(), *newTree.RootNode(), []byte(oldSource), []byte(newSource)) {
+		c := textChange{Kind: change.Kind}
+		if change.Old != nil {
+			c.NodeKind = change.Old.Kind()
+			c.Old = change.Old.Utf8Text([]byte(oldSource))
+			assert.Equal(t, change.Old.Range(), change.OldRange)
+		}
+		if change.New != nil {
+			c.NodeKind = change.New.Kind()
+			c.New = change.New.Utf8Text([]byte(newSource))
+			assert.Equal(t, change.New.Range(), change.NewRange)
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func TestStructuralDiffOfRenamedFunction(t *testing.T) {
+	changes := structuralDiffOfGo(t,
+		"package main\n\nfunc Foo() int { return 1 }\n\nfunc Bar() {}\n",
+		"package main\n\nfunc Baz() int { return 1 }\n\nfunc Bar() {}\n",
+	)
+	assert.Equal(t, []textChange{
+		{Kind: TreeChangeModified, NodeKind: "identifier", Old: "Foo", New: "Baz"},
+	}, changes)
+}
+
+func TestStructuralDiffOfAddedStatement(t *testing.T) {
+	changes := structuralDiffOfGo(t,
+		"package main\n\nfunc main() {\n\ta()\n\tc()\n}\n",
+		"package main\n\nfunc main() {\n\ta()\n\tb()\n\tc()\n}\n",
+	)
+	assert.Equal(t, []textChange{
+		{Kind: TreeChangeInserted, NodeKind: "expression_statement", New: "b()"},
+	}, changes)
+
+	changes = structuralDiffOfGo(t,
+		"package main\n\nfunc main() {\n\tx := 1 + 2\n}\n",
+		"package main\n\nfunc main() {\n\tx := 1 - 2\n}\n",
+	)
+	assert.Equal(t, []textChange{
+		{Kind: TreeChangeModified, NodeKind: "binary_expression", Old: "1 + 2", New: "1 - 2"},
+	}, changes)
+}
+
+func TestStructuralDiffOfMovedFunction(t *testing.T) {
+	a := "func A() { a() }"
+	b := "func B() { b() }"
+	changes := structuralDiffOfGo(t,
+		"package main\n\n"+a+"\n\n"+b+"\n",
+		"package main\n\n"+b+"\n\n"+a+"\n",
+	)
+	// One of the functions is matched, and the other moved around it.
+	if assert.Len(t, changes, 2) {
+		assert.ElementsMatch(t, []TreeChangeKind{TreeChangeDeleted, TreeChangeInserted}, []TreeChangeKind{changes[0].Kind, changes[1].Kind})
+		moved := changes[0].Old + changes[0].New
+		assert.Contains(t, []string{a, b}, moved)
+		assert.Equal(t, moved, changes[1].Old+changes[1].New)
+	}
+
+	assert.Empty(t, structuralDiffOfGo(t, "package main\n\n"+a+"\n", "package main\n\n\n"+a+"\n"))
+}
+
+func TestStructuralDiffOfManySiblings(t *testing.T) {
+	// Too many functions change places for them all to be compared with each
+	// other, so they are matched by the ones that are unique.
+	functions := make([]string, 1000)
+	for i := range functions {
+		functions[i] = fmt.Sprintf("func f%d() { f(%d) }", i, i)
+	}
+	first, last := functions[0], functions[len(functions)-1]
+	moved := slices.Clone(functions)
+	moved[0], moved[len(moved)-1] = last, first
+	changes := structuralDiffOfGo(t,
+		"package main\n\n"+strings.Join(functions, "\n\n")+"\n",
+		"package main\n\n"+strings.Join(moved, "\n\n")+"\n",
+	)
+	swapped := []textChange{
+		{Kind: TreeChangeModified, NodeKind: "identifier", Old: "f0", New: "f999"},
+		{Kind: TreeChangeModified, NodeKind: "int_literal", Old: "0", New: "999"},
+		{Kind: TreeChangeModified, NodeKind: "identifier", Old: "f999", New: "f0"},
+		{Kind: TreeChangeModified, NodeKind: "int_literal", Old: "999", New: "0"},
+	}
+	assert.Equal(t, swapped, changes)
+
+	// Functions between the unique ones are still matched with each other.
+	repeated := slices.Clone(functions)
+	for i := 100; i < 200; i++ {
+		repeated[i] = "func g() {}"
+	}
+	edited := slices.Clone(repeated)
+	edited[0], edited[len(edited)-1] = last, first
+	edited[150] = "func h() {}"
+	changes = structuralDiffOfGo(t,
+		"package main\n\n"+strings.Join(repeated, "\n\n")+"\n",
+		"package main\n\n"+strings.Join(edited, "\n\n")+"\n",
+	)
+	assert.Equal(t, []textChange{
+		swapped[0], swapped[1],
+		{Kind: TreeChangeModified, NodeKind: "identifier", Old: "g", New: "h"},
+		swapped[2], swapped[3],
+	}, changes)
+}
+
+func TestStructuralDiffOfZeroNode(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := []byte("package main\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	root := *tree.RootNode()
+
+	assert.Empty(t, StructuralDiff(Node{}, Node{}, nil, nil))
+
+	changes := StructuralDiff(Node{}, root, nil, source)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, TreeChangeInserted, changes[0].Kind)
+	assert.Nil(t, changes[0].Old)
+	assert.Equal(t, root, *changes[0].New)
+	assert.Equal(t, root.Range(), changes[0].NewRange)
+
+	changes = StructuralDiff(root, Node{}, source, nil)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, TreeChangeDeleted, changes[0].Kind)
+	assert.Equal(t, root, *changes[0].Old)
+	assert.Nil(t, changes[0].New)
+	assert.Equal(t, root.Range(), changes[0].OldRange)
+}
@@ -0,0 +1,145 @@
+package tree_sitter
+
+// An option that changes how [TreesEqual] and [DiffTrees] compare trees.
+type EqualOption func(*equalOptions)
+
+type equalOptions struct {
+	ignoreAnonymous bool
+	ignoreExtras    bool
+	compareText     bool
+	sourceA         []byte
+	sourceB         []byte
+}
+
+// Skip anonymous nodes, such as punctuation and keywords, on both sides.
+func IgnoreAnonymousNodes() EqualOption {
+	return func(o *equalOptions) {
+		o.ignoreAnonymous = true
+	}
+}
+
+// Skip extra nodes, such as comments, on both sides.
+func IgnoreExtras() EqualOption {
+	return func(o *equalOptions) {
+		o.ignoreExtras = true
+	}
+}
+
+// Compare the text of leaf nodes as well as the shape of the trees, reading
+// the text of the first tree from `sourceA` and that of the second from
+// `sourceB`.
+func CompareText(sourceA, sourceB []byte) EqualOption {
+	return func(o *equalOptions) {
+		o.compareText = true
+		o.sourceA = sourceA
+		o.sourceB = sourceB
+	}
+}
+
+// Check whether the trees rooted at `a` and `b` have the same structure:
+// the same kinds of nodes, with the same field names, in the same order.
+// Positions are ignored, so trees parsed from documents that only differ in
+// whitespace are equal.
+func TreesEqual(a, b Node, opts ...EqualOption) bool {
+	_, equal := DiffTrees(a, b, opts...)
+	return equal
+}
+
+// Compare the trees rooted at `a` and `b` like [TreesEqual], returning the
+// path from `a` to the first node that differs if they are not equal.
+//
+// The path leads to a node whose kind, field name or text differs from the
+// corresponding node in `b`, or to a node whose children differ in number.
+// The indices and kinds in the path are those of the nodes in `a`. An empty
+// path means that the root nodes themselves differ. The zero [Node] is only
+// equal to itself.
+func DiffTrees(a, b Node, opts ...EqualOption) (path []PathStep, equal bool) {
+	var o equalOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if a.IsNull() || b.IsNull() {
+		if a.IsNull() && b.IsNull() {
+			return nil, true
+		}
+		return []PathStep{}, false
+	}
+
+	cursorA := acquireCursor(a)
+	defer releaseCursor(cursorA)
+	cursorB := acquireCursor(b)
+	defer releaseCursor(cursorB)
+
+	path, equal = o.diff(cursorA, cursorB, []PathStep{})
+	if equal {
+		return nil, true
+	}
+	return path, false
+}
+
+// Compare the nodes at the two cursors and their descendants, which are
+// reached by `path`. When the nodes are equal, the cursors are left where
+// they started.
+func (o *equalOptions) diff(a, b *TreeCursor, path []PathStep) ([]PathStep, bool) {
+	if !o.nodesEqual(a.Node(), b.Node()) {
+		return path, false
+	}
+
+	hasA, indexA := o.gotoFirstChild(a)
+	hasB, _ := o.gotoFirstChild(b)
+	for hasA && hasB {
+		step := PathStep{Index: indexA, FieldName: a.FieldName(), Kind: a.Node().Kind()}
+		if step.FieldName != b.FieldName() {
+			return append(path, step), false
+		}
+		if childPath, equal := o.diff(a, b, append(path, step)); !equal {
+			return childPath, false
+		}
+		hasA, indexA = o.gotoNextSibling(a, indexA)
+		hasB, _ = o.gotoNextSibling(b, 0)
+	}
+	if hasA || hasB {
+		return path, false
+	}
+	return nil, true
+}
+
+func (o *equalOptions) nodesEqual(a, b *Node) bool {
+	if a.Kind() != b.Kind() || a.IsNamed() != b.IsNamed() {
+		return false
+	}
+	if o.compareText && a.ChildCount() == 0 && b.ChildCount() == 0 {
+		return a.Utf8Text(o.sourceA) == b.Utf8Text(o.sourceB)
+	}
+	return true
+}
+
+func (o *equalOptions) skipped(node *Node) bool {
+	return (o.ignoreAnonymous && !node.IsNamed()) || (o.ignoreExtras && node.IsExtra())
+}
+
+// Move the cursor to the first child of its node that is not skipped,
+// returning the child's index. If there is none, the cursor does not move.
+func (o *equalOptions) gotoFirstChild(cursor *TreeCursor) (bool, int) {
+	if !cursor.GotoFirstChild() {
+		return false, 0
+	}
+	if !o.skipped(cursor.Node()) {
+		return true, 0
+	}
+	return o.gotoNextSibling(cursor, 0)
+}
+
+// Move the cursor from the child at `index` to the next child that is not
+// skipped, returning its index. If there is none, the cursor moves back to
+// the parent node.
+func (o *equalOptions) gotoNextSibling(cursor *TreeCursor, index int) (bool, int) {
+	for cursor.GotoNextSibling() {
+		index++
+		if !o.skipped(cursor.Node()) {
+			return true, index
+		}
+	}
+	cursor.GotoParent()
+	return false, 0
+}
@@ -0,0 +1,74 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestTreesEqual(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	sourceA := []byte(`{"a": [1, 2], "b": "x y"}`)
+	sourceB := []byte("{\n  \"a\" : [ 1,2 ],\n  \"b\" : \"x  y\"\n}\n")
+	treeA := parser.Parse(sourceA, nil)
+	defer treeA.Close()
+	treeB := parser.Parse(sourceB, nil)
+	defer treeB.Close()
+
+	// The shapes are the same, but the text of the second string differs.
+	assert.True(t, TreesEqual(*treeA.RootNode(), *treeB.RootNode()))
+	assert.False(t, TreesEqual(*treeA.RootNode(), *treeB.RootNode(), CompareText(sourceA, sourceB)))
+
+	path, equal := DiffTrees(*treeA.RootNode(), *treeB.RootNode(), CompareText(sourceA, sourceB))
+	assert.False(t, equal)
+	assert.Equal(t, []PathStep{
+		{Index: 0, Kind: "object"},
+		{Index: 3, Kind: "pair"},
+		{Index: 2, FieldName: "value", Kind: "string"},
+		{Index: 1, Kind: "string_content"},
+	}, path)
+
+	node := treeA.RootNode()
+	for _, step := range path {
+		node = node.Child(uint(step.Index))
+	}
+	assert.Equal(t, "string_content", node.Kind())
+	assert.Equal(t, "x y", node.Utf8Text(sourceA))
+
+	path, equal = DiffTrees(*treeA.RootNode(), *treeB.RootNode())
+	assert.True(t, equal)
+	assert.Nil(t, path)
+}
+
+func TestTreesEqualWithSkippedNodes(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("javascript"))
+
+	treeA := parser.Parse([]byte("f(a, b);"), nil)
+	defer treeA.Close()
+	treeB := parser.Parse([]byte("f(a, /* second */ b)"), nil)
+	defer treeB.Close()
+
+	// The comment is found in place of the second argument.
+	path, equal := DiffTrees(*treeA.RootNode(), *treeB.RootNode())
+	assert.False(t, equal)
+	assert.Equal(t, []PathStep{
+		{Index: 0, Kind: "expression_statement"},
+		{Index: 0, Kind: "call_expression"},
+		{Index: 1, FieldName: "arguments", Kind: "arguments"},
+		{Index: 3, Kind: "identifier"},
+	}, path)
+
+	// Ignoring extras skips the comment, but the semicolon still differs.
+	path, equal = DiffTrees(*treeA.RootNode(), *treeB.RootNode(), IgnoreExtras())
+	assert.False(t, equal)
+	assert.Equal(t, []PathStep{{Index: 0, Kind: "expression_statement"}}, path)
+
+	assert.True(t, TreesEqual(*treeA.RootNode(), *treeB.RootNode(), IgnoreExtras(), IgnoreAnonymousNodes()))
+	assert.False(t, TreesEqual(*treeA.RootNode(), *treeB.RootNode(), IgnoreAnonymousNodes()))
+}
@@ -0,0 +1,126 @@
+package tree_sitter
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Options for [WriteTreeJSON].
+type TreeJSONOptions struct {
+	// The number of levels of descendants to include below the node, or
+	// zero to include all of them. Nodes at the limit are written without
+	// their `children`.
+	MaxDepth int
+
+	// Leave out anonymous nodes, such as punctuation and keywords.
+	SkipAnonymous bool
+
+	// The source code that the tree was parsed from. If it is given, leaf
+	// nodes include their text as `text`.
+	Source []byte
+}
+
+// Write the subtree rooted at `node` to `w` as JSON.
+//
+// Every node is written as an object of this form, where `field` is left
+// out for nodes without a field name and `text` for nodes without text:
+//
+//	{
+//	  "kind": "identifier",
+//	  "named": true,
+//	  "start": {"byte": 3, "row": 0, "column": 3},
+//	  "end": {"byte": 6, "row": 0, "column": 6},
+//	  "field": "name",
+//	  "text": "add",
+//	  "children": []
+//	}
+//
+// The JSON is written as the tree is walked, so large trees are never held
+// in memory as a whole. `options` may be nil. Nothing is written for the
+// zero [Node].
+func WriteTreeJSON(w io.Writer, node Node, options *TreeJSONOptions) error {
+	if node.IsNull() {
+		return nil
+	}
+	if options == nil {
+		options = &TreeJSONOptions{}
+	}
+	cursor := acquireCursor(node)
+	defer releaseCursor(cursor)
+
+	bw := bufio.NewWriter(w)
+	tw := treeJSONWriter{w: bw, options: options}
+	tw.writeNode(cursor, 0)
+	if _, err := bw.WriteString("\n"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+type treeJSONWriter struct {
+	w       *bufio.Writer
+	options *TreeJSONOptions
+}
+
+// Write the node at the cursor and its descendants, leaving the cursor on
+// the node. Write errors are kept by the buffered writer and reported when
+// it is flushed.
+func (tw *treeJSONWriter) writeNode(cursor *TreeCursor, depth int) {
+	node := cursor.Node()
+	tw.w.WriteString(`{"kind":`)
+	tw.writeString(node.Kind())
+	tw.w.WriteString(`,"named":`)
+	tw.w.WriteString(strconv.FormatBool(node.IsNamed()))
+	tw.w.WriteString(`,"start":`)
+	tw.writePosition(node.StartByte(), node.StartPosition())
+	tw.w.WriteString(`,"end":`)
+	tw.writePosition(node.EndByte(), node.EndPosition())
+	if field := cursor.FieldName(); field != "" {
+		tw.w.WriteString(`,"field":`)
+		tw.writeString(field)
+	}
+	if tw.options.Source != nil && node.ChildCount() == 0 {
+		tw.w.WriteString(`,"text":`)
+		tw.writeString(node.Utf8Text(tw.options.Source))
+	}
+
+	if tw.options.MaxDepth == 0 || depth < tw.options.MaxDepth {
+		tw.w.WriteString(`,"children":[`)
+		if cursor.GotoFirstChild() {
+			first := true
+			for {
+				if !tw.options.SkipAnonymous || cursor.Node().IsNamed() {
+					if !first {
+						tw.w.WriteByte(',')
+					}
+					tw.writeNode(cursor, depth+1)
+					first = false
+				}
+				if !cursor.GotoNextSibling() {
+					break
+				}
+			}
+			cursor.GotoParent()
+		}
+		tw.w.WriteByte(']')
+	}
+	tw.w.WriteByte('}')
+}
+
+func (tw *treeJSONWriter) writePosition(offset uint, point Point) {
+	tw.w.WriteString(`{"byte":`)
+	tw.w.WriteString(strconv.FormatUint(uint64(offset), 10))
+	tw.w.WriteString(`,"row":`)
+	tw.w.WriteString(strconv.FormatUint(uint64(point.Row), 10))
+	tw.w.WriteString(`,"column":`)
+	tw.w.WriteString(strconv.FormatUint(uint64(point.Column), 10))
+	tw.w.WriteByte('}')
+}
+
+func (tw *treeJSONWriter) writeString(s string) {
+	// Marshalling a string cannot fail.
+	encoded, _ := json.Marshal(s)
+	tw.w.Write(encoded)
+}
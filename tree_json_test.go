@@ -0,0 +1,90 @@
+package tree_sitter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+type jsonPosition struct {
+	Byte   uint `json:"byte"`
+	Row    uint `json:"row"`
+	Column uint `json:"column"`
+}
+
+type jsonNode struct {
+	Kind     string       `json:"kind"`
+	Named    bool         `json:"named"`
+	Start    jsonPosition `json:"start"`
+	End      jsonPosition `json:"end"`
+	Field    string       `json:"field,omitempty"`
+	Text     *string      `json:"text,omitempty"`
+	Children *[]*jsonNode `json:"children,omitempty"`
+}
+
+func TestWriteTreeJSON(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+	source := []byte("fn add(a: i32) -> i32 {\n    a + 1\n}\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	var output bytes.Buffer
+	assert.NoError(t, WriteTreeJSON(&output, *tree.RootNode(), &TreeJSONOptions{Source: source}))
+
+	golden, err := os.ReadFile("testdata/tree.json")
+	assert.NoError(t, err)
+	var compactGolden bytes.Buffer
+	assert.NoError(t, json.Compact(&compactGolden, golden))
+	assert.Equal(t, compactGolden.String()+"\n", output.String())
+
+	// The output decodes into the documented structure and encodes back to
+	// the same JSON.
+	var root jsonNode
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &root))
+	assert.Equal(t, "source_file", root.Kind)
+	function := (*root.Children)[0]
+	name := (*function.Children)[1]
+	assert.Equal(t, "identifier", name.Kind)
+	assert.Equal(t, "name", name.Field)
+	assert.Equal(t, "add", *name.Text)
+	assert.Equal(t, jsonPosition{Byte: 3, Row: 0, Column: 3}, name.Start)
+	assert.Equal(t, []*jsonNode{}, *name.Children)
+	encoded, err := json.Marshal(root)
+	assert.NoError(t, err)
+	assert.Equal(t, compactGolden.String(), string(encoded))
+}
+
+func TestWriteTreeJSONWithOptions(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	tree := parser.Parse([]byte(`[1, [2]]`), nil)
+	defer tree.Close()
+
+	var output bytes.Buffer
+	assert.NoError(t, WriteTreeJSON(&output, *tree.RootNode(), &TreeJSONOptions{MaxDepth: 2, SkipAnonymous: true}))
+	var root jsonNode
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &root))
+
+	array := (*root.Children)[0]
+	assert.Len(t, *array.Children, 2)
+	assert.Equal(t, "number", (*array.Children)[0].Kind)
+	assert.Nil(t, (*array.Children)[0].Text)
+	assert.Equal(t, "array", (*array.Children)[1].Kind)
+	assert.Nil(t, (*array.Children)[1].Children)
+
+	assert.Error(t, WriteTreeJSON(failingJSONWriter{}, *tree.RootNode(), nil))
+}
+
+type failingJSONWriter struct{}
+
+func (failingJSONWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
@@ -3,6 +3,7 @@ package tree_sitter_test
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -710,6 +711,36 @@ func TestGetChangedRanges(t *testing.T) {
 	}
 }
 
+func TestGetChangedRangesConfinedToEditedFunction(t *testing.T) {
+	sourceCode := []byte("fn a() {\n    1\n}\n\nfn b() {\n    2\n}\n\nfn c() {\n    3\n}\n")
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+	tree := parser.Parse(sourceCode, nil)
+	defer tree.Close()
+
+	functionB := tree.RootNode().NamedChild(1)
+	assert.Equal(t, "function_item", functionB.Kind())
+	startByte, endByte := functionB.StartByte(), functionB.EndByte()
+
+	// Replace the `2` in the body of `b` with a call.
+	ranges := getChangedRanges(parser, tree, &sourceCode, &testEdit{
+		position:      indexOf(sourceCode, "2"),
+		deletedLength: 1,
+		insertedText:  []byte("f(2)"),
+	})
+
+	assert.NotEmpty(t, ranges)
+	for _, r := range ranges {
+		assert.GreaterOrEqual(t, r.StartByte, startByte)
+		assert.LessOrEqual(t, r.EndByte, endByte+3)
+		assert.Equal(t, uint(5), r.StartPoint.Row)
+		assert.Equal(t, uint(5), r.EndPoint.Row)
+	}
+	assert.Equal(t, "f(2)", string(sourceCode[ranges[0].StartByte:ranges[len(ranges)-1].EndByte]))
+}
+
 func TestConsistencyWithMidCodepointEdit(t *testing.T) {
 	parser := NewParser()
 	defer parser.Close()
@@ -752,7 +783,272 @@ func getChangedRanges(parser *Parser, tree *Tree, sourceCode *[]byte, edit *test
 	performEdit(tree, sourceCode, edit)
 	newTree := parser.Parse(*sourceCode, tree)
 	result := tree.ChangedRanges(newTree)
-	tree.Close()
-	*tree = *newTree
+	// Swap the trees rather than copying the new one over the old, so that
+	// each C tree is still owned by a single wrapper and is not freed twice
+	// by automatic cleanup.
+	*tree, *newTree = *newTree, *tree
+	newTree.Close()
 	return result
 }
+
+func TestTreeCloneQueriedConcurrently(t *testing.T) {
+	source := []byte(strings.Repeat("fn f() { let x = [1, 2, 3]; }\n", 100))
+
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+	tree := parser.Parse(source, nil)
+	clone := tree.Clone()
+
+	query, err := NewQuery(getLanguage("rust"), "(integer_literal) @number")
+	assert.Nil(t, err)
+	defer query.Close()
+
+	countNumbers := func(tree *Tree, count *int) {
+		cursor := NewQueryCursor()
+		defer cursor.Close()
+		captures := cursor.Captures(query, tree.RootNode(), source)
+		for match, _ := captures.Next(); match != nil; match, _ = captures.Next() {
+			*count++
+		}
+	}
+
+	var treeCount, cloneCount int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		countNumbers(tree, &treeCount)
+	}()
+	go func() {
+		defer wg.Done()
+		countNumbers(clone, &cloneCount)
+	}()
+	wg.Wait()
+
+	assert.Equal(t, 300, treeCount)
+	assert.Equal(t, 300, cloneCount)
+
+	// Closing the original first leaves the copy usable.
+	tree.Close()
+	assert.Equal(t, "source_file", clone.RootNode().Kind())
+	clone.Close()
+}
+
+func TestTreePrintDotGraph(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("rust"))
+	tree := parser.Parse([]byte("fn main() { let x = 1; }"), nil)
+	defer tree.Close()
+
+	var graph strings.Builder
+	assert.NoError(t, tree.PrintDotGraph(&graph))
+	assert.True(t, strings.HasPrefix(graph.String(), "digraph tree {"))
+	assert.Contains(t, graph.String(), `label="function_item"`)
+	assert.Contains(t, graph.String(), `label="let_declaration"`)
+	assert.True(t, strings.HasSuffix(graph.String(), "}\n"))
+}
+
+func TestTreeLanguage(t *testing.T) {
+	language := getLanguage("json")
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(`{"a": [1, 2]}`), nil)
+	defer tree.Close()
+
+	treeLanguage := tree.Language()
+	assert.Equal(t, language.Inner, treeLanguage.Inner)
+	assert.Equal(t, language.NodeKindCount(), treeLanguage.NodeKindCount())
+
+	query, err := NewQuery(treeLanguage, "(number) @number")
+	assert.Nil(t, err)
+	defer query.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	matches := cursor.Matches(query, tree.RootNode(), []byte(`{"a": [1, 2]}`))
+	count := 0
+	for match := matches.Next(); match != nil; match = matches.Next() {
+		count++
+	}
+	assert.Equal(t, 2, count)
+}
+
+func TestTreeUseAfterClose(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+
+	tree := parser.Parse([]byte("[]"), nil)
+	tree.Close()
+	tree.Close()
+
+	assert.PanicsWithValue(t, "tree_sitter: use of closed Tree", func() {
+		tree.RootNode()
+	})
+	assert.PanicsWithValue(t, "tree_sitter: use of closed Tree", func() {
+		parser.Parse([]byte("[1]"), tree)
+	})
+}
+
+func TestTreeErrors(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("c"))
+
+	// An unclosed brace.
+	tree := parser.Parse([]byte("int main() {\n  if (1) {\n    return 0;\n}\n"), nil)
+	defer tree.Close()
+	assert.Equal(t, []SyntaxErrorInfo{{
+		ErrorSite: ErrorSite{Kind: "}", Missing: true, Range: Range{
+			StartByte: 39, EndByte: 39,
+			StartPoint: NewPoint(3, 1), EndPoint: NewPoint(3, 1),
+		}},
+		Parent: "compound_statement",
+	}}, tree.Errors())
+
+	// A missing semicolon.
+	tree = parser.Parse([]byte("int main() {\n  int a = 1\n  return a;\n}\n"), nil)
+	defer tree.Close()
+	assert.Equal(t, []SyntaxErrorInfo{{
+		ErrorSite: ErrorSite{Kind: ";", Missing: true, Range: Range{
+			StartByte: 24, EndByte: 24,
+			StartPoint: NewPoint(1, 11), EndPoint: NewPoint(1, 11),
+		}},
+		Parent: "declaration",
+	}}, tree.Errors())
+
+	// Text that cannot be parsed.
+	tree = parser.Parse([]byte("int x = @;"), nil)
+	defer tree.Close()
+	assert.Equal(t, []SyntaxErrorInfo{{
+		ErrorSite: ErrorSite{Kind: "ERROR", Range: Range{
+			StartByte: 0, EndByte: 9,
+			StartPoint: NewPoint(0, 0), EndPoint: NewPoint(0, 9),
+		}},
+		Parent: "translation_unit",
+	}}, tree.Errors())
+
+	// A clean file.
+	tree = parser.Parse([]byte("int main() { return 0; }"), nil)
+	defer tree.Close()
+	assert.Empty(t, tree.Errors())
+	assert.NotNil(t, tree.Errors())
+}
+
+func TestNodeErrorDetail(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+
+	findError := func(tree *Tree) *Node {
+		for node := range Walk(*tree.RootNode()) {
+			if node.IsError() || node.IsMissing() {
+				return &node
+			}
+		}
+		return nil
+	}
+
+	// An unclosed argument list.
+	source := []byte("package main\n\nfunc main() {\n\tf(1, 2\n}\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+	missing := findError(tree)
+	assert.Equal(t, ")", missing.MissingKind())
+	detail := missing.ErrorDetail(source)
+	assert.True(t, detail.Missing)
+	assert.Equal(t, missing.Range(), detail.Range)
+	assert.Equal(t, "2", detail.PrecedingText)
+	assert.Equal(t, ")", detail.Expected[0])
+	assert.Contains(t, detail.Expected, ",")
+	assert.Contains(t, detail.Expected, "+")
+
+	// An unclosed parameter list expects another parameter or a `)`.
+	source = []byte("package main\n\nfunc main( {\n}\n")
+	tree = parser.Parse(source, nil)
+	defer tree.Close()
+	detail = findError(tree).ErrorDetail(source)
+	assert.Equal(t, "(", detail.PrecedingText)
+	assert.Equal(t, ")", detail.Expected[0])
+	assert.Contains(t, detail.Expected, "identifier")
+	assert.Contains(t, detail.Expected, "...")
+
+	// Text that cannot be parsed, after the package clause, which should
+	// have been followed by the end of the line.
+	source = []byte("package main\n\nvar x = [3]int{1, 2\n")
+	tree = parser.Parse(source, nil)
+	defer tree.Close()
+	errorNode := findError(tree)
+	assert.Empty(t, errorNode.MissingKind())
+	literal := errorNode.NamedChild(errorNode.NamedChildCount() - 1).NamedChild(0)
+	assert.Equal(t, errorNode, literal.NearestError())
+	detail = literal.ErrorDetail(source)
+	assert.False(t, detail.Missing)
+	assert.Equal(t, "main", detail.PrecedingText)
+	assert.Contains(t, detail.Expected, ";")
+
+	// Nodes outside of errors have no error detail.
+	assert.Nil(t, tree.RootNode().NamedChild(0).NearestError())
+	assert.Nil(t, tree.RootNode().NamedChild(0).ErrorDetail(source))
+	assert.Empty(t, tree.RootNode().MissingKind())
+}
+
+func TestTreeCursorDescendToByte(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	source := []byte("package main\n\nfunc add(a int, b int) int {\n\treturn a + b\n}\n")
+	tree := parser.Parse(source, nil)
+	defer tree.Close()
+
+	cursor := tree.Walk()
+	defer cursor.Close()
+	offset := uint32(indexOf(source, "a + b"))
+	var steps []string
+	for cursor.GotoFirstChildForByte(offset) != nil {
+		steps = append(steps, cursor.FieldName()+":"+cursor.Node().Kind())
+	}
+	assert.Equal(t, []string{
+		":function_declaration",
+		"body:block",
+		":return_statement",
+		":expression_list",
+		":binary_expression",
+		"left:identifier",
+	}, steps)
+	assert.EqualValues(t, 6, cursor.Depth())
+	assert.Equal(t, "a", cursor.Node().Utf8Text(source))
+
+	// A copy moves independently of the cursor it was copied from.
+	copied := cursor.Copy()
+	defer copied.Close()
+	assert.True(t, cursor.GotoNextSibling())
+	assert.Equal(t, "+", cursor.Node().Kind())
+	assert.True(t, copied.GotoParent())
+	assert.True(t, copied.GotoLastChild())
+	assert.Equal(t, "right", copied.FieldName())
+	assert.Equal(t, "b", copied.Node().Utf8Text(source))
+	assert.True(t, cursor.GotoPreviousSibling())
+	assert.Equal(t, "left", cursor.FieldName())
+	assert.Equal(t, "a", cursor.Node().Utf8Text(source))
+
+	// The descendant index leads a fresh cursor to the same node.
+	fresh := tree.Walk()
+	defer fresh.Close()
+	fresh.GotoDescendant(copied.DescendantIndex())
+	assert.True(t, fresh.Node().Equals(*copied.Node()))
+	assert.Equal(t, copied.Depth(), fresh.Depth())
+
+	// Resetting to another cursor keeps its ancestors, while resetting to a
+	// node makes that node the root.
+	fresh.ResetTo(cursor)
+	assert.Equal(t, "a", fresh.Node().Utf8Text(source))
+	assert.True(t, fresh.GotoParent())
+	assert.Equal(t, "binary_expression", fresh.Node().Kind())
+	fresh.Reset(*copied.Node())
+	assert.EqualValues(t, 0, fresh.Depth())
+	assert.False(t, fresh.GotoParent())
+	assert.Nil(t, fresh.GotoFirstChildForByte(offset))
+}
@@ -0,0 +1,38 @@
+package tstest
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Fail the test if it leaves any [tree_sitter.Parser], [tree_sitter.Tree],
+// [tree_sitter.Query], [tree_sitter.QueryCursor] or [tree_sitter.TreeCursor]
+// unclosed.
+//
+// This enables leak tracking with [tree_sitter.EnableLeakTracking], which
+// stays enabled afterwards, and compares the live objects when the test
+// finishes with those that were live when it started. Objects created by
+// other tests that run in parallel are counted too, so it should only be
+// used in tests that do not call [testing.T.Parallel].
+func CheckNoLeaks(t testing.TB) {
+	t.Helper()
+	tree_sitter.EnableLeakTracking()
+	before := tree_sitter.LiveObjects()
+	t.Cleanup(func() {
+		after := tree_sitter.LiveObjects()
+		var leaks []string
+		for _, kind := range slices.Sorted(maps.Keys(after)) {
+			if leaked := after[kind] - before[kind]; leaked > 0 {
+				leaks = append(leaks, fmt.Sprintf("%d %s", leaked, kind))
+			}
+		}
+		if len(leaks) > 0 {
+			t.Errorf("tstest: objects were not closed: %s", strings.Join(leaks, ", "))
+		}
+	})
+}
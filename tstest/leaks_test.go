@@ -0,0 +1,34 @@
+package tstest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/go-tree-sitter/tstest"
+)
+
+func TestCheckNoLeaks(t *testing.T) {
+	defer tree_sitter.DisableLeakTracking()
+
+	recorder := &recordingTB{TB: t}
+	tstest.CheckNoLeaks(recorder)
+	tree := parseJSON(t, `[1, 2]`)
+	cursor := tree.Walk()
+	cursor.Close()
+	tree.Close()
+	recorder.finish()
+	assert.Empty(t, recorder.failures)
+
+	recorder = &recordingTB{TB: t}
+	tstest.CheckNoLeaks(recorder)
+	tree = parseJSON(t, `[1, 2]`)
+	cursor = tree.Walk()
+	query, err := tree_sitter.NewQuery(tree.Language(), "(number) @number")
+	assert.Nil(t, err)
+	recorder.finish()
+	assert.Equal(t, []string{"tstest: objects were not closed: 1 Query, 1 Tree, 1 TreeCursor"}, recorder.failures)
+
+	cursor.Close()
+	query.Close()
+}
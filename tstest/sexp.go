@@ -0,0 +1,174 @@
+package tstest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A node of an s-expression in the format produced by
+// [tree_sitter.Node.ToSexp], such as `(pair key: (string) value: (number))`.
+type SExp struct {
+	// The field name that the node was given in its parent, without the
+	// trailing colon, or an empty string.
+	Field string
+
+	// The node's label: its kind, and any further words that follow it, as
+	// in `MISSING ";"`.
+	Kind string
+
+	Children []*SExp
+}
+
+// Parse an s-expression. Any amount of whitespace may separate its parts.
+func ParseSExp(source string) (*SExp, error) {
+	p := sexpParser{source: source}
+	p.skipSpace()
+	node, err := p.parseNode("")
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.offset < len(p.source) {
+		return nil, p.errorf("unexpected %q after the end of the s-expression", p.source[p.offset:])
+	}
+	return node, nil
+}
+
+type sexpParser struct {
+	source string
+	offset int
+}
+
+func (p *sexpParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid s-expression at offset %d: %s", p.offset, fmt.Sprintf(format, args...))
+}
+
+func (p *sexpParser) skipSpace() {
+	for p.offset < len(p.source) && strings.IndexByte(" \t\r\n", p.source[p.offset]) >= 0 {
+		p.offset++
+	}
+}
+
+func (p *sexpParser) parseNode(field string) (*SExp, error) {
+	if p.offset >= len(p.source) || p.source[p.offset] != '(' {
+		return nil, p.errorf("expected '('")
+	}
+	p.offset++
+
+	node := &SExp{Field: field}
+	var label []string
+	for {
+		p.skipSpace()
+		if p.offset >= len(p.source) {
+			return nil, p.errorf("unterminated node")
+		}
+		switch c := p.source[p.offset]; c {
+		case ')':
+			p.offset++
+			if len(label) == 0 {
+				return nil, p.errorf("node without a kind")
+			}
+			node.Kind = strings.Join(label, " ")
+			return node, nil
+		case '(':
+			child, err := p.parseNode("")
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		case '"', '\'':
+			word, err := p.parseQuoted(c)
+			if err != nil {
+				return nil, err
+			}
+			label = append(label, word)
+		default:
+			word := p.parseWord()
+			if !strings.HasSuffix(word, ":") {
+				if len(node.Children) > 0 {
+					return nil, p.errorf("unexpected %q after a child node", word)
+				}
+				label = append(label, word)
+				continue
+			}
+			p.skipSpace()
+			child, err := p.parseNode(strings.TrimSuffix(word, ":"))
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+	}
+}
+
+func (p *sexpParser) parseWord() string {
+	start := p.offset
+	for p.offset < len(p.source) && strings.IndexByte(" \t\r\n()\"'", p.source[p.offset]) < 0 {
+		p.offset++
+	}
+	return p.source[start:p.offset]
+}
+
+// Parse a quoted word, keeping its quotes so that it prints as it was
+// written.
+func (p *sexpParser) parseQuoted(quote byte) (string, error) {
+	start := p.offset
+	p.offset++
+	for p.offset < len(p.source) {
+		switch p.source[p.offset] {
+		case '\\':
+			p.offset += 2
+		case quote:
+			p.offset++
+			return p.source[start:p.offset], nil
+		default:
+			p.offset++
+		}
+	}
+	p.offset = start
+	return "", p.errorf("unterminated quoted string")
+}
+
+// Print the s-expression on one line, in the same format as
+// [tree_sitter.Node.ToSexp].
+func (s *SExp) String() string {
+	var sb strings.Builder
+	s.write(&sb, -1, 0)
+	return sb.String()
+}
+
+// Print the s-expression with every child on its own line, indented by two
+// spaces for each level of nesting.
+func (s *SExp) Indented() string {
+	var sb strings.Builder
+	s.write(&sb, 0, 0)
+	return sb.String()
+}
+
+func (s *SExp) write(sb *strings.Builder, indent, depth int) {
+	if s.Field != "" {
+		sb.WriteString(s.Field)
+		sb.WriteString(": ")
+	}
+	sb.WriteByte('(')
+	sb.WriteString(s.Kind)
+	for _, child := range s.Children {
+		if indent < 0 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteByte('\n')
+			sb.WriteString(strings.Repeat("  ", depth+1))
+		}
+		child.write(sb, indent, depth+1)
+	}
+	sb.WriteByte(')')
+}
+
+// Remove the field names from the s-expression and all of its descendants.
+func (s *SExp) withoutFields() *SExp {
+	result := &SExp{Kind: s.Kind}
+	for _, child := range s.Children {
+		result.Children = append(result.Children, child.withoutFields())
+	}
+	return result
+}
@@ -0,0 +1,185 @@
+// Package tstest provides helpers for testing grammars and code that uses
+// syntax trees.
+package tstest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// An Option changes how [AssertSExp] compares trees.
+type Option func(*options)
+
+type options struct {
+	fields bool
+}
+
+// Compare field names as well as node kinds. By default, field names are
+// ignored on both sides, so that expectations can be written without them.
+func WithFields() Option {
+	return func(o *options) {
+		o.fields = true
+	}
+}
+
+// Assert that the tree rooted at `node` has the structure described by the
+// s-expression `want`, in the format produced by [tree_sitter.Node.ToSexp].
+//
+// The comparison is structural, so `want` may be laid out with any
+// whitespace. On a mismatch, the test fails with a line-by-line diff of both
+// trees and the byte range of the first node that differs.
+func AssertSExp(t testing.TB, node *tree_sitter.Node, want string, opts ...Option) bool {
+	t.Helper()
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	expected, err := ParseSExp(want)
+	if err != nil {
+		t.Errorf("tstest: cannot parse the expected tree: %v", err)
+		return false
+	}
+	actual, err := ParseSExp(node.ToSexp())
+	if err != nil {
+		t.Errorf("tstest: cannot parse the actual tree: %v", err)
+		return false
+	}
+	if !o.fields {
+		expected = expected.withoutFields()
+		actual = actual.withoutFields()
+	}
+
+	path, index, ok := firstMismatch(expected, actual, nil, 0)
+	if ok {
+		return true
+	}
+
+	var message strings.Builder
+	message.WriteString("syntax trees differ (- want, + got):\n")
+	message.WriteString(lineDiff(expected.Indented(), actual.Indented()))
+	fmt.Fprintf(&message, "\nfirst difference at %s", strings.Join(path, " > "))
+	if mismatched := printedNode(node, index); mismatched != nil {
+		fmt.Fprintf(
+			&message,
+			", bytes %d..%d (%s..%s)",
+			mismatched.StartByte(),
+			mismatched.EndByte(),
+			formatPoint(mismatched.StartPosition()),
+			formatPoint(mismatched.EndPosition()),
+		)
+	}
+	t.Error(message.String())
+	return false
+}
+
+// Find the first node in pre-order where the two trees differ. This returns
+// the kinds of the nodes leading to it, and its pre-order index within the
+// actual tree, or `ok` if the trees are equal.
+func firstMismatch(expected, actual *SExp, path []string, index int) ([]string, int, bool) {
+	path = append(path, actual.label())
+	if expected.Kind != actual.Kind || expected.Field != actual.Field {
+		return path, index, false
+	}
+
+	next := index + 1
+	for i := range min(len(expected.Children), len(actual.Children)) {
+		if childPath, childIndex, ok := firstMismatch(expected.Children[i], actual.Children[i], path, next); !ok {
+			return childPath, childIndex, false
+		}
+		next += actual.Children[i].size()
+	}
+	if len(expected.Children) != len(actual.Children) {
+		return path, index, false
+	}
+	return nil, 0, true
+}
+
+func (s *SExp) label() string {
+	if s.Field != "" {
+		return s.Field + ": " + s.Kind
+	}
+	return s.Kind
+}
+
+// The number of nodes in the s-expression, including itself.
+func (s *SExp) size() int {
+	size := 1
+	for _, child := range s.Children {
+		size += child.size()
+	}
+	return size
+}
+
+// Get the node within `root` that [tree_sitter.Node.ToSexp] prints at the
+// given pre-order index, which are its named and missing nodes.
+func printedNode(root *tree_sitter.Node, index int) *tree_sitter.Node {
+	cursor := root.Walk()
+	defer cursor.Close()
+
+	for {
+		node := cursor.Node()
+		if node.IsNamed() || node.IsMissing() {
+			if index == 0 {
+				return node
+			}
+			index--
+		}
+		if cursor.GotoFirstChild() {
+			continue
+		}
+		for !cursor.GotoNextSibling() {
+			if !cursor.GotoParent() {
+				return nil
+			}
+		}
+	}
+}
+
+func formatPoint(point tree_sitter.Point) string {
+	return fmt.Sprintf("%d:%d", point.Row+1, point.Column+1)
+}
+
+// Diff two texts line by line, using their longest common subsequence.
+func lineDiff(a, b string) string {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+
+	// lengths[i][j] is the length of the longest common subsequence of
+	// aLines[i:] and bLines[j:].
+	lengths := make([][]int, len(aLines)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(bLines)+1)
+	}
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(aLines) || j < len(bLines) {
+		switch {
+		case i < len(aLines) && j < len(bLines) && aLines[i] == bLines[j]:
+			diff.WriteString("  " + aLines[i] + "\n")
+			i++
+			j++
+		case i < len(aLines) && (j == len(bLines) || lengths[i+1][j] >= lengths[i][j+1]):
+			diff.WriteString("- " + aLines[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+ " + bLines[j] + "\n")
+			j++
+		}
+	}
+	return diff.String()
+}
@@ -0,0 +1,132 @@
+package tstest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/go-tree-sitter/tstest"
+	tree_sitter_json "github.com/tree-sitter/tree-sitter-json/bindings/go"
+)
+
+// Records the failures reported by an assertion, instead of failing the
+// test that runs it.
+type recordingTB struct {
+	testing.TB
+	failures []string
+	cleanups []func()
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+// Run the cleanup functions, as the testing package does when a test
+// finishes.
+func (r *recordingTB) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func (r *recordingTB) Error(args ...any) {
+	r.failures = append(r.failures, fmt.Sprint(args...))
+}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func parseJSON(t *testing.T, source string) *tree_sitter.Tree {
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+	assert.NoError(t, parser.SetLanguage(tree_sitter.NewLanguage(tree_sitter_json.Language())))
+	tree := parser.Parse([]byte(source), nil)
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func TestParseSExp(t *testing.T) {
+	sexp, err := tstest.ParseSExp(`
+		(document
+		  (object (pair
+		    key: (string (string_content))
+		    value: (MISSING "}"))))
+	`)
+	assert.NoError(t, err)
+	assert.Equal(t, `(document (object (pair key: (string (string_content)) value: (MISSING "}"))))`, sexp.String())
+	assert.Equal(
+		t,
+		"(document\n  (object\n    (pair\n      key: (string\n        (string_content))\n      value: (MISSING \"}\"))))",
+		sexp.Indented(),
+	)
+
+	for _, invalid := range []string{"", "document", "(document", "()", "(a) (b)", "(a (b) c)", `(a "b)`} {
+		_, err := tstest.ParseSExp(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestAssertSExp(t *testing.T) {
+	tree := parseJSON(t, `{"a": [1, null]}`)
+	root := tree.RootNode()
+
+	assert.True(t, tstest.AssertSExp(t, root, `
+		(document
+		  (object
+		    (pair
+		      (string (string_content))
+		      (array (number) (null)))))
+	`))
+	assert.True(t, tstest.AssertSExp(t, root, `
+		(document (object (pair
+		  key: (string (string_content))
+		  value: (array (number) (null)))))
+	`, tstest.WithFields()))
+
+	// Field names are only compared when asked for.
+	recorder := &recordingTB{TB: t}
+	assert.False(t, tstest.AssertSExp(recorder, root, `
+		(document (object (pair
+		  (string (string_content))
+		  (array (number) (null)))))
+	`, tstest.WithFields()))
+	assert.Len(t, recorder.failures, 1)
+	assert.Contains(t, recorder.failures[0], "first difference at document > object > pair > key: string")
+
+	recorder = &recordingTB{TB: t}
+	assert.False(t, tstest.AssertSExp(recorder, root, `
+		(document (object (pair
+		  (string (string_content))
+		  (array (number) (number)))))
+	`))
+	assert.Equal(
+		t,
+		[]string{`syntax trees differ (- want, + got):
+  (document
+    (object
+      (pair
+        (string
+          (string_content))
+        (array
+          (number)
+-         (number)))))
++         (null)))))
+
+first difference at document > object > pair > array > null, bytes 10..14 (1:11..1:15)`},
+		recorder.failures,
+	)
+
+	// A node with missing children is reported as the mismatch.
+	recorder = &recordingTB{TB: t}
+	assert.False(t, tstest.AssertSExp(recorder, root, `(document (object (pair (string (string_content)))))`))
+	assert.Len(t, recorder.failures, 1)
+	assert.Contains(t, recorder.failures[0], "first difference at document > object > pair, bytes 1..15 (1:2..1:16)")
+
+	recorder = &recordingTB{TB: t}
+	assert.False(t, tstest.AssertSExp(recorder, root, `(document`))
+	assert.Equal(t, []string{"tstest: cannot parse the expected tree: invalid s-expression at offset 9: unterminated node"}, recorder.failures)
+}
@@ -0,0 +1,183 @@
+package tree_sitter
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Fill the struct that `v` points to from the subtree rooted at `node`,
+// following the `ts` tags of its fields. `source` is the source code that
+// the tree was parsed from.
+//
+// A tag selects some of the node's children, and the field is set from
+// them:
+//
+//   - `ts:"field=name"` selects the children with the field name `name`.
+//   - `ts:"kind=comment"` selects the named children of kind `comment`.
+//   - `ts:"field=parameters,kind=parameter_declaration"` selects the named
+//     children of kind `parameter_declaration` of the child or children in
+//     the `parameters` field.
+//
+// A string field is set to the text of the first selected node, a [Node] or
+// *Node field to the node itself, and a struct or struct pointer field is
+// filled from the node by following its own tags. A slice of any of these
+// gets an element for each selected node.
+//
+// Fields without a `ts` tag are left alone, and so are fields whose tag
+// selects nothing, unless the tag also has the `required` option, as in
+// `ts:"field=name,required"`. Then an [*UnmarshalError] naming the field is
+// returned instead.
+//
+//	type Param struct {
+//		Name string `ts:"field=name"`
+//		Type string `ts:"field=type,required"`
+//	}
+//
+//	type Func struct {
+//		Name   string  `ts:"field=name,required"`
+//		Params []Param `ts:"field=parameters,kind=parameter_declaration"`
+//		Body   Node    `ts:"field=body"`
+//	}
+func Unmarshal(node Node, source []byte, v any) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Unmarshal needs a non-nil pointer to a struct, not %T", v)
+	}
+	u := unmarshaler{source: source}
+	return u.unmarshalStruct(node, value.Elem(), value.Elem().Type().Name())
+}
+
+// An error that occurred in [Unmarshal] while filling a struct field.
+type UnmarshalError struct {
+	// The path to the field from the struct passed to [Unmarshal], such as
+	// `Func.Params[1].Type`.
+	Path string
+
+	// The node that the field was being filled from.
+	Node Node
+
+	// A description of what went wrong.
+	Message string
+}
+
+func (e *UnmarshalError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+var (
+	nodeType        = reflect.TypeFor[Node]()
+	nodePointerType = reflect.TypeFor[*Node]()
+)
+
+type unmarshaler struct {
+	source []byte
+}
+
+// A parsed `ts` struct tag.
+type unmarshalTag struct {
+	field    string
+	kind     string
+	required bool
+}
+
+func parseUnmarshalTag(tag string) (unmarshalTag, error) {
+	var result unmarshalTag
+	for _, part := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "field":
+			result.field = value
+		case "kind":
+			result.kind = value
+		case "required":
+			result.required = true
+		default:
+			return result, fmt.Errorf("invalid ts tag %q", tag)
+		}
+	}
+	if result.field == "" && result.kind == "" {
+		return result, fmt.Errorf("ts tag %q selects no children", tag)
+	}
+	return result, nil
+}
+
+// Get the nodes that the tag selects from the children of `node`.
+func (tag *unmarshalTag) selectNodes(node Node) []Node {
+	parents := []Node{node}
+	if tag.field != "" {
+		parents = slices.Collect(node.ChildrenByFieldNameSeq(tag.field))
+		if tag.kind == "" {
+			return parents
+		}
+	}
+
+	var result []Node
+	for _, parent := range parents {
+		for child := range parent.NamedChildrenSeq() {
+			if child.Kind() == tag.kind {
+				result = append(result, child)
+			}
+		}
+	}
+	return result
+}
+
+func (u *unmarshaler) unmarshalStruct(node Node, value reflect.Value, path string) error {
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		tagValue, ok := field.Tag.Lookup("ts")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		fieldPath := path + "." + field.Name
+
+		tag, err := parseUnmarshalTag(tagValue)
+		if err != nil {
+			return &UnmarshalError{Path: fieldPath, Node: node, Message: err.Error()}
+		}
+		nodes := tag.selectNodes(node)
+		if len(nodes) == 0 {
+			if tag.required {
+				return &UnmarshalError{Path: fieldPath, Node: node, Message: fmt.Sprintf("the %s has no children matching the required tag %q", node.Kind(), tagValue)}
+			}
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(field.Type, len(nodes), len(nodes))
+			for j, child := range nodes {
+				if err := u.unmarshalValue(child, slice.Index(j), fmt.Sprintf("%s[%d]", fieldPath, j)); err != nil {
+					return err
+				}
+			}
+			value.Field(i).Set(slice)
+		} else if err := u.unmarshalValue(nodes[0], value.Field(i), fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unmarshaler) unmarshalValue(node Node, value reflect.Value, path string) error {
+	switch {
+	case value.Type() == nodeType:
+		value.Set(reflect.ValueOf(node))
+	case value.Type() == nodePointerType:
+		value.Set(reflect.ValueOf(&node))
+	case value.Kind() == reflect.String:
+		value.SetString(node.Utf8Text(u.source))
+	case value.Kind() == reflect.Struct:
+		return u.unmarshalStruct(node, value, path)
+	case value.Kind() == reflect.Pointer && value.Type().Elem().Kind() == reflect.Struct:
+		pointer := reflect.New(value.Type().Elem())
+		if err := u.unmarshalStruct(node, pointer.Elem(), path); err != nil {
+			return err
+		}
+		value.Set(pointer)
+	default:
+		return &UnmarshalError{Path: path, Node: node, Message: fmt.Sprintf("cannot unmarshal a node into a %s", value.Type())}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package tree_sitter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+type Param struct {
+	Names []string `ts:"field=name"`
+	Type  string   `ts:"field=type,required"`
+}
+
+type Func struct {
+	Name    string  `ts:"field=name,required"`
+	Params  []Param `ts:"field=parameters,kind=parameter_declaration"`
+	Result  *Node   `ts:"field=result"`
+	Body    Node    `ts:"field=body"`
+	Comment string
+}
+
+func parseGoFunction(t *testing.T, source string) (*Tree, *Node) {
+	t.Helper()
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse([]byte(source), nil)
+	return tree, tree.RootNode().NamedChild(1)
+}
+
+func TestUnmarshal(t *testing.T) {
+	source := "package main\n\nfunc join(a, b string, n int) {}\n"
+	tree, function := parseGoFunction(t, source)
+	defer tree.Close()
+
+	f := Func{Comment: "kept"}
+	assert.NoError(t, Unmarshal(*function, []byte(source), &f))
+	assert.Equal(t, "join", f.Name)
+	assert.Equal(t, []Param{
+		{Names: []string{"a", "b"}, Type: "string"},
+		{Names: []string{"n"}, Type: "int"},
+	}, f.Params)
+	assert.Nil(t, f.Result)
+	assert.Equal(t, "block", f.Body.Kind())
+	assert.Equal(t, "kept", f.Comment)
+
+	source = "package main\n\nfunc zero() int { return 0 }\n"
+	tree, function = parseGoFunction(t, source)
+	defer tree.Close()
+	f = Func{}
+	assert.NoError(t, Unmarshal(*function, []byte(source), &f))
+	assert.Empty(t, f.Params)
+	if assert.NotNil(t, f.Result) {
+		assert.Equal(t, "int", f.Result.Utf8Text([]byte(source)))
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	source := "package main\n\nfunc f(int, string) {}\n"
+	tree, function := parseGoFunction(t, source)
+	defer tree.Close()
+
+	// The parameters have no names, which are required here.
+	type NamedParam struct {
+		Names []string `ts:"field=name,required"`
+	}
+	type NamedFunc struct {
+		Params []NamedParam `ts:"field=parameters,kind=parameter_declaration"`
+	}
+	var f NamedFunc
+	err := Unmarshal(*function, []byte(source), &f)
+	var unmarshalErr *UnmarshalError
+	if assert.ErrorAs(t, err, &unmarshalErr) {
+		assert.Equal(t, "NamedFunc.Params[0].Names", unmarshalErr.Path)
+		assert.Equal(t, "parameter_declaration", unmarshalErr.Node.Kind())
+		assert.Equal(t, `NamedFunc.Params[0].Names: the parameter_declaration has no children matching the required tag "field=name,required"`, err.Error())
+	}
+
+	var method struct {
+		Receiver Node `ts:"field=receiver,required"`
+	}
+	err = Unmarshal(*function, []byte(source), &method)
+	if assert.ErrorAs(t, err, &unmarshalErr) {
+		assert.Equal(t, ".Receiver", unmarshalErr.Path)
+	}
+
+	var badTag struct {
+		Name string `ts:"name"`
+	}
+	assert.EqualError(t, Unmarshal(*function, []byte(source), &badTag), `.Name: invalid ts tag "name"`)
+
+	var badType struct {
+		Name int `ts:"field=name"`
+	}
+	assert.EqualError(t, Unmarshal(*function, []byte(source), &badType), ".Name: cannot unmarshal a node into a int")
+
+	assert.Error(t, Unmarshal(*function, []byte(source), f))
+	assert.Error(t, Unmarshal(*function, []byte(source), (*Func)(nil)))
+}
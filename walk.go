@@ -0,0 +1,163 @@
+package tree_sitter
+
+import "iter"
+
+// Iterate over the subtree rooted at `node` in pre-order, yielding each
+// node along with its depth below `node`, which is yielded first with a
+// depth of zero.
+//
+// Breaking out of the loop stops the walk. Use a [Walker] to skip the
+// descendants of some of the nodes instead.
+func Walk(node Node) iter.Seq2[Node, int] {
+	return func(yield func(Node, int) bool) {
+		walker := NewWalker(node)
+		defer walker.Close()
+		for walker.Next() {
+			if !yield(walker.cursor.currentNode(), walker.Depth()) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over the named nodes in the subtree rooted at `node`, like
+// [Walk]. The depths count every level of the tree, including anonymous
+// nodes.
+func WalkNamed(node Node) iter.Seq2[Node, int] {
+	return func(yield func(Node, int) bool) {
+		for node, depth := range Walk(node) {
+			if node.IsNamed() && !yield(node, depth) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over the subtree rooted at `node` in pre-order, like [Walk], but
+// yielding each node along with its field name in its parent, or an empty
+// string for a node that is not in a field. The root, which is yielded
+// first, has no field name.
+func WalkFields(node Node) iter.Seq2[Node, string] {
+	return func(yield func(Node, string) bool) {
+		walker := NewWalker(node)
+		defer walker.Close()
+		for walker.Next() {
+			if !yield(walker.cursor.currentNode(), walker.cursor.FieldName()) {
+				return
+			}
+		}
+	}
+}
+
+// What [Visit] does after visiting a node.
+type VisitAction int
+
+const (
+	// Go on to the node's children, and then to the rest of the subtree.
+	VisitContinue VisitAction = iota
+	// Skip the node's descendants and go on to its next sibling.
+	VisitSkipChildren
+	// Stop the walk without visiting any more nodes.
+	VisitStop
+)
+
+// Call `fn` for each node in the subtree rooted at `node`, in pre-order,
+// with the node's field name in its parent and its depth below `node`. The
+// root is visited first, with no field name and a depth of zero.
+//
+// The action returned by `fn` prunes the walk. Since the nodes are visited
+// with a [TreeCursor] rather than by recursion, even very deeply nested
+// trees can be visited.
+func Visit(node Node, fn func(n Node, fieldName string, depth int) VisitAction) {
+	walker := NewWalker(node)
+	defer walker.Close()
+	for walker.Next() {
+		switch fn(walker.cursor.currentNode(), walker.cursor.FieldName(), walker.Depth()) {
+		case VisitSkipChildren:
+			walker.SkipSubtree()
+		case VisitStop:
+			return
+		}
+	}
+}
+
+// A Walker visits the nodes of a subtree in pre-order, like [Walk], with
+// the option of skipping the descendants of the current node.
+//
+//	walker := NewWalker(root)
+//	defer walker.Close()
+//	for walker.Next() {
+//		if walker.Node().Kind() == "function_declaration" {
+//			walker.SkipSubtree()
+//		}
+//	}
+type Walker struct {
+	cursor  *TreeCursor
+	started bool
+	skip    bool
+	done    bool
+}
+
+// Create a new [Walker] over the subtree rooted at `node`. The walker must
+// be closed once it is no longer needed.
+func NewWalker(node Node) *Walker {
+	if node.IsNull() {
+		return &Walker{done: true}
+	}
+	return &Walker{cursor: acquireCursor(node)}
+}
+
+// Delete the walker, letting its cursor be reused by later walks.
+func (w *Walker) Close() {
+	if w.cursor != nil {
+		releaseCursor(w.cursor)
+		w.cursor = nil
+	}
+}
+
+// Move to the next node, returning false once every node has been visited.
+// The first call moves to the root of the subtree.
+func (w *Walker) Next() bool {
+	if w.done {
+		return false
+	}
+	if !w.started {
+		w.started = true
+		return true
+	}
+
+	skip := w.skip
+	w.skip = false
+	if !skip && w.cursor.GotoFirstChild() {
+		return true
+	}
+	for !w.cursor.GotoNextSibling() {
+		if !w.cursor.GotoParent() {
+			w.done = true
+			return false
+		}
+	}
+	return true
+}
+
+// Skip the descendants of the current node, so that the next call to
+// [Walker.Next] moves to its next sibling or an ancestor's sibling.
+func (w *Walker) SkipSubtree() {
+	w.skip = true
+}
+
+// Get the current node.
+func (w *Walker) Node() *Node {
+	if w.cursor == nil {
+		return nil
+	}
+	return w.cursor.Node()
+}
+
+// Get the depth of the current node below the root of the subtree.
+func (w *Walker) Depth() int {
+	if w.cursor == nil {
+		return 0
+	}
+	return int(w.cursor.Depth())
+}
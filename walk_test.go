@@ -0,0 +1,298 @@
+package tree_sitter_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/tree-sitter/go-tree-sitter"
+)
+
+const walkExample = `package main
+
+func add(a, b int) int {
+	return a + b
+}
+
+func main() {
+	x := add(1, 2)
+	println(x)
+}
+`
+
+func TestWalkCountsIdentifiersLikeQuery(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	language := getLanguage("go")
+	parser.SetLanguage(language)
+	tree := parser.Parse([]byte(walkExample), nil)
+	defer tree.Close()
+
+	query, err := NewQuery(language, "(identifier) @identifier")
+	assert.Nil(t, err)
+	defer query.Close()
+	cursor := NewQueryCursor()
+	defer cursor.Close()
+	matches := cursor.Matches(query, tree.RootNode(), []byte(walkExample))
+	var fromQuery []Node
+	for match := matches.Next(); match != nil; match = matches.Next() {
+		fromQuery = append(fromQuery, match.Captures[0].Node)
+	}
+
+	var fromWalk []Node
+	for node := range WalkNamed(*tree.RootNode()) {
+		if node.Kind() == "identifier" {
+			fromWalk = append(fromWalk, node)
+		}
+	}
+	assert.Len(t, fromWalk, 10)
+	assert.Equal(t, fromQuery, fromWalk)
+}
+
+func TestWalk(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	tree := parser.Parse([]byte(`[1, {"a": 2}]`), nil)
+	defer tree.Close()
+
+	var visited []string
+	var depths []int
+	for node, depth := range Walk(*tree.RootNode().NamedChild(0)) {
+		visited = append(visited, node.Kind())
+		depths = append(depths, depth)
+	}
+	assert.Equal(t, []string{"array", "[", "number", ",", "object", "{", "pair", "string", "\"", "string_content", "\"", ":", "number", "}", "]"}, visited)
+	assert.Equal(t, []int{0, 1, 1, 1, 1, 2, 2, 3, 4, 4, 4, 3, 3, 2, 1}, depths)
+
+	visited = nil
+	for node, depth := range WalkNamed(*tree.RootNode()) {
+		if depth > 2 {
+			break
+		}
+		visited = append(visited, node.Kind())
+	}
+	assert.Equal(t, []string{"document", "array", "number", "object"}, visited)
+}
+
+func TestWalkerSkipSubtree(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse([]byte(walkExample), nil)
+	defer tree.Close()
+
+	walker := NewWalker(*tree.RootNode())
+	defer walker.Close()
+	var visited []string
+	for walker.Next() {
+		node := walker.Node()
+		if node.IsNamed() {
+			visited = append(visited, node.Kind())
+		}
+		if node.Kind() == "function_declaration" {
+			walker.SkipSubtree()
+		}
+	}
+	assert.Equal(t, []string{"source_file", "package_clause", "package_identifier", "function_declaration", "function_declaration"}, visited)
+	assert.False(t, walker.Next())
+
+	// Skipping a leaf has no effect.
+	leaf := tree.RootNode().NamedChild(0).NamedChild(0)
+	walker = NewWalker(*leaf)
+	defer walker.Close()
+	assert.True(t, walker.Next())
+	walker.SkipSubtree()
+	assert.False(t, walker.Next())
+}
+
+func TestVisit(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse([]byte(walkExample), nil)
+	defer tree.Close()
+
+	// The field names are those that the parents give their children.
+	var count int
+	Visit(*tree.RootNode(), func(node Node, fieldName string, depth int) VisitAction {
+		count++
+		expected := ""
+		if parent := node.Parent(); parent != nil {
+			for i, child := range parent.ChildrenSlice() {
+				if child.Equals(node) {
+					expected = parent.FieldNameForChild(uint32(i))
+				}
+			}
+		} else {
+			assert.Equal(t, 0, depth)
+		}
+		assert.Equal(t, expected, fieldName, "the field of the %s at depth %d", node.Kind(), depth)
+		return VisitContinue
+	})
+	assert.EqualValues(t, tree.RootNode().DescendantCount(), count)
+
+	// Skipping the children of a function body skips all of its statements.
+	var visited []string
+	Visit(*tree.RootNode(), func(node Node, fieldName string, depth int) VisitAction {
+		if node.Kind() == "identifier" || node.Kind() == "call_expression" {
+			visited = append(visited, node.Utf8Text([]byte(walkExample)))
+		}
+		if fieldName == "body" {
+			return VisitSkipChildren
+		}
+		return VisitContinue
+	})
+	assert.Equal(t, []string{"add", "a", "b", "main"}, visited)
+
+	// Stopping visits no more nodes.
+	visited = nil
+	Visit(*tree.RootNode(), func(node Node, fieldName string, depth int) VisitAction {
+		visited = append(visited, fieldName+":"+node.Kind())
+		if fieldName == "name" {
+			return VisitStop
+		}
+		return VisitContinue
+	})
+	assert.Equal(t, []string{":source_file", ":package_clause", ":package", ":package_identifier", ":function_declaration", ":func", "name:identifier"}, visited)
+}
+
+func TestVisitDeeplyNestedTree(t *testing.T) {
+	const depth = 20000
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("json"))
+	tree := parser.Parse([]byte(strings.Repeat("[", depth)+strings.Repeat("]", depth)), nil)
+	defer tree.Close()
+
+	var arrays, maxDepth int
+	Visit(*tree.RootNode(), func(node Node, fieldName string, depth int) VisitAction {
+		if node.Kind() == "array" {
+			arrays++
+		}
+		maxDepth = max(maxDepth, depth)
+		return VisitContinue
+	})
+	assert.Equal(t, depth, arrays)
+	assert.Equal(t, depth+1, maxDepth)
+}
+
+func TestWalksFromManyGoroutines(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse([]byte(walkExample), nil)
+	defer tree.Close()
+	expected := tree.RootNode().DescendantCount()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clone := tree.Clone()
+			defer clone.Close()
+			root := *clone.RootNode()
+			for range 100 {
+				var count uint
+				for range Walk(root) {
+					count++
+				}
+				assert.Equal(t, expected, count)
+
+				// Breaking out early still gives back the cursors.
+				for range Tokens(root) {
+					break
+				}
+				for child := range root.ChildrenSeq() {
+					for range Walk(child) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Walk over each function of a large file in turn, as a highlighter might
+// walk the subtrees in view.
+func BenchmarkWalkSubtrees(b *testing.B) {
+	parser, tree := largeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+	functions := tree.RootNode().NamedChildren(tree.Walk())[1:]
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		var count int
+		for range Walk(functions[i%len(functions)]) {
+			count++
+		}
+	}
+}
+
+func BenchmarkTokensOfSubtrees(b *testing.B) {
+	parser, tree := largeGoTree(b)
+	defer parser.Close()
+	defer tree.Close()
+	functions := tree.RootNode().NamedChildren(tree.Walk())[1:]
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		var count int
+		for range Tokens(functions[i%len(functions)]) {
+			count++
+		}
+	}
+}
+
+func TestWalkFields(t *testing.T) {
+	parser := NewParser()
+	defer parser.Close()
+	parser.SetLanguage(getLanguage("go"))
+	tree := parser.Parse([]byte(walkExample), nil)
+	defer tree.Close()
+
+	var pairs [][2]string
+	for node, field := range WalkFields(*tree.RootNode().NamedChild(1)) {
+		pairs = append(pairs, [2]string{node.Kind(), field})
+	}
+	assert.Equal(t, [][2]string{
+		{"function_declaration", ""},
+		{"func", ""},
+		{"identifier", "name"},
+		{"parameter_list", "parameters"},
+		{"(", ""},
+		{"parameter_declaration", ""},
+		{"identifier", "name"},
+		{",", ""},
+		{"identifier", "name"},
+		{"type_identifier", "type"},
+		{")", ""},
+		{"type_identifier", "result"},
+		{"block", "body"},
+		{"{", ""},
+		{"return_statement", ""},
+		{"return", ""},
+		{"expression_list", ""},
+		{"binary_expression", ""},
+		{"identifier", "left"},
+		{"+", "operator"},
+		{"identifier", "right"},
+		{"}", ""},
+	}, pairs)
+
+	// Breaking out of the loop stops the walk at the first field.
+	var first string
+	for _, field := range WalkFields(*tree.RootNode()) {
+		if field != "" {
+			first = field
+			break
+		}
+	}
+	assert.Equal(t, "name", first)
+}